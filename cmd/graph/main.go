@@ -11,7 +11,7 @@ func main() {
 	tp := &cli.TektonParams{}
 	tkn := cmd.Root(tp)
 
-	if err := tkn.Execute(); err != nil {
+	if err := cmd.Execute(tkn); err != nil {
 		os.Exit(1)
 	}
 }
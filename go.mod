@@ -3,10 +3,17 @@ module github.com/sergk/tkn-graph
 go 1.24.1
 
 require (
+	github.com/fatih/color v1.15.0
 	github.com/jonboulle/clockwork v0.4.0
 	github.com/stretchr/testify v1.9.0
 	github.com/tektoncd/cli v0.32.0
+	golang.org/x/image v0.25.0
+	golang.org/x/term v0.38.0
+	gonum.org/v1/gonum v0.16.0
+	k8s.io/api v0.31.0
 	k8s.io/client-go v0.31.0
+	knative.dev/pkg v0.0.0-20230718152110-aef227e72ead
+	sigs.k8s.io/yaml v1.4.0
 )
 
 require (
@@ -18,7 +25,6 @@ require (
 	github.com/census-instrumentation/opencensus-proto v0.4.1 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/evanphx/json-patch/v5 v5.6.0 // indirect
-	github.com/fatih/color v1.15.0 // indirect
 	github.com/fxamacker/cbor/v2 v2.7.0 // indirect
 	github.com/go-kit/log v0.2.1 // indirect
 	github.com/go-logfmt/logfmt v0.6.0 // indirect
@@ -67,10 +73,8 @@ require (
 	google.golang.org/grpc v1.79.3 // indirect
 	gopkg.in/evanphx/json-patch.v4 v4.12.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
-	k8s.io/api v0.31.0 // indirect
 	k8s.io/apiextensions-apiserver v0.26.5 // indirect
 	k8s.io/kube-openapi v0.0.0-20240228011516-70dd3763d340 // indirect
-	knative.dev/pkg v0.0.0-20230718152110-aef227e72ead // indirect
 )
 
 require (
@@ -85,12 +89,11 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/spf13/cobra v1.7.0
-	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/spf13/pflag v1.0.5
 	github.com/tektoncd/pipeline v0.52.0
 	golang.org/x/net v0.48.0 // indirect
 	golang.org/x/oauth2 v0.34.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
-	golang.org/x/term v0.38.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
 	google.golang.org/protobuf v1.36.10 // indirect
@@ -101,5 +104,4 @@ require (
 	k8s.io/utils v0.0.0-20240711033017-18e509b52bc8 // indirect
 	sigs.k8s.io/json v0.0.0-20221116044647-bc3834ca7abd // indirect
 	sigs.k8s.io/structured-merge-diff/v4 v4.4.1 // indirect
-	sigs.k8s.io/yaml v1.4.0 // indirect
 )
@@ -1,27 +1,129 @@
 package prerun
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sergk/tkn-graph/pkg/config"
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/spf13/cobra"
 )
 
-// Define the allowed output formats
-var ValidOutputFormats = []string{"dot", "puml", "mmd"}
+// ValidOutputFormats returns the names of the registered renderers, see taskgraph.RegisterRenderer
+func ValidOutputFormats() []string {
+	return taskgraph.RendererNames()
+}
 
 func ValidateGraphPreRunE(outputFormat string) error {
-	if !contains(ValidOutputFormats, outputFormat) {
-		return fmt.Errorf("Invalid output format: %s. Allowed formats are: %v", outputFormat, ValidOutputFormats)
+	if _, ok := taskgraph.LookupRenderer(outputFormat); !ok {
+		return fmt.Errorf("Invalid output format: %s. Allowed formats are: %v", outputFormat, ValidOutputFormats())
 	}
 
 	return nil
 }
 
-// Helper function to check if a string is in a slice of strings
-func contains(s []string, e string) bool {
-	for _, a := range s {
-		if a == e {
-			return true
+// AddOutputFormatFlag adds the --output-format flag of the commands rendering graphs, its help and shell
+// completion list the registered renderers
+func AddOutputFormatFlag(c *cobra.Command, outputFormat *string) {
+	c.Flags().StringVar(outputFormat, "output-format", "dot", outputFormatUsage())
+	_ = c.RegisterFlagCompletionFunc("output-format",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			var formats []string
+			for _, r := range taskgraph.Renderers() {
+				formats = append(formats, r.Name()+"\t"+r.Description())
+			}
+
+			return formats, cobra.ShellCompDirectiveNoFileComp
+		})
+}
+
+// outputFormatUsage describes the formats, the ones with the same description are grouped, e.g. json or yaml
+func outputFormatUsage() string {
+	var descriptions []string
+
+	names := map[string][]string{}
+
+	for _, r := range taskgraph.Renderers() {
+		if _, ok := names[r.Description()]; !ok {
+			descriptions = append(descriptions, r.Description())
 		}
+
+		names[r.Description()] = append(names[r.Description()], r.Name())
+	}
+
+	formats := make([]string, 0, len(descriptions))
+	for _, description := range descriptions {
+		formats = append(formats, strings.Join(names[description], " or ")+" - "+description)
+	}
+
+	return fmt.Sprintf("the output format (%s)", strings.Join(formats, ", "))
+}
+
+// AddTemplateFlags adds the --template and --template-dir flags of the commands rendering graphs
+func AddTemplateFlags(c *cobra.Command, file, dir *string) {
+	c.Flags().StringVar(
+		file, "template", "", "a text/template file used instead of the built-in output of the format, see the README for its data and functions")
+	c.Flags().StringVar(
+		dir, "template-dir", "", "a directory of <format>.tmpl templates, e.g. dot.tmpl, overriding the built-in output of those formats")
+	c.MarkFlagsMutuallyExclusive("template", "template-dir")
+}
+
+// LoadTemplates loads the user templates of the --template and --template-dir flags, nil if none is set
+func LoadTemplates(file, dir, outputFormat string) (*taskgraph.Templates, error) {
+	if r, ok := taskgraph.LookupRenderer(outputFormat); ok && file != "" && taskgraph.IsBinary(r) {
+		return nil, fmt.Errorf("--template can't be used with the %s output format", r.Name())
+	}
+
+	return taskgraph.LoadTemplates(file, dir)
+}
+
+// AddThemeFlag adds the --theme flag of the commands rendering graphs, it completes the built-in themes, the themes
+// of the configuration files and the files
+func AddThemeFlag(c *cobra.Command, theme *string) {
+	c.Flags().StringVar(
+		theme, "theme", "", fmt.Sprintf("the theme of the dot, puml and mmd output formats, one of the built-in themes (%s), "+
+			"a theme of the config files or a YAML theme file", strings.Join(taskgraph.ThemeNames(), ", ")))
+	_ = c.RegisterFlagCompletionFunc("theme",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			names := taskgraph.ThemeNames()
+			if cfg, err := config.Load(config.DefaultSources()); err == nil {
+				for name := range cfg.Themes {
+					if !slices.Contains(names, name) {
+						names = append(names, name)
+					}
+				}
+			}
+
+			sort.Strings(names)
+
+			return names, cobra.ShellCompDirectiveDefault
+		})
+}
+
+// LoadTheme returns the theme of the --theme flag, nil if it isn't set. The themes of the configuration files
+// take precedence over the built-in themes, the other values are theme files.
+func LoadTheme(theme string, themes map[string]*taskgraph.Theme) (*taskgraph.Theme, error) {
+	if theme == "" {
+		return nil, nil
+	}
+
+	if t, ok := themes[theme]; ok {
+		return t, nil
+	}
+
+	if t, ok := taskgraph.LookupTheme(theme); ok {
+		return t, nil
+	}
+
+	t, err := taskgraph.LoadThemeFile(theme)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unknown theme %s, use one of the built-in themes (%s) or a YAML theme file",
+			theme, strings.Join(taskgraph.ThemeNames(), ", "))
 	}
 
-	return false
+	return t, err
 }
@@ -1,7 +1,14 @@
 package prerun
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/spf13/cobra"
 )
 
 func TestValidateGraphPreRunE(t *testing.T) {
@@ -31,3 +38,84 @@ func TestValidateGraphPreRunE(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadTemplates(t *testing.T) {
+	templates, err := LoadTemplates("", "", "png")
+	if err != nil || templates != nil {
+		t.Errorf("LoadTemplates() = %v, %v, want no templates", templates, err)
+	}
+
+	if _, err := LoadTemplates("graph.tmpl", "", "PNG"); err == nil {
+		t.Errorf("LoadTemplates() with the png format should fail")
+	}
+
+	if _, err := LoadTemplates("missing.tmpl", "", "dot"); err == nil {
+		t.Errorf("LoadTemplates() with a missing template should fail")
+	}
+}
+
+func TestAddOutputFormatFlag(t *testing.T) {
+	var outputFormat string
+
+	c := &cobra.Command{Use: "graph"}
+	AddOutputFormatFlag(c, &outputFormat)
+
+	flag := c.Flags().Lookup("output-format")
+	if flag == nil || flag.DefValue != "dot" {
+		t.Fatalf("AddOutputFormatFlag() flag = %v, want the dot default", flag)
+	}
+
+	expected := "the output format (dot - DOT, puml - PlantUML, mmd - Mermaid, json or yaml - graph structure, " +
+		"svg or png - image, html - interactive page, ascii or unicode - text for the terminal)"
+	if flag.Usage != expected {
+		t.Errorf("AddOutputFormatFlag() usage = %q, want %q", flag.Usage, expected)
+	}
+
+	var out bytes.Buffer
+
+	c.Run = func(*cobra.Command, []string) {}
+	c.SetOut(&out)
+	c.SetArgs([]string{cobra.ShellCompRequestCmd, "--output-format", ""})
+
+	if err := c.Execute(); err != nil {
+		t.Fatalf("completion of --output-format failed: %v", err)
+	}
+
+	if !strings.HasPrefix(out.String(), "dot\tDOT\npuml\tPlantUML\n") || !strings.Contains(out.String(), "unicode\ttext for the terminal\n") {
+		t.Errorf("AddOutputFormatFlag() completion = %q", out.String())
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	theme, err := LoadTheme("", nil)
+	if err != nil || theme != nil {
+		t.Errorf("LoadTheme() = %v, %v, want no theme", theme, err)
+	}
+
+	theme, err = LoadTheme("dark", nil)
+	if err != nil || theme == nil || theme.Name != "dark" {
+		t.Errorf("LoadTheme(dark) = %v, %v, want the dark theme", theme, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "theme.yaml")
+	if err := os.WriteFile(path, []byte("base: print\ndirection: LR\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	theme, err = LoadTheme(path, nil)
+	if err != nil || theme.Direction != taskgraph.DirectionLeftRight || theme.Mermaid != "neutral" {
+		t.Errorf("LoadTheme(%s) = %v, %v, want the print theme from left to right", path, theme, err)
+	}
+
+	if _, err := LoadTheme("missing.yaml", nil); err == nil {
+		t.Errorf("LoadTheme() with a missing theme file should fail")
+	}
+
+	// the themes of the config files override the built-in ones
+	themes := map[string]*taskgraph.Theme{"dark": {Name: "dark", Direction: taskgraph.DirectionLeftRight}}
+
+	theme, err = LoadTheme("dark", themes)
+	if err != nil || theme != themes["dark"] {
+		t.Errorf("LoadTheme(dark) = %v, %v, want the dark theme of the config", theme, err)
+	}
+}
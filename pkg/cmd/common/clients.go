@@ -0,0 +1,61 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/cli/pkg/cli"
+	"github.com/tektoncd/cli/pkg/flags"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Client-side rate limits of the cluster requests, the same as the defaults of tkn and kubectl
+const (
+	DefaultQPS   float32 = 50
+	DefaultBurst         = 300
+)
+
+// initClients creates the cluster clients with the given rate limits. It has to run before flags.InitParams,
+// which creates the clients with the default rate limits otherwise.
+func initClients(p cli.Params, cmd *cobra.Command, qps float32, burst int) error {
+	opts := flags.GetTektonOptions(cmd)
+
+	config, namespace, err := restConfig(opts.KubeConfig, opts.Context, qps, burst)
+	if err != nil {
+		return err
+	}
+
+	// The namespace of the kubeconfig is only set by cli.Params when it builds the config itself
+	if p.Namespace() == "" {
+		p.SetNamespace(namespace)
+	}
+
+	_, err = p.Clients(config)
+
+	return err
+}
+
+// restConfig returns the client config and the namespace of the kubeconfig with the given rate limits
+func restConfig(kubeConfigPath, kubeContext string, qps float32, burst int) (*rest.Config, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeConfigPath
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+
+	namespace, _, err := kubeConfig.Namespace()
+	if err != nil {
+		return nil, "", fmt.Errorf("couldn't get the kubeconfig namespace: %w", err)
+	}
+
+	config, err := kubeConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing kubeconfig failed: %w", err)
+	}
+
+	config.QPS = qps
+	config.Burst = burst
+
+	return config, namespace, nil
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+current-context: dev
+contexts:
+- name: dev
+  context: {cluster: dev, user: dev, namespace: team-a}
+- name: prod
+  context: {cluster: prod, user: dev, namespace: team-b}
+clusters:
+- name: dev
+  cluster: {server: https://dev.example.com}
+- name: prod
+  cluster: {server: https://prod.example.com}
+users:
+- name: dev
+  user: {token: secret}
+`
+
+func TestRestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(testKubeConfig), 0o600))
+
+	config, namespace, err := restConfig(path, "", 20, 40)
+	require.NoError(t, err)
+	assert.Equal(t, "https://dev.example.com", config.Host)
+	assert.Equal(t, "team-a", namespace)
+	assert.Equal(t, float32(20), config.QPS)
+	assert.Equal(t, 40, config.Burst)
+
+	config, namespace, err = restConfig(path, "prod", DefaultQPS, DefaultBurst)
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod.example.com", config.Host)
+	assert.Equal(t, "team-b", namespace)
+}
+
+func TestRestConfigWithUnknownContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(testKubeConfig), 0o600))
+
+	_, _, err := restConfig(path, "missing", DefaultQPS, DefaultBurst)
+	assert.ErrorContains(t, err, "context was not found for specified context: missing")
+}
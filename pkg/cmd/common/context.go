@@ -0,0 +1,57 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sergk/tkn-graph/pkg/request"
+	"github.com/spf13/cobra"
+)
+
+// RequestTimeoutFlag is the global flag limiting the time spent waiting for the cluster
+const RequestTimeoutFlag = "request-timeout"
+
+// AddRequestTimeoutFlag adds the --request-timeout flag to the command and all its subcommands
+func AddRequestTimeoutFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().Duration(
+		RequestTimeoutFlag, 0, "the maximum time to wait for the cluster, e.g. 30s or 2m, 0 waits indefinitely")
+}
+
+// commandContext returns the context of the command, with the deadline of --request-timeout if it is set
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	timeout := requestTimeout(cmd)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// requestTimeout returns the value of --request-timeout, 0 if it isn't set
+func requestTimeout(cmd *cobra.Command) time.Duration {
+	// the flag is not defined when the command runs without the root command, e.g. in tests
+	timeout, err := cmd.Flags().GetDuration(RequestTimeoutFlag)
+	if err != nil {
+		return 0
+	}
+
+	return timeout
+}
+
+// fetchError wraps the error of a fetcher. Timed out and cancelled requests are returned as is,
+// the *request.InterruptedError already names the resource.
+func fetchError(err error, msg string) error {
+	var interrupted *request.InterruptedError
+	if errors.As(err, &interrupted) {
+		return interrupted
+	}
+
+	return fmt.Errorf("%s: %w", msg, err)
+}
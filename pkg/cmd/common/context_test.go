@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sergk/tkn-graph/pkg/request"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandContext(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	AddRequestTimeoutFlag(root)
+
+	var deadline time.Time
+
+	var hasDeadline bool
+
+	child := &cobra.Command{
+		Use: "child",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+
+			deadline, hasDeadline = ctx.Deadline()
+
+			return nil
+		},
+	}
+	root.AddCommand(child)
+
+	root.SetArgs([]string{"child", "--request-timeout", "1m"})
+	require.NoError(t, root.Execute())
+	assert.True(t, hasDeadline)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+
+	root.SetArgs([]string{"child", "--request-timeout", "0"})
+	require.NoError(t, root.Execute())
+	assert.False(t, hasDeadline)
+}
+
+func TestCommandContextWithoutFlag(t *testing.T) {
+	ctx, cancel := commandContext(&cobra.Command{})
+	defer cancel()
+
+	_, hasDeadline := ctx.Deadline()
+	assert.False(t, hasDeadline)
+}
+
+func TestFetchError(t *testing.T) {
+	interrupted := &request.InterruptedError{Action: "getting PipelineRun run1 in namespace default", Err: context.DeadlineExceeded}
+
+	err := fetchError(fmt.Errorf("failed to get PipelineRun by name: %w", interrupted), "failed to run GetByName")
+	assert.EqualError(t, err, "timed out getting PipelineRun run1 in namespace default")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = fetchError(errors.New("not found"), "failed to run GetByName")
+	assert.EqualError(t, err, "failed to run GetByName: not found")
+}
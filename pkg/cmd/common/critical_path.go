@@ -0,0 +1,144 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/cli/pkg/cli"
+	"github.com/tektoncd/cli/pkg/flags"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CreateCriticalPathCommand returns the critical-path command, it reports the chain of tasks which determines the
+// duration of the PipelineRuns
+func CreateCriticalPathCommand(p cli.Params, fetcher GraphFetcher) *cobra.Command {
+	return &cobra.Command{
+		Use:   "critical-path [name]",
+		Short: "Reports the chain of tasks which determines the duration of the PipelineRuns",
+		Annotations: map[string]string{
+			"commandType": "main",
+		},
+		SilenceUsage: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			return flags.InitParams(p, cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			setWarnings(cmd, fetcher)
+
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+
+			return RunCriticalPathCommand(ctx, p, fetcher, args, cmd.OutOrStdout())
+		},
+	}
+}
+
+// RunCriticalPathCommand prints the critical path and the slack of every task of the PipelineRun named in args,
+// or of all PipelineRuns if no name is provided
+func RunCriticalPathCommand(ctx context.Context, p cli.Params, fetcher GraphFetcher, args []string, out io.Writer) error {
+	// The PipelineRuns fetched successfully are reported even if some failed, the failures are returned at the end
+	pipelines, fetchErr := fetchPipelines(ctx, p, fetcher, true, args, p.Namespace(), metav1.ListOptions{Limit: DefaultPageSize})
+	if len(pipelines) == 0 && fetchErr != nil {
+		return fetchErr
+	}
+
+	for i := range pipelines {
+		spec := pipelines[i].TektonPipeline.Spec
+
+		graph, err := taskgraph.BuildTaskGraph(spec.Tasks, spec.Finally...)
+		if err != nil {
+			return fmt.Errorf("invalid Pipeline %s: %w", pipelines[i].Name, err)
+		}
+
+		graph.SetStatuses(pipelines[i].TaskStatuses)
+
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+
+		if err := writeCriticalPath(out, pipelines[i].Name, graph); err != nil {
+			return err
+		}
+	}
+
+	return fetchErr
+}
+
+func writeCriticalPath(out io.Writer, name string, graph *taskgraph.TaskGraph) error {
+	path := graph.CriticalPath()
+	if len(path.Tasks) == 0 {
+		fmt.Fprintf(out, "%s: no task has completed yet\n", name)
+		return nil
+	}
+
+	names := make([]string, 0, len(path.Tasks))
+	critical := make(map[string]bool, len(path.Tasks))
+
+	for _, node := range path.Tasks {
+		names = append(names, node.Name)
+		critical[node.Name] = true
+	}
+
+	fmt.Fprintf(out, "%s: critical path %s\n  %s\n\n", name, formatDuration(path.Duration), strings.Join(names, " -> "))
+
+	// tasks in execution order, the ones which haven't started last
+	nodes := make([]*taskgraph.TaskNode, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		si, sj := startTime(nodes[i]), startTime(nodes[j])
+		if si.Equal(sj) {
+			return nodes[i].Name < nodes[j].Name
+		}
+
+		return !si.IsZero() && (sj.IsZero() || si.Before(sj))
+	})
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tSTATE\tSTART\tDURATION\tSLACK")
+
+	for _, node := range nodes {
+		marker := " "
+		if critical[node.Name] {
+			marker = "*"
+		}
+
+		state, start, duration := "-", "-", "-"
+		if node.Status != nil {
+			state = string(node.Status.State)
+
+			if !node.Status.StartTime.IsZero() {
+				start = node.Status.StartTime.UTC().Format(time.TimeOnly)
+			}
+
+			if d := node.Status.Duration(); d > 0 {
+				duration = formatDuration(d)
+			}
+		}
+
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\t%s\n", marker, node.Name, state, start, duration, formatDuration(path.Slack[node.Name]))
+	}
+
+	return w.Flush()
+}
+
+func startTime(node *taskgraph.TaskNode) time.Time {
+	if node.Status == nil {
+		return time.Time{}
+	}
+
+	return node.Status.StartTime
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/sergk/tkn-graph/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateCriticalPathCommand(t *testing.T) {
+	cmd := CreateCriticalPathCommand(&test.Params{}, new(MockGraphFetcher))
+
+	assert.Equal(t, "critical-path [name]", cmd.Use)
+	assert.Equal(t, map[string]string{"commandType": "main"}, cmd.Annotations)
+	assert.True(t, cmd.SilenceUsage)
+}
+
+func TestRunCriticalPathCommand(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	start := time.Date(2024, time.January, 2, 10, 0, 0, 0, time.UTC)
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "run1", "default").Return(&Pipeline{
+		Name: "run1",
+		TektonPipeline: v1.Pipeline{
+			Spec: v1.PipelineSpec{
+				Tasks: []v1.PipelineTask{
+					{Name: "build", TaskRef: &v1.TaskRef{Name: "build"}},
+					{Name: "lint", TaskRef: &v1.TaskRef{Name: "lint"}},
+					{Name: "deploy", TaskRef: &v1.TaskRef{Name: "deploy"}, RunAfter: []string{"build", "lint"}},
+					{Name: "cleanup", TaskRef: &v1.TaskRef{Name: "cleanup"}, RunAfter: []string{"deploy"}},
+				},
+			},
+		},
+		TaskStatuses: map[string]*taskgraph.TaskStatus{
+			"build": {
+				State: taskgraph.TaskStateSucceeded, StartTime: start, CompletionTime: start.Add(2 * time.Minute),
+			},
+			"lint": {
+				State: taskgraph.TaskStateSucceeded, StartTime: start, CompletionTime: start.Add(30 * time.Second),
+			},
+			"deploy": {
+				State:          taskgraph.TaskStateSucceeded,
+				StartTime:      start.Add(2 * time.Minute),
+				CompletionTime: start.Add(3 * time.Minute),
+			},
+		},
+	}, nil)
+
+	var out bytes.Buffer
+
+	err := RunCriticalPathCommand(context.Background(), p, fetcher, []string{"run1"}, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, `run1: critical path 3m0s
+  build -> deploy
+
+TASK       STATE      START     DURATION  SLACK
+* build    Succeeded  10:00:00  2m0s      0s
+  lint     Succeeded  10:00:00  30s       1m30s
+* deploy   Succeeded  10:02:00  1m0s      0s
+  cleanup  -          -         -         0s
+`, out.String())
+
+	fetcher.AssertExpectations(t)
+}
+
+func TestRunCriticalPathCommandWithoutDurations(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetAll", mock.Anything, "default", metav1.ListOptions{Limit: DefaultPageSize}).Return([]Pipeline{
+		{
+			Name: "run1",
+			TektonPipeline: v1.Pipeline{
+				Spec: v1.PipelineSpec{Tasks: []v1.PipelineTask{{Name: "task1", TaskRef: &v1.TaskRef{Name: "task1"}}}},
+			},
+		},
+	}, nil)
+
+	var out bytes.Buffer
+
+	err := RunCriticalPathCommand(context.Background(), p, fetcher, nil, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "run1: no task has completed yet\n", out.String())
+}
@@ -0,0 +1,171 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/sergk/tkn-graph/pkg/manifest"
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/cli/pkg/cli"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValidDiffFormats are the output formats of the diff command
+var ValidDiffFormats = []string{"text", "dot", "mmd"}
+
+// DiffOptions holds the options for the diff command
+// OutputFormat: text - summary of the changes, dot or mmd - graph with additions in green and removals in red
+type DiffOptions struct {
+	OutputFormat string
+}
+
+// CreateDiffCommand returns the diff command, each side of the comparison is either a name in the cluster
+// or a file with a single Pipeline or PipelineRun
+func CreateDiffCommand(p cli.Params, fetcher GraphFetcher) *cobra.Command {
+	opts := &DiffOptions{}
+	c := &cobra.Command{
+		Use:   "diff <old> <new>",
+		Short: "Compares the tasks and dependencies of two Pipelines",
+		Example: `  # Compare a Pipeline in the cluster with its new version
+  tkn-graph pipeline diff build .tekton/build.yaml
+
+  # Render the changes as Mermaid
+  tkn-graph pipeline diff old.yaml new.yaml --output-format mmd`,
+		Annotations: map[string]string{
+			"commandType": "main",
+		},
+		SilenceUsage: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+				return err
+			}
+
+			// stdin can only be read once
+			if args[0] == "-" && args[1] == "-" {
+				return fmt.Errorf("only one side of the diff can be read from stdin")
+			}
+
+			var files []string
+
+			for _, arg := range args {
+				if isFile(arg) {
+					files = append(files, arg)
+				}
+			}
+
+			// the cluster is only required if one of the sides is a name
+			if len(files) == len(args) {
+				return initParams(p, cmd, files)
+			}
+
+			return initParams(p, cmd, nil)
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(ValidDiffFormats, opts.OutputFormat) {
+				return fmt.Errorf("Invalid output format: %s. Allowed formats are: %v", opts.OutputFormat, ValidDiffFormats)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+
+			return RunDiffCommand(ctx, p, opts, fetcher, args, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	c.Flags().StringVar(
+		&opts.OutputFormat, "output-format", "text",
+		"the output format (text - summary of the changes, dot - DOT, mmd - Mermaid)")
+
+	return c
+}
+
+// RunDiffCommand compares the two Pipelines named in args and prints the changes in the requested format
+func RunDiffCommand(ctx context.Context, p cli.Params, opts *DiffOptions, fetcher GraphFetcher, args []string, stdin io.Reader, out io.Writer) error {
+	graphs := make([]*taskgraph.TaskGraph, 0, len(args))
+
+	for _, arg := range args {
+		pipeline, err := fetchDiffPipeline(ctx, p, fetcher, arg, stdin)
+		if err != nil {
+			return err
+		}
+
+		spec := pipeline.TektonPipeline.Spec
+
+		graph, err := taskgraph.BuildTaskGraph(spec.Tasks, spec.Finally...)
+		if err != nil {
+			return fmt.Errorf("invalid Pipeline %s: %w", arg, err)
+		}
+
+		graph.PipelineName = arg
+		graphs = append(graphs, graph)
+	}
+
+	diff := taskgraph.Diff(graphs[0], graphs[1])
+
+	var output string
+
+	var err error
+
+	switch opts.OutputFormat {
+	case "dot":
+		output, err = diff.ToDOT()
+	case "mmd":
+		output, err = diff.ToMermaid()
+	default:
+		output = diff.String()
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to print diff: %w", err)
+	}
+
+	fmt.Fprint(out, output)
+
+	return nil
+}
+
+// fetchDiffPipeline returns the Pipeline from the file, or from the cluster if there is no such file
+func fetchDiffPipeline(ctx context.Context, p cli.Params, fetcher GraphFetcher, arg string, stdin io.Reader) (*Pipeline, error) {
+	if isFile(arg) {
+		pipelines, err := (&FileFetcher{Filenames: []string{arg}, Stdin: stdin}).GetAll(ctx, nil, "", metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pipelines) != 1 {
+			return nil, fmt.Errorf("%s contains %d Pipelines and PipelineRuns, exactly one is expected", arg, len(pipelines))
+		}
+
+		return &pipelines[0], nil
+	}
+
+	cs, err := p.Clients()
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline, err := fetcher.GetByName(ctx, cs, arg, p.Namespace())
+	if err != nil {
+		return nil, fetchError(err, "failed to run GetByName")
+	}
+
+	return pipeline, nil
+}
+
+// isFile returns true if the argument is stdin or an existing file, otherwise it is a name in the cluster
+func isFile(arg string) bool {
+	if arg == manifest.Stdin {
+		return true
+	}
+
+	info, err := os.Stat(arg)
+
+	return err == nil && !info.IsDir()
+}
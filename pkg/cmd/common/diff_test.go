@@ -0,0 +1,119 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sergk/tkn-graph/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tektoncd/cli/pkg/flags"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+const diffManifest = `apiVersion: tekton.dev/v1
+kind: Pipeline
+metadata:
+  name: build
+spec:
+  tasks:
+    - name: fetch
+      taskRef:
+        name: git-clone
+    - name: compile
+      runAfter: [fetch]
+      taskRef:
+        name: golang
+`
+
+func TestRunDiffCommand(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	filename := filepath.Join(t.TempDir(), "build.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(diffManifest), 0o600))
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "build", "default").Return(&Pipeline{
+		Name: "build",
+		TektonPipeline: v1.Pipeline{
+			Spec: v1.PipelineSpec{
+				Tasks: []v1.PipelineTask{
+					{Name: "clone", TaskRef: &v1.TaskRef{Name: "git-clone"}},
+					{Name: "compile", TaskRef: &v1.TaskRef{Name: "golang"}},
+				},
+			},
+		},
+	}, nil)
+
+	testCases := []struct {
+		name         string
+		outputFormat string
+		expected     string
+	}{
+		{
+			"text", "text",
+			"--- build\n+++ " + filename + "\ntasks:\n  ~ fetch: renamed from clone\ndependencies:\n  + fetch -> compile\n",
+		},
+		{"dot", "dot", "   \"fetch\" -> \"compile\" [color=\"#1e8e3e\" penwidth=2]\n"},
+		{"mermaid", "mmd", "   linkStyle 0 stroke:#1e8e3e,stroke-width:2px\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := RunDiffCommand(context.Background(), p, &DiffOptions{OutputFormat: tc.outputFormat}, fetcher, []string{"build", filename}, nil, &out)
+			assert.NoError(t, err)
+			assert.Contains(t, out.String(), tc.expected)
+		})
+	}
+
+	fetcher.AssertExpectations(t)
+}
+
+func TestDiffCommandWithFiles(t *testing.T) {
+	// no clients are configured in the params, the cluster must not be used
+	filename := filepath.Join(t.TempDir(), "build.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(diffManifest), 0o600))
+
+	cmd := CreateDiffCommand(&test.Params{}, new(MockGraphFetcher))
+	flags.AddTektonOptions(cmd)
+	cmd.SetIn(strings.NewReader(strings.ReplaceAll(diffManifest, "golang", "go-build")))
+
+	out, err := test.ExecuteCommand(cmd, "-", filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "--- -\n+++ "+filename+"\ntasks:\n  ~ compile: taskRef changed from go-build to golang\n", out)
+}
+
+func TestDiffCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "all.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(manifests), 0o600))
+
+	testCases := []struct {
+		name         string
+		args         []string
+		errorMessage string
+	}{
+		{"one argument", []string{filename}, "accepts 2 arg(s), received 1"},
+		{"stdin on both sides", []string{"-", "-"}, "only one side of the diff can be read from stdin"},
+		{"invalid output format", []string{filename, filename, "--output-format", "svg"}, "Invalid output format: svg. Allowed formats are: [text dot mmd]"},
+		{"several pipelines", []string{filename, filename}, filename + " contains 3 Pipelines and PipelineRuns, exactly one is expected"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := CreateDiffCommand(&test.Params{}, new(MockGraphFetcher))
+			flags.AddTektonOptions(cmd)
+
+			_, err := test.ExecuteCommand(cmd, tc.args...)
+			assert.EqualError(t, err, tc.errorMessage)
+		})
+	}
+}
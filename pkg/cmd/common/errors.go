@@ -0,0 +1,53 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FetchErrors is returned by GraphFetcher.GetAll along with the Pipelines which were fetched successfully
+// when some of the resources couldn't be fetched
+type FetchErrors struct {
+	Kind  string // Kind of the fetched resources, e.g. PipelineRuns
+	Total int    // Number of resources listed
+	Errs  []error
+}
+
+func (e *FetchErrors) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d of %d %s couldn't be fetched:", len(e.Errs), e.Total, e.Kind)
+
+	for _, err := range e.Errs {
+		fmt.Fprintf(&b, "\n  - %s", err)
+	}
+
+	return b.String()
+}
+
+func (e *FetchErrors) Unwrap() []error {
+	return e.Errs
+}
+
+// InvalidPipelines is returned by the graph command, after the graphs of the valid Pipelines are rendered, when some
+// of the Pipelines are invalid, e.g. have a dependency cycle
+type InvalidPipelines struct {
+	Total int // Number of Pipelines graphed
+	Errs  []error
+}
+
+func (e *InvalidPipelines) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d of %d Pipelines are invalid:", len(e.Errs), e.Total)
+
+	for _, err := range e.Errs {
+		fmt.Fprintf(&b, "\n  - %s", err)
+	}
+
+	return b.String()
+}
+
+func (e *InvalidPipelines) Unwrap() []error {
+	return e.Errs
+}
@@ -0,0 +1,230 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sergk/tkn-graph/pkg/manifest"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/cli/pkg/cli"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Kinds of the documents read by the FileFetcher
+const (
+	KindPipeline    = "Pipeline"
+	KindPipelineRun = "PipelineRun"
+)
+
+// FileFetcher implements GraphFetcher on top of local manifests, so no cluster access is required.
+// The Pipelines and PipelineRuns are returned, or only the documents of Kind if it is set. The namespace is ignored
+// but the label and field selectors are applied. The PipelineRuns whose Pipeline can't be resolved from the files are
+// returned as a *FetchErrors along with the others. The manifests are read once and reused by the following calls.
+type FileFetcher struct {
+	Filenames []string
+	Stdin     io.Reader
+	Kind      string // KindPipeline or KindPipelineRun, both kinds are returned if empty
+
+	documents []fileDocument
+}
+
+// newFileFetcher returns the fetcher of the files of the command, it only reads the documents of the kind of the
+// resources of fetcher if it reports one
+func newFileFetcher(cmd *cobra.Command, fetcher GraphFetcher, filenames []string) *FileFetcher {
+	f := &FileFetcher{Filenames: filenames, Stdin: cmd.InOrStdin()}
+	if kf, ok := fetcher.(KindFetcher); ok {
+		f.Kind = kf.Kind()
+	}
+
+	return f
+}
+
+// fileDocument is a Pipeline or PipelineRun read from the files, err is set if its Pipeline couldn't be resolved
+type fileDocument struct {
+	kind     string
+	pipeline Pipeline
+	labels   map[string]string // used by the selectors
+	err      error
+}
+
+func (f *FileFetcher) GetByName(_ context.Context, _ *cli.Clients, name, _ string) (*Pipeline, error) {
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	for i := range f.documents {
+		if f.documents[i].pipeline.Name == name {
+			if f.documents[i].err != nil {
+				return nil, f.documents[i].err
+			}
+
+			return &f.documents[i].pipeline, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no %s with name %s found in %s", f.kinds(true), name, strings.Join(f.Filenames, ", "))
+}
+
+func (f *FileFetcher) GetAll(_ context.Context, _ *cli.Clients, _ string, opts metav1.ListOptions) ([]Pipeline, error) {
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	labelSelector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector: %w", err)
+	}
+
+	fieldSelector, err := fields.ParseSelector(opts.FieldSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field selector: %w", err)
+	}
+
+	var cp []Pipeline
+
+	fetchErrs := &FetchErrors{Kind: "PipelineRuns"}
+
+	for i := range f.documents {
+		doc := &f.documents[i]
+
+		// only the metadata fields are supported, as for the Tekton resources in the cluster
+		objectFields := fields.Set{"metadata.name": doc.pipeline.Name, "metadata.namespace": doc.pipeline.Namespace}
+		if !labelSelector.Matches(labels.Set(doc.labels)) || !fieldSelector.Matches(objectFields) {
+			continue
+		}
+
+		if doc.kind == KindPipelineRun {
+			fetchErrs.Total++
+		}
+
+		if doc.err != nil {
+			fetchErrs.Errs = append(fetchErrs.Errs, doc.err)
+			continue
+		}
+
+		cp = append(cp, doc.pipeline)
+	}
+
+	if len(cp) == 0 && len(fetchErrs.Errs) == 0 {
+		if opts.LabelSelector == "" && opts.FieldSelector == "" {
+			return nil, fmt.Errorf("no %s found in %s", f.kinds(false), strings.Join(f.Filenames, ", "))
+		}
+
+		return nil, fmt.Errorf("no %s matching the selectors found in %s", f.kinds(false), strings.Join(f.Filenames, ", "))
+	}
+
+	if len(fetchErrs.Errs) > 0 {
+		return cp, fetchErrs
+	}
+
+	return cp, nil
+}
+
+// kinds returns the kinds of the documents returned, e.g. "Pipelines or PipelineRuns", singular if one is true
+func (f *FileFetcher) kinds(one bool) string {
+	kinds := []string{KindPipeline, KindPipelineRun}
+	if f.Kind != "" {
+		kinds = []string{f.Kind}
+	}
+
+	if !one {
+		for i := range kinds {
+			kinds[i] += "s"
+		}
+	}
+
+	return strings.Join(kinds, " or ")
+}
+
+// load reads the manifests once
+func (f *FileFetcher) load() error {
+	if f.documents != nil {
+		return nil
+	}
+
+	m, err := manifest.Load(f.Filenames, f.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to load manifests: %w", err)
+	}
+
+	docs := make([]fileDocument, 0, len(m.Pipelines)+len(m.PipelineRuns))
+
+	if f.Kind != KindPipelineRun {
+		for i := range m.Pipelines {
+			docs = append(docs, fileDocument{
+				kind: KindPipeline,
+				pipeline: Pipeline{
+					Name:           m.Pipelines[i].Name,
+					Namespace:      m.Pipelines[i].Namespace,
+					TektonPipeline: m.Pipelines[i],
+				},
+				labels: m.Pipelines[i].Labels,
+			})
+		}
+	}
+
+	if f.Kind != KindPipeline {
+		for i := range m.PipelineRuns {
+			doc := fileDocument{
+				kind: KindPipelineRun,
+				pipeline: Pipeline{
+					Name:      runName(&m.PipelineRuns[i]),
+					Namespace: m.PipelineRuns[i].Namespace,
+				},
+				labels: m.PipelineRuns[i].Labels,
+			}
+
+			// the other documents are still returned, the runs which can't be resolved are reported with them
+			if p, err := pipelineForRun(&m.PipelineRuns[i], m.Pipelines); err != nil {
+				doc.err = err
+			} else {
+				doc.pipeline.TektonPipeline = *p
+			}
+
+			docs = append(docs, doc)
+		}
+	}
+
+	f.documents = docs
+
+	return nil
+}
+
+// pipelineForRun returns the Pipeline executed by the PipelineRun: the spec resolved by Tekton (status),
+// the embedded pipelineSpec or the referenced Pipeline if it is part of the loaded manifests
+func pipelineForRun(pr *v1.PipelineRun, pipelines []v1.Pipeline) (*v1.Pipeline, error) {
+	switch {
+	case pr.Status.PipelineSpec != nil:
+		return &v1.Pipeline{ObjectMeta: pr.ObjectMeta, Spec: *pr.Status.PipelineSpec}, nil
+	case pr.Spec.PipelineSpec != nil:
+		return &v1.Pipeline{ObjectMeta: pr.ObjectMeta, Spec: *pr.Spec.PipelineSpec}, nil
+	case pr.Spec.PipelineRef != nil && pr.Spec.PipelineRef.Resolver != "":
+		return nil, fmt.Errorf("the pipelineRef of PipelineRun %s uses the %s resolver, which is not supported with -f",
+			runName(pr), pr.Spec.PipelineRef.Resolver)
+	case pr.Spec.PipelineRef != nil && pr.Spec.PipelineRef.Name != "":
+		for i := range pipelines {
+			if pipelines[i].Name == pr.Spec.PipelineRef.Name {
+				return &pipelines[i], nil
+			}
+		}
+
+		return nil, fmt.Errorf("Pipeline %s referenced by PipelineRun %s not found in the provided files",
+			pr.Spec.PipelineRef.Name, runName(pr))
+	default:
+		return nil, fmt.Errorf("PipelineRun %s has neither pipelineSpec nor pipelineRef", runName(pr))
+	}
+}
+
+// runName returns the PipelineRun name, PipelineRuns stored in a repository usually only have generateName
+func runName(pr *v1.PipelineRun) string {
+	if pr.Name != "" {
+		return pr.Name
+	}
+
+	return strings.TrimSuffix(pr.GenerateName, "-")
+}
@@ -0,0 +1,250 @@
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sergk/tkn-graph/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tektoncd/cli/pkg/flags"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const manifests = `apiVersion: tekton.dev/v1
+kind: Pipeline
+metadata:
+  name: pipeline1
+spec:
+  tasks:
+    - name: task1
+      taskRef:
+        name: taskRef1
+---
+apiVersion: tekton.dev/v1
+kind: PipelineRun
+metadata:
+  name: run-ref
+spec:
+  pipelineRef:
+    name: pipeline1
+---
+apiVersion: tekton.dev/v1
+kind: PipelineRun
+metadata:
+  generateName: run-inline-
+spec:
+  pipelineSpec:
+    tasks:
+      - name: inline-task
+        taskRef:
+          name: taskRef2
+`
+
+func TestFileFetcherGetAll(t *testing.T) {
+	fetcher := &FileFetcher{Filenames: []string{"-"}, Stdin: strings.NewReader(manifests)}
+
+	ps, err := fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{})
+
+	require.NoError(t, err)
+	require.Len(t, ps, 3)
+	assert.Equal(t, "pipeline1", ps[0].Name)
+	assert.Equal(t, "run-ref", ps[1].Name)
+	assert.Equal(t, "task1", ps[1].TektonPipeline.Spec.Tasks[0].Name)
+	assert.Equal(t, "run-inline", ps[2].Name)
+	assert.Equal(t, "inline-task", ps[2].TektonPipeline.Spec.Tasks[0].Name)
+}
+
+func TestFileFetcherGetAllWithSelectors(t *testing.T) {
+	fetcher := &FileFetcher{Filenames: []string{"-"}, Stdin: strings.NewReader(strings.Replace(manifests, `  name: run-ref
+`, `  name: run-ref
+  labels:
+    app: shop
+`, 1))}
+
+	ps, err := fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{LabelSelector: "app=shop"})
+	require.NoError(t, err)
+	require.Len(t, ps, 1)
+	assert.Equal(t, "run-ref", ps[0].Name)
+
+	ps, err = fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{FieldSelector: "metadata.name!=run-ref"})
+	require.NoError(t, err)
+	assert.Len(t, ps, 2)
+
+	_, err = fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{LabelSelector: "app=blog"})
+	assert.EqualError(t, err, "no Pipelines or PipelineRuns matching the selectors found in -")
+
+	_, err = fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{LabelSelector: "app in"})
+	assert.ErrorContains(t, err, "invalid label selector")
+}
+
+func TestFileFetcherGetByName(t *testing.T) {
+	fetcher := &FileFetcher{Filenames: []string{"-"}, Stdin: strings.NewReader(manifests)}
+
+	p, err := fetcher.GetByName(context.Background(), nil, "run-ref", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, "run-ref", p.Name)
+
+	_, err = fetcher.GetByName(context.Background(), nil, "unknown", "")
+	assert.ErrorContains(t, err, "no Pipeline or PipelineRun with name unknown found in -")
+}
+
+func TestFileFetcherMissingPipeline(t *testing.T) {
+	fetcher := &FileFetcher{
+		Filenames: []string{"-"},
+		Stdin: strings.NewReader(`apiVersion: tekton.dev/v1
+kind: PipelineRun
+metadata:
+  name: run
+spec:
+  pipelineRef:
+    name: missing
+`),
+	}
+
+	_, err := fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{})
+	assert.EqualError(t, err, "1 of 1 PipelineRuns couldn't be fetched:\n"+
+		"  - Pipeline missing referenced by PipelineRun run not found in the provided files")
+
+	_, err = fetcher.GetByName(context.Background(), nil, "run", "")
+	assert.EqualError(t, err, "Pipeline missing referenced by PipelineRun run not found in the provided files")
+}
+
+func TestFileFetcherSkipsUnresolvedPipelineRuns(t *testing.T) {
+	fetcher := &FileFetcher{Filenames: []string{"-"}, Stdin: strings.NewReader(manifests + `---
+apiVersion: tekton.dev/v1
+kind: PipelineRun
+metadata:
+  name: run-git
+spec:
+  pipelineRef:
+    resolver: git
+`)}
+
+	ps, err := fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{})
+
+	var fetchErrs *FetchErrors
+	require.ErrorAs(t, err, &fetchErrs)
+	assert.Equal(t, 3, fetchErrs.Total)
+	require.Len(t, fetchErrs.Errs, 1)
+	assert.EqualError(t, fetchErrs.Errs[0],
+		"the pipelineRef of PipelineRun run-git uses the git resolver, which is not supported with -f")
+	require.Len(t, ps, 3)
+	assert.Equal(t, "pipeline1", ps[0].Name)
+	assert.Equal(t, "run-ref", ps[1].Name)
+	assert.Equal(t, "run-inline", ps[2].Name)
+}
+
+func TestFileFetcherWithKind(t *testing.T) {
+	fetcher := &FileFetcher{Filenames: []string{"-"}, Stdin: strings.NewReader(manifests), Kind: KindPipeline}
+
+	ps, err := fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, ps, 1)
+	assert.Equal(t, "pipeline1", ps[0].Name)
+
+	_, err = fetcher.GetByName(context.Background(), nil, "run-ref", "")
+	assert.EqualError(t, err, "no Pipeline with name run-ref found in -")
+
+	fetcher = &FileFetcher{Filenames: []string{"-"}, Stdin: strings.NewReader(manifests), Kind: KindPipelineRun}
+
+	ps, err = fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, ps, 2)
+	assert.Equal(t, "run-ref", ps[0].Name)
+	assert.Equal(t, "task1", ps[0].TektonPipeline.Spec.Tasks[0].Name)
+	assert.Equal(t, "run-inline", ps[1].Name)
+
+	_, err = fetcher.GetAll(context.Background(), nil, "", metav1.ListOptions{LabelSelector: "app=blog"})
+	assert.EqualError(t, err, "no PipelineRuns matching the selectors found in -")
+}
+
+func TestGraphCommandWithFilename(t *testing.T) {
+	// no clients are configured in the params, the cluster must not be used
+	cmd := CreateGraphCommand(&test.Params{}, new(MockGraphFetcher))
+	flags.AddTektonOptions(cmd)
+	cmd.SetIn(strings.NewReader(manifests))
+
+	_, err := test.ExecuteCommand(cmd, "-f", "-", "--output-format", "mmd", "pipeline1")
+	assert.NoError(t, err)
+}
+
+func TestGraphCommandWithFilenameAndCriticalPath(t *testing.T) {
+	cmd := CreateGraphCommand(&test.Params{}, new(statusFetcher))
+	flags.AddTektonOptions(cmd)
+	cmd.SetIn(strings.NewReader(manifests))
+
+	_, err := test.ExecuteCommand(cmd, "-f", "-", "--highlight-critical-path", "run-ref")
+	assert.EqualError(t, err, "--highlight-critical-path is only supported for the PipelineRuns in the cluster")
+}
+
+func TestGraphCommandWithConfig(t *testing.T) {
+	dir := t.TempDir()
+	outputDir := filepath.Join(dir, "docs")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tkn-graph"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tkn-graph", "config.yaml"), []byte(`output-format: dot
+output-dir: `+outputDir+`
+namespaces:
+  team-a:
+    output-format: puml
+    theme: corp
+themes:
+  corp:
+    base: dark
+`), 0o600))
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("TKN_GRAPH_WITH_TASK_REF", "true")
+
+	// the options of the namespace and the environment apply, the flags of the command line take precedence
+	cmd := CreateGraphCommand(&test.Params{}, new(MockGraphFetcher))
+	flags.AddTektonOptions(cmd)
+	cmd.SetIn(strings.NewReader(manifests))
+
+	_, err := test.ExecuteCommand(cmd, "-f", "-", "-n", "team-a", "pipeline1")
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "pipeline1.puml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "skinparam backgroundColor #202124")
+	assert.Contains(t, string(content), "taskRef1")
+
+	cmd = CreateGraphCommand(&test.Params{}, new(MockGraphFetcher))
+	flags.AddTektonOptions(cmd)
+	cmd.SetIn(strings.NewReader(manifests))
+
+	_, err = test.ExecuteCommand(cmd, "-f", "-", "--output-format", "mmd", "pipeline1")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(outputDir, "pipeline1.mmd"))
+}
+
+func TestGraphCommandWithConfigInAllNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	outputDir := filepath.Join(dir, "docs")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tkn-graph"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tkn-graph", "config.yaml"), []byte(`output-format: mmd
+output-dir: `+outputDir+`
+namespaces:
+  team-a:
+    output-format: puml
+`), 0o600))
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	// the top-level options apply, the ones of the namespaces are reported as ignored
+	cmd := CreateGraphCommand(&test.Params{}, new(MockGraphFetcher))
+	flags.AddTektonOptions(cmd)
+	cmd.SetIn(strings.NewReader(manifests))
+
+	output, err := test.ExecuteCommand(cmd, "-f", "-", "-A")
+	require.NoError(t, err)
+	assert.Equal(t, "Warning: the options of the namespaces team-a in the config files don't apply with --all-namespaces\n",
+		output)
+
+	assert.FileExists(t, filepath.Join(outputDir, "pipeline1.mmd"))
+	assert.NoFileExists(t, filepath.Join(outputDir, "pipeline1.puml"))
+}
@@ -1,40 +1,138 @@
 package common
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/sergk/tkn-graph/pkg/cli/prerun"
+	"github.com/sergk/tkn-graph/pkg/config"
 	"github.com/sergk/tkn-graph/pkg/taskgraph"
 	"github.com/spf13/cobra"
 	"github.com/tektoncd/cli/pkg/cli"
 	"github.com/tektoncd/cli/pkg/flags"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // GraphOptions holds the options for the graph command
-// OutputFormat: dot, puml, mmd
+// OutputFormat: dot, puml, mmd, json, yaml, svg, png, html
 // OutputDir: the directory to save the output files. Otherwise, the output is printed to the screen
 // WithTaskRef: Include TaskRefName information in the output
+// Filenames: read Pipelines and PipelineRuns from local files instead of the cluster
+// HighlightCriticalPath: highlight the chain of tasks which determines the duration of the PipelineRun
+// Reduce: remove the dependencies implied by other dependencies and report them
+// Selector, FieldSelector: only graph the Pipelines matching the label and field selectors
+// AllNamespaces: graph the Pipelines of all namespaces, the output files are written to a directory per namespace
+// Concurrency: number of PipelineRuns fetched in parallel
+// QPS, Burst: client-side rate limits of the cluster requests
+// PageSize: number of Pipelines requested from the cluster at once, 0 requests all of them at once
+// Limit: maximum number of Pipelines to graph, 0 graphs all of them
+// Watch: render the graph again every time the state of one of its tasks changes, until the PipelineRun completes
+// Template, TemplateDir: user text/template for every format, or a directory of <format>.tmpl templates
+// Theme: built-in theme, theme of the config files or YAML theme file styling the dot, puml and mmd output formats
+//
+// The flags which aren't set on the command line are read from the config files and the TKN_GRAPH_ environment
+// variables, see the config package.
 type GraphOptions struct {
-	OutputFormat string
-	OutputDir    string
-	WithTaskRef  bool
+	OutputFormat          string
+	OutputDir             string
+	WithTaskRef           bool
+	Filenames             []string
+	HighlightCriticalPath bool
+	Reduce                bool
+	Selector              string
+	FieldSelector         string
+	AllNamespaces         bool
+	Concurrency           int
+	QPS                   float32
+	Burst                 int
+	PageSize              int64
+	Limit                 int
+	Watch                 bool
+	Template              string
+	TemplateDir           string
+	Theme                 string
+
+	report         io.Writer            // destination of the redundant dependencies report, stderr if nil
+	requestTimeout time.Duration        // limit of each request of a watch, 0 waits indefinitely
+	templates      *taskgraph.Templates // loaded from Template or TemplateDir, nil for the built-in output
+	theme          *taskgraph.Theme     // loaded from Theme, nil for the default styling
 }
 
 // Holds the Pipeline name and the Pipeline itself, in case of PipelineRun it holds the PipelineRun name and the Pipeline
+// and the execution status of its tasks
 type Pipeline struct {
 	Name           string
+	Namespace      string
 	TektonPipeline v1.Pipeline
+	TaskStatuses   map[string]*taskgraph.TaskStatus
 }
 
 // GraphFetcher is an interface that defines the methods to fetch the Pipeline
 type GraphFetcher interface {
-	GetByName(cs *cli.Clients, name, namespace string) (*Pipeline, error)
-	GetAll(cs *cli.Clients, namespace string) ([]Pipeline, error)
+	GetByName(ctx context.Context, cs *cli.Clients, name, namespace string) (*Pipeline, error)
+	GetAll(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]Pipeline, error)
+}
+
+// DefaultConcurrency is the default number of resources fetched in parallel
+const DefaultConcurrency = 8
+
+// DefaultPageSize is the default number of resources requested from the cluster at once, the same as kubectl
+const DefaultPageSize = 500
+
+// PagedFetcher is implemented by the fetchers which list the resources page by page, opts.Limit is the page size.
+// fn is called with the Pipelines of every page and listing stops at the first error returned by fn.
+type PagedFetcher interface {
+	GetPages(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions, fn func(page []Pipeline) error) error
+}
+
+// WatchFetcher is implemented by the fetchers which can follow a resource until it completes. fn is called with the
+// Pipeline every time the state of one of its tasks changes, until the resource completes or ctx is done. timeout
+// limits each request of the watch, e.g. the establishment of a watch, not the whole watch; 0 waits indefinitely.
+type WatchFetcher interface {
+	Watch(
+		ctx context.Context, cs *cli.Clients, name, namespace string, timeout time.Duration, fn func(pipeline *Pipeline) error,
+	) error
+}
+
+// StatusFetcher is implemented by the fetchers whose Pipelines carry the execution status of their tasks, e.g. the
+// PipelineRuns. The options based on the status, e.g. --highlight-critical-path, are only offered with them.
+type StatusFetcher interface {
+	SuppliesTaskStatuses()
+}
+
+// KindFetcher is implemented by the fetchers which report the kind of the resources they fetch, KindPipeline or
+// KindPipelineRun. The local files of -f are filtered by it, so a command only reads the documents of its own kind.
+type KindFetcher interface {
+	Kind() string
+}
+
+// ConcurrentFetcher is implemented by the fetchers which fetch the resources of GetAll in parallel
+type ConcurrentFetcher interface {
+	SetConcurrency(n int)
+}
+
+// WarningFetcher is implemented by the fetchers which report warnings about the fetched resources, e.g. a Pipeline
+// changed since it was run. The commands set the writer to their error output.
+type WarningFetcher interface {
+	SetWarnings(w io.Writer)
+}
+
+// setWarnings sends the warnings of the fetcher, if it reports any, to the error output of the command
+func setWarnings(cmd *cobra.Command, fetcher GraphFetcher) {
+	if wf, ok := fetcher.(WarningFetcher); ok {
+		wf.SetWarnings(cmd.ErrOrStderr())
+	}
 }
 
 func CreateGraphCommand(p cli.Params, fetcher GraphFetcher) *cobra.Command {
 	opts := &GraphOptions{}
+	cfg := &config.Config{}
 	// Define the root command
 	c := &cobra.Command{
 		Use:     "graph",
@@ -45,76 +143,410 @@ func CreateGraphCommand(p cli.Params, fetcher GraphFetcher) *cobra.Command {
 		},
 		SilenceUsage: true,
 		Args: func(cmd *cobra.Command, args []string) error {
-			// Add global args to the args list
-			if err := flags.InitParams(p, cmd); err != nil {
+			var err error
+
+			if cfg, err = loadConfig(cmd); err != nil {
+				return err
+			}
+
+			if len(opts.Filenames) == 0 && (cmd.Flags().Changed("qps") || cmd.Flags().Changed("burst")) {
+				if err := initClients(p, cmd, opts.QPS, opts.Burst); err != nil {
+					return err
+				}
+			}
+
+			if err := initParams(p, cmd, opts.Filenames); err != nil {
 				return err
 			}
+
+			// the options of the namespace are only known once the namespace of the kubeconfig is resolved, the graphs
+			// of all namespaces only get the options shared by all of them
+			namespace := p.Namespace()
+			if opts.AllNamespaces {
+				namespace = ""
+			}
+
+			if err := cfg.Apply(cmd, namespace); err != nil {
+				return err
+			}
+
+			if namespaces := cfg.Namespaces(); opts.AllNamespaces && len(namespaces) > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: the options of the namespaces %s in the config files "+
+					"don't apply with --all-namespaces\n", strings.Join(namespaces, ", "))
+			}
+
 			return nil
 		},
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return prerun.ValidateGraphPreRunE(opts.OutputFormat)
+			if cmd.Flags().Lookup("concurrency") != nil && opts.Concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1")
+			}
+
+			if opts.PageSize < 0 || opts.Limit < 0 {
+				return fmt.Errorf("--page-size and --limit can't be negative")
+			}
+
+			// the files have no TaskRuns, so there are no durations to compute the critical path from
+			if opts.HighlightCriticalPath && len(opts.Filenames) > 0 {
+				return fmt.Errorf("--highlight-critical-path is only supported for the PipelineRuns in the cluster")
+			}
+
+			if err := prerun.ValidateGraphPreRunE(opts.OutputFormat); err != nil {
+				return err
+			}
+
+			var err error
+
+			opts.templates, err = prerun.LoadTemplates(opts.Template, opts.TemplateDir, opts.OutputFormat)
+			if err != nil {
+				return err
+			}
+
+			opts.theme, err = prerun.LoadTheme(opts.Theme, cfg.Themes)
+
+			return err
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return RunGraphCommand(p, opts, fetcher, args)
+			opts.report = cmd.ErrOrStderr()
+			setWarnings(cmd, fetcher)
+
+			// a watch lasts as long as the PipelineRun, --request-timeout applies to each of its requests instead
+			if opts.Watch {
+				opts.requestTimeout = requestTimeout(cmd)
+				return RunWatchCommand(cmd.Context(), p, opts, fetcher, args)
+			}
+
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+
+			if len(opts.Filenames) > 0 {
+				return RunGraphCommand(ctx, p, opts, newFileFetcher(cmd, fetcher, opts.Filenames), args)
+			}
+
+			return RunGraphCommand(ctx, p, opts, fetcher, args)
 		},
 	}
 
 	// Define the command-line opts
-	c.Flags().StringVar(
-		&opts.OutputFormat, "output-format", "dot", "the output format (dot - DOT, puml - PlantUML or mmd - Mermaid)")
+	prerun.AddOutputFormatFlag(c, &opts.OutputFormat)
 	c.Flags().StringVar(
 		&opts.OutputDir, "output-dir", "", "the directory to save the output files. Otherwise, the output is printed to the screen")
 	c.Flags().BoolVar(
 		&opts.WithTaskRef, "with-task-ref", false, "Include TaskRefName information in the output")
+	c.Flags().StringSliceVarP(
+		&opts.Filenames, "filename", "f", nil,
+		"files, directories or glob patterns with Pipelines/PipelineRuns to graph instead of the cluster, use - for stdin")
+	c.Flags().BoolVar(
+		&opts.Reduce, "reduce", false,
+		"leave out the runAfter dependencies implied by longer chains of dependencies, they are reported on stderr")
+	c.Flags().StringVarP(
+		&opts.Selector, "selector", "l", "", "only graph the Pipelines matching the label selector, e.g. -l app=my-app")
+	c.Flags().StringVar(
+		&opts.FieldSelector, "field-selector", "", "only graph the Pipelines matching the field selector, e.g. --field-selector metadata.name=build")
+	c.Flags().BoolVarP(
+		&opts.AllNamespaces, "all-namespaces", "A", false, "graph the Pipelines of all namespaces, the output files are written to <output-dir>/<namespace>/")
+	c.Flags().Float32Var(
+		&opts.QPS, "qps", DefaultQPS, "the maximum number of requests per second to the cluster")
+	c.Flags().IntVar(
+		&opts.Burst, "burst", DefaultBurst, "the maximum burst of requests to the cluster")
+	c.Flags().Int64Var(
+		&opts.PageSize, "page-size", DefaultPageSize, "the number of Pipelines requested from the cluster at once, 0 requests all of them at once")
+	c.Flags().IntVar(
+		&opts.Limit, "limit", 0, "the maximum number of Pipelines to graph, 0 graphs all of them")
+	prerun.AddTemplateFlags(c, &opts.Template, &opts.TemplateDir)
+	prerun.AddThemeFlag(c, &opts.Theme)
+
+	if _, ok := fetcher.(StatusFetcher); ok {
+		c.Flags().BoolVar(
+			&opts.HighlightCriticalPath, "highlight-critical-path", false,
+			"highlight the chain of tasks which determines the duration of the PipelineRun, based on the TaskRun durations")
+	}
+
+	if _, ok := fetcher.(ConcurrentFetcher); ok {
+		c.Flags().IntVar(
+			&opts.Concurrency, "concurrency", DefaultConcurrency, "the number of PipelineRuns fetched in parallel")
+	}
+
+	if _, ok := fetcher.(WatchFetcher); ok {
+		c.Flags().BoolVarP(
+			&opts.Watch, "watch", "w", false,
+			"render the graph again every time the state of one of its tasks changes, until the PipelineRun completes")
+	}
 
 	return c
 }
 
-func RunGraphCommand(p cli.Params, opts *GraphOptions, fetcher GraphFetcher, args []string) error {
-	cs, err := p.Clients()
-	if err != nil {
-		return err
+func RunGraphCommand(ctx context.Context, p cli.Params, opts *GraphOptions, fetcher GraphFetcher, args []string) error {
+	if len(args) > 0 && (opts.AllNamespaces || opts.Selector != "" || opts.FieldSelector != "") {
+		return fmt.Errorf("a name can't be combined with --all-namespaces, --selector or --field-selector")
 	}
 
-	var pipelines []Pipeline
+	namespace := p.Namespace()
+	if opts.AllNamespaces {
+		namespace = metav1.NamespaceAll
+	}
 
-	switch len(args) {
-	case 1:
-		var pipeline *Pipeline
+	if cf, ok := fetcher.(ConcurrentFetcher); ok && opts.Concurrency > 0 {
+		cf.SetConcurrency(opts.Concurrency)
+	}
 
-		pipeline, err = fetcher.GetByName(cs, args[0], p.Namespace())
-		if err != nil {
-			return fmt.Errorf("failed to run GetByName: %w", err)
+	listOpts := metav1.ListOptions{
+		LabelSelector: opts.Selector,
+		FieldSelector: opts.FieldSelector,
+		Limit:         opts.PageSize,
+	}
+
+	// no page is larger than the number of Pipelines to graph
+	if opts.Limit > 0 && (listOpts.Limit == 0 || int64(opts.Limit) < listOpts.Limit) {
+		listOpts.Limit = int64(opts.Limit)
+	}
+
+	// Every page is graphed as soon as it is fetched, so the Pipelines of the previous pages can be released.
+	// The valid Pipelines fetched successfully are rendered even if some failed or are invalid, the failures are
+	// returned at the end.
+	graphed := 0
+	invalid := &InvalidPipelines{}
+
+	err := forEachPage(ctx, p, fetcher, len(opts.Filenames) == 0, args, namespace, listOpts, func(pipelines []Pipeline) error {
+		if opts.Limit > 0 {
+			pipelines = pipelines[:min(len(pipelines), opts.Limit-graphed)]
 		}
 
-		pipelines = append(pipelines, *pipeline)
-	case 0:
-		pipelines, err = fetcher.GetAll(cs, p.Namespace())
-		if err != nil {
-			return fmt.Errorf("failed to run GetAll: %w", err)
+		graphs, errs := buildGraphs(pipelines, opts)
+		invalid.Total += len(pipelines)
+		invalid.Errs = append(invalid.Errs, errs...)
+
+		if len(graphs) > 0 {
+			if err := renderGraphs(graphs, opts, graphed > 0); err != nil {
+				return err
+			}
 		}
-	default:
-		return fmt.Errorf("too many arguments. Provide either no arguments to get all Pipelines or a single Pipeline name")
+
+		graphed += len(graphs)
+
+		if opts.Limit > 0 && graphed >= opts.Limit {
+			return errLimitReached
+		}
+
+		return nil
+	})
+	if errors.Is(err, errLimitReached) {
+		err = nil
+	}
+
+	if len(invalid.Errs) > 0 {
+		return errors.Join(err, invalid)
 	}
 
+	return err
+}
+
+// errLimitReached stops the listing once --limit Pipelines are graphed
+var errLimitReached = errors.New("limit reached")
+
+// buildGraphs builds the graphs of the valid Pipelines with the options applied, the invalid Pipelines are skipped
+// and their errors returned
+func buildGraphs(pipelines []Pipeline, opts *GraphOptions) ([]*taskgraph.TaskGraph, []error) {
 	// Pre-allocate the graphs slice based on the number of pipelines
 	graphs := make([]*taskgraph.TaskGraph, 0, len(pipelines))
 
+	var errs []error
+
 	for i := range pipelines {
-		graph := taskgraph.BuildTaskGraph(pipelines[i].TektonPipeline.Spec.Tasks)
-		graph.PipelineName = pipelines[i].Name
+		graph, err := buildGraph(&pipelines[i], opts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
 		graphs = append(graphs, graph)
 	}
 
+	return graphs, errs
+}
+
+// buildGraph builds the graph of the Pipeline with the options applied
+func buildGraph(pipeline *Pipeline, opts *GraphOptions) (*taskgraph.TaskGraph, error) {
+	graph, err := taskgraph.BuildTaskGraph(pipeline.TektonPipeline.Spec.Tasks, pipeline.TektonPipeline.Spec.Finally...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Pipeline %s: %w", pipeline.Name, err)
+	}
+
+	graph.PipelineName = pipeline.Name
+	if opts.AllNamespaces {
+		graph.Namespace = pipeline.Namespace
+	}
+
+	graph.SetStatuses(pipeline.TaskStatuses)
+
+	if opts.Reduce {
+		writeRedundantEdges(opts.reportWriter(), graph.Title(), graph.Reduce())
+	}
+
+	if opts.HighlightCriticalPath {
+		graph.HighlightCriticalPath()
+	}
+
+	return graph, nil
+}
+
+// renderGraphs writes the graphs to the output directory or prints them, continued is true if graphs of previous
+// pages were already printed
+func renderGraphs(graphs []*taskgraph.TaskGraph, opts *GraphOptions, continued bool) error {
 	if opts.OutputDir != "" {
-		if err = taskgraph.WriteAllGraphs(graphs, opts.OutputFormat, opts.OutputDir, opts.WithTaskRef); err != nil {
+		if err := taskgraph.WriteAllGraphs(graphs, opts.OutputFormat, opts.OutputDir, opts.renderOptions(), opts.templates); err != nil {
 			return fmt.Errorf("failed to save graph: %w", err)
 		}
-	} else {
-		if err = taskgraph.PrintAllGraphs(graphs, opts.OutputFormat, opts.WithTaskRef); err != nil {
-			return fmt.Errorf("failed to print graph: %w", err)
+
+		return nil
+	}
+
+	// separate the documents of the pages, e.g. of YAML, like PrintAllGraphs does within a page
+	if r, ok := taskgraph.LookupRenderer(opts.OutputFormat); ok && continued && len(graphs) > 0 {
+		if separator := taskgraph.DocumentSeparator(r); separator != "" {
+			fmt.Println(separator)
 		}
 	}
 
+	if err := taskgraph.PrintAllGraphs(graphs, opts.OutputFormat, opts.renderOptions(), opts.templates); err != nil {
+		return fmt.Errorf("failed to print graph: %w", err)
+	}
+
 	return nil
 }
+
+func (opts *GraphOptions) renderOptions() taskgraph.RenderOptions {
+	return taskgraph.RenderOptions{WithTaskRef: opts.WithTaskRef, Theme: opts.theme}
+}
+
+func (opts *GraphOptions) reportWriter() io.Writer {
+	if opts.report == nil {
+		return os.Stderr
+	}
+
+	return opts.report
+}
+
+// writeRedundantEdges reports the dependencies removed from the graph, nothing is written if there are none
+func writeRedundantEdges(out io.Writer, name string, edges []taskgraph.RedundantEdge) {
+	if len(edges) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "%s: redundant dependencies\n", name)
+
+	for _, edge := range edges {
+		fmt.Fprintf(out, "  - %s\n", edge)
+	}
+}
+
+// loadConfig reads the config files and the environment variables and sets the flags of cmd which weren't set on
+// the command line, the options of the namespaces are applied by the caller
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	cfg, err := config.Load(config.DefaultSources())
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, cfg.Apply(cmd, "")
+}
+
+// initParams initializes the global flags, the cluster connection is skipped for local files
+func initParams(p cli.Params, cmd *cobra.Command, filenames []string) error {
+	if len(filenames) > 0 {
+		cmd.Annotations["kubernetes"] = "false"
+	}
+
+	// Add global args to the args list
+	return flags.InitParams(p, cmd)
+}
+
+// clients returns the cluster clients, or nil if the cluster is not used
+func clients(p cli.Params, useCluster bool) (*cli.Clients, error) {
+	if !useCluster {
+		return nil, nil
+	}
+
+	return p.Clients()
+}
+
+// forEachPage calls fn with the Pipeline named in args, or with every page of the Pipelines of the namespace matching
+// opts if no name is provided. The fetchers which don't list page by page return a single page. The errors of fn are
+// returned as is, if only some of the Pipelines couldn't be fetched the *FetchErrors is returned at the end.
+func forEachPage(
+	ctx context.Context, p cli.Params, fetcher GraphFetcher, useCluster bool, args []string, namespace string,
+	opts metav1.ListOptions, fn func(pipelines []Pipeline) error,
+) error {
+	pf, paged := fetcher.(PagedFetcher)
+	if len(args) > 0 || !paged {
+		pipelines, fetchErr := fetchPipelines(ctx, p, fetcher, useCluster, args, namespace, opts)
+		if len(pipelines) == 0 && fetchErr != nil {
+			return fetchErr
+		}
+
+		if err := fn(pipelines); err != nil {
+			return err
+		}
+
+		return fetchErr
+	}
+
+	cs, err := clients(p, useCluster)
+	if err != nil {
+		return err
+	}
+
+	var pageErr error
+
+	err = pf.GetPages(ctx, cs, namespace, opts, func(pipelines []Pipeline) error {
+		pageErr = fn(pipelines)
+		return pageErr
+	})
+
+	var fetchErrs *FetchErrors
+
+	switch {
+	case pageErr != nil:
+		return pageErr
+	case err == nil, errors.As(err, &fetchErrs):
+		return err
+	default:
+		return fetchError(err, "failed to run GetAll")
+	}
+}
+
+// fetchPipelines returns the Pipeline named in args or all Pipelines of the namespace matching opts if no name is provided.
+// If only some of the Pipelines couldn't be fetched, the others are returned along with the *FetchErrors.
+func fetchPipelines(
+	ctx context.Context, p cli.Params, fetcher GraphFetcher, useCluster bool, args []string, namespace string, opts metav1.ListOptions,
+) ([]Pipeline, error) {
+	cs, err := clients(p, useCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(args) {
+	case 1:
+		pipeline, err := fetcher.GetByName(ctx, cs, args[0], p.Namespace())
+		if err != nil {
+			return nil, fetchError(err, "failed to run GetByName")
+		}
+
+		return []Pipeline{*pipeline}, nil
+	case 0:
+		pipelines, err := fetcher.GetAll(ctx, cs, namespace, opts)
+
+		var fetchErrs *FetchErrors
+		if errors.As(err, &fetchErrs) {
+			return pipelines, err
+		}
+
+		if err != nil {
+			return nil, fetchError(err, "failed to run GetAll")
+		}
+
+		return pipelines, nil
+	default:
+		return nil, fmt.Errorf("too many arguments. Provide either no arguments to get all Pipelines or a single Pipeline name")
+	}
+}
@@ -2,13 +2,23 @@
 package common
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/sergk/tkn-graph/pkg/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/tektoncd/cli/pkg/cli"
+	"github.com/tektoncd/cli/pkg/flags"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // MockGraphFetcher is a mock implementation of the GraphFetcher interface
@@ -16,13 +26,13 @@ type MockGraphFetcher struct {
 	mock.Mock
 }
 
-func (m *MockGraphFetcher) GetByName(cs *cli.Clients, name, namespace string) (*Pipeline, error) {
+func (m *MockGraphFetcher) GetByName(_ context.Context, cs *cli.Clients, name, namespace string) (*Pipeline, error) {
 	args := m.Called(cs, name, namespace)
 	return args.Get(0).(*Pipeline), args.Error(1)
 }
 
-func (m *MockGraphFetcher) GetAll(cs *cli.Clients, namespace string) ([]Pipeline, error) {
-	args := m.Called(cs, namespace)
+func (m *MockGraphFetcher) GetAll(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]Pipeline, error) {
+	args := m.Called(cs, namespace, opts)
 	return args.Get(0).([]Pipeline), args.Error(1)
 }
 
@@ -40,6 +50,26 @@ func TestCreateGraphCommand(t *testing.T) {
 	assert.True(t, cmd.SilenceUsage)
 }
 
+// statusFetcher supplies the status of the tasks, like the fetcher of the PipelineRuns
+type statusFetcher struct {
+	MockGraphFetcher
+}
+
+func (f *statusFetcher) SuppliesTaskStatuses() {}
+
+func TestCreateGraphCommandOptionalFlags(t *testing.T) {
+	cmd := CreateGraphCommand(&test.Params{}, new(MockGraphFetcher))
+	assert.Nil(t, cmd.Flags().Lookup("highlight-critical-path"))
+
+	assert.Nil(t, cmd.Flags().Lookup("concurrency"))
+
+	cmd = CreateGraphCommand(&test.Params{}, new(statusFetcher))
+	assert.NotNil(t, cmd.Flags().Lookup("highlight-critical-path"))
+
+	cmd = CreateGraphCommand(&test.Params{}, new(concurrentFetcher))
+	assert.NotNil(t, cmd.Flags().Lookup("concurrency"))
+}
+
 // TestRunGraphCommand tests the RunGraphCommand function
 func TestRunGraphCommand(t *testing.T) {
 	p := &test.Params{}
@@ -79,7 +109,7 @@ func TestRunGraphCommand(t *testing.T) {
 			}
 			args := []string{"pipeline1"}
 
-			err := RunGraphCommand(p, opts, fetcher, args)
+			err := RunGraphCommand(context.Background(), p, opts, fetcher, args)
 
 			if tc.expectError {
 				assert.Error(t, err)
@@ -98,7 +128,7 @@ func TestRunGraphCommandWithGetAll(t *testing.T) {
 	p.SetNamespace("default")
 
 	fetcher := new(MockGraphFetcher)
-	fetcher.On("GetAll", mock.Anything, "default").Return([]Pipeline{
+	fetcher.On("GetAll", mock.Anything, "default", metav1.ListOptions{}).Return([]Pipeline{
 		{
 			Name: "pipeline1",
 			TektonPipeline: v1.Pipeline{
@@ -149,7 +179,7 @@ func TestRunGraphCommandWithGetAll(t *testing.T) {
 			}
 			args := []string{} // Empty args to trigger GetAll
 
-			err := RunGraphCommand(p, opts, fetcher, args)
+			err := RunGraphCommand(context.Background(), p, opts, fetcher, args)
 
 			if tc.expectError {
 				assert.Error(t, err)
@@ -173,8 +203,333 @@ func TestRunGraphCommandWithTooManyArgs(t *testing.T) {
 	}
 	args := []string{"pipeline1", "pipeline2"} // Two arguments to trigger an error
 
-	err := RunGraphCommand(p, opts, fetcher, args)
+	err := RunGraphCommand(context.Background(), p, opts, fetcher, args)
 
 	assert.Error(t, err)
 	assert.Equal(t, "too many arguments. Provide either no arguments to get all Pipelines or a single Pipeline name", err.Error())
 }
+
+func TestRunGraphCommandWithInvalidPipeline(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "pipeline1", "default").Return(&Pipeline{
+		Name: "pipeline1",
+		TektonPipeline: v1.Pipeline{
+			Spec: v1.PipelineSpec{
+				Tasks: []v1.PipelineTask{
+					{
+						Name:     "task1",
+						RunAfter: []string{"typo"},
+					},
+				},
+			},
+		},
+	}, nil)
+
+	err := RunGraphCommand(context.Background(), p, &GraphOptions{OutputFormat: "dot"}, fetcher, []string{"pipeline1"})
+
+	assert.EqualError(t, err, "1 of 1 Pipelines are invalid:\n"+
+		`  - invalid Pipeline pipeline1: unknown dependency: task "task1" depends on unknown task "typo"`)
+}
+
+func TestRunGraphCommandSkipsInvalidPipelines(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetAll", mock.Anything, "default", metav1.ListOptions{}).Return([]Pipeline{
+		{
+			Name: "cycle",
+			TektonPipeline: v1.Pipeline{Spec: v1.PipelineSpec{Tasks: []v1.PipelineTask{
+				{Name: "task1", RunAfter: []string{"task2"}},
+				{Name: "task2", RunAfter: []string{"task1"}},
+			}}},
+		},
+		{
+			Name:           "valid",
+			TektonPipeline: v1.Pipeline{Spec: v1.PipelineSpec{Tasks: []v1.PipelineTask{{Name: "task1"}}}},
+		},
+	}, nil)
+
+	opts := &GraphOptions{OutputFormat: "dot", OutputDir: t.TempDir()}
+
+	// the valid Pipeline is rendered, the invalid one is reported at the end
+	err := RunGraphCommand(context.Background(), p, opts, fetcher, nil)
+
+	var invalid *InvalidPipelines
+	require.ErrorAs(t, err, &invalid)
+	assert.EqualError(t, err, "1 of 2 Pipelines are invalid:\n  - invalid Pipeline cycle: dependency cycle: task1 -> task2 -> task1")
+
+	assert.FileExists(t, filepath.Join(opts.OutputDir, "valid.dot"))
+	assert.NoFileExists(t, filepath.Join(opts.OutputDir, "cycle.dot"))
+}
+
+func TestRunGraphCommandWithReduce(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "pipeline1", "default").Return(&Pipeline{
+		Name: "pipeline1",
+		TektonPipeline: v1.Pipeline{
+			Spec: v1.PipelineSpec{
+				Tasks: []v1.PipelineTask{
+					{Name: "build"},
+					{Name: "test", RunAfter: []string{"build"}},
+					{Name: "deploy", RunAfter: []string{"test", "build"}},
+				},
+			},
+		},
+	}, nil)
+
+	var report bytes.Buffer
+
+	opts := &GraphOptions{
+		OutputFormat: "mmd",
+		OutputDir:    t.TempDir(),
+		Reduce:       true,
+		report:       &report,
+	}
+
+	err := RunGraphCommand(context.Background(), p, opts, fetcher, []string{"pipeline1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "pipeline1: redundant dependencies\n  - deploy: runAfter build is implied by build -> test -> deploy\n", report.String())
+
+	mermaid, err := os.ReadFile(filepath.Join(opts.OutputDir, "pipeline1.mmd"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(mermaid), "build --> deploy")
+	assert.Contains(t, string(mermaid), "test --> deploy")
+}
+
+func TestRunGraphCommandWithAllNamespaces(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	pipeline := func(namespace string) Pipeline {
+		return Pipeline{
+			Name:      "build",
+			Namespace: namespace,
+			TektonPipeline: v1.Pipeline{
+				Spec: v1.PipelineSpec{Tasks: []v1.PipelineTask{{Name: "task1"}}},
+			},
+		}
+	}
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetAll", mock.Anything, metav1.NamespaceAll, metav1.ListOptions{LabelSelector: "app=shop"}).
+		Return([]Pipeline{pipeline("team-a"), pipeline("team-b")}, nil)
+
+	opts := &GraphOptions{
+		OutputFormat:  "dot",
+		OutputDir:     t.TempDir(),
+		Selector:      "app=shop",
+		AllNamespaces: true,
+	}
+
+	err := RunGraphCommand(context.Background(), p, opts, fetcher, nil)
+	assert.NoError(t, err)
+
+	for _, namespace := range []string{"team-a", "team-b"} {
+		dot, err := os.ReadFile(filepath.Join(opts.OutputDir, namespace, "build.dot"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(dot), "label=\""+namespace+"/build\"")
+	}
+
+	fetcher.AssertExpectations(t)
+}
+
+func TestRunGraphCommandWithNameAndSelector(t *testing.T) {
+	opts := &GraphOptions{
+		OutputFormat: "dot",
+		Selector:     "app=shop",
+	}
+
+	err := RunGraphCommand(context.Background(), &test.Params{}, opts, new(MockGraphFetcher), []string{"build"})
+	assert.EqualError(t, err, "a name can't be combined with --all-namespaces, --selector or --field-selector")
+}
+
+func TestRunGraphCommandWithPartialFailure(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetchErr := &FetchErrors{Kind: "PipelineRuns", Total: 2, Errs: []error{errors.New("run2: not found")}}
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetAll", mock.Anything, "default", metav1.ListOptions{}).Return([]Pipeline{{
+		Name:           "run1",
+		TektonPipeline: v1.Pipeline{Spec: v1.PipelineSpec{Tasks: []v1.PipelineTask{{Name: "task1"}}}},
+	}}, fetchErr)
+
+	opts := &GraphOptions{OutputFormat: "dot", OutputDir: t.TempDir()}
+
+	err := RunGraphCommand(context.Background(), p, opts, fetcher, nil)
+	assert.EqualError(t, err, "1 of 2 PipelineRuns couldn't be fetched:\n  - run2: not found")
+
+	// the PipelineRuns fetched successfully are still rendered
+	assert.FileExists(t, filepath.Join(opts.OutputDir, "run1.dot"))
+
+	fetcher.AssertExpectations(t)
+}
+
+func TestRunGraphCommandWithAllFailed(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetchErr := &FetchErrors{Kind: "PipelineRuns", Total: 1, Errs: []error{errors.New("run1: not found")}}
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetAll", mock.Anything, "default", metav1.ListOptions{}).Return([]Pipeline{}, fetchErr)
+
+	err := RunGraphCommand(context.Background(), p, &GraphOptions{OutputFormat: "dot"}, fetcher, nil)
+	assert.ErrorIs(t, err, fetchErr.Errs[0])
+}
+
+// concurrentFetcher records the concurrency set by the graph command
+type concurrentFetcher struct {
+	MockGraphFetcher
+	concurrency int
+}
+
+func (f *concurrentFetcher) SetConcurrency(n int) {
+	f.concurrency = n
+}
+
+func TestGraphCommandInvalidConcurrency(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cmd := CreateGraphCommand(&test.Params{}, new(concurrentFetcher))
+	flags.AddTektonOptions(cmd)
+
+	_, err := test.ExecuteCommand(cmd, "-n", "default", "--concurrency", "0", "pipeline1")
+	assert.EqualError(t, err, "--concurrency must be at least 1")
+}
+
+func TestRunGraphCommandSetsConcurrency(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := new(concurrentFetcher)
+	fetcher.On("GetAll", mock.Anything, "default", metav1.ListOptions{}).Return([]Pipeline{}, nil)
+
+	err := RunGraphCommand(context.Background(), p, &GraphOptions{OutputFormat: "dot", OutputDir: t.TempDir(), Concurrency: 3}, fetcher, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fetcher.concurrency)
+}
+
+// warningFetcher warns about every Pipeline it fetches
+type warningFetcher struct {
+	MockGraphFetcher
+	warnings io.Writer
+}
+
+func (f *warningFetcher) SetWarnings(w io.Writer) {
+	f.warnings = w
+}
+
+func (f *warningFetcher) GetByName(ctx context.Context, cs *cli.Clients, name, namespace string) (*Pipeline, error) {
+	fmt.Fprintf(f.warnings, "Warning: Pipeline %s changed\n", name)
+	return f.MockGraphFetcher.GetByName(ctx, cs, name, namespace)
+}
+
+func TestGraphCommandSetsWarnings(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := new(warningFetcher)
+	fetcher.On("GetByName", mock.Anything, "pipeline1", "default").Return(&Pipeline{Name: "pipeline1"}, nil)
+
+	cmd := CreateGraphCommand(p, fetcher)
+	flags.AddTektonOptions(cmd)
+
+	// the warnings are written to the output of the command instead of os.Stderr
+	output, err := test.ExecuteCommand(cmd, "-n", "default", "--output-dir", t.TempDir(), "pipeline1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Warning: Pipeline pipeline1 changed\n", output)
+}
+
+// pagedFetcher serves the pages in order and records the list options
+type pagedFetcher struct {
+	MockGraphFetcher
+	pages     [][]Pipeline
+	err       error
+	opts      metav1.ListOptions
+	requested int
+}
+
+func (f *pagedFetcher) GetPages(_ context.Context, _ *cli.Clients, _ string, opts metav1.ListOptions, fn func(page []Pipeline) error) error {
+	f.opts = opts
+
+	for _, page := range f.pages {
+		f.requested++
+
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+
+	return f.err
+}
+
+func pipelinePage(names ...string) []Pipeline {
+	page := make([]Pipeline, 0, len(names))
+	for _, name := range names {
+		page = append(page, Pipeline{
+			Name:           name,
+			TektonPipeline: v1.Pipeline{Spec: v1.PipelineSpec{Tasks: []v1.PipelineTask{{Name: "task1"}}}},
+		})
+	}
+
+	return page
+}
+
+func TestRunGraphCommandWithPages(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := &pagedFetcher{
+		pages: [][]Pipeline{pipelinePage("run1", "run2"), pipelinePage("run3")},
+		err:   &FetchErrors{Kind: "PipelineRuns", Total: 4, Errs: []error{errors.New("run4: not found")}},
+	}
+
+	opts := &GraphOptions{OutputFormat: "dot", OutputDir: t.TempDir(), PageSize: 2, Selector: "app=shop"}
+
+	err := RunGraphCommand(context.Background(), p, opts, fetcher, nil)
+	assert.EqualError(t, err, "1 of 4 PipelineRuns couldn't be fetched:\n  - run4: not found")
+	assert.Equal(t, metav1.ListOptions{LabelSelector: "app=shop", Limit: 2}, fetcher.opts)
+
+	for _, name := range []string{"run1", "run2", "run3"} {
+		assert.FileExists(t, filepath.Join(opts.OutputDir, name+".dot"))
+	}
+}
+
+func TestRunGraphCommandWithLimit(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := &pagedFetcher{
+		pages: [][]Pipeline{pipelinePage("run1", "run2"), pipelinePage("run3", "run4"), pipelinePage("run5")},
+	}
+
+	opts := &GraphOptions{OutputFormat: "dot", OutputDir: t.TempDir(), PageSize: 2, Limit: 3}
+
+	err := RunGraphCommand(context.Background(), p, opts, fetcher, nil)
+	assert.NoError(t, err)
+	// the listing stops once the limit is reached
+	assert.Equal(t, 2, fetcher.requested)
+
+	files, err := os.ReadDir(opts.OutputDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+	assert.NoFileExists(t, filepath.Join(opts.OutputDir, "run4.dot"))
+
+	// the pages are not larger than the limit
+	fetcher = &pagedFetcher{pages: [][]Pipeline{pipelinePage("run1")}}
+	opts = &GraphOptions{OutputFormat: "dot", OutputDir: t.TempDir(), PageSize: DefaultPageSize, Limit: 1}
+
+	assert.NoError(t, RunGraphCommand(context.Background(), p, opts, fetcher, nil))
+	assert.Equal(t, int64(1), fetcher.opts.Limit)
+}
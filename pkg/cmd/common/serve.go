@@ -0,0 +1,601 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/sergk/tkn-graph/pkg/cli/prerun"
+	"github.com/sergk/tkn-graph/pkg/config"
+	"github.com/sergk/tkn-graph/pkg/request"
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/cli/pkg/cli"
+	"github.com/tektoncd/cli/pkg/flags"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ServeOptions holds the options for the serve command
+// Addr: the address the server listens on, e.g. :8080
+// OutputFormat: the format of the paths without the extension of a format
+// WithTaskRef, Theme: the default rendering options, the with-task-ref and theme query parameters override them
+// CacheTTL: how long a rendered graph is served without fetching its resource again, 0 disables the cache
+type ServeOptions struct {
+	Addr         string
+	OutputFormat string
+	WithTaskRef  bool
+	Theme        string
+	CacheTTL     time.Duration
+
+	theme  *taskgraph.Theme
+	themes map[string]*taskgraph.Theme // defined in the config files, selectable with the theme query parameter
+}
+
+// DefaultCacheTTL is the default time the rendered graphs are cached by the server
+const DefaultCacheTTL = 30 * time.Second
+
+// DefaultServeTimeout limits the time spent fetching a graph when --request-timeout is not set, the server
+// must not wait indefinitely for the cluster
+const DefaultServeTimeout = time.Minute
+
+// CreateServeCommand returns the serve command, it serves the graphs of the Pipelines and PipelineRuns over HTTP.
+// The fetchers are created for every request, so their memoized lookups never serve stale resources.
+func CreateServeCommand(p cli.Params, pipelines, pipelineRuns func() GraphFetcher) *cobra.Command {
+	opts := &ServeOptions{}
+	cfg := &config.Config{}
+	c := &cobra.Command{
+		Use:   "serve",
+		Short: "Serves the graphs of the Pipelines and PipelineRuns over HTTP",
+		Example: `  # Serve the graphs on port 8080, e.g. http://localhost:8080/namespaces/ci/pipelines/build.svg
+  tkn-graph serve --addr :8080`,
+		Annotations: map[string]string{
+			"commandType": "main",
+		},
+		SilenceUsage: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.NoArgs(cmd, args); err != nil {
+				return err
+			}
+
+			var err error
+
+			if cfg, err = loadConfig(cmd); err != nil {
+				return err
+			}
+
+			return initParams(p, cmd, nil)
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if opts.CacheTTL < 0 {
+				return fmt.Errorf("--cache-ttl can't be negative")
+			}
+
+			if err := prerun.ValidateGraphPreRunE(opts.OutputFormat); err != nil {
+				return err
+			}
+
+			var err error
+
+			opts.themes = cfg.Themes
+			opts.theme, err = prerun.LoadTheme(opts.Theme, cfg.Themes)
+
+			return err
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cs, err := p.Clients()
+			if err != nil {
+				return err
+			}
+
+			timeout, err := cmd.Flags().GetDuration(RequestTimeoutFlag)
+			if err != nil || timeout <= 0 {
+				timeout = DefaultServeTimeout
+			}
+
+			server := NewServer(p, cs, pipelines, pipelineRuns, opts)
+			server.Timeout = timeout
+			server.Log = cmd.ErrOrStderr()
+
+			listener, err := net.Listen("tcp", opts.Addr)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Serving the graphs on http://%s\n", listener.Addr())
+
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			return server.Serve(ctx, listener)
+		},
+	}
+
+	flags.AddTektonOptions(c)
+	c.Flags().StringVar(&opts.Addr, "addr", ":8080", "the address the server listens on")
+	prerun.AddOutputFormatFlag(c, &opts.OutputFormat)
+	c.Flags().BoolVar(
+		&opts.WithTaskRef, "with-task-ref", false, "Include TaskRefName information in the output")
+	prerun.AddThemeFlag(c, &opts.Theme)
+	c.Flags().DurationVar(
+		&opts.CacheTTL, "cache-ttl", DefaultCacheTTL, "how long a rendered graph is served without fetching it again, 0 disables the cache")
+
+	return c
+}
+
+// Server serves the graphs of the Pipelines and PipelineRuns:
+//
+//	/namespaces/{namespace}/pipelines/{name}[.{format}]
+//	/namespaces/{namespace}/pipelineruns/{name}[.{format}]
+//	/pipelines/{name}[.{format}] and /pipelineruns/{name}[.{format}] in the default namespace
+//
+// The format is the name or the file extension of an output format, the default output format is used without it.
+// The with-task-ref, reduce, highlight-critical-path and theme query parameters set the rendering options, the
+// theme is a built-in theme or a theme of the config files. The responses are cached and carry an ETag.
+// The defaults are the options of the command, the namespaces sections of the config files don't apply to the
+// graphs served.
+type Server struct {
+	Timeout time.Duration // limit of the time spent fetching a graph, no limit if 0
+	Log     io.Writer     // receives the errors of the failed requests, they are discarded if nil
+
+	clients   *cli.Clients
+	namespace string
+	fetchers  map[string]func() GraphFetcher
+	opts      *ServeOptions
+	clock     clockwork.Clock
+	cache     *responseCache
+	mux       *http.ServeMux
+}
+
+// NewServer returns the server of the graphs of the resources fetched with the clients, the paths without
+// a namespace use the namespace of p
+func NewServer(p cli.Params, cs *cli.Clients, pipelines, pipelineRuns func() GraphFetcher, opts *ServeOptions) *Server {
+	s := &Server{
+		clients:   cs,
+		namespace: p.Namespace(),
+		fetchers: map[string]func() GraphFetcher{
+			"pipelines":    pipelines,
+			"pipelineruns": pipelineRuns,
+		},
+		opts:  opts,
+		clock: p.Time(),
+		mux:   http.NewServeMux(),
+	}
+	s.cache = newResponseCache(opts.CacheTTL, s.clock)
+
+	for resource := range s.fetchers {
+		s.mux.HandleFunc("GET /namespaces/{namespace}/"+resource+"/{name}", s.handleGraph(resource))
+		s.mux.HandleFunc("GET /"+resource+"/{name}", s.handleGraph(resource))
+	}
+
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve serves the requests of the listener until ctx is done, the requests in progress are then given a few
+// seconds to complete
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// graphRequest is a graph requested from the server, it is the key of the cache
+type graphRequest struct {
+	resource    string
+	namespace   string
+	name        string
+	format      string
+	withTaskRef bool
+	reduce      bool
+	critical    bool
+	theme       string
+}
+
+// graphResponse is a rendered graph
+type graphResponse struct {
+	body        []byte
+	contentType string
+	etag        string
+}
+
+// requestError is an error of the request, it is returned to the client with its status code
+type requestError struct {
+	status int
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(format string, a ...any) error {
+	return &requestError{status: http.StatusBadRequest, err: fmt.Errorf(format, a...)}
+}
+
+func (s *Server) handleGraph(resource string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := s.parseRequest(resource, r)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+
+		resp, err := s.cache.Get(req, func() (*graphResponse, error) {
+			return s.render(req)
+		})
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+
+		w.Header().Set("ETag", resp.etag)
+		w.Header().Set("Cache-Control", s.cacheControl())
+
+		if etagMatches(r.Header.Get("If-None-Match"), resp.etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", resp.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(resp.body)))
+		_, _ = w.Write(resp.body)
+	}
+}
+
+// parseRequest reads the graph requested by the path and the query parameters
+func (s *Server) parseRequest(resource string, r *http.Request) (graphRequest, error) {
+	req := graphRequest{
+		resource:    resource,
+		namespace:   r.PathValue("namespace"),
+		format:      s.opts.OutputFormat,
+		withTaskRef: s.opts.WithTaskRef,
+		theme:       s.opts.Theme,
+	}
+
+	if req.namespace == "" {
+		req.namespace = s.namespace
+	}
+
+	req.name, req.format = splitFormat(r.PathValue("name"), req.format)
+
+	query := r.URL.Query()
+
+	for param, value := range map[string]*bool{
+		"with-task-ref":           &req.withTaskRef,
+		"reduce":                  &req.reduce,
+		"highlight-critical-path": &req.critical,
+	} {
+		if !query.Has(param) {
+			continue
+		}
+
+		// a parameter without a value is true, e.g. ?reduce
+		if query.Get(param) == "" {
+			*value = true
+			continue
+		}
+
+		b, err := strconv.ParseBool(query.Get(param))
+		if err != nil {
+			return req, badRequest("invalid %s %q, it must be true or false", param, query.Get(param))
+		}
+
+		*value = b
+	}
+
+	if _, ok := s.fetchers[resource]().(StatusFetcher); req.critical && !ok {
+		return req, badRequest("highlight-critical-path is only supported for the PipelineRuns")
+	}
+
+	if query.Has("theme") {
+		req.theme = query.Get("theme")
+		if _, err := s.theme(req.theme); err != nil {
+			return req, err
+		}
+	}
+
+	return req, nil
+}
+
+// splitFormat returns the name without the extension of the format and the format, the name is kept as is if it
+// doesn't end with the name or the file extension of an output format
+func splitFormat(name, defaultFormat string) (string, string) {
+	i := strings.LastIndex(name, ".")
+	if i <= 0 {
+		return name, defaultFormat
+	}
+
+	ext := strings.ToLower(name[i+1:])
+	if r, ok := taskgraph.LookupRenderer(ext); ok {
+		return name[:i], r.Name()
+	}
+
+	for _, r := range taskgraph.Renderers() {
+		if r.Extension() == ext {
+			return name[:i], r.Name()
+		}
+	}
+
+	return name, defaultFormat
+}
+
+// theme returns the theme of the request, the query parameter only selects the built-in themes and the themes of
+// the config files, the theme files are only read from the command line
+func (s *Server) theme(name string) (*taskgraph.Theme, error) {
+	switch {
+	case name == "":
+		return nil, nil
+	case name == s.opts.Theme:
+		return s.opts.theme, nil
+	}
+
+	if t, ok := s.opts.themes[name]; ok {
+		return t, nil
+	}
+
+	if t, ok := taskgraph.LookupTheme(name); ok {
+		return t, nil
+	}
+
+	return nil, badRequest("unknown theme %s", name)
+}
+
+// render fetches the resource of the request and renders its graph
+func (s *Server) render(req graphRequest) (*graphResponse, error) {
+	// the response may be shared by concurrent requests, it isn't cancelled when its first client goes away
+	ctx, cancel := s.fetchContext()
+	defer cancel()
+
+	theme, err := s.theme(req.theme)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline, err := s.fetchers[req.resource]().GetByName(ctx, s.clients, req.name, req.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &GraphOptions{
+		WithTaskRef:           req.withTaskRef,
+		Reduce:                req.reduce,
+		HighlightCriticalPath: req.critical,
+		report:                io.Discard,
+		theme:                 theme,
+	}
+
+	graph, err := buildGraph(pipeline, opts)
+	if err != nil {
+		return nil, &requestError{status: http.StatusUnprocessableEntity, err: err}
+	}
+
+	r, ok := taskgraph.LookupRenderer(req.format)
+	if !ok {
+		return nil, badRequest("invalid output format %s", req.format)
+	}
+
+	var body bytes.Buffer
+	if err := r.Render(&body, graph, opts.renderOptions()); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+
+	return &graphResponse{
+		body:        body.Bytes(),
+		contentType: contentType(r),
+		etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}, nil
+}
+
+func (s *Server) fetchContext() (context.Context, context.CancelFunc) {
+	if s.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), s.Timeout)
+}
+
+// contentTypes are the media types of the output formats, the other formats are plain text or binary
+var contentTypes = map[string]string{
+	"dot":  "text/vnd.graphviz; charset=utf-8",
+	"json": "application/json",
+	"yaml": "application/yaml",
+	"svg":  "image/svg+xml",
+	"png":  "image/png",
+	"html": "text/html; charset=utf-8",
+}
+
+func contentType(r taskgraph.Renderer) string {
+	if t, ok := contentTypes[r.Name()]; ok {
+		return t
+	}
+
+	if taskgraph.IsBinary(r) {
+		return "application/octet-stream"
+	}
+
+	return "text/plain; charset=utf-8"
+}
+
+// cacheControl lets the clients and the proxies keep the graphs as long as the server does
+func (s *Server) cacheControl() string {
+	if s.opts.CacheTTL <= 0 {
+		return "no-cache"
+	}
+
+	return fmt.Sprintf("public, max-age=%d", int(s.opts.CacheTTL.Seconds()))
+}
+
+// etagMatches returns true if the If-None-Match header lists the ETag, the weak ETags match the strong ones
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeError returns the error to the client, the errors of the server are logged
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+
+	var reqErr *requestError
+
+	var interrupted *request.InterruptedError
+
+	switch {
+	case errors.As(err, &reqErr):
+		status = reqErr.status
+	case apierrors.IsNotFound(err):
+		status = http.StatusNotFound
+	case apierrors.IsForbidden(err):
+		status = http.StatusForbidden
+	case errors.As(err, &interrupted), errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+
+	if status >= http.StatusInternalServerError && s.Log != nil {
+		fmt.Fprintf(s.Log, "%s %s: %s\n", r.Method, r.URL.Path, err)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, err.Error(), status)
+}
+
+// responseCache keeps the rendered graphs for the TTL. Concurrent requests of the same graph wait for the first
+// one, the errors are returned to the waiting requests but aren't cached.
+type responseCache struct {
+	ttl   time.Duration
+	clock clockwork.Clock
+
+	mu      sync.Mutex
+	entries map[graphRequest]*responseCacheEntry
+}
+
+type responseCacheEntry struct {
+	ready   chan struct{} // closed once the graph is rendered
+	resp    *graphResponse
+	err     error
+	expires time.Time
+}
+
+func newResponseCache(ttl time.Duration, clock clockwork.Clock) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		clock:   clock,
+		entries: map[graphRequest]*responseCacheEntry{},
+	}
+}
+
+// Get returns the cached response of the request or renders it
+func (c *responseCache) Get(req graphRequest, render func() (*graphResponse, error)) (resp *graphResponse, err error) {
+	c.mu.Lock()
+	now := c.clock.Now()
+
+	entry, ok := c.entries[req]
+	if ok && entry.done() && !now.Before(entry.expires) {
+		ok = false
+	}
+
+	if !ok {
+		c.evict(now)
+
+		entry = &responseCacheEntry{ready: make(chan struct{})}
+		c.entries[req] = entry
+	}
+	c.mu.Unlock()
+
+	if ok {
+		<-entry.ready
+		return entry.resp, entry.err
+	}
+
+	// the waiting requests are released even if render panics, the panic is returned as an error
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			entry.resp, entry.err = nil, fmt.Errorf("failed to render the graph: %v", recovered)
+		}
+
+		c.mu.Lock()
+		entry.expires = c.clock.Now().Add(c.ttl)
+
+		// nothing is kept without a TTL and the errors are retried by the next request
+		if entry.err != nil || c.ttl <= 0 {
+			if c.entries[req] == entry {
+				delete(c.entries, req)
+			}
+		}
+		c.mu.Unlock()
+
+		close(entry.ready)
+
+		resp, err = entry.resp, entry.err
+	}()
+
+	entry.resp, entry.err = render()
+
+	return entry.resp, entry.err
+}
+
+// evict removes the expired entries, it is called with the lock held
+func (c *responseCache) evict(now time.Time) {
+	for req, entry := range c.entries {
+		if entry.done() && !now.Before(entry.expires) {
+			delete(c.entries, req)
+		}
+	}
+}
+
+func (e *responseCacheEntry) done() bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,270 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/sergk/tkn-graph/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func getTestServePipeline() *Pipeline {
+	return &Pipeline{
+		Name: "build",
+		TektonPipeline: v1.Pipeline{
+			Spec: v1.PipelineSpec{
+				Tasks: []v1.PipelineTask{
+					{Name: "clone", TaskRef: &v1.TaskRef{Name: "git-clone"}},
+					{Name: "test", TaskRef: &v1.TaskRef{Name: "go-test"}, RunAfter: []string{"clone"}},
+				},
+			},
+		},
+	}
+}
+
+// getTestServer returns a server whose fetchers are the mock and its fake clock
+func getTestServer(t *testing.T, fetcher *MockGraphFetcher, opts *ServeOptions) (*Server, clockwork.FakeClock) {
+	t.Helper()
+
+	p := &test.Params{}
+	p.SetNamespace("ci")
+
+	fetchers := func() GraphFetcher { return fetcher }
+
+	return NewServer(p, nil, fetchers, fetchers, opts), p.Time().(clockwork.FakeClock)
+}
+
+func serveTestRequest(s *Server, path string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestServerFormats(t *testing.T) {
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "build", mock.Anything).Return(getTestServePipeline(), nil)
+
+	s, _ := getTestServer(t, fetcher, &ServeOptions{OutputFormat: "dot"})
+
+	testCases := []struct {
+		path        string
+		contentType string
+		expected    string
+	}{
+		{path: "/namespaces/team-a/pipelines/build.mmd", contentType: "text/plain; charset=utf-8", expected: "flowchart TD"},
+		{path: "/namespaces/team-a/pipelines/build.dot", contentType: "text/vnd.graphviz; charset=utf-8", expected: "digraph"},
+		{path: "/namespaces/team-a/pipelines/build.svg", contentType: "image/svg+xml", expected: "<svg"},
+		{path: "/namespaces/team-a/pipelines/build.json", contentType: "application/json", expected: `"name": "build"`},
+		{path: "/namespaces/team-a/pipelines/build.txt", contentType: "text/plain; charset=utf-8", expected: "clone"},
+		{path: "/namespaces/team-a/pipelineruns/build.png", contentType: "image/png", expected: "PNG"},
+		// the default output format is used without an extension
+		{path: "/pipelines/build", contentType: "text/vnd.graphviz; charset=utf-8", expected: "digraph"},
+		{path: "/pipelines/build.puml?with-task-ref", contentType: "text/plain; charset=utf-8", expected: "git-clone"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			w := serveTestRequest(s, tc.path)
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, tc.contentType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), tc.expected)
+		})
+	}
+
+	fetcher.AssertCalled(t, "GetByName", mock.Anything, "build", "team-a")
+	fetcher.AssertCalled(t, "GetByName", mock.Anything, "build", "ci")
+}
+
+func TestServerCache(t *testing.T) {
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "build", "ci").Return(getTestServePipeline(), nil)
+
+	s, clock := getTestServer(t, fetcher, &ServeOptions{OutputFormat: "dot", CacheTTL: time.Minute})
+
+	w := serveTestRequest(s, "/pipelines/build.mmd")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	etag := w.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+
+	// the cached graph is returned, a client which has it already gets no body
+	w = serveTestRequest(s, "/pipelines/build.mmd", "If-None-Match", `"other", `+etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	w = serveTestRequest(s, "/pipelines/build.mmd", "If-None-Match", "W/"+etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	fetcher.AssertNumberOfCalls(t, "GetByName", 1)
+
+	// the other formats and options are cached separately
+	w = serveTestRequest(s, "/pipelines/build.mmd?with-task-ref=true", "If-None-Match", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	fetcher.AssertNumberOfCalls(t, "GetByName", 2)
+
+	// the graph is fetched again once it expires, it has the same ETag as long as it doesn't change
+	clock.Advance(time.Minute)
+
+	w = serveTestRequest(s, "/pipelines/build.mmd", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	fetcher.AssertNumberOfCalls(t, "GetByName", 3)
+}
+
+func TestServerWithoutCache(t *testing.T) {
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "build", "ci").Return(getTestServePipeline(), nil)
+
+	s, _ := getTestServer(t, fetcher, &ServeOptions{OutputFormat: "dot"})
+
+	for range 2 {
+		w := serveTestRequest(s, "/pipelines/build")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	}
+
+	fetcher.AssertNumberOfCalls(t, "GetByName", 2)
+}
+
+func TestServerErrors(t *testing.T) {
+	invalid := getTestServePipeline()
+	invalid.TektonPipeline.Spec.Tasks[0].RunAfter = []string{"test"}
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "build", "ci").Return(getTestServePipeline(), nil)
+	fetcher.On("GetByName", mock.Anything, "cycle", "ci").Return(invalid, nil)
+	fetcher.On("GetByName", mock.Anything, "missing", "ci").Return((*Pipeline)(nil),
+		apierrors.NewNotFound(schema.GroupResource{Group: "tekton.dev", Resource: "pipelines"}, "missing"))
+
+	log := new(strings.Builder)
+	s, _ := getTestServer(t, fetcher, &ServeOptions{OutputFormat: "dot", CacheTTL: time.Minute})
+	s.Log = log
+
+	testCases := []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{path: "/pipelines/missing.svg", status: http.StatusNotFound, expected: `pipelines.tekton.dev "missing" not found`},
+		{path: "/pipelines/cycle.svg", status: http.StatusUnprocessableEntity, expected: "invalid Pipeline build"},
+		{path: "/pipelines/build.svg?reduce=maybe", status: http.StatusBadRequest, expected: `invalid reduce "maybe"`},
+		{path: "/pipelines/build.svg?highlight-critical-path", status: http.StatusBadRequest, expected: "highlight-critical-path is only supported for the PipelineRuns"},
+		{path: "/pipelines/build.svg?theme=/etc/theme.yaml", status: http.StatusBadRequest, expected: "unknown theme /etc/theme.yaml"},
+		{path: "/tasks/build.svg", status: http.StatusNotFound, expected: "404 page not found"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			w := serveTestRequest(s, tc.path)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expected)
+		})
+	}
+
+	// the errors are not cached, only the server errors are logged
+	serveTestRequest(s, "/pipelines/missing.svg")
+	fetcher.AssertNumberOfCalls(t, "GetByName", 3)
+	assert.Empty(t, log.String())
+}
+
+func TestServerRecoversPanic(t *testing.T) {
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "build", "ci").Panic("boom").Once()
+	fetcher.On("GetByName", mock.Anything, "build", "ci").Return(getTestServePipeline(), nil)
+
+	log := new(strings.Builder)
+	s, _ := getTestServer(t, fetcher, &ServeOptions{OutputFormat: "dot", CacheTTL: time.Minute})
+	s.Log = log
+
+	w := serveTestRequest(s, "/pipelines/build.dot")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "failed to render the graph: boom")
+	assert.Contains(t, log.String(), "GET /pipelines/build.dot: failed to render the graph: boom")
+
+	// the failed render isn't cached, the next request doesn't wait for it
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serveTestRequest(s, "/pipelines/build.dot") }()
+
+	select {
+	case w = <-done:
+		assert.Equal(t, http.StatusOK, w.Code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request waits for the render which panicked")
+	}
+}
+
+func TestServerThemes(t *testing.T) {
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "build", "ci").Return(getTestServePipeline(), nil)
+
+	s, _ := getTestServer(t, fetcher, &ServeOptions{OutputFormat: "dot"})
+
+	w := serveTestRequest(s, "/pipelines/build.dot?theme=dark")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `bgcolor="#202124"`)
+
+	w = serveTestRequest(s, "/pipelines/build.dot")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "bgcolor")
+}
+
+func TestSplitFormat(t *testing.T) {
+	testCases := []struct {
+		name, expectedName, expectedFormat string
+	}{
+		{name: "build.svg", expectedName: "build", expectedFormat: "svg"},
+		{name: "build.MMD", expectedName: "build", expectedFormat: "mmd"},
+		{name: "build.unicode", expectedName: "build", expectedFormat: "unicode"},
+		{name: "build.txt", expectedName: "build", expectedFormat: "ascii"},
+		{name: "build", expectedName: "build", expectedFormat: "dot"},
+		// the names of the resources may contain dots
+		{name: "build.v2", expectedName: "build.v2", expectedFormat: "dot"},
+		{name: ".svg", expectedName: ".svg", expectedFormat: "dot"},
+	}
+
+	for _, tc := range testCases {
+		name, format := splitFormat(tc.name, "dot")
+		assert.Equal(t, tc.expectedName, name, tc.name)
+		assert.Equal(t, tc.expectedFormat, format, tc.name)
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"a"`, `"a"`))
+	assert.True(t, etagMatches(`"b", W/"a"`, `"a"`))
+	assert.True(t, etagMatches("*", `"a"`))
+	assert.False(t, etagMatches("", `"a"`))
+	assert.False(t, etagMatches(`"b"`, `"a"`))
+}
+
+func TestServeCommandErrors(t *testing.T) {
+	fetchers := func() GraphFetcher { return new(MockGraphFetcher) }
+
+	_, err := test.ExecuteCommand(CreateServeCommand(&test.Params{}, fetchers, fetchers), "--cache-ttl", "-1s")
+	assert.EqualError(t, err, "--cache-ttl can't be negative")
+
+	_, err = test.ExecuteCommand(CreateServeCommand(&test.Params{}, fetchers, fetchers), "--output-format", "gif")
+	assert.ErrorContains(t, err, "Invalid output format: gif")
+
+	_, err = test.ExecuteCommand(CreateServeCommand(&test.Params{}, fetchers, fetchers), "build")
+	assert.ErrorContains(t, err, "unknown command")
+}
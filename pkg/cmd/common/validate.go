@@ -0,0 +1,123 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/cli/pkg/cli"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValidateOptions holds the options for the validate command
+// Filenames: read Pipelines and PipelineRuns from local files instead of the cluster
+type ValidateOptions struct {
+	Filenames []string
+}
+
+func CreateValidateCommand(p cli.Params, fetcher GraphFetcher) *cobra.Command {
+	opts := &ValidateOptions{}
+	c := &cobra.Command{
+		Use:   "validate [name...]",
+		Short: "Reports unknown dependencies, cycles and duplicate tasks",
+		Annotations: map[string]string{
+			"commandType": "main",
+		},
+		SilenceUsage: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			return initParams(p, cmd, opts.Filenames)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+
+			if len(opts.Filenames) > 0 {
+				return RunValidateCommand(ctx, p, opts, newFileFetcher(cmd, fetcher, opts.Filenames), args, cmd.OutOrStdout())
+			}
+
+			return RunValidateCommand(ctx, p, opts, fetcher, args, cmd.OutOrStdout())
+		},
+	}
+
+	c.Flags().StringSliceVarP(
+		&opts.Filenames, "filename", "f", nil,
+		"files, directories or glob patterns with Pipelines/PipelineRuns to validate instead of the cluster, use - for stdin")
+
+	return c
+}
+
+// RunValidateCommand validates the Pipelines named in args, or all Pipelines if no name is provided,
+// and reports every problem found. A named Pipeline which can't be fetched is reported as invalid, the others are
+// still validated. An error is returned if at least one Pipeline is invalid.
+func RunValidateCommand(ctx context.Context, p cli.Params, opts *ValidateOptions, fetcher GraphFetcher, args []string, out io.Writer) error {
+	cs, err := clients(p, len(opts.Filenames) == 0)
+	if err != nil {
+		return err
+	}
+
+	var pipelines []Pipeline
+
+	if len(args) == 0 {
+		pipelines, err = fetcher.GetAll(ctx, cs, p.Namespace(), metav1.ListOptions{Limit: DefaultPageSize})
+		if err != nil {
+			return fetchError(err, "failed to run GetAll")
+		}
+	}
+
+	// the errors of the named Pipelines which can't be fetched, by position in pipelines
+	fetchErrs := map[int]error{}
+
+	for _, name := range args {
+		pipeline, err := fetcher.GetByName(ctx, cs, name, p.Namespace())
+		if err != nil {
+			fetchErrs[len(pipelines)] = fetchError(err, "failed to run GetByName")
+			pipelines = append(pipelines, Pipeline{Name: name})
+
+			continue
+		}
+
+		pipelines = append(pipelines, *pipeline)
+	}
+
+	invalid := 0
+
+	for i := range pipelines {
+		if err, ok := fetchErrs[i]; ok {
+			invalid++
+
+			fmt.Fprintf(out, "%s: 1 problem(s)\n  - %s\n", pipelines[i].Name, err)
+
+			continue
+		}
+
+		spec := pipelines[i].TektonPipeline.Spec
+
+		_, err := taskgraph.BuildTaskGraph(spec.Tasks, spec.Finally...)
+		if err == nil {
+			fmt.Fprintf(out, "%s: OK\n", pipelines[i].Name)
+			continue
+		}
+
+		invalid++
+
+		var errs taskgraph.ValidationErrors
+		if !errors.As(err, &errs) {
+			return fmt.Errorf("failed to validate Pipeline %s: %w", pipelines[i].Name, err)
+		}
+
+		fmt.Fprintf(out, "%s: %d problem(s)\n", pipelines[i].Name, len(errs))
+
+		for _, e := range errs {
+			fmt.Fprintf(out, "  - %s\n", e)
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d Pipelines are invalid", invalid, len(pipelines))
+	}
+
+	return nil
+}
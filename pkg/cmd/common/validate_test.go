@@ -0,0 +1,84 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sergk/tkn-graph/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/tektoncd/cli/pkg/flags"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRunValidateCommand(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetAll", mock.Anything, "default", metav1.ListOptions{Limit: DefaultPageSize}).Return([]Pipeline{
+		{
+			Name: "valid",
+			TektonPipeline: v1.Pipeline{Spec: v1.PipelineSpec{Tasks: []v1.PipelineTask{
+				{Name: "task1"},
+				{Name: "task2", RunAfter: []string{"task1"}},
+			}}},
+		},
+		{
+			Name: "invalid",
+			TektonPipeline: v1.Pipeline{Spec: v1.PipelineSpec{Tasks: []v1.PipelineTask{
+				{Name: "task1", RunAfter: []string{"task2"}},
+				{Name: "task2", RunAfter: []string{"task1", "typo"}},
+			}}},
+		},
+	}, nil)
+
+	out := new(bytes.Buffer)
+	err := RunValidateCommand(context.Background(), p, &ValidateOptions{}, fetcher, nil, out)
+
+	assert.EqualError(t, err, "1 of 2 Pipelines are invalid")
+	assert.Equal(t, `valid: OK
+invalid: 2 problem(s)
+  - unknown dependency: task "task2" depends on unknown task "typo"
+  - dependency cycle: task1 -> task2 -> task1
+`, out.String())
+	fetcher.AssertExpectations(t)
+}
+
+func TestRunValidateCommandWithFetchErrors(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := new(MockGraphFetcher)
+	fetcher.On("GetByName", mock.Anything, "missing", "default").Return((*Pipeline)(nil), errors.New("not found"))
+	fetcher.On("GetByName", mock.Anything, "valid", "default").Return(&Pipeline{
+		Name:           "valid",
+		TektonPipeline: v1.Pipeline{Spec: v1.PipelineSpec{Tasks: []v1.PipelineTask{{Name: "task1"}}}},
+	}, nil)
+
+	// the Pipelines after the one which can't be fetched are still validated
+	out := new(bytes.Buffer)
+	err := RunValidateCommand(context.Background(), p, &ValidateOptions{}, fetcher, []string{"missing", "valid"}, out)
+
+	assert.EqualError(t, err, "1 of 2 Pipelines are invalid")
+	assert.Equal(t, `missing: 1 problem(s)
+  - failed to run GetByName: not found
+valid: OK
+`, out.String())
+	fetcher.AssertExpectations(t)
+}
+
+func TestValidateCommandWithFilename(t *testing.T) {
+	cmd := CreateValidateCommand(&test.Params{}, new(MockGraphFetcher))
+	flags.AddTektonOptions(cmd)
+	cmd.SetIn(strings.NewReader(manifests))
+
+	out, err := test.ExecuteCommand(cmd, "-f", "-", "pipeline1", "run-inline")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "pipeline1: OK\nrun-inline: OK\n", out)
+}
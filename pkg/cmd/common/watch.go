@@ -0,0 +1,70 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/tektoncd/cli/pkg/cli"
+	"golang.org/x/term"
+)
+
+// clearScreen moves the cursor to the top left corner and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// stdoutIsTerminal reports whether the graphs are printed to a terminal, the screen is cleared before every render then
+var stdoutIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// RunWatchCommand renders the graph of the PipelineRun named in args every time the state of one of its tasks changes.
+// The output files are rewritten if an output directory is set, otherwise the graph is printed again. Watching ends
+// when the PipelineRun completes, or without an error when it is interrupted with Ctrl-C.
+func RunWatchCommand(ctx context.Context, p cli.Params, opts *GraphOptions, fetcher GraphFetcher, args []string) error {
+	wf, ok := fetcher.(WatchFetcher)
+	if !ok || len(opts.Filenames) > 0 {
+		return fmt.Errorf("--watch is only supported for the PipelineRuns in the cluster")
+	}
+
+	if len(args) != 1 || opts.AllNamespaces || opts.Selector != "" || opts.FieldSelector != "" {
+		return fmt.Errorf("--watch requires the name of a single PipelineRun")
+	}
+
+	cs, err := p.Clients()
+	if err != nil {
+		return err
+	}
+
+	redraw := opts.OutputDir == "" && stdoutIsTerminal()
+	rendered := false
+
+	err = wf.Watch(ctx, cs, args[0], p.Namespace(), opts.requestTimeout, func(pipeline *Pipeline) error {
+		graph, err := buildGraph(pipeline, opts)
+		if err != nil {
+			return err
+		}
+
+		if redraw {
+			fmt.Print(clearScreen)
+		}
+
+		if err := renderGraphs([]*taskgraph.TaskGraph{graph}, opts, rendered && !redraw); err != nil {
+			return err
+		}
+
+		rendered = true
+
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	if err != nil {
+		return fetchError(err, "failed to run Watch")
+	}
+
+	return nil
+}
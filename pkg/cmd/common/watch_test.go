@@ -0,0 +1,153 @@
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sergk/tkn-graph/pkg/request"
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/sergk/tkn-graph/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tektoncd/cli/pkg/cli"
+	"github.com/tektoncd/cli/pkg/flags"
+)
+
+// watchFetcher calls fn with the states in order, then returns err
+type watchFetcher struct {
+	MockGraphFetcher
+	states  []taskgraph.TaskState
+	err     error
+	name    string
+	timeout time.Duration
+	ctx     context.Context
+}
+
+func (f *watchFetcher) Watch(
+	ctx context.Context, _ *cli.Clients, name, _ string, timeout time.Duration, fn func(pipeline *Pipeline) error,
+) error {
+	f.name = name
+	f.timeout = timeout
+	f.ctx = ctx
+
+	for _, state := range f.states {
+		pipeline := pipelinePage(name)[0]
+		pipeline.TaskStatuses = map[string]*taskgraph.TaskStatus{"task1": {State: state}}
+
+		if err := fn(&pipeline); err != nil {
+			return err
+		}
+	}
+
+	return f.err
+}
+
+func TestCreateGraphCommandWatchFlag(t *testing.T) {
+	cmd := CreateGraphCommand(&test.Params{}, new(MockGraphFetcher))
+	assert.Nil(t, cmd.Flags().Lookup("watch"))
+
+	cmd = CreateGraphCommand(&test.Params{}, new(watchFetcher))
+	assert.NotNil(t, cmd.Flags().Lookup("watch"))
+}
+
+func TestRunWatchCommand(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	fetcher := &watchFetcher{states: []taskgraph.TaskState{taskgraph.TaskStateRunning, taskgraph.TaskStateSucceeded}}
+	opts := &GraphOptions{OutputFormat: "json", OutputDir: t.TempDir(), Watch: true}
+
+	err := RunWatchCommand(context.Background(), p, opts, fetcher, []string{"run"})
+	assert.NoError(t, err)
+	assert.Equal(t, "run", fetcher.name)
+
+	// the output file is rewritten with the latest state
+	content, err := os.ReadFile(filepath.Join(opts.OutputDir, "run.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), string(taskgraph.TaskStateSucceeded))
+	assert.NotContains(t, string(content), string(taskgraph.TaskStateRunning))
+}
+
+func TestRunWatchCommandInterrupted(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	opts := &GraphOptions{OutputFormat: "dot", OutputDir: t.TempDir(), Watch: true}
+
+	// Ctrl-C stops watching without an error
+	fetcher := &watchFetcher{err: &request.InterruptedError{Action: "watching PipelineRun run", Err: context.Canceled}}
+	assert.NoError(t, RunWatchCommand(context.Background(), p, opts, fetcher, []string{"run"}))
+
+	fetcher = &watchFetcher{err: &request.InterruptedError{Action: "watching PipelineRun run", Err: context.DeadlineExceeded}}
+	assert.EqualError(t, RunWatchCommand(context.Background(), p, opts, fetcher, []string{"run"}),
+		"timed out watching PipelineRun run")
+}
+
+func TestRunWatchCommandInvalid(t *testing.T) {
+	p := &test.Params{}
+	p.SetNamespace("default")
+
+	testCases := []struct {
+		name        string
+		opts        *GraphOptions
+		fetcher     GraphFetcher
+		args        []string
+		expectedErr string
+	}{
+		{
+			name:        "no watch support",
+			opts:        &GraphOptions{Watch: true},
+			fetcher:     new(MockGraphFetcher),
+			args:        []string{"run"},
+			expectedErr: "--watch is only supported for the PipelineRuns in the cluster",
+		},
+		{
+			name:        "local files",
+			opts:        &GraphOptions{Watch: true, Filenames: []string{"run.yaml"}},
+			fetcher:     new(watchFetcher),
+			args:        []string{"run"},
+			expectedErr: "--watch is only supported for the PipelineRuns in the cluster",
+		},
+		{
+			name:        "no name",
+			opts:        &GraphOptions{Watch: true},
+			fetcher:     new(watchFetcher),
+			expectedErr: "--watch requires the name of a single PipelineRun",
+		},
+		{
+			name:        "selector",
+			opts:        &GraphOptions{Watch: true, Selector: "app=shop"},
+			fetcher:     new(watchFetcher),
+			args:        []string{"run"},
+			expectedErr: "--watch requires the name of a single PipelineRun",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := RunWatchCommand(context.Background(), p, tc.opts, tc.fetcher, tc.args)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestGraphCommandWatchWithRequestTimeout(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	fetcher := &watchFetcher{states: []taskgraph.TaskState{taskgraph.TaskStateRunning}}
+
+	cmd := CreateGraphCommand(&test.Params{}, fetcher)
+	flags.AddTektonOptions(cmd)
+	AddRequestTimeoutFlag(cmd)
+
+	// the timeout applies to each request of the watch, not to the whole watch
+	_, err := test.ExecuteCommand(cmd, "--watch", "--request-timeout", "30s", "-n", "default", "--output-dir", t.TempDir(), "run")
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, fetcher.timeout)
+
+	_, hasDeadline := fetcher.ctx.Deadline()
+	assert.False(t, hasDeadline)
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/sergk/tkn-graph/pkg/config"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// ViewOptions holds the options for the config view command
+// Namespace: include the options of the namespace sections of this namespace
+// Output: table or yaml
+type ViewOptions struct {
+	Namespace string
+	Output    string
+}
+
+// Command returns the config command, it shows the configuration read from the config files and the environment
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Shows the default options read from the config files and the environment",
+		Annotations: map[string]string{
+			"commandType": "utility",
+		},
+	}
+
+	cmd.AddCommand(viewCommand())
+
+	return cmd
+}
+
+func viewCommand() *cobra.Command {
+	opts := &ViewOptions{}
+	c := &cobra.Command{
+		Use:   "view",
+		Short: "Shows the effective configuration and where every option comes from",
+		Example: `  # Show the options applied to the graphs of the team-a namespace
+  tkn-graph config view --namespace team-a`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(config.DefaultSources())
+			if err != nil {
+				return err
+			}
+
+			if err := cfg.Validate(cmd.Root()); err != nil {
+				return err
+			}
+
+			return RunView(cmd.OutOrStdout(), cfg, opts)
+		},
+	}
+
+	c.Flags().StringVarP(
+		&opts.Namespace, "namespace", "n", "", "include the options of the namespace sections of this namespace")
+	c.Flags().StringVarP(
+		&opts.Output, "output", "o", "table", "the output format, table or yaml")
+
+	return c
+}
+
+// view is the yaml output of the view command
+type view struct {
+	Options []config.Value `json:"options"`
+	Themes  []themeSource  `json:"themes,omitempty"`
+}
+
+type themeSource struct {
+	Name   string `json:"name"`
+	Base   string `json:"base"`
+	Source string `json:"source"`
+}
+
+// RunView prints the options of the configuration which apply to the namespace and the themes it defines
+func RunView(out io.Writer, cfg *config.Config, opts *ViewOptions) error {
+	v := view{Options: cfg.Values(opts.Namespace)}
+
+	names := make([]string, 0, len(cfg.Themes))
+	for name := range cfg.Themes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		base := cfg.Themes[name].Base
+		if base == "" {
+			base = "light"
+		}
+
+		v.Themes = append(v.Themes, themeSource{Name: name, Base: base, Source: cfg.ThemeSource(name)})
+	}
+
+	switch opts.Output {
+	case "table":
+		return writeTable(out, &v)
+	case "yaml":
+		content, err := yaml.Marshal(&v)
+		if err != nil {
+			return fmt.Errorf("failed to print config: %w", err)
+		}
+
+		_, err = out.Write(content)
+
+		return err
+	default:
+		return fmt.Errorf("invalid output format %s, it must be table or yaml", opts.Output)
+	}
+}
+
+func writeTable(out io.Writer, v *view) error {
+	if len(v.Options) == 0 && len(v.Themes) == 0 {
+		_, err := fmt.Fprintln(out, "no options are set by the config files or the environment")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+
+	for _, value := range v.Options {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", value.Key, value.Value, value.Source)
+	}
+
+	for _, theme := range v.Themes {
+		fmt.Fprintf(w, "themes.%s\tbase %s\t%s\n", theme.Name, theme.Base, theme.Source)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sergk/tkn-graph/pkg/config"
+	"github.com/sergk/tkn-graph/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestConfig(t *testing.T) (*config.Config, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`output-format: mmd
+namespaces:
+  team-a:
+    with-task-ref: true
+themes:
+  corp:
+    base: print
+`), 0o600))
+
+	cfg, err := config.Load(config.Sources{UserFile: path, Environ: []string{"TKN_GRAPH_OUTPUT_DIR=docs/"}})
+	require.NoError(t, err)
+
+	return cfg, path
+}
+
+func TestRunView(t *testing.T) {
+	cfg, path := getTestConfig(t)
+
+	out := new(bytes.Buffer)
+	require.NoError(t, RunView(out, cfg, &ViewOptions{Output: "table"}))
+	assert.Equal(t, "KEY            VALUE       SOURCE\n"+
+		"output-dir     docs/       $TKN_GRAPH_OUTPUT_DIR\n"+
+		"output-format  mmd         "+path+"\n"+
+		"themes.corp    base print  "+path+"\n", out.String())
+
+	out.Reset()
+	require.NoError(t, RunView(out, cfg, &ViewOptions{Namespace: "team-a", Output: "yaml"}))
+	assert.Equal(t, `options:
+- key: output-dir
+  source: $TKN_GRAPH_OUTPUT_DIR
+  value: docs/
+- key: output-format
+  source: `+path+`
+  value: mmd
+- key: with-task-ref
+  source: `+path+` (namespace team-a)
+  value: "true"
+themes:
+- base: print
+  name: corp
+  source: `+path+`
+`, out.String())
+
+	assert.EqualError(t, RunView(out, cfg, &ViewOptions{Output: "json"}), "invalid output format json, it must be table or yaml")
+}
+
+func TestRunViewWithoutConfig(t *testing.T) {
+	cfg, err := config.Load(config.Sources{})
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	require.NoError(t, RunView(out, cfg, &ViewOptions{Output: "table"}))
+	assert.Equal(t, "no options are set by the config files or the environment\n", out.String())
+}
+
+func TestViewCommand(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("TKN_GRAPH_OUTPUT_FORMT", "mmd")
+
+	out, err := test.ExecuteCommand(Command(), "view")
+	require.NoError(t, err)
+	assert.Equal(t, "Warning: ignoring the environment variable TKN_GRAPH_OUTPUT_FORMT, output-formt is not an option\n"+
+		"no options are set by the config files or the environment\n", out)
+}
@@ -1,43 +1,98 @@
 package pipeline
 
 import (
+	"context"
 	"fmt"
 
 	common "github.com/sergk/tkn-graph/pkg/cmd/common"
 	"github.com/tektoncd/cli/pkg/cli"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// PipelineFetcher fetches the Pipelines, ListPipelinesFunc is optional, when set the Pipelines are listed page by page
+// instead of GetAllPipelinesFunc
 type PipelineFetcher struct {
-	GetPipelineByNameFunc func(cs *cli.Clients, name, namespace string) (*v1.Pipeline, error)
-	GetAllPipelinesFunc   func(cs *cli.Clients, namespace string) ([]v1.Pipeline, error)
+	GetPipelineByNameFunc func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error)
+	GetAllPipelinesFunc   func(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.Pipeline, error)
+	ListPipelinesFunc     func(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions, fn func(page []v1.Pipeline) error) error
 }
 
-func (f *PipelineFetcher) GetByName(cs *cli.Clients, name, namespace string) (*common.Pipeline, error) {
-	p, err := f.GetPipelineByNameFunc(cs, name, namespace)
+// Kind returns the kind of the resources of the fetcher, the commands only read the Pipelines of their files
+func (f *PipelineFetcher) Kind() string {
+	return common.KindPipeline
+}
+
+func (f *PipelineFetcher) GetByName(ctx context.Context, cs *cli.Clients, name, namespace string) (*common.Pipeline, error) {
+	p, err := f.GetPipelineByNameFunc(ctx, cs, name, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Pipeline by name: %w", err)
 	}
 
 	return &common.Pipeline{
 		Name:           name,
+		Namespace:      namespace,
 		TektonPipeline: *p,
 	}, nil
 }
 
-func (f *PipelineFetcher) GetAll(cs *cli.Clients, namespace string) ([]common.Pipeline, error) {
-	ps, err := f.GetAllPipelinesFunc(cs, namespace)
+func (f *PipelineFetcher) GetAll(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]common.Pipeline, error) {
+	var cp []common.Pipeline
+
+	err := f.GetPages(ctx, cs, namespace, opts, func(page []common.Pipeline) error {
+		cp = append(cp, page...)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all Pipelines: %w", err)
+		return nil, err
 	}
 
-	cp := make([]common.Pipeline, 0, len(ps))
-	for i := range ps {
-		cp = append(cp, common.Pipeline{
-			Name:           ps[i].Name,
-			TektonPipeline: ps[i],
-		})
+	return cp, nil
+}
+
+// GetPages fetches the Pipelines page by page, opts.Limit is the size of the pages. fn is called with the Pipelines
+// of every page, in the order of the list.
+func (f *PipelineFetcher) GetPages(
+	ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions, fn func(page []common.Pipeline) error,
+) error {
+	// the errors of fn are returned as is
+	var pageErr error
+
+	toPipelines := func(ps []v1.Pipeline) error {
+		cp := make([]common.Pipeline, 0, len(ps))
+		for i := range ps {
+			cp = append(cp, common.Pipeline{
+				Name:           ps[i].Name,
+				Namespace:      ps[i].Namespace,
+				TektonPipeline: ps[i],
+			})
+		}
+
+		pageErr = fn(cp)
+
+		return pageErr
 	}
 
-	return cp, nil
+	var err error
+
+	if f.ListPipelinesFunc != nil {
+		err = f.ListPipelinesFunc(ctx, cs, namespace, opts, toPipelines)
+	} else {
+		var ps []v1.Pipeline
+
+		ps, err = f.GetAllPipelinesFunc(ctx, cs, namespace, opts)
+		if err == nil {
+			err = toPipelines(ps)
+		}
+	}
+
+	if pageErr != nil {
+		return pageErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get all Pipelines: %w", err)
+	}
+
+	return nil
 }
@@ -1,8 +1,11 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	common "github.com/sergk/tkn-graph/pkg/cmd/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/tektoncd/cli/pkg/cli"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
@@ -11,7 +14,7 @@ import (
 
 func TestGetByName(t *testing.T) {
 	fetcher := &PipelineFetcher{
-		GetPipelineByNameFunc: func(cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+		GetPipelineByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
 			// Return a dummy pipeline
 			return &v1.Pipeline{
 				ObjectMeta: metav1.ObjectMeta{
@@ -21,7 +24,7 @@ func TestGetByName(t *testing.T) {
 		},
 	}
 
-	p, err := fetcher.GetByName(nil, "pipeline1", "default")
+	p, err := fetcher.GetByName(context.Background(), nil, "pipeline1", "default")
 
 	assert.NoError(t, err)
 	assert.Equal(t, "pipeline1", p.Name)
@@ -29,7 +32,7 @@ func TestGetByName(t *testing.T) {
 
 func TestGetAll(t *testing.T) {
 	fetcher := &PipelineFetcher{
-		GetAllPipelinesFunc: func(cs *cli.Clients, namespace string) ([]v1.Pipeline, error) {
+		GetAllPipelinesFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.Pipeline, error) {
 			// Return a slice of dummy pipelines
 			return []v1.Pipeline{
 				{
@@ -46,10 +49,48 @@ func TestGetAll(t *testing.T) {
 		},
 	}
 
-	ps, err := fetcher.GetAll(nil, "default")
+	ps, err := fetcher.GetAll(context.Background(), nil, "default", metav1.ListOptions{})
 
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(ps))
 	assert.Equal(t, "pipeline1", ps[0].Name)
 	assert.Equal(t, "pipeline2", ps[1].Name)
 }
+
+func TestGetAllByPage(t *testing.T) {
+	fetcher := &PipelineFetcher{
+		ListPipelinesFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions,
+			fn func(page []v1.Pipeline) error,
+		) error {
+			assert.Equal(t, int64(1), opts.Limit)
+
+			for _, name := range []string{"pipeline1", "pipeline2"} {
+				if err := fn([]v1.Pipeline{{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	ps, err := fetcher.GetAll(context.Background(), nil, "default", metav1.ListOptions{Limit: 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ps))
+	assert.Equal(t, "pipeline1", ps[0].Name)
+	assert.Equal(t, "default", ps[0].Namespace)
+	assert.Equal(t, "pipeline2", ps[1].Name)
+
+	// the errors of fn are returned as is
+	stop := errors.New("stop")
+	pages := 0
+
+	err = fetcher.GetPages(context.Background(), nil, "default", metav1.ListOptions{Limit: 1}, func(page []common.Pipeline) error {
+		pages++
+		return stop
+	})
+
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, pages)
+}
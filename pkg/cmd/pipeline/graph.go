@@ -8,8 +8,22 @@ import (
 )
 
 func graphCommand(p cli.Params) *cobra.Command {
-	return common.CreateGraphCommand(p, &PipelineFetcher{
+	return common.CreateGraphCommand(p, NewFetcher())
+}
+
+func validateCommand(p cli.Params) *cobra.Command {
+	return common.CreateValidateCommand(p, NewFetcher())
+}
+
+func diffCommand(p cli.Params) *cobra.Command {
+	return common.CreateDiffCommand(p, NewFetcher())
+}
+
+// NewFetcher returns the fetcher of the Pipelines of the cluster
+func NewFetcher() *PipelineFetcher {
+	return &PipelineFetcher{
 		GetPipelineByNameFunc: pipeline.GetPipelineByName,
 		GetAllPipelinesFunc:   pipeline.GetAllPipelines,
-	})
+		ListPipelinesFunc:     pipeline.ListPipelines,
+	}
 }
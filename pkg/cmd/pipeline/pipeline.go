@@ -19,6 +19,8 @@ func Command(p cli.Params) *cobra.Command {
 	flags.AddTektonOptions(cmd)
 	cmd.AddCommand(
 		graphCommand(p),
+		validateCommand(p),
+		diffCommand(p),
 	)
 
 	return cmd
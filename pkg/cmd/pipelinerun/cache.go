@@ -0,0 +1,74 @@
+package pipelinerun
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tektoncd/cli/pkg/cli"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// pipelineCache memoizes the Pipeline lookups by namespace and name, errors included, so many runs of
+// the same Pipeline cause a single request. Concurrent lookups of the same Pipeline wait for the first one.
+// The lookups cut short by their context aren't cached, e.g. a request timeout of a watch.
+type pipelineCache struct {
+	get func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error)
+
+	mu      sync.Mutex
+	entries map[types.NamespacedName]*pipelineCacheEntry
+}
+
+type pipelineCacheEntry struct {
+	ready       chan struct{} // closed once the lookup is done
+	pipeline    *v1.Pipeline
+	err         error
+	interrupted bool // the context of the lookup was done, the entry was removed from the cache
+}
+
+func newPipelineCache(get func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error)) *pipelineCache {
+	return &pipelineCache{
+		get:     get,
+		entries: map[types.NamespacedName]*pipelineCacheEntry{},
+	}
+}
+
+// Get returns the Pipeline, it is only fetched the first time. The returned Pipeline is shared and must not be modified.
+// If the lookup is cut short by the context of the first caller, the callers waiting for it fetch the Pipeline again
+// with their own context.
+func (c *pipelineCache) Get(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+
+	if !ok {
+		entry = &pipelineCacheEntry{ready: make(chan struct{})}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	if ok {
+		<-entry.ready
+
+		if entry.interrupted && ctx.Err() == nil {
+			return c.Get(ctx, cs, name, namespace)
+		}
+
+		return entry.pipeline, entry.err
+	}
+
+	entry.pipeline, entry.err = c.get(ctx, cs, name, namespace)
+
+	if entry.err != nil && ctx.Err() != nil {
+		entry.interrupted = true
+
+		c.mu.Lock()
+		delete(c.entries, key)
+		c.mu.Unlock()
+	}
+
+	close(entry.ready)
+
+	return entry.pipeline, entry.err
+}
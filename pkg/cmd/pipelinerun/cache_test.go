@@ -0,0 +1,144 @@
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/cli/pkg/cli"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPipelineCacheGet(t *testing.T) {
+	var calls atomic.Int32
+
+	release := make(chan struct{})
+	cache := newPipelineCache(func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+		calls.Add(1)
+		<-release
+
+		return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}, nil
+	})
+
+	var wg sync.WaitGroup
+
+	pipelines := make([]*v1.Pipeline, 10)
+
+	for i := range pipelines {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			p, err := cache.Get(context.Background(), nil, "build", "default")
+			assert.NoError(t, err)
+
+			pipelines[i] = p
+		}()
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+
+	for _, p := range pipelines {
+		assert.Same(t, pipelines[0], p)
+	}
+
+	// Pipelines with the same name in another namespace are different
+	p, err := cache.Get(context.Background(), nil, "build", "other")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", p.Namespace)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestPipelineCacheGetError(t *testing.T) {
+	calls := 0
+	cache := newPipelineCache(func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+		calls++
+		return nil, errors.New("not found")
+	})
+
+	for range 2 {
+		p, err := cache.Get(context.Background(), nil, "build", "default")
+		assert.Nil(t, p)
+		assert.EqualError(t, err, "not found")
+	}
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestPipelineCacheGetInterrupted(t *testing.T) {
+	calls := 0
+	cache := newPipelineCache(func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+		calls++
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cache.Get(ctx, nil, "build", "default")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// the lookup which was cut short isn't cached
+	for range 2 {
+		p, err := cache.Get(context.Background(), nil, "build", "default")
+		assert.NoError(t, err)
+		assert.Equal(t, "build", p.Name)
+	}
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestPipelineCacheGetInterruptedWhileWaiting(t *testing.T) {
+	var calls atomic.Int32
+
+	started := make(chan struct{})
+	cache := newPipelineCache(func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-ctx.Done()
+
+			return nil, ctx.Err()
+		}
+
+		return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_, err := cache.Get(ctx, nil, "build", "default")
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+
+	<-started
+
+	// the waiting caller fetches the Pipeline again with its own context
+	result := make(chan error)
+
+	go func() {
+		_, err := cache.Get(context.Background(), nil, "build", "default")
+		result <- err
+	}()
+
+	cancel()
+	<-done
+
+	assert.NoError(t, <-result)
+	assert.Equal(t, int32(2), calls.Load())
+}
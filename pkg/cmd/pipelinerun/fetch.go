@@ -1,57 +1,528 @@
 package pipelinerun
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
 
 	common "github.com/sergk/tkn-graph/pkg/cmd/common"
+	"github.com/sergk/tkn-graph/pkg/request"
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
 	"github.com/tektoncd/cli/pkg/cli"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"knative.dev/pkg/apis"
 )
 
+const (
+	kindTaskRun   = "TaskRun"
+	kindCustomRun = "CustomRun"
+)
+
+// errNotResolved is wrapped by the error of a PipelineRun whose Pipeline isn't resolved by its resolver yet
+var errNotResolved = errors.New("has no resolved pipelineSpec yet")
+
+// PipelineRunFetcher fetches the Pipeline of the PipelineRuns.
+// The TaskRun and CustomRun funcs are optional, when set the status of every task is collected as well.
+// ListPipelineRunsFunc is optional too, when set the PipelineRuns are listed page by page instead of GetAllPipelineRunsFunc.
+// The Watch funcs are only required by Watch.
 type PipelineRunFetcher struct {
-	GetPipelineRunByNameFunc func(cs *cli.Clients, name, namespace string) (*v1.PipelineRun, error)
-	GetAllPipelineRunsFunc   func(cs *cli.Clients, namespace string) ([]v1.PipelineRun, error)
-	GetPipelineByNameFunc    func(cs *cli.Clients, name, namespace string) (*v1.Pipeline, error)
+	GetPipelineRunByNameFunc func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.PipelineRun, error)
+	GetAllPipelineRunsFunc   func(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.PipelineRun, error)
+	ListPipelineRunsFunc     func(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions, fn func(page []v1.PipelineRun) error) error
+	GetPipelineByNameFunc    func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error)
+	GetTaskRunByNameFunc     func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.TaskRun, error)
+	GetAllTaskRunsFunc       func(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.TaskRun, error)
+	GetCustomRunByNameFunc   func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1beta1.CustomRun, error)
+	GetAllCustomRunsFunc     func(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1beta1.CustomRun, error)
+	WatchPipelineRunsFunc    func(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) (watch.Interface, error)
+	WatchTaskRunsFunc        func(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) (watch.Interface, error)
+	Warnings                 io.Writer // Optional, receives warnings when the live Pipeline differs from the run
+	Concurrency              int       // Number of PipelineRuns of a page processed in parallel, 1 if not set
+
+	pipelines *pipelineCache
+}
+
+// SetConcurrency sets the number of PipelineRuns processed in parallel by GetAll and GetPages
+func (f *PipelineRunFetcher) SetConcurrency(n int) {
+	f.Concurrency = n
+}
+
+// Kind returns the kind of the resources of the fetcher, the commands only read the PipelineRuns of their files
+func (f *PipelineRunFetcher) Kind() string {
+	return common.KindPipelineRun
+}
+
+// SuppliesTaskStatuses marks the fetcher as a common.StatusFetcher, the Pipelines carry the status of the TaskRuns
+func (f *PipelineRunFetcher) SuppliesTaskStatuses() {}
+
+// SetWarnings sets the writer of the warnings, the live Pipelines aren't compared with the runs without one
+func (f *PipelineRunFetcher) SetWarnings(w io.Writer) {
+	f.Warnings = w
+}
+
+// getPipeline returns the Pipeline by name, each Pipeline is only fetched once
+func (f *PipelineRunFetcher) getPipeline(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+	if f.pipelines == nil {
+		f.pipelines = newPipelineCache(f.GetPipelineByNameFunc)
+	}
+
+	return f.pipelines.Get(ctx, cs, name, namespace)
 }
 
-func (f *PipelineRunFetcher) GetByName(cs *cli.Clients, name, namespace string) (*common.Pipeline, error) {
-	pr, err := f.GetPipelineRunByNameFunc(cs, name, namespace)
+func (f *PipelineRunFetcher) GetByName(ctx context.Context, cs *cli.Clients, name, namespace string) (*common.Pipeline, error) {
+	pr, err := f.GetPipelineRunByNameFunc(ctx, cs, name, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get PipelineRun by name: %w", err)
 	}
 
-	// Fetch the Pipeline that the PipelineRun is based on
-	p, err := f.GetPipelineByNameFunc(cs, pr.Spec.PipelineRef.Name, namespace)
+	p, warning, err := f.pipelineForRun(ctx, cs, pr, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	f.warn(warning)
+
+	statuses, err := taskStatuses(pr, &childRunGetter{
+		taskRun: func(_ *v1.PipelineRun, name string) (*v1.TaskRun, error) {
+			if f.GetTaskRunByNameFunc == nil {
+				return nil, nil
+			}
+
+			return f.GetTaskRunByNameFunc(ctx, cs, name, namespace)
+		},
+		customRun: func(_ *v1.PipelineRun, name string) (*v1beta1.CustomRun, error) {
+			if f.GetCustomRunByNameFunc == nil {
+				return nil, nil
+			}
+
+			return f.GetCustomRunByNameFunc(ctx, cs, name, namespace)
+		},
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get Pipeline by name: %w", err)
+		return nil, err
 	}
 
 	return &common.Pipeline{
 		Name:           name,
+		Namespace:      namespace,
 		TektonPipeline: *p,
+		TaskStatuses:   statuses,
 	}, nil
 }
 
-func (f *PipelineRunFetcher) GetAll(cs *cli.Clients, namespace string) ([]common.Pipeline, error) {
-	prs, err := f.GetAllPipelineRunsFunc(cs, namespace)
+func (f *PipelineRunFetcher) GetAll(ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]common.Pipeline, error) {
+	var cp []common.Pipeline
+
+	err := f.GetPages(ctx, cs, namespace, opts, func(page []common.Pipeline) error {
+		cp = append(cp, page...)
+		return nil
+	})
+
+	var fetchErrs *common.FetchErrors
+	if err != nil && !errors.As(err, &fetchErrs) {
+		return nil, err
+	}
+
+	return cp, err
+}
+
+// GetPages fetches the PipelineRuns page by page, opts.Limit is the size of the pages. fn is called with the Pipelines
+// of every page, in the order of the list. A PipelineRun which can't be fetched doesn't prevent fetching the others,
+// the failures are returned at the end as *common.FetchErrors.
+func (f *PipelineRunFetcher) GetPages(
+	ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions, fn func(page []common.Pipeline) error,
+) error {
+	fetchErrs := &common.FetchErrors{Kind: "PipelineRuns"}
+
+	// the errors of fn are returned as is
+	var pageErr error
+
+	err := f.listPipelineRuns(ctx, cs, namespace, opts, func(prs []v1.PipelineRun) error {
+		var pipelines []common.Pipeline
+
+		pipelines, pageErr = f.fetchPage(ctx, cs, namespace, opts, prs, fetchErrs)
+		if pageErr != nil {
+			return pageErr
+		}
+
+		pageErr = fn(pipelines)
+
+		return pageErr
+	})
+
+	switch {
+	case pageErr != nil:
+		return pageErr
+	case err != nil:
+		return fmt.Errorf("failed to get all PipelineRuns: %w", err)
+	case len(fetchErrs.Errs) > 0:
+		return fetchErrs
+	default:
+		return nil
+	}
+}
+
+// listPipelineRuns lists the PipelineRuns page by page, or all at once if ListPipelineRunsFunc is not set
+func (f *PipelineRunFetcher) listPipelineRuns(
+	ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions, fn func(page []v1.PipelineRun) error,
+) error {
+	if f.ListPipelineRunsFunc != nil {
+		return f.ListPipelineRunsFunc(ctx, cs, namespace, opts, fn)
+	}
+
+	prs, err := f.GetAllPipelineRunsFunc(ctx, cs, namespace, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all PipelineRuns: %w", err)
+		return err
 	}
 
-	// Pre-allocate the cp slice based on the number of pipeline runs
-	cp := make([]common.Pipeline, 0, len(prs))
+	return fn(prs)
+}
+
+// fetchPage fetches the Pipelines and the task statuses of a page of PipelineRuns in parallel.
+// The PipelineRuns which can't be fetched are added to fetchErrs.
+func (f *PipelineRunFetcher) fetchPage(
+	ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions, prs []v1.PipelineRun,
+	fetchErrs *common.FetchErrors,
+) ([]common.Pipeline, error) {
+	// The child runs of the page are listed at once instead of being fetched one by one
+	getter, err := f.listChildRuns(ctx, cs, namespace, opts, prs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Pipeline cache is created before the workers start
+	if f.pipelines == nil {
+		f.pipelines = newPipelineCache(f.GetPipelineByNameFunc)
+	}
+
+	results := make([]runResult, len(prs))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for range max(f.Concurrency, 1) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
 
+			for i := range jobs {
+				results[i] = f.fetchRun(ctx, cs, &prs[i], namespace, getter)
+			}
+		}()
+	}
+
+	// no new PipelineRun is started once ctx is done
+dispatch:
 	for i := range prs {
-		pipeline, err := f.GetPipelineByNameFunc(cs, prs[i].Spec.PipelineRef.Name, namespace)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get Pipeline by name: %w", err)
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
 		}
+	}
 
-		cp = append(cp, common.Pipeline{
-			Name:           prs[i].Name,
-			TektonPipeline: *pipeline,
-		})
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, interruptedError(ctx, results, namespace)
+	}
+
+	// The results are collected in the order of the list, a failed run doesn't prevent graphing the others
+	cp := make([]common.Pipeline, 0, len(prs))
+	fetchErrs.Total += len(prs)
+
+	for i := range results {
+		f.warn(results[i].warning)
+
+		if results[i].err != nil {
+			fetchErrs.Errs = append(fetchErrs.Errs, fmt.Errorf("%s: %w", prs[i].Name, results[i].err))
+			continue
+		}
+
+		cp = append(cp, results[i].pipeline)
 	}
 
 	return cp, nil
 }
+
+// interruptedError returns the first request cut short by ctx, so the error names the resource which timed out
+func interruptedError(ctx context.Context, results []runResult, namespace string) error {
+	var interrupted *request.InterruptedError
+
+	for i := range results {
+		if errors.As(results[i].err, &interrupted) {
+			return interrupted
+		}
+	}
+
+	return request.Interrupted(ctx, ctx.Err(), request.Listing("PipelineRuns", namespace))
+}
+
+// runResult is the outcome of fetching the Pipeline and the statuses of a PipelineRun
+type runResult struct {
+	pipeline common.Pipeline
+	warning  string
+	err      error
+}
+
+// fetchRun returns the Pipeline and the task statuses of the PipelineRun, it is safe for concurrent use
+func (f *PipelineRunFetcher) fetchRun(ctx context.Context, cs *cli.Clients, pr *v1.PipelineRun, namespace string, getter *childRunGetter) runResult {
+	// the namespace is empty when listing all namespaces
+	ns := pr.Namespace
+	if ns == "" {
+		ns = namespace
+	}
+
+	pipeline, warning, err := f.pipelineForRun(ctx, cs, pr, ns)
+	if err != nil {
+		return runResult{err: err}
+	}
+
+	statuses, err := taskStatuses(pr, getter)
+	if err != nil {
+		return runResult{warning: warning, err: err}
+	}
+
+	return runResult{
+		pipeline: common.Pipeline{
+			Name:           pr.Name,
+			Namespace:      ns,
+			TektonPipeline: *pipeline,
+			TaskStatuses:   statuses,
+		},
+		warning: warning,
+	}
+}
+
+// warn writes the warning, if any, to the Warnings writer
+func (f *PipelineRunFetcher) warn(warning string) {
+	if warning != "" && f.Warnings != nil {
+		fmt.Fprintln(f.Warnings, warning)
+	}
+}
+
+// pipelineForRun returns the Pipeline executed by the PipelineRun. The snapshot stored by Tekton in the status
+// is preferred, as the referenced Pipeline may have been changed since, then the embedded pipelineSpec
+// and finally the Pipeline referenced by name. The warning is set when the live Pipeline differs from the snapshot.
+func (f *PipelineRunFetcher) pipelineForRun(ctx context.Context, cs *cli.Clients, pr *v1.PipelineRun, namespace string) (*v1.Pipeline, string, error) {
+	ref := pr.Spec.PipelineRef
+	hasNameRef := ref != nil && ref.Name != "" && ref.Resolver == ""
+
+	switch {
+	case pr.Status.PipelineSpec != nil:
+		p := &v1.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: pr.Name, Namespace: namespace},
+			Spec:       *pr.Status.PipelineSpec,
+		}
+
+		var warning string
+
+		if hasNameRef {
+			p.Name = ref.Name
+			warning = f.changedWarning(ctx, cs, pr, p, namespace)
+		}
+
+		return p, warning, nil
+	case pr.Spec.PipelineSpec != nil:
+		return &v1.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: pr.Name, Namespace: namespace},
+			Spec:       *pr.Spec.PipelineSpec,
+		}, "", nil
+	case hasNameRef:
+		// Fetch the Pipeline that the PipelineRun is based on
+		p, err := f.getPipeline(ctx, cs, ref.Name, namespace)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get Pipeline by name: %w", err)
+		}
+
+		return p, "", nil
+	case ref != nil && ref.Resolver != "":
+		return nil, "", fmt.Errorf("PipelineRun %s references its Pipeline with the %s resolver and %w",
+			pr.Name, ref.Resolver, errNotResolved)
+	default:
+		return nil, "", fmt.Errorf("PipelineRun %s has neither pipelineSpec nor pipelineRef", pr.Name)
+	}
+}
+
+// changedWarning returns a warning when the live Pipeline differs from the snapshot used by the PipelineRun.
+// The check is best effort, e.g. the Pipeline may have been deleted since.
+func (f *PipelineRunFetcher) changedWarning(ctx context.Context, cs *cli.Clients, pr *v1.PipelineRun, snapshot *v1.Pipeline, namespace string) string {
+	if f.Warnings == nil {
+		return ""
+	}
+
+	live, err := f.getPipeline(ctx, cs, snapshot.Name, namespace)
+	if err != nil {
+		return ""
+	}
+
+	// Tekton stores the snapshot with the defaults applied
+	liveSpec := live.Spec.DeepCopy()
+	liveSpec.SetDefaults(ctx)
+	snapshotSpec := snapshot.Spec.DeepCopy()
+	snapshotSpec.SetDefaults(ctx)
+
+	if equality.Semantic.DeepEqual(liveSpec, snapshotSpec) {
+		return ""
+	}
+
+	return fmt.Sprintf("Warning: Pipeline %s has changed since PipelineRun %s was started, "+
+		"the graph shows the Pipeline used by the run", snapshot.Name, pr.Name)
+}
+
+// childRunSelectorSize is the number of PipelineRuns per request listing their child runs, so the label selector
+// naming them stays well below the URL length limits of the proxies in front of the API server
+const childRunSelectorSize = 50
+
+// listChildRuns lists the TaskRuns and CustomRuns of the PipelineRuns and indexes them by namespace and name.
+// Tekton copies the labels of the PipelineRun to its child runs, so the label selector of opts narrows them as well.
+func (f *PipelineRunFetcher) listChildRuns(
+	ctx context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions, prs []v1.PipelineRun,
+) (*childRunGetter, error) {
+	taskRuns := map[types.NamespacedName]*v1.TaskRun{}
+	customRuns := map[types.NamespacedName]*v1beta1.CustomRun{}
+
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector: %w", err)
+	}
+
+	var names []string
+	if f.GetAllTaskRunsFunc != nil || f.GetAllCustomRunsFunc != nil {
+		names = runNames(prs)
+	}
+
+	for chunk := range slices.Chunk(names, childRunSelectorSize) {
+		runs, err := labels.NewRequirement(pipeline.PipelineRunLabelKey, selection.In, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select the child runs: %w", err)
+		}
+
+		childOpts := metav1.ListOptions{LabelSelector: selector.Add(*runs).String(), Limit: opts.Limit}
+
+		if f.GetAllTaskRunsFunc != nil {
+			trs, err := f.GetAllTaskRunsFunc(ctx, cs, namespace, childOpts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get all TaskRuns: %w", err)
+			}
+
+			for i := range trs {
+				taskRuns[types.NamespacedName{Namespace: trs[i].Namespace, Name: trs[i].Name}] = &trs[i]
+			}
+		}
+
+		if f.GetAllCustomRunsFunc != nil {
+			crs, err := f.GetAllCustomRunsFunc(ctx, cs, namespace, childOpts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get all CustomRuns: %w", err)
+			}
+
+			for i := range crs {
+				customRuns[types.NamespacedName{Namespace: crs[i].Namespace, Name: crs[i].Name}] = &crs[i]
+			}
+		}
+	}
+
+	return &childRunGetter{
+		taskRun: func(pr *v1.PipelineRun, name string) (*v1.TaskRun, error) {
+			return taskRuns[types.NamespacedName{Namespace: pr.Namespace, Name: name}], nil
+		},
+		customRun: func(pr *v1.PipelineRun, name string) (*v1beta1.CustomRun, error) {
+			return customRuns[types.NamespacedName{Namespace: pr.Namespace, Name: name}], nil
+		},
+	}, nil
+}
+
+// runNames returns the names of the PipelineRuns without duplicates, runs of different namespaces may have the same name
+func runNames(prs []v1.PipelineRun) []string {
+	names := make([]string, 0, len(prs))
+	seen := make(map[string]bool, len(prs))
+
+	for i := range prs {
+		if !seen[prs[i].Name] {
+			seen[prs[i].Name] = true
+			names = append(names, prs[i].Name)
+		}
+	}
+
+	return names
+}
+
+// childRunGetter returns the child runs of a PipelineRun, nil if the run is not available
+type childRunGetter struct {
+	taskRun   func(pr *v1.PipelineRun, name string) (*v1.TaskRun, error)
+	customRun func(pr *v1.PipelineRun, name string) (*v1beta1.CustomRun, error)
+}
+
+// taskStatuses collects the status of every task of the PipelineRun from its child runs and skipped tasks.
+// Child runs which were already deleted are ignored.
+func taskStatuses(pr *v1.PipelineRun, getter *childRunGetter) (map[string]*taskgraph.TaskStatus, error) {
+	statuses := make(map[string]*taskgraph.TaskStatus)
+
+	for _, child := range pr.Status.ChildReferences {
+		var status *taskgraph.TaskStatus
+
+		switch child.Kind {
+		case kindTaskRun:
+			tr, err := getter.taskRun(pr, child.Name)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get TaskRun %s of PipelineRun %s: %w", child.Name, pr.Name, err)
+			}
+
+			if tr != nil {
+				status = taskgraph.NewTaskStatus(tr.Status.GetCondition(apis.ConditionSucceeded),
+					timeOrNil(tr.Status.StartTime), timeOrNil(tr.Status.CompletionTime))
+			}
+		case kindCustomRun:
+			cr, err := getter.customRun(pr, child.Name)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get CustomRun %s of PipelineRun %s: %w", child.Name, pr.Name, err)
+			}
+
+			if cr != nil {
+				status = taskgraph.NewTaskStatus(cr.Status.GetCondition(apis.ConditionSucceeded),
+					timeOrNil(cr.Status.StartTime), timeOrNil(cr.Status.CompletionTime))
+			}
+		}
+
+		if status == nil {
+			continue
+		}
+
+		// matrix tasks have several child runs
+		if existing, ok := statuses[child.PipelineTaskName]; ok {
+			existing.Merge(status)
+		} else {
+			statuses[child.PipelineTaskName] = status
+		}
+	}
+
+	for _, skipped := range pr.Status.SkippedTasks {
+		statuses[skipped.Name] = &taskgraph.TaskStatus{State: taskgraph.TaskStateSkipped}
+	}
+
+	return statuses, nil
+}
+
+func timeOrNil(t *metav1.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	return &t.Time
+}
@@ -1,17 +1,31 @@
 package pipelinerun
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	common "github.com/sergk/tkn-graph/pkg/cmd/common"
+	"github.com/sergk/tkn-graph/pkg/request"
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
 	"github.com/stretchr/testify/assert"
 	"github.com/tektoncd/cli/pkg/cli"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
 )
 
 func TestGetByName(t *testing.T) {
 	fetcher := &PipelineRunFetcher{
-		GetPipelineRunByNameFunc: func(cs *cli.Clients, name, namespace string) (*v1.PipelineRun, error) {
+		GetPipelineRunByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.PipelineRun, error) {
 			// Return a dummy pipeline run
 			return &v1.PipelineRun{
 				ObjectMeta: metav1.ObjectMeta{
@@ -24,7 +38,7 @@ func TestGetByName(t *testing.T) {
 				},
 			}, nil
 		},
-		GetPipelineByNameFunc: func(cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+		GetPipelineByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
 			// Return a dummy pipeline
 			return &v1.Pipeline{
 				ObjectMeta: metav1.ObjectMeta{
@@ -34,7 +48,7 @@ func TestGetByName(t *testing.T) {
 		},
 	}
 
-	p, err := fetcher.GetByName(nil, "pipelinerun1", "default")
+	p, err := fetcher.GetByName(context.Background(), nil, "pipelinerun1", "default")
 
 	assert.NoError(t, err)
 	assert.Equal(t, "pipelinerun1", p.Name)
@@ -42,7 +56,7 @@ func TestGetByName(t *testing.T) {
 
 func TestGetAll(t *testing.T) {
 	fetcher := &PipelineRunFetcher{
-		GetAllPipelineRunsFunc: func(cs *cli.Clients, namespace string) ([]v1.PipelineRun, error) {
+		GetAllPipelineRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.PipelineRun, error) {
 			// Return a slice of dummy pipeline runs
 			return []v1.PipelineRun{
 				{
@@ -67,7 +81,7 @@ func TestGetAll(t *testing.T) {
 				},
 			}, nil
 		},
-		GetPipelineByNameFunc: func(cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+		GetPipelineByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
 			// Return a dummy pipeline
 			return &v1.Pipeline{
 				ObjectMeta: metav1.ObjectMeta{
@@ -77,10 +91,454 @@ func TestGetAll(t *testing.T) {
 		},
 	}
 
-	ps, err := fetcher.GetAll(nil, "default")
+	ps, err := fetcher.GetAll(context.Background(), nil, "default", metav1.ListOptions{})
 
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(ps))
 	assert.Equal(t, "pipelinerun1", ps[0].Name)
 	assert.Equal(t, "pipelinerun2", ps[1].Name)
 }
+
+func getTestPipelineRunWithChildren(name string) *v1.PipelineRun {
+	return &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.PipelineRunSpec{
+			PipelineRef: &v1.PipelineRef{
+				Name: "pipeline1",
+			},
+		},
+		Status: v1.PipelineRunStatus{
+			PipelineRunStatusFields: v1.PipelineRunStatusFields{
+				ChildReferences: []v1.ChildStatusReference{
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: name + "-build", PipelineTaskName: "build"},
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: name + "-test-0", PipelineTaskName: "test"},
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: name + "-test-1", PipelineTaskName: "test"},
+					{TypeMeta: runtime.TypeMeta{Kind: "CustomRun"}, Name: name + "-wait", PipelineTaskName: "wait"},
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: name + "-deleted", PipelineTaskName: "deleted"},
+				},
+				SkippedTasks: []v1.SkippedTask{
+					{Name: "deploy"},
+				},
+			},
+		},
+	}
+}
+
+func getTestTaskRun(name string, status corev1.ConditionStatus, reason string) v1.TaskRun {
+	start := metav1.NewTime(time.Date(2024, time.January, 2, 10, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(start.Add(time.Minute))
+
+	tr := v1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	tr.Status.StartTime = &start
+	tr.Status.CompletionTime = &end
+	tr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: status, Reason: reason})
+
+	return tr
+}
+
+func getTestTaskRuns(name string) map[string]v1.TaskRun {
+	return map[string]v1.TaskRun{
+		name + "-build":  getTestTaskRun(name+"-build", corev1.ConditionTrue, "Succeeded"),
+		name + "-test-0": getTestTaskRun(name+"-test-0", corev1.ConditionTrue, "Succeeded"),
+		name + "-test-1": getTestTaskRun(name+"-test-1", corev1.ConditionFalse, "Failed"),
+	}
+}
+
+func getTestCustomRun(name string) v1beta1.CustomRun {
+	cr := v1beta1.CustomRun{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	cr.Status.SetCondition(&apis.Condition{
+		Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: "CustomRunTimedOut",
+	})
+
+	return cr
+}
+
+func assertTestStatuses(t *testing.T, statuses map[string]*taskgraph.TaskStatus) {
+	t.Helper()
+
+	assert.Len(t, statuses, 4)
+	assert.Equal(t, taskgraph.TaskStateSucceeded, statuses["build"].State)
+	assert.Equal(t, time.Minute, statuses["build"].Duration())
+	assert.Equal(t, taskgraph.TaskStateFailed, statuses["test"].State)
+	assert.Equal(t, taskgraph.TaskStateTimedOut, statuses["wait"].State)
+	assert.Equal(t, taskgraph.TaskStateSkipped, statuses["deploy"].State)
+}
+
+func TestGetByNameWithStatuses(t *testing.T) {
+	taskRuns := getTestTaskRuns("pipelinerun1")
+	fetcher := &PipelineRunFetcher{
+		GetPipelineRunByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.PipelineRun, error) {
+			return getTestPipelineRunWithChildren(name), nil
+		},
+		GetPipelineByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+			return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+		},
+		GetTaskRunByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.TaskRun, error) {
+			tr, ok := taskRuns[name]
+			if !ok {
+				return nil, apierrors.NewNotFound(v1.Resource("taskrun"), name)
+			}
+
+			return &tr, nil
+		},
+		GetCustomRunByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1beta1.CustomRun, error) {
+			cr := getTestCustomRun(name)
+			return &cr, nil
+		},
+	}
+
+	p, err := fetcher.GetByName(context.Background(), nil, "pipelinerun1", "default")
+
+	assert.NoError(t, err)
+	assertTestStatuses(t, p.TaskStatuses)
+}
+
+func TestGetByNameWithStatusError(t *testing.T) {
+	fetcher := &PipelineRunFetcher{
+		GetPipelineRunByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.PipelineRun, error) {
+			return getTestPipelineRunWithChildren(name), nil
+		},
+		GetPipelineByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+			return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+		},
+		GetTaskRunByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.TaskRun, error) {
+			return nil, errors.New("connection refused")
+		},
+	}
+
+	_, err := fetcher.GetByName(context.Background(), nil, "pipelinerun1", "default")
+
+	assert.EqualError(t, err, "failed to get TaskRun pipelinerun1-build of PipelineRun pipelinerun1: connection refused")
+}
+
+func TestGetAllWithStatuses(t *testing.T) {
+	fetcher := &PipelineRunFetcher{
+		GetAllPipelineRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.PipelineRun, error) {
+			return []v1.PipelineRun{*getTestPipelineRunWithChildren("pipelinerun1")}, nil
+		},
+		GetPipelineByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+			return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+		},
+		GetAllTaskRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.TaskRun, error) {
+			trs := make([]v1.TaskRun, 0)
+			for _, tr := range getTestTaskRuns("pipelinerun1") {
+				trs = append(trs, tr)
+			}
+
+			return trs, nil
+		},
+		GetAllCustomRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1beta1.CustomRun, error) {
+			return []v1beta1.CustomRun{getTestCustomRun("pipelinerun1-wait")}, nil
+		},
+	}
+
+	ps, err := fetcher.GetAll(context.Background(), nil, "default", metav1.ListOptions{})
+
+	assert.NoError(t, err)
+	assert.Len(t, ps, 1)
+	assertTestStatuses(t, ps[0].TaskStatuses)
+}
+
+func getTestPipelineSpec(taskName string) *v1.PipelineSpec {
+	return &v1.PipelineSpec{
+		Tasks: []v1.PipelineTask{
+			{Name: taskName, TaskRef: &v1.TaskRef{Name: "taskRef1"}},
+		},
+	}
+}
+
+func TestGetByNamePipelineSource(t *testing.T) {
+	livePipeline := func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+		return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: *getTestPipelineSpec("live")}, nil
+	}
+
+	testCases := []struct {
+		name         string
+		pipelineRun  *v1.PipelineRun
+		expectedTask string
+		expectedWarn string
+		expectedErr  string
+	}{
+		{
+			name: "status snapshot is preferred",
+			pipelineRun: &v1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run"},
+				Spec:       v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "pipeline1"}},
+				Status: v1.PipelineRunStatus{PipelineRunStatusFields: v1.PipelineRunStatusFields{
+					PipelineSpec: getTestPipelineSpec("snapshot"),
+				}},
+			},
+			expectedTask: "snapshot",
+			expectedWarn: "Warning: Pipeline pipeline1 has changed since PipelineRun run was started, " +
+				"the graph shows the Pipeline used by the run\n",
+		},
+		{
+			name: "unchanged pipeline",
+			pipelineRun: &v1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run"},
+				Spec:       v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "pipeline1"}},
+				Status: v1.PipelineRunStatus{PipelineRunStatusFields: v1.PipelineRunStatusFields{
+					PipelineSpec: getTestPipelineSpec("live"),
+				}},
+			},
+			expectedTask: "live",
+		},
+		{
+			name: "embedded pipelineSpec",
+			pipelineRun: &v1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run"},
+				Spec:       v1.PipelineRunSpec{PipelineSpec: getTestPipelineSpec("embedded")},
+			},
+			expectedTask: "embedded",
+		},
+		{
+			name: "pipelineRef",
+			pipelineRun: &v1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run"},
+				Spec:       v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "pipeline1"}},
+			},
+			expectedTask: "live",
+		},
+		{
+			name: "unresolved resolver",
+			pipelineRun: &v1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run"},
+				Spec: v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{
+					ResolverRef: v1.ResolverRef{Resolver: "git"},
+				}},
+			},
+			expectedErr: "PipelineRun run references its Pipeline with the git resolver and has no resolved pipelineSpec yet",
+		},
+		{
+			name:        "no pipeline",
+			pipelineRun: &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "run"}},
+			expectedErr: "PipelineRun run has neither pipelineSpec nor pipelineRef",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			warnings := new(bytes.Buffer)
+			fetcher := &PipelineRunFetcher{
+				GetPipelineRunByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.PipelineRun, error) {
+					return tc.pipelineRun, nil
+				},
+				GetPipelineByNameFunc: livePipeline,
+				Warnings:              warnings,
+			}
+
+			p, err := fetcher.GetByName(context.Background(), nil, "run", "default")
+
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedTask, p.TektonPipeline.Spec.Tasks[0].Name)
+			assert.Equal(t, tc.expectedWarn, warnings.String())
+		})
+	}
+}
+
+func TestGetAllInAllNamespaces(t *testing.T) {
+	run := func(namespace string) v1.PipelineRun {
+		pr := getTestPipelineRunWithChildren("run")
+		pr.Namespace = namespace
+
+		return *pr
+	}
+
+	taskRun := func(namespace string, status corev1.ConditionStatus, reason string) v1.TaskRun {
+		tr := getTestTaskRun("run-build", status, reason)
+		tr.Namespace = namespace
+
+		return tr
+	}
+
+	var childSelectors []string
+
+	fetcher := &PipelineRunFetcher{
+		GetAllPipelineRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.PipelineRun, error) {
+			assert.Equal(t, metav1.NamespaceAll, namespace)
+			assert.Equal(t, metav1.ListOptions{LabelSelector: "app=shop", FieldSelector: "metadata.name=run"}, opts)
+
+			return []v1.PipelineRun{run("team-a"), run("team-b")}, nil
+		},
+		GetPipelineByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+			return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}, nil
+		},
+		GetAllTaskRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.TaskRun, error) {
+			childSelectors = append(childSelectors, opts.LabelSelector)
+
+			return []v1.TaskRun{
+				taskRun("team-a", corev1.ConditionTrue, "Succeeded"),
+				taskRun("team-b", corev1.ConditionFalse, "Failed"),
+			}, nil
+		},
+	}
+
+	ps, err := fetcher.GetAll(context.Background(), nil, metav1.NamespaceAll, metav1.ListOptions{LabelSelector: "app=shop", FieldSelector: "metadata.name=run"})
+
+	assert.NoError(t, err)
+	assert.Len(t, ps, 2)
+	assert.Equal(t, "team-a", ps[0].Namespace)
+	assert.Equal(t, "team-a", ps[0].TektonPipeline.Namespace)
+	assert.Equal(t, taskgraph.TaskStateSucceeded, ps[0].TaskStatuses["build"].State)
+	assert.Equal(t, "team-b", ps[1].Namespace)
+	assert.Equal(t, taskgraph.TaskStateFailed, ps[1].TaskStatuses["build"].State)
+	// the field selector only applies to the PipelineRuns
+	assert.Equal(t, []string{"app=shop,tekton.dev/pipelineRun in (run)"}, childSelectors)
+}
+
+func TestGetAllConcurrently(t *testing.T) {
+	prs := make([]v1.PipelineRun, 20)
+	for i := range prs {
+		prs[i] = v1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("run-%02d", i)},
+			Spec:       v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: fmt.Sprintf("pipeline-%d", i%3)}},
+		}
+	}
+
+	// the resolver runs can't be graphed until the Pipeline is resolved
+	prs[3].Spec.PipelineRef = &v1.PipelineRef{ResolverRef: v1.ResolverRef{Resolver: "git"}}
+	prs[7].Spec.PipelineRef = &v1.PipelineRef{Name: "missing"}
+
+	var mu sync.Mutex
+
+	calls := map[string]int{}
+
+	fetcher := &PipelineRunFetcher{
+		GetAllPipelineRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.PipelineRun, error) {
+			return prs, nil
+		},
+		GetPipelineByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+			mu.Lock()
+			calls[name]++
+			mu.Unlock()
+
+			if name == "missing" {
+				return nil, errors.New("pipelines.tekton.dev \"missing\" not found")
+			}
+
+			return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+		},
+		Concurrency: 4,
+	}
+
+	ps, err := fetcher.GetAll(context.Background(), nil, "default", metav1.ListOptions{})
+
+	var fetchErrs *common.FetchErrors
+
+	assert.ErrorAs(t, err, &fetchErrs)
+	assert.Equal(t, 20, fetchErrs.Total)
+	assert.EqualError(t, err, "2 of 20 PipelineRuns couldn't be fetched:\n"+
+		"  - run-03: PipelineRun run-03 references its Pipeline with the git resolver and has no resolved pipelineSpec yet\n"+
+		"  - run-07: failed to get Pipeline by name: pipelines.tekton.dev \"missing\" not found")
+
+	// the successful runs are returned in the order of the list
+	assert.Len(t, ps, 18)
+
+	for i := 1; i < len(ps); i++ {
+		assert.Less(t, ps[i-1].Name, ps[i].Name)
+	}
+
+	// each Pipeline is only fetched once
+	assert.Equal(t, map[string]int{"pipeline-0": 1, "pipeline-1": 1, "pipeline-2": 1, "missing": 1}, calls)
+}
+
+func TestGetAllWithTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	fetcher := &PipelineRunFetcher{
+		GetAllPipelineRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.PipelineRun, error) {
+			return []v1.PipelineRun{
+				{ObjectMeta: metav1.ObjectMeta{Name: "run1"}, Spec: v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "slow"}}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "run2"}, Spec: v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "other"}}},
+			}, nil
+		},
+		GetPipelineByNameFunc: func(ctx context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+			// the first lookup hangs until the deadline
+			cancel()
+			<-ctx.Done()
+
+			return nil, &request.InterruptedError{Action: request.Getting("Pipeline", name, namespace), Err: context.DeadlineExceeded}
+		},
+	}
+
+	ps, err := fetcher.GetAll(ctx, nil, "default", metav1.ListOptions{})
+
+	assert.Nil(t, ps)
+	assert.EqualError(t, err, "timed out getting Pipeline slow in namespace default")
+}
+
+func TestGetPages(t *testing.T) {
+	pages := [][]v1.PipelineRun{
+		{*getTestPipelineRunWithChildren("run1"), *getTestPipelineRunWithChildren("run2")},
+		{*getTestPipelineRunWithChildren("run3")},
+	}
+	pages[1][0].Spec.PipelineRef.Name = "missing"
+
+	var childSelectors []string
+
+	fetcher := &PipelineRunFetcher{
+		ListPipelineRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions,
+			fn func(page []v1.PipelineRun) error,
+		) error {
+			assert.Equal(t, int64(2), opts.Limit)
+
+			for _, page := range pages {
+				if err := fn(page); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		GetPipelineByNameFunc: func(_ context.Context, cs *cli.Clients, name, namespace string) (*v1.Pipeline, error) {
+			if name == "missing" {
+				return nil, errors.New("not found")
+			}
+
+			return &v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+		},
+		GetAllTaskRunsFunc: func(_ context.Context, cs *cli.Clients, namespace string, opts metav1.ListOptions) ([]v1.TaskRun, error) {
+			childSelectors = append(childSelectors, opts.LabelSelector)
+			assert.Equal(t, int64(2), opts.Limit)
+
+			return []v1.TaskRun{getTestTaskRun("run1-build", corev1.ConditionTrue, "Succeeded")}, nil
+		},
+	}
+
+	var names [][]string
+
+	err := fetcher.GetPages(context.Background(), nil, "default", metav1.ListOptions{Limit: 2}, func(page []common.Pipeline) error {
+		pageNames := []string{}
+		for _, p := range page {
+			pageNames = append(pageNames, p.Name)
+		}
+
+		names = append(names, pageNames)
+
+		return nil
+	})
+
+	assert.EqualError(t, err, "1 of 3 PipelineRuns couldn't be fetched:\n  - run3: failed to get Pipeline by name: not found")
+	assert.Equal(t, [][]string{{"run1", "run2"}, {}}, names)
+	// the child runs are listed for each page
+	assert.Equal(t, []string{"tekton.dev/pipelineRun in (run1,run2)", "tekton.dev/pipelineRun in (run3)"}, childSelectors)
+
+	// the errors of fn stop the listing
+	stop := errors.New("stop")
+	calls := 0
+
+	err = fetcher.GetPages(context.Background(), nil, "default", metav1.ListOptions{Limit: 2}, func(page []common.Pipeline) error {
+		calls++
+		return stop
+	})
+
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
@@ -4,14 +4,33 @@ import (
 	common "github.com/sergk/tkn-graph/pkg/cmd/common"
 	"github.com/sergk/tkn-graph/pkg/pipeline"
 	"github.com/sergk/tkn-graph/pkg/pipelinerun"
+	"github.com/sergk/tkn-graph/pkg/taskrun"
 	"github.com/spf13/cobra"
 	"github.com/tektoncd/cli/pkg/cli"
 )
 
 func graphCommand(p cli.Params) *cobra.Command {
-	return common.CreateGraphCommand(p, &PipelineRunFetcher{
+	return common.CreateGraphCommand(p, NewFetcher())
+}
+
+func criticalPathCommand(p cli.Params) *cobra.Command {
+	return common.CreateCriticalPathCommand(p, NewFetcher())
+}
+
+// NewFetcher returns the fetcher of the PipelineRuns of the cluster, with the status of their tasks. The commands
+// set its Warnings to their error output.
+func NewFetcher() *PipelineRunFetcher {
+	return &PipelineRunFetcher{
 		GetPipelineRunByNameFunc: pipelinerun.GetPipelineRunsByName,
 		GetAllPipelineRunsFunc:   pipelinerun.GetAllPipelineRuns,
+		ListPipelineRunsFunc:     pipelinerun.ListPipelineRuns,
 		GetPipelineByNameFunc:    pipeline.GetPipelineByName,
-	})
+		GetTaskRunByNameFunc:     taskrun.GetTaskRunByName,
+		GetAllTaskRunsFunc:       taskrun.GetAllTaskRuns,
+		GetCustomRunByNameFunc:   taskrun.GetCustomRunByName,
+		GetAllCustomRunsFunc:     taskrun.GetAllCustomRuns,
+		WatchPipelineRunsFunc:    pipelinerun.WatchPipelineRuns,
+		WatchTaskRunsFunc:        taskrun.WatchTaskRuns,
+		Concurrency:              common.DefaultConcurrency,
+	}
 }
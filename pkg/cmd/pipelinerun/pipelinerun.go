@@ -19,6 +19,7 @@ func Command(p cli.Params) *cobra.Command {
 	flags.AddTektonOptions(cmd)
 	cmd.AddCommand(
 		graphCommand(p),
+		criticalPathCommand(p),
 	)
 
 	return cmd
@@ -0,0 +1,358 @@
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	common "github.com/sergk/tkn-graph/pkg/cmd/common"
+	"github.com/sergk/tkn-graph/pkg/request"
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/tektoncd/cli/pkg/cli"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Watch follows the PipelineRun and its TaskRuns and calls fn with the Pipeline every time the state of one of its
+// tasks changes, until the PipelineRun completes or ctx is done. The graph is first rendered once the Pipeline of
+// the run is known, e.g. after it is resolved, the other errors getting the Pipeline end the watch. timeout limits each
+// request, the watches are only bounded by ctx once they are established.
+func (f *PipelineRunFetcher) Watch(
+	ctx context.Context, cs *cli.Clients, name, namespace string, timeout time.Duration,
+	fn func(pipeline *common.Pipeline) error,
+) error {
+	if f.GetPipelineRunByNameFunc == nil || f.WatchPipelineRunsFunc == nil || f.WatchTaskRunsFunc == nil {
+		return errors.New("watching PipelineRuns is not supported by the fetcher")
+	}
+
+	w := &runWatch{
+		f:         f,
+		cs:        cs,
+		name:      name,
+		namespace: namespace,
+		timeout:   timeout,
+		taskRuns:  map[string]*v1.TaskRun{},
+	}
+
+	// the watch of a PipelineRun which doesn't exist only waits for it to be created
+	if err := w.get(ctx); err != nil {
+		return err
+	}
+
+	if err := w.start(ctx); err != nil {
+		return err
+	}
+	defer w.stop()
+
+	var last string
+
+	warned := false
+
+	for {
+		if err := w.next(ctx); err != nil {
+			return err
+		}
+
+		if w.pr == nil {
+			continue
+		}
+
+		reqCtx, cancel := w.requestContext(ctx)
+		p, warning, err := f.pipelineForRun(reqCtx, cs, w.pr, namespace)
+		cancel()
+
+		if err != nil {
+			// the resolver may not have resolved the Pipeline yet
+			if errors.Is(err, errNotResolved) && !w.pr.IsDone() {
+				continue
+			}
+
+			return err
+		}
+
+		if !warned {
+			f.warn(warning)
+			warned = true
+		}
+
+		statuses, err := taskStatuses(w.pr, w.childRunGetter(ctx))
+		if err != nil {
+			return err
+		}
+
+		if key := stateKey(w.pr, statuses); key != last {
+			last = key
+
+			if err := fn(&common.Pipeline{
+				Name:           name,
+				Namespace:      namespace,
+				TektonPipeline: *p,
+				TaskStatuses:   statuses,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if w.pr.IsDone() {
+			return nil
+		}
+	}
+}
+
+// runWatch holds the watches of a PipelineRun and its TaskRuns, and the latest version of each of them
+type runWatch struct {
+	f         *PipelineRunFetcher
+	cs        *cli.Clients
+	name      string
+	namespace string
+
+	timeout time.Duration
+
+	pipelineRunWatch watch.Interface
+	taskRunWatch     watch.Interface
+	stopPipelineRuns context.CancelFunc
+	stopTaskRuns     context.CancelFunc
+
+	pr       *v1.PipelineRun
+	taskRuns map[string]*v1.TaskRun
+}
+
+// get checks that the PipelineRun exists
+func (w *runWatch) get(ctx context.Context) error {
+	reqCtx, cancel := w.requestContext(ctx)
+	defer cancel()
+
+	if _, err := w.f.GetPipelineRunByNameFunc(reqCtx, w.cs, w.name, w.namespace); err != nil {
+		return fmt.Errorf("failed to get PipelineRun by name: %w", err)
+	}
+
+	return nil
+}
+
+func (w *runWatch) start(ctx context.Context) error {
+	if err := w.startPipelineRunWatch(ctx); err != nil {
+		return err
+	}
+
+	return w.startTaskRunWatch(ctx)
+}
+
+// startPipelineRunWatch (re)starts the watch of the PipelineRun, the current version is sent first
+func (w *runWatch) startPipelineRunWatch(ctx context.Context) error {
+	stopWatch(w.pipelineRunWatch, w.stopPipelineRuns)
+
+	var err error
+
+	w.pipelineRunWatch, w.stopPipelineRuns, err = w.open(ctx, "PipelineRun "+w.name,
+		func(ctx context.Context) (watch.Interface, error) {
+			return w.f.WatchPipelineRunsFunc(ctx, w.cs, w.namespace, metav1.ListOptions{
+				FieldSelector: fields.OneTermEqualSelector("metadata.name", w.name).String(),
+			})
+		})
+
+	return err
+}
+
+// startTaskRunWatch (re)starts the watch of the TaskRuns of the PipelineRun, the existing ones are sent first
+func (w *runWatch) startTaskRunWatch(ctx context.Context) error {
+	stopWatch(w.taskRunWatch, w.stopTaskRuns)
+
+	var err error
+
+	w.taskRunWatch, w.stopTaskRuns, err = w.open(ctx, "TaskRuns of PipelineRun "+w.name,
+		func(ctx context.Context) (watch.Interface, error) {
+			return w.f.WatchTaskRunsFunc(ctx, w.cs, w.namespace, metav1.ListOptions{
+				LabelSelector: pipeline.PipelineRunLabelKey + "=" + w.name,
+			})
+		})
+
+	return err
+}
+
+// open establishes a watch within the timeout of a request. The watch is bound to its own context, which lasts until
+// the returned func is called or ctx is done, so the timeout doesn't end a watch which is established already.
+func (w *runWatch) open(
+	ctx context.Context, kind string, watchFunc func(ctx context.Context) (watch.Interface, error),
+) (watch.Interface, context.CancelFunc, error) {
+	watchCtx, cancel := context.WithCancel(ctx)
+
+	var timer *time.Timer
+	if w.timeout > 0 {
+		timer = time.AfterFunc(w.timeout, cancel)
+	}
+
+	wi, err := watchFunc(watchCtx)
+
+	// the timer already cancelled the watch if it can't be stopped
+	if timer != nil && !timer.Stop() {
+		stopWatch(wi, cancel)
+		return nil, nil, &request.InterruptedError{
+			Action: request.Watching(kind, w.namespace),
+			Err:    context.DeadlineExceeded,
+		}
+	}
+
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	return wi, cancel, nil
+}
+
+// requestContext returns the context of a single request of the watch, with the deadline of the timeout if it is set
+func (w *runWatch) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if w.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, w.timeout)
+}
+
+func (w *runWatch) stop() {
+	stopWatch(w.pipelineRunWatch, w.stopPipelineRuns)
+	stopWatch(w.taskRunWatch, w.stopTaskRuns)
+}
+
+// stopWatch stops the watch and cancels its context, both may be nil
+func stopWatch(wi watch.Interface, cancel context.CancelFunc) {
+	if wi != nil {
+		wi.Stop()
+	}
+
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// next waits for the next event, then applies all the pending ones so a burst of events is rendered once
+func (w *runWatch) next(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return request.Interrupted(ctx, ctx.Err(), request.Watching("PipelineRun "+w.name, w.namespace))
+	case event, ok := <-w.pipelineRunWatch.ResultChan():
+		if err := w.applyPipelineRunEvent(ctx, event, ok); err != nil {
+			return err
+		}
+	case event, ok := <-w.taskRunWatch.ResultChan():
+		if err := w.applyTaskRunEvent(ctx, event, ok); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-w.pipelineRunWatch.ResultChan():
+			if err := w.applyPipelineRunEvent(ctx, event, ok); err != nil {
+				return err
+			}
+		case event, ok := <-w.taskRunWatch.ResultChan():
+			if err := w.applyTaskRunEvent(ctx, event, ok); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// applyPipelineRunEvent updates the PipelineRun, the watch is restarted when the API server closes or expires it
+func (w *runWatch) applyPipelineRunEvent(ctx context.Context, event watch.Event, ok bool) error {
+	if !ok {
+		return w.startPipelineRunWatch(ctx)
+	}
+
+	switch event.Type {
+	case watch.Error:
+		if err := watchError(event); err != nil {
+			return fmt.Errorf("failed to watch PipelineRun %s: %w", w.name, err)
+		}
+
+		return w.startPipelineRunWatch(ctx)
+	case watch.Deleted:
+		return fmt.Errorf("PipelineRun %s was deleted", w.name)
+	}
+
+	if pr, isRun := event.Object.(*v1.PipelineRun); isRun {
+		w.pr = pr
+	}
+
+	return nil
+}
+
+// applyTaskRunEvent updates the TaskRuns, the watch is restarted when the API server closes or expires it
+func (w *runWatch) applyTaskRunEvent(ctx context.Context, event watch.Event, ok bool) error {
+	if !ok {
+		return w.startTaskRunWatch(ctx)
+	}
+
+	if event.Type == watch.Error {
+		if err := watchError(event); err != nil {
+			return fmt.Errorf("failed to watch the TaskRuns of PipelineRun %s: %w", w.name, err)
+		}
+
+		return w.startTaskRunWatch(ctx)
+	}
+
+	tr, isRun := event.Object.(*v1.TaskRun)
+	if !isRun {
+		return nil
+	}
+
+	if event.Type == watch.Deleted {
+		delete(w.taskRuns, tr.Name)
+	} else {
+		w.taskRuns[tr.Name] = tr
+	}
+
+	return nil
+}
+
+// childRunGetter returns the TaskRuns received from the watch, the CustomRuns are fetched by name
+func (w *runWatch) childRunGetter(ctx context.Context) *childRunGetter {
+	return &childRunGetter{
+		taskRun: func(_ *v1.PipelineRun, name string) (*v1.TaskRun, error) {
+			return w.taskRuns[name], nil
+		},
+		customRun: func(_ *v1.PipelineRun, name string) (*v1beta1.CustomRun, error) {
+			if w.f.GetCustomRunByNameFunc == nil {
+				return nil, nil
+			}
+
+			reqCtx, cancel := w.requestContext(ctx)
+			defer cancel()
+
+			return w.f.GetCustomRunByNameFunc(reqCtx, w.cs, name, w.namespace)
+		},
+	}
+}
+
+// watchError returns the error of an error event, nil if the watch expired and has to be restarted
+func watchError(event watch.Event) error {
+	err := apierrors.FromObject(event.Object)
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		return nil
+	}
+
+	return err
+}
+
+// stateKey identifies the state of the run and of its tasks, the graph is only rendered again when it changes
+func stateKey(pr *v1.PipelineRun, statuses map[string]*taskgraph.TaskStatus) string {
+	states := make([]string, 0, len(statuses)+1)
+	for name, status := range statuses {
+		states = append(states, name+"="+string(status.State))
+	}
+
+	sort.Strings(states)
+
+	return fmt.Sprintf("%t;%s", pr.IsDone(), strings.Join(states, ","))
+}
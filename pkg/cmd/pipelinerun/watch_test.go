@@ -0,0 +1,304 @@
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	common "github.com/sergk/tkn-graph/pkg/cmd/common"
+	"github.com/sergk/tkn-graph/pkg/request"
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tektoncd/cli/pkg/cli"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"knative.dev/pkg/apis"
+)
+
+func getTestWatchedRun(status corev1.ConditionStatus) *v1.PipelineRun {
+	pr := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run"},
+		Spec:       v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "pipeline1"}},
+	}
+	pr.Status.PipelineSpec = getTestPipelineSpec("build")
+	pr.Status.ChildReferences = []v1.ChildStatusReference{
+		{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "run-build", PipelineTaskName: "build"},
+	}
+	pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: status})
+
+	return pr
+}
+
+// getTestWatchFetcher returns a fetcher whose watches are served by the fake watchers, their channels are buffered so
+// the events can be sent before they are received. Only the PipelineRun run exists, there are no Pipelines.
+func getTestWatchFetcher(prWatch, trWatch watch.Interface) *PipelineRunFetcher {
+	return &PipelineRunFetcher{
+		GetPipelineRunByNameFunc: func(_ context.Context, _ *cli.Clients, name, _ string) (*v1.PipelineRun, error) {
+			if name != "run" {
+				return nil, apierrors.NewNotFound(v1.Resource("pipelineruns"), name)
+			}
+
+			return getTestWatchedRun(corev1.ConditionUnknown), nil
+		},
+		GetPipelineByNameFunc: func(_ context.Context, _ *cli.Clients, name, _ string) (*v1.Pipeline, error) {
+			return nil, apierrors.NewNotFound(v1.Resource("pipelines"), name)
+		},
+		WatchPipelineRunsFunc: func(_ context.Context, _ *cli.Clients, _ string, opts metav1.ListOptions) (watch.Interface, error) {
+			if opts.FieldSelector != "metadata.name=run" {
+				panic("unexpected field selector " + opts.FieldSelector)
+			}
+
+			return prWatch, nil
+		},
+		WatchTaskRunsFunc: func(_ context.Context, _ *cli.Clients, _ string, opts metav1.ListOptions) (watch.Interface, error) {
+			if opts.LabelSelector != "tekton.dev/pipelineRun=run" {
+				panic("unexpected label selector " + opts.LabelSelector)
+			}
+
+			return trWatch, nil
+		},
+	}
+}
+
+func TestWatch(t *testing.T) {
+	prWatch := watch.NewFakeWithChanSize(10, false)
+	trWatch := watch.NewFakeWithChanSize(10, false)
+	fetcher := getTestWatchFetcher(prWatch, trWatch)
+
+	running := getTestTaskRun("run-build", corev1.ConditionUnknown, "Running")
+	succeeded := getTestTaskRun("run-build", corev1.ConditionTrue, "Succeeded")
+
+	prWatch.Add(getTestWatchedRun(corev1.ConditionUnknown))
+	trWatch.Add(&running)
+
+	var states []taskgraph.TaskState
+
+	// every render triggers the next change of the run
+	err := fetcher.Watch(context.Background(), nil, "run", "default", 0, func(p *common.Pipeline) error {
+		assert.Equal(t, "run", p.Name)
+		assert.Equal(t, "build", p.TektonPipeline.Spec.Tasks[0].Name)
+
+		states = append(states, p.TaskStatuses["build"].State)
+
+		switch len(states) {
+		case 1:
+			// the same state again doesn't render the graph
+			trWatch.Modify(&running)
+			trWatch.Modify(&succeeded)
+		case 2:
+			prWatch.Modify(getTestWatchedRun(corev1.ConditionTrue))
+		}
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []taskgraph.TaskState{
+		taskgraph.TaskStateRunning, taskgraph.TaskStateSucceeded, taskgraph.TaskStateSucceeded,
+	}, states)
+}
+
+func TestWatchRestartsExpiredWatch(t *testing.T) {
+	prWatch := watch.NewFakeWithChanSize(10, false)
+	trWatch := watch.NewFakeWithChanSize(10, false)
+	fetcher := getTestWatchFetcher(prWatch, trWatch)
+
+	restarted := watch.NewFakeWithChanSize(10, false)
+	watchTaskRuns := fetcher.WatchTaskRunsFunc
+	fetcher.WatchTaskRunsFunc = func(ctx context.Context, cs *cli.Clients, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+		w, err := watchTaskRuns(ctx, cs, ns, opts)
+		watchTaskRuns = func(context.Context, *cli.Clients, string, metav1.ListOptions) (watch.Interface, error) {
+			return restarted, nil
+		}
+
+		return w, err
+	}
+
+	succeeded := getTestTaskRun("run-build", corev1.ConditionTrue, "Succeeded")
+
+	trWatch.Error(&apierrors.NewResourceExpired("too old resource version").ErrStatus)
+	restarted.Add(&succeeded)
+	prWatch.Add(getTestWatchedRun(corev1.ConditionTrue))
+
+	var state taskgraph.TaskState
+
+	err := fetcher.Watch(context.Background(), nil, "run", "default", 0, func(p *common.Pipeline) error {
+		state = p.TaskStatuses["build"].State
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, taskgraph.TaskStateSucceeded, state)
+}
+
+func TestWatchErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		send        func(prWatch, trWatch *watch.FakeWatcher)
+		expectedErr string
+	}{
+		{
+			name: "deleted PipelineRun",
+			send: func(prWatch, _ *watch.FakeWatcher) {
+				prWatch.Add(getTestWatchedRun(corev1.ConditionUnknown))
+				prWatch.Delete(getTestWatchedRun(corev1.ConditionUnknown))
+			},
+			expectedErr: "PipelineRun run was deleted",
+		},
+		{
+			name: "forbidden",
+			send: func(_, trWatch *watch.FakeWatcher) {
+				trWatch.Error(&apierrors.NewForbidden(v1.Resource("taskruns"), "", errors.New("no access")).ErrStatus)
+			},
+			expectedErr: "failed to watch the TaskRuns of PipelineRun run: taskruns.tekton.dev is forbidden: no access",
+		},
+		{
+			name: "missing Pipeline",
+			send: func(prWatch, _ *watch.FakeWatcher) {
+				pr := getTestWatchedRun(corev1.ConditionUnknown)
+				pr.Status.PipelineSpec = nil
+				prWatch.Add(pr)
+			},
+			expectedErr: `failed to get Pipeline by name: pipelines.tekton.dev "pipeline1" not found`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prWatch := watch.NewFakeWithChanSize(10, false)
+			trWatch := watch.NewFakeWithChanSize(10, false)
+			tc.send(prWatch, trWatch)
+
+			err := getTestWatchFetcher(prWatch, trWatch).Watch(context.Background(), nil, "run", "default", 0,
+				func(*common.Pipeline) error { return nil })
+
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestWatchNotFound(t *testing.T) {
+	fetcher := getTestWatchFetcher(nil, nil)
+	fetcher.WatchPipelineRunsFunc = func(context.Context, *cli.Clients, string, metav1.ListOptions) (watch.Interface, error) {
+		panic("the PipelineRun must not be watched")
+	}
+
+	err := fetcher.Watch(context.Background(), nil, "unknown", "default", 0, func(*common.Pipeline) error { return nil })
+
+	assert.EqualError(t, err, `failed to get PipelineRun by name: pipelineruns.tekton.dev "unknown" not found`)
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestWatchWaitsForResolver(t *testing.T) {
+	prWatch := watch.NewFakeWithChanSize(10, false)
+	trWatch := watch.NewFakeWithChanSize(10, false)
+	fetcher := getTestWatchFetcher(prWatch, trWatch)
+
+	unresolved := getTestWatchedRun(corev1.ConditionUnknown)
+	unresolved.Spec.PipelineRef = &v1.PipelineRef{ResolverRef: v1.ResolverRef{Resolver: "git"}}
+	unresolved.Status.PipelineSpec = nil
+	resolved := getTestWatchedRun(corev1.ConditionTrue)
+	resolved.Spec.PipelineRef = unresolved.Spec.PipelineRef
+
+	// the resolver resolves the Pipeline after the first version of the run is received
+	prWatch.Add(unresolved)
+	time.AfterFunc(20*time.Millisecond, func() {
+		prWatch.Modify(resolved)
+	})
+
+	renders := 0
+
+	err := fetcher.Watch(context.Background(), nil, "run", "default", 0, func(p *common.Pipeline) error {
+		renders++
+
+		assert.Equal(t, "build", p.TektonPipeline.Spec.Tasks[0].Name)
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, renders)
+}
+
+func TestWatchCancelled(t *testing.T) {
+	fetcher := getTestWatchFetcher(watch.NewFake(), watch.NewFake())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := fetcher.Watch(ctx, nil, "run", "default", 0, func(*common.Pipeline) error { return nil })
+
+	var interrupted *request.InterruptedError
+	require.ErrorAs(t, err, &interrupted)
+	assert.EqualError(t, err, "cancelled watching PipelineRun run in namespace default")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWatchLongerThanRequestTimeout(t *testing.T) {
+	prWatch := watch.NewFakeWithChanSize(10, false)
+	trWatch := watch.NewFakeWithChanSize(10, false)
+	fetcher := getTestWatchFetcher(prWatch, trWatch)
+
+	var watchCtx context.Context
+
+	watchPipelineRuns := fetcher.WatchPipelineRunsFunc
+	fetcher.WatchPipelineRunsFunc = func(ctx context.Context, cs *cli.Clients, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+		watchCtx = ctx
+		return watchPipelineRuns(ctx, cs, ns, opts)
+	}
+
+	running := getTestTaskRun("run-build", corev1.ConditionUnknown, "Running")
+	succeeded := getTestTaskRun("run-build", corev1.ConditionTrue, "Succeeded")
+
+	prWatch.Add(getTestWatchedRun(corev1.ConditionUnknown))
+	trWatch.Add(&running)
+
+	renders := 0
+
+	// the run completes after several request timeouts, the established watches are kept
+	err := fetcher.Watch(context.Background(), nil, "run", "default", 10*time.Millisecond, func(*common.Pipeline) error {
+		renders++
+
+		if renders == 1 {
+			time.AfterFunc(50*time.Millisecond, func() {
+				trWatch.Modify(&succeeded)
+				prWatch.Modify(getTestWatchedRun(corev1.ConditionTrue))
+			})
+		}
+
+		assert.NoError(t, watchCtx.Err())
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, renders)
+}
+
+func TestWatchRequestTimeout(t *testing.T) {
+	fetcher := getTestWatchFetcher(watch.NewFake(), nil)
+
+	// the watch of the TaskRuns is never established
+	fetcher.WatchTaskRunsFunc = func(ctx context.Context, _ *cli.Clients, ns string, _ metav1.ListOptions) (watch.Interface, error) {
+		<-ctx.Done()
+		return nil, request.Interrupted(ctx, ctx.Err(), request.Watching("TaskRuns", ns))
+	}
+
+	err := fetcher.Watch(context.Background(), nil, "run", "default", 10*time.Millisecond,
+		func(*common.Pipeline) error { return nil })
+
+	assert.EqualError(t, err, "timed out watching TaskRuns of PipelineRun run in namespace default")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWatchNotSupported(t *testing.T) {
+	err := (&PipelineRunFetcher{}).Watch(context.Background(), nil, "run", "default", 0, nil)
+
+	assert.EqualError(t, err, "watching PipelineRuns is not supported by the fetcher")
+}
@@ -0,0 +1,149 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sergk/tkn-graph/pkg/cli/prerun"
+	"github.com/sergk/tkn-graph/pkg/config"
+	"github.com/sergk/tkn-graph/pkg/manifest"
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/spf13/cobra"
+)
+
+// Options holds the options for the render command
+// Inputs: the graphs exported with the json or yaml output format, - for stdin
+// OutputFormat: dot, puml, mmd, json, yaml, svg, png, html
+// OutputDir: the directory to save the output files. Otherwise, the output is printed to the screen
+// WithTaskRef: Include TaskRefName information in the output
+// Template, TemplateDir: user text/template for every format, or a directory of <format>.tmpl templates
+// Theme: built-in theme, theme of the config files or YAML theme file styling the dot, puml and mmd output formats
+type Options struct {
+	Inputs       []string
+	OutputFormat string
+	OutputDir    string
+	WithTaskRef  bool
+	Template     string
+	TemplateDir  string
+	Theme        string
+
+	templates *taskgraph.Templates
+	theme     *taskgraph.Theme
+}
+
+// Command returns the render command, it converts previously exported graphs without accessing the cluster
+func Command() *cobra.Command {
+	opts := &Options{}
+	c := &cobra.Command{
+		Use:   "render",
+		Short: "Renders graphs exported in the json or yaml format",
+		Example: `  # Render an exported graph as Mermaid
+  tkn-graph render --input graph.json --output-format mmd`,
+		Annotations: map[string]string{
+			"commandType": "main",
+		},
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(config.DefaultSources())
+			if err != nil {
+				return err
+			}
+
+			if err := cfg.Apply(cmd, ""); err != nil {
+				return err
+			}
+
+			if err := prerun.ValidateGraphPreRunE(opts.OutputFormat); err != nil {
+				return err
+			}
+
+			opts.templates, err = prerun.LoadTemplates(opts.Template, opts.TemplateDir, opts.OutputFormat)
+			if err != nil {
+				return err
+			}
+
+			opts.theme, err = prerun.LoadTheme(opts.Theme, cfg.Themes)
+
+			return err
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Run(opts, cmd.InOrStdin())
+		},
+	}
+
+	c.Flags().StringSliceVarP(
+		&opts.Inputs, "input", "i", nil, "files with graphs exported in the json or yaml format, use - for stdin")
+	prerun.AddOutputFormatFlag(c, &opts.OutputFormat)
+	c.Flags().StringVar(
+		&opts.OutputDir, "output-dir", "", "the directory to save the output files. Otherwise, the output is printed to the screen")
+	c.Flags().BoolVar(
+		&opts.WithTaskRef, "with-task-ref", false, "Include TaskRefName information in the output")
+	prerun.AddTemplateFlags(c, &opts.Template, &opts.TemplateDir)
+	prerun.AddThemeFlag(c, &opts.Theme)
+
+	_ = c.MarkFlagRequired("input")
+
+	return c
+}
+
+// Run reads the graphs from all inputs and renders them in the requested format
+func Run(opts *Options, stdin io.Reader) error {
+	var graphs []*taskgraph.TaskGraph
+
+	for _, input := range opts.Inputs {
+		g, err := readGraphs(input, stdin)
+		if err != nil {
+			return err
+		}
+
+		graphs = append(graphs, g...)
+	}
+
+	if len(graphs) == 0 {
+		return fmt.Errorf("no graphs found in the input")
+	}
+
+	if opts.OutputDir != "" {
+		if err := taskgraph.WriteAllGraphs(graphs, opts.OutputFormat, opts.OutputDir, opts.renderOptions(), opts.templates); err != nil {
+			return fmt.Errorf("failed to save graph: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := taskgraph.PrintAllGraphs(graphs, opts.OutputFormat, opts.renderOptions(), opts.templates); err != nil {
+		return fmt.Errorf("failed to print graph: %w", err)
+	}
+
+	return nil
+}
+
+func (opts *Options) renderOptions() taskgraph.RenderOptions {
+	return taskgraph.RenderOptions{WithTaskRef: opts.WithTaskRef, Theme: opts.theme}
+}
+
+func readGraphs(input string, stdin io.Reader) ([]*taskgraph.TaskGraph, error) {
+	if input == manifest.Stdin {
+		graphs, err := taskgraph.ReadGraphs(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+
+		return graphs, nil
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", input, err)
+	}
+	defer f.Close()
+
+	graphs, err := taskgraph.ReadGraphs(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", input, err)
+	}
+
+	return graphs, nil
+}
@@ -0,0 +1,94 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sergk/tkn-graph/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGraph = `{
+  "apiVersion": "tkn-graph/v1",
+  "kind": "TaskGraph",
+  "metadata": {"name": "pipeline1"},
+  "nodes": [
+    {"name": "build", "kind": "task", "taskRef": {"kind": "Task", "name": "buildah"}},
+    {"name": "deploy", "kind": "task", "taskRef": {"kind": "Task", "name": "helm"}}
+  ],
+  "edges": [{"from": "build", "to": "deploy", "kind": "result"}]
+}`
+
+func TestRenderCommand(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "graph.json")
+	require.NoError(t, os.WriteFile(input, []byte(testGraph), 0600))
+
+	outputDir := filepath.Join(dir, "out")
+	_, err := test.ExecuteCommand(Command(), "--input", input, "--output-format", "mmd", "--output-dir", outputDir, "--with-task-ref")
+	require.NoError(t, err)
+
+	output, err := os.ReadFile(filepath.Join(outputDir, "pipeline1.mmd"))
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "title: pipeline1\n")
+	assert.Contains(t, string(output), "   build(\"build\n   (buildah)\") -.-> deploy(\"deploy\n   (helm)\")\n")
+}
+
+func TestRenderCommandFromStdin(t *testing.T) {
+	outputDir := t.TempDir()
+
+	cmd := Command()
+	cmd.SetIn(strings.NewReader(testGraph))
+
+	_, err := test.ExecuteCommand(cmd, "-i", "-", "--output-format", "dot", "--output-dir", outputDir)
+	require.NoError(t, err)
+
+	output, err := os.ReadFile(filepath.Join(outputDir, "pipeline1.dot"))
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "   \"build\" -> \"deploy\" [style=\"dashed\"]\n")
+}
+
+func TestRenderCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.yaml")
+	require.NoError(t, os.WriteFile(empty, []byte("---\n"), 0600))
+
+	testCases := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{
+			name:        "missing input",
+			args:        []string{},
+			expectedErr: `required flag(s) "input" not set`,
+		},
+		{
+			name:        "invalid output format",
+			args:        []string{"-i", empty, "--output-format", "gif"},
+			expectedErr: "Invalid output format: gif. Allowed formats are: [dot puml mmd json yaml svg png html ascii unicode]",
+		},
+		{
+			name:        "file not found",
+			args:        []string{"-i", filepath.Join(dir, "missing.json")},
+			expectedErr: "failed to open " + filepath.Join(dir, "missing.json"),
+		},
+		{
+			name:        "no graphs",
+			args:        []string{"-i", empty},
+			expectedErr: "no graphs found in the input",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := test.ExecuteCommand(Command(), tc.args...)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
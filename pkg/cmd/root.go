@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sergk/tkn-graph/pkg/cmd/common"
 	"github.com/sergk/tkn-graph/pkg/cmd/completion"
+	"github.com/sergk/tkn-graph/pkg/cmd/config"
 	"github.com/sergk/tkn-graph/pkg/cmd/pipeline"
 	"github.com/sergk/tkn-graph/pkg/cmd/pipelinerun"
+	"github.com/sergk/tkn-graph/pkg/cmd/render"
+	"github.com/sergk/tkn-graph/pkg/cmd/serve"
 	"github.com/sergk/tkn-graph/pkg/cmd/version"
 	"github.com/spf13/cobra"
 	"github.com/tektoncd/cli/pkg/cli"
@@ -44,13 +53,31 @@ func Root(p cli.Params) *cobra.Command {
 		SilenceUsage: true,
 	}
 	cmd.SetUsageTemplate(usageTemplate)
+	common.AddRequestTimeoutFlag(cmd)
 
 	cmd.AddCommand(
 		pipeline.Command(p),
 		pipelinerun.Command(p),
+		render.Command(),
+		serve.Command(p),
 		version.Command(),
 		completion.Command(),
+		config.Command(),
 	)
 
 	return cmd
 }
+
+// Execute runs the command, the requests to the cluster are cancelled on Ctrl-C or SIGTERM.
+// A second signal terminates the process right away.
+func Execute(cmd *cobra.Command) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return cmd.ExecuteContext(ctx)
+}
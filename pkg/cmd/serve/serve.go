@@ -0,0 +1,24 @@
+package serve
+
+import (
+	common "github.com/sergk/tkn-graph/pkg/cmd/common"
+	"github.com/sergk/tkn-graph/pkg/cmd/pipeline"
+	"github.com/sergk/tkn-graph/pkg/cmd/pipelinerun"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/cli/pkg/cli"
+)
+
+// Command returns the serve command, it serves the graphs of the Pipelines and PipelineRuns of the cluster over HTTP
+func Command(p cli.Params) *cobra.Command {
+	return common.CreateServeCommand(p, pipelineFetcher, pipelineRunFetcher)
+}
+
+func pipelineFetcher() common.GraphFetcher {
+	return pipeline.NewFetcher()
+}
+
+// pipelineRunFetcher returns the fetcher of the PipelineRuns without warnings, the server doesn't report the
+// changes of the live Pipelines since the runs, which saves a request per run
+func pipelineRunFetcher() common.GraphFetcher {
+	return pipelinerun.NewFetcher()
+}
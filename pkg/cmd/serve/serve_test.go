@@ -0,0 +1,177 @@
+package serve
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	common "github.com/sergk/tkn-graph/pkg/cmd/common"
+	"github.com/sergk/tkn-graph/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	fakeclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+const namespace = "ci"
+
+// getTestParams returns the params of a fake cluster with a Pipeline and a PipelineRun of it, whose first task
+// succeeded and second task failed
+func getTestParams(t *testing.T) *test.Params {
+	t.Helper()
+
+	pipeline := &v1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: namespace},
+		Spec: v1.PipelineSpec{
+			Tasks: []v1.PipelineTask{
+				{Name: "clone", TaskRef: &v1.TaskRef{Name: "git-clone"}},
+				{Name: "test", TaskRef: &v1.TaskRef{Name: "go-test"}, RunAfter: []string{"clone"}},
+			},
+		},
+	}
+
+	run := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "build-42", Namespace: namespace},
+		Spec:       v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "build"}},
+		Status: v1.PipelineRunStatus{
+			PipelineRunStatusFields: v1.PipelineRunStatusFields{
+				PipelineSpec: &pipeline.Spec,
+				ChildReferences: []v1.ChildStatusReference{
+					{TypeMeta: runtimeTypeMeta("TaskRun"), Name: "build-42-clone", PipelineTaskName: "clone"},
+					{TypeMeta: runtimeTypeMeta("TaskRun"), Name: "build-42-test", PipelineTaskName: "test"},
+				},
+			},
+		},
+	}
+
+	return &test.Params{Tekton: fakeclient.NewSimpleClientset(pipeline, run,
+		getTestTaskRun("build-42-clone", corev1.ConditionTrue), getTestTaskRun("build-42-test", corev1.ConditionFalse))}
+}
+
+func runtimeTypeMeta(kind string) runtime.TypeMeta {
+	return runtime.TypeMeta{APIVersion: "tekton.dev/v1", Kind: kind}
+}
+
+func getTestTaskRun(name string, succeeded corev1.ConditionStatus) *v1.TaskRun {
+	return &v1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status: v1.TaskRunStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: succeeded}},
+			},
+		},
+	}
+}
+
+// getTestServer serves the graphs of the fake cluster with the fetchers of the serve command
+func getTestServer(t *testing.T, p *test.Params) *httptest.Server {
+	t.Helper()
+
+	p.SetNamespace(namespace)
+
+	cs, err := p.Clients()
+	require.NoError(t, err)
+
+	server := httptest.NewServer(common.NewServer(p, cs, pipelineFetcher, pipelineRunFetcher,
+		&common.ServeOptions{OutputFormat: "svg", CacheTTL: time.Minute}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func get(t *testing.T, url string, header ...string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, string(body)
+}
+
+func TestServePipelines(t *testing.T) {
+	server := getTestServer(t, getTestParams(t))
+
+	resp, body := get(t, server.URL+"/namespaces/ci/pipelines/build.mmd?with-task-ref=true")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, "flowchart TD")
+	assert.Contains(t, body, "git-clone")
+
+	resp, body = get(t, server.URL+"/namespaces/ci/pipelines/build")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, "image/svg+xml", resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, "<svg")
+
+	resp, _ = get(t, server.URL+"/namespaces/ci/pipelines/build", "If-None-Match", resp.Header.Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, body = get(t, server.URL+"/namespaces/team-a/pipelines/build.dot")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, body, "not found")
+}
+
+func TestServePipelineRuns(t *testing.T) {
+	server := getTestServer(t, getTestParams(t))
+
+	// the tasks are colored by their state
+	resp, body := get(t, server.URL+"/pipelineruns/build-42.dot")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, `"clone" [style="filled" fillcolor="#b7e1cd"`)
+	assert.Contains(t, body, `"test" [style="filled" fillcolor="#f4c7c3"`)
+
+	resp, body = get(t, server.URL+"/namespaces/ci/pipelineruns/build-42.json")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, `"Failed"`)
+
+	resp, body = get(t, server.URL+"/pipelineruns/build-42.dot?highlight-critical-path")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	resp, _ = get(t, server.URL+"/pipelineruns/build-43.svg")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServeCachedGraphs(t *testing.T) {
+	p := getTestParams(t)
+	server := getTestServer(t, p)
+
+	resp, body := get(t, server.URL+"/pipelines/build.mmd")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	// the changes of the cluster are served once the cached graph expires
+	pipeline, err := p.Tekton.TektonV1().Pipelines(namespace).Get(context.Background(), "build", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	pipeline.Spec.Tasks = append(pipeline.Spec.Tasks, v1.PipelineTask{Name: "deploy", RunAfter: []string{"test"}})
+	_, err = p.Tekton.TektonV1().Pipelines(namespace).Update(context.Background(), pipeline, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	_, cached := get(t, server.URL+"/pipelines/build.mmd")
+	assert.Equal(t, body, cached)
+
+	p.Time().(clockwork.FakeClock).Advance(time.Minute)
+
+	resp, updated := get(t, server.URL+"/pipelines/build.mmd", "If-None-Match", resp.Header.Get("ETag"))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, updated, "deploy")
+}
@@ -0,0 +1,444 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sergk/tkn-graph/pkg/taskgraph"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// RepoFile is the name of the repository configuration file, it is searched from the working directory up to the
+// root of the git repository
+const RepoFile = ".tkn-graph.yaml"
+
+// EnvPrefix is the prefix of the environment variables setting the options, e.g. TKN_GRAPH_OUTPUT_FORMAT=mmd
+const EnvPrefix = "TKN_GRAPH_"
+
+// clusterOptions are the options used to connect to the cluster before the namespace is known, they can't be set
+// per namespace
+var clusterOptions = map[string]bool{
+	"namespace":      true,
+	"all-namespaces": true,
+	"kubeconfig":     true,
+	"context":        true,
+	"qps":            true,
+	"burst":          true,
+	"filename":       true,
+}
+
+// Sources are the locations the configuration is read from, an empty location is skipped
+// UserFile: the configuration file of the user, $XDG_CONFIG_HOME/tkn-graph/config.yaml
+// WorkDir: the directory the repository configuration file is searched from
+// Environ: the environment variables, in the form key=value
+type Sources struct {
+	UserFile string
+	WorkDir  string
+	Environ  []string
+}
+
+// DefaultSources returns the configuration file of the user, the working directory and the environment of the process
+func DefaultSources() Sources {
+	sources := Sources{Environ: os.Environ()}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		sources.UserFile = filepath.Join(dir, "tkn-graph", "config.yaml")
+	} else if home, err := os.UserHomeDir(); err == nil {
+		sources.UserFile = filepath.Join(home, ".config", "tkn-graph", "config.yaml")
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		sources.WorkDir = wd
+	}
+
+	return sources
+}
+
+// Config is the merged configuration of all sources. The options are the names of the command-line flags, e.g.
+// output-format, the flags set on the command line take precedence over the configuration.
+type Config struct {
+	// Themes are the themes defined in the configuration files, by name
+	Themes map[string]*taskgraph.Theme
+
+	layers       []layer
+	themeSources map[string]string
+	applied      map[*pflag.Flag]bool
+}
+
+// layer holds the options of one source, from the lowest precedence to the highest one
+type layer struct {
+	source    string
+	namespace string // the options only apply to this namespace if not empty
+	environ   bool   // the options were set by environment variables
+	values    map[string]string
+}
+
+// Value is an option of the configuration and the source which set it
+type Value struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// file is the content of a configuration file, the other top-level keys are options
+type file struct {
+	Namespaces map[string]map[string]json.RawMessage `json:"namespaces"`
+	Themes     map[string]json.RawMessage            `json:"themes"`
+}
+
+// Load reads the configuration from the sources. The options set by the repository configuration file override the
+// ones of the user configuration file, the environment variables override both.
+func Load(sources Sources) (*Config, error) {
+	c := &Config{
+		Themes:       map[string]*taskgraph.Theme{},
+		themeSources: map[string]string{},
+		applied:      map[*pflag.Flag]bool{},
+	}
+
+	if sources.UserFile != "" {
+		if err := c.loadFile(sources.UserFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if sources.WorkDir != "" {
+		path, err := findRepoFile(sources.WorkDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if path != "" {
+			if err := c.loadFile(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	c.loadEnviron(sources.Environ)
+
+	return c, nil
+}
+
+// findRepoFile returns the path of the repository configuration file in dir or its parents, up to the root of the
+// git repository. An empty path is returned if there is none.
+func findRepoFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, RepoFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+
+		dir = parent
+	}
+}
+
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	var sections file
+	if err := yaml.Unmarshal(content, &sections); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	var options map[string]json.RawMessage
+	if err := yaml.Unmarshal(content, &options); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	delete(options, "namespaces")
+	delete(options, "themes")
+
+	values, err := optionValues(options)
+	if err != nil {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	c.layers = append(c.layers, layer{source: path, values: values})
+
+	for _, namespace := range sortedKeys(sections.Namespaces) {
+		values, err := optionValues(sections.Namespaces[namespace])
+		if err != nil {
+			return fmt.Errorf("invalid config %s: namespace %s: %w", path, namespace, err)
+		}
+
+		for key := range values {
+			if clusterOptions[key] {
+				return fmt.Errorf("invalid config %s: namespace %s: %s can't be set per namespace", path, namespace, key)
+			}
+		}
+
+		c.layers = append(c.layers, layer{source: path, namespace: namespace, values: values})
+	}
+
+	for _, name := range sortedKeys(sections.Themes) {
+		theme, err := taskgraph.ParseTheme(name, sections.Themes[name])
+		if err != nil {
+			return fmt.Errorf("invalid config %s: %w", path, err)
+		}
+
+		c.Themes[name] = theme
+		c.themeSources[name] = path
+	}
+
+	return nil
+}
+
+// loadEnviron reads the options from the TKN_GRAPH_ environment variables, TKN_GRAPH_OUTPUT_FORMAT sets output-format
+func (c *Config) loadEnviron(environ []string) {
+	values := map[string]string{}
+
+	for _, env := range environ {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) || name == EnvPrefix {
+			continue
+		}
+
+		values[envKey(name)] = value
+	}
+
+	if len(values) > 0 {
+		c.layers = append(c.layers, layer{source: "environment", environ: true, values: values})
+	}
+}
+
+// envKey returns the option of the environment variable
+func envKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(name, EnvPrefix)), "_", "-")
+}
+
+// envName returns the environment variable of the option
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// optionValues converts the values of the options to the text of the command-line flags, the lists are joined
+// with commas
+func optionValues(options map[string]json.RawMessage) (map[string]string, error) {
+	values := make(map[string]string, len(options))
+
+	for key, raw := range options {
+		value, err := optionValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+func optionValue(raw json.RawMessage) (string, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		items := make([]string, 0, len(v))
+
+		for _, item := range v {
+			text, err := optionValue(mustMarshal(item))
+			if err != nil {
+				return "", err
+			}
+
+			items = append(items, text)
+		}
+
+		return strings.Join(items, ","), nil
+	case nil:
+		return "", fmt.Errorf("it can't be empty")
+	default:
+		return "", fmt.Errorf("it must be a string, a number, a boolean or a list")
+	}
+}
+
+func mustMarshal(v any) json.RawMessage {
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+// Values returns the options which apply to the namespace and their source, sorted by option
+func (c *Config) Values(namespace string) []Value {
+	merged := map[string]Value{}
+
+	for _, l := range c.layers {
+		if l.namespace != "" && l.namespace != namespace {
+			continue
+		}
+
+		for key, value := range l.values {
+			merged[key] = Value{Key: key, Value: value, Source: l.sourceOf(key)}
+		}
+	}
+
+	values := make([]Value, 0, len(merged))
+	for _, key := range sortedKeys(merged) {
+		values = append(values, merged[key])
+	}
+
+	return values
+}
+
+// Namespaces returns the namespaces which have options of their own, sorted by name
+func (c *Config) Namespaces() []string {
+	seen := map[string]bool{}
+	for _, l := range c.layers {
+		if l.namespace != "" {
+			seen[l.namespace] = true
+		}
+	}
+
+	return sortedKeys(seen)
+}
+
+// ThemeSource returns the configuration file which defines the theme
+func (c *Config) ThemeSource(name string) string {
+	return c.themeSources[name]
+}
+
+func (l *layer) sourceOf(key string) string {
+	switch {
+	case l.environ:
+		return "$" + envName(key)
+	case l.namespace != "":
+		return fmt.Sprintf("%s (namespace %s)", l.source, l.namespace)
+	default:
+		return l.source
+	}
+}
+
+// Validate checks that every option of the configuration files is a flag of one of the commands of root. The
+// environment may be shared with other versions of the tool, so its unknown options are ignored with a warning on the
+// error output of root instead.
+func (c *Config) Validate(root *cobra.Command) error {
+	known := map[string]bool{}
+	addFlagNames(root, known)
+
+	for _, l := range c.layers {
+		for _, key := range sortedKeys(l.values) {
+			if known[key] {
+				continue
+			}
+
+			if l.environ {
+				fmt.Fprintf(root.ErrOrStderr(), "Warning: ignoring the environment variable %s, %s is not an option\n",
+					envName(key), key)
+				delete(l.values, key)
+
+				continue
+			}
+
+			return fmt.Errorf("unknown option %s in %s", key, l.source)
+		}
+	}
+
+	return nil
+}
+
+func addFlagNames(cmd *cobra.Command, names map[string]bool) {
+	add := func(f *pflag.Flag) {
+		if f.Name != "help" {
+			names[f.Name] = true
+		}
+	}
+
+	cmd.Flags().VisitAll(add)
+	cmd.PersistentFlags().VisitAll(add)
+
+	for _, sub := range cmd.Commands() {
+		addFlagNames(sub, names)
+	}
+}
+
+// Apply sets the flags of cmd which weren't set on the command line to the options of the namespace. It can be
+// called again with another namespace, the flags set by the previous call are overridden.
+func (c *Config) Apply(cmd *cobra.Command, namespace string) error {
+	if err := c.Validate(cmd.Root()); err != nil {
+		return err
+	}
+
+	for _, v := range c.Values(namespace) {
+		f := cmd.Flags().Lookup(v.Key)
+		if f == nil || (f.Changed && !c.applied[f]) {
+			continue
+		}
+
+		if err := setFlag(f, v.Value); err != nil {
+			return fmt.Errorf("invalid %s %q from %s: %w", v.Key, v.Value, v.Source, err)
+		}
+
+		c.applied[f] = true
+	}
+
+	return nil
+}
+
+// setFlag sets the value of the flag, the lists replace the previous value instead of being appended to it
+func setFlag(f *pflag.Flag, value string) error {
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		var items []string
+		if value != "" {
+			items = strings.Split(value, ",")
+		}
+
+		if err := sv.Replace(items); err != nil {
+			return err
+		}
+	} else if err := f.Value.Set(value); err != nil {
+		return err
+	}
+
+	f.Changed = true
+
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
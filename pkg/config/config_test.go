@@ -0,0 +1,225 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, path, content string) string {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+// getTestSources returns a user config file and a repository with a config file at its root, the working directory
+// is a subdirectory of the repository
+func getTestSources(t *testing.T) (Sources, string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	userFile := writeTestFile(t, filepath.Join(dir, "xdg", "tkn-graph", "config.yaml"), `output-format: dot
+with-task-ref: true
+concurrency: 4
+namespaces:
+  team-a:
+    output-format: puml
+    output-dir: team-a/
+themes:
+  corp:
+    base: dark
+    direction: LR
+`)
+
+	repoFile := writeTestFile(t, filepath.Join(dir, "repo", RepoFile), `output-format: mmd
+filename:
+  - a.yaml
+  - b.yaml
+namespaces:
+  team-a:
+    theme: corp
+`)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "repo", ".git"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "repo", "docs", "pipelines"), 0o755))
+
+	return Sources{
+		UserFile: userFile,
+		WorkDir:  filepath.Join(dir, "repo", "docs", "pipelines"),
+		Environ:  []string{"HOME=/root", "TKN_GRAPH_OUTPUT_DIR=docs/"},
+	}, userFile, repoFile
+}
+
+func TestLoad(t *testing.T) {
+	sources, userFile, repoFile := getTestSources(t)
+
+	cfg, err := Load(sources)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Value{
+		{Key: "concurrency", Value: "4", Source: userFile},
+		{Key: "filename", Value: "a.yaml,b.yaml", Source: repoFile},
+		{Key: "output-dir", Value: "docs/", Source: "$TKN_GRAPH_OUTPUT_DIR"},
+		{Key: "output-format", Value: "mmd", Source: repoFile},
+		{Key: "with-task-ref", Value: "true", Source: userFile},
+	}, cfg.Values(""))
+
+	// the namespace sections override the top-level options of the same file, not the ones of the other sources
+	assert.Equal(t, []Value{
+		{Key: "concurrency", Value: "4", Source: userFile},
+		{Key: "filename", Value: "a.yaml,b.yaml", Source: repoFile},
+		{Key: "output-dir", Value: "docs/", Source: "$TKN_GRAPH_OUTPUT_DIR"},
+		{Key: "output-format", Value: "mmd", Source: repoFile},
+		{Key: "theme", Value: "corp", Source: repoFile + " (namespace team-a)"},
+		{Key: "with-task-ref", Value: "true", Source: userFile},
+	}, cfg.Values("team-a"))
+
+	require.Contains(t, cfg.Themes, "corp")
+	assert.Equal(t, "corp", cfg.Themes["corp"].Name)
+	assert.Equal(t, "dark", cfg.Themes["corp"].Mermaid)
+	assert.Equal(t, userFile, cfg.ThemeSource("corp"))
+	assert.Equal(t, []string{"team-a"}, cfg.Namespaces())
+}
+
+func TestLoadWithoutFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o755))
+
+	cfg, err := Load(Sources{UserFile: filepath.Join(dir, "missing.yaml"), WorkDir: dir})
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Values(""))
+	assert.Empty(t, cfg.Themes)
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "yaml", content: "output-format: [", expected: "failed to parse config"},
+		{name: "object", content: "output-format:\n  name: mmd", expected: "invalid value of output-format: it must be a string"},
+		{name: "null", content: "output-dir:", expected: "invalid value of output-dir: it can't be empty"},
+		{name: "namespace", content: "namespaces:\n  team-a:\n    qps: 10", expected: "namespace team-a: qps can't be set per namespace"},
+		{name: "theme", content: "themes:\n  corp:\n    direction: BT", expected: `invalid theme corp: invalid direction "BT"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeTestFile(t, filepath.Join(dir, tc.name+".yaml"), tc.content)
+
+			_, err := Load(Sources{UserFile: path})
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+// getTestCommand returns a command with flags of every kind under a root command with a persistent flag
+func getTestCommand() *cobra.Command {
+	root := &cobra.Command{Use: "root"}
+	root.PersistentFlags().String("namespace", "", "")
+
+	cmd := &cobra.Command{Use: "graph", RunE: func(*cobra.Command, []string) error { return nil }}
+	cmd.Flags().String("output-format", "dot", "")
+	cmd.Flags().String("output-dir", "", "")
+	cmd.Flags().Bool("with-task-ref", false, "")
+	cmd.Flags().Int("concurrency", 8, "")
+	cmd.Flags().StringSlice("filename", nil, "")
+	cmd.Flags().String("theme", "", "")
+	root.AddCommand(cmd)
+
+	return cmd
+}
+
+func TestApply(t *testing.T) {
+	sources, _, _ := getTestSources(t)
+
+	cfg, err := Load(sources)
+	require.NoError(t, err)
+
+	cmd := getTestCommand()
+	require.NoError(t, cmd.ParseFlags([]string{"--output-format", "png", "--filename", "c.yaml"}))
+	require.NoError(t, cfg.Apply(cmd, ""))
+
+	// the flags set on the command line are kept
+	format, _ := cmd.Flags().GetString("output-format")
+	assert.Equal(t, "png", format)
+
+	filenames, _ := cmd.Flags().GetStringSlice("filename")
+	assert.Equal(t, []string{"c.yaml"}, filenames)
+
+	dir, _ := cmd.Flags().GetString("output-dir")
+	assert.Equal(t, "docs/", dir)
+
+	withTaskRef, _ := cmd.Flags().GetBool("with-task-ref")
+	assert.True(t, withTaskRef)
+
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	assert.Equal(t, 4, concurrency)
+	assert.True(t, cmd.Flags().Changed("concurrency"))
+
+	// the options of the namespace override the ones applied before
+	theme, _ := cmd.Flags().GetString("theme")
+	assert.Empty(t, theme)
+
+	require.NoError(t, cfg.Apply(cmd, "team-a"))
+
+	theme, _ = cmd.Flags().GetString("theme")
+	assert.Equal(t, "corp", theme)
+}
+
+func TestApplyLists(t *testing.T) {
+	cfg, err := Load(Sources{Environ: []string{"TKN_GRAPH_FILENAME=a.yaml,b.yaml"}})
+	require.NoError(t, err)
+
+	cmd := getTestCommand()
+	require.NoError(t, cfg.Apply(cmd, ""))
+	require.NoError(t, cfg.Apply(cmd, "team-a"))
+
+	// the lists are replaced, not appended to
+	filenames, _ := cmd.Flags().GetStringSlice("filename")
+	assert.Equal(t, []string{"a.yaml", "b.yaml"}, filenames)
+}
+
+func TestApplyUnknownEnviron(t *testing.T) {
+	cfg, err := Load(Sources{Environ: []string{"TKN_GRAPH_OUTPUT_FORMT=mmd", "TKN_GRAPH_OUTPUT_DIR=docs/"}})
+	require.NoError(t, err)
+
+	cmd := getTestCommand()
+	out := new(bytes.Buffer)
+	cmd.Root().SetErr(out)
+
+	// the warning is only printed once
+	require.NoError(t, cfg.Apply(cmd, ""))
+	require.NoError(t, cfg.Apply(cmd, "team-a"))
+	assert.Equal(t, "Warning: ignoring the environment variable TKN_GRAPH_OUTPUT_FORMT, output-formt is not an option\n",
+		out.String())
+
+	dir, _ := cmd.Flags().GetString("output-dir")
+	assert.Equal(t, "docs/", dir)
+	assert.Equal(t, []Value{{Key: "output-dir", Value: "docs/", Source: "$TKN_GRAPH_OUTPUT_DIR"}}, cfg.Values(""))
+}
+
+func TestApplyErrors(t *testing.T) {
+	path := writeTestFile(t, filepath.Join(t.TempDir(), "config.yaml"), "colour: red")
+
+	cfg, err := Load(Sources{UserFile: path})
+	require.NoError(t, err)
+	assert.EqualError(t, cfg.Apply(getTestCommand(), ""), "unknown option colour in "+path)
+
+	// the persistent flags of the parent commands are known options
+	cfg, err = Load(Sources{Environ: []string{"TKN_GRAPH_NAMESPACE=team-a", "TKN_GRAPH_CONCURRENCY=many"}})
+	require.NoError(t, err)
+	assert.EqualError(t, cfg.Apply(getTestCommand(), ""),
+		`invalid concurrency "many" from $TKN_GRAPH_CONCURRENCY: strconv.ParseInt: parsing "many": invalid syntax`)
+}
@@ -0,0 +1,264 @@
+package manifest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// Stdin is the filename used to read manifests from the standard input
+	Stdin = "-"
+
+	kindPipeline    = "Pipeline"
+	kindPipelineRun = "PipelineRun"
+	kindList        = "List"
+
+	tektonGroup       = "tekton.dev"
+	apiVersionV1      = tektonGroup + "/v1"
+	apiVersionV1beta1 = tektonGroup + "/v1beta1"
+)
+
+// Manifests holds the Tekton objects decoded from local files
+type Manifests struct {
+	Pipelines    []v1.Pipeline
+	PipelineRuns []v1.PipelineRun
+}
+
+// Load reads all Pipelines and PipelineRuns from the given filenames.
+// A filename can be a file, a directory (all *.yaml, *.yml and *.json files in it), a glob pattern
+// or "-" to read from stdin. Each file may contain multiple YAML documents, JSON objects or a "kind: List".
+// Objects of any other kind are ignored.
+func Load(filenames []string, stdin io.Reader) (*Manifests, error) {
+	m := &Manifests{}
+
+	for _, filename := range filenames {
+		if filename == Stdin {
+			if err := m.decode(stdin, "stdin"); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		paths, err := expand(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			if err := m.decodeFile(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// expand resolves a filename to the list of files it points to
+func expand(filename string) ([]string, error) {
+	info, err := os.Stat(filename)
+	if err == nil {
+		if !info.IsDir() {
+			return []string{filename}, nil
+		}
+
+		return readDir(filename)
+	}
+
+	matches, globErr := filepath.Glob(filename)
+	if globErr != nil {
+		return nil, fmt.Errorf("invalid filename pattern %s: %w", filename, globErr)
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	var paths []string
+
+	for _, match := range matches {
+		expanded, err := expand(match)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, expanded...)
+	}
+
+	return paths, nil
+}
+
+// readDir returns the manifest files located directly in the directory
+func readDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	var paths []string
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return paths, nil
+}
+
+func (m *Manifests) decodeFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return m.decode(f, path)
+}
+
+// decode reads all documents from the reader and adds the Tekton objects to the Manifests
+func (m *Manifests) decode(r io.Reader, source string) error {
+	decoder := yaml.NewYAMLOrJSONDecoder(bufio.NewReader(r), 4096)
+
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to decode %s: %w", source, err)
+		}
+
+		// empty documents, e.g. a trailing "---"
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+
+		if err := m.add(raw); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", source, err)
+		}
+	}
+}
+
+// add decodes a single object and appends it to the Manifests
+func (m *Manifests) add(raw json.RawMessage) error {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(typeMeta.Kind, kindList) {
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return err
+		}
+
+		for _, item := range list.Items {
+			if err := m.add(item); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// objects of other API groups may share the same kind, e.g. a CI system's own "Pipeline"
+	if !strings.HasPrefix(typeMeta.APIVersion, tektonGroup+"/") {
+		return nil
+	}
+
+	switch typeMeta.Kind {
+	case kindPipeline:
+		p, err := decodePipeline(raw, typeMeta.APIVersion)
+		if err != nil {
+			return err
+		}
+
+		m.Pipelines = append(m.Pipelines, *p)
+	case kindPipelineRun:
+		pr, err := decodePipelineRun(raw, typeMeta.APIVersion)
+		if err != nil {
+			return err
+		}
+
+		m.PipelineRuns = append(m.PipelineRuns, *pr)
+	}
+
+	return nil
+}
+
+func decodePipeline(raw json.RawMessage, apiVersion string) (*v1.Pipeline, error) {
+	switch apiVersion {
+	case apiVersionV1:
+		p := &v1.Pipeline{}
+		if err := json.Unmarshal(raw, p); err != nil {
+			return nil, fmt.Errorf("failed to decode Pipeline: %w", err)
+		}
+
+		return p, nil
+	case apiVersionV1beta1:
+		old := &v1beta1.Pipeline{}
+		if err := json.Unmarshal(raw, old); err != nil {
+			return nil, fmt.Errorf("failed to decode Pipeline: %w", err)
+		}
+
+		p := &v1.Pipeline{}
+		if err := old.ConvertTo(context.Background(), p); err != nil {
+			return nil, fmt.Errorf("failed to convert Pipeline %s to %s: %w", old.Name, apiVersionV1, err)
+		}
+
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unsupported Pipeline apiVersion: %s", apiVersion)
+	}
+}
+
+func decodePipelineRun(raw json.RawMessage, apiVersion string) (*v1.PipelineRun, error) {
+	switch apiVersion {
+	case apiVersionV1:
+		pr := &v1.PipelineRun{}
+		if err := json.Unmarshal(raw, pr); err != nil {
+			return nil, fmt.Errorf("failed to decode PipelineRun: %w", err)
+		}
+
+		return pr, nil
+	case apiVersionV1beta1:
+		old := &v1beta1.PipelineRun{}
+		if err := json.Unmarshal(raw, old); err != nil {
+			return nil, fmt.Errorf("failed to decode PipelineRun: %w", err)
+		}
+
+		pr := &v1.PipelineRun{}
+		if err := old.ConvertTo(context.Background(), pr); err != nil {
+			return nil, fmt.Errorf("failed to convert PipelineRun %s to %s: %w", old.Name, apiVersionV1, err)
+		}
+
+		return pr, nil
+	default:
+		return nil, fmt.Errorf("unsupported PipelineRun apiVersion: %s", apiVersion)
+	}
+}
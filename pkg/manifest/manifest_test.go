@@ -0,0 +1,109 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	pipelineYAML = `apiVersion: tekton.dev/v1
+kind: Pipeline
+metadata:
+  name: pipeline-1
+spec:
+  tasks:
+    - name: task1
+      taskRef:
+        name: taskRef1
+`
+	pipelineRunYAML = `apiVersion: tekton.dev/v1beta1
+kind: PipelineRun
+metadata:
+  generateName: run-
+spec:
+  pipelineRef:
+    name: pipeline-1
+`
+	listJSON = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "tekton.dev/v1", "kind": "Pipeline", "metadata": {"name": "pipeline-2"}},
+    {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "cm"}}
+  ]
+}`
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	return path
+}
+
+func TestLoadMultiDocument(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "all.yaml", pipelineYAML+"---\n"+pipelineRunYAML+"---\n")
+
+	m, err := Load([]string{path}, nil)
+
+	require.NoError(t, err)
+	require.Len(t, m.Pipelines, 1)
+	require.Len(t, m.PipelineRuns, 1)
+	assert.Equal(t, "pipeline-1", m.Pipelines[0].Name)
+	assert.Equal(t, "taskRef1", m.Pipelines[0].Spec.Tasks[0].TaskRef.Name)
+	// v1beta1 is converted to v1
+	assert.Equal(t, "run-", m.PipelineRuns[0].GenerateName)
+	assert.Equal(t, "pipeline-1", m.PipelineRuns[0].Spec.PipelineRef.Name)
+}
+
+func TestLoadDirectoryAndList(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "pipeline.yml", pipelineYAML)
+	writeFile(t, dir, "list.json", listJSON)
+	writeFile(t, dir, "README.md", "# not a manifest")
+
+	m, err := Load([]string{dir}, nil)
+
+	require.NoError(t, err)
+	assert.Len(t, m.Pipelines, 2)
+	assert.Empty(t, m.PipelineRuns)
+}
+
+func TestLoadGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.yaml", pipelineYAML)
+	writeFile(t, dir, "b.yaml", pipelineRunYAML)
+
+	m, err := Load([]string{filepath.Join(dir, "*.yaml")}, nil)
+
+	require.NoError(t, err)
+	assert.Len(t, m.Pipelines, 1)
+	assert.Len(t, m.PipelineRuns, 1)
+}
+
+func TestLoadStdin(t *testing.T) {
+	m, err := Load([]string{Stdin}, strings.NewReader(listJSON))
+
+	require.NoError(t, err)
+	require.Len(t, m.Pipelines, 1)
+	assert.Equal(t, "pipeline-2", m.Pipelines[0].Name)
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load([]string{"does-not-exist.yaml"}, nil)
+	assert.ErrorContains(t, err, "failed to read does-not-exist.yaml")
+
+	_, err = Load([]string{Stdin}, strings.NewReader("apiVersion: tekton.dev/v1alpha1\nkind: Pipeline\n"))
+	assert.ErrorContains(t, err, "unsupported Pipeline apiVersion: tekton.dev/v1alpha1")
+
+	_, err = Load([]string{Stdin}, strings.NewReader("kind: ["))
+	assert.ErrorContains(t, err, "failed to decode stdin")
+}
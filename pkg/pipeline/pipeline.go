@@ -4,28 +4,76 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/sergk/tkn-graph/pkg/request"
 	"github.com/tektoncd/cli/pkg/cli"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func GetAllPipelines(c *cli.Clients, ns string) ([]v1.Pipeline, error) {
-	pipelines, err := c.Tekton.TektonV1().Pipelines(ns).List(context.TODO(), metav1.ListOptions{})
+// GetAllPipelines lists the Pipelines of the namespace, or of all namespaces if ns is empty, matching the selectors of opts
+func GetAllPipelines(ctx context.Context, c *cli.Clients, ns string, opts metav1.ListOptions) ([]v1.Pipeline, error) {
+	var pipelines []v1.Pipeline
+
+	err := ListPipelines(ctx, c, ns, opts, func(page []v1.Pipeline) error {
+		pipelines = append(pipelines, page...)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get Pipelines: %w", err)
+		return nil, err
 	}
 
-	if len(pipelines.Items) == 0 {
-		return nil, fmt.Errorf("no Pipelines found in namespace %s", ns)
+	return pipelines, nil
+}
+
+// ListPipelines lists the Pipelines like GetAllPipelines, page by page. opts.Limit is the size of the pages,
+// all Pipelines are listed at once if it is 0. Listing stops at the first error returned by fn.
+func ListPipelines(ctx context.Context, c *cli.Clients, ns string, opts metav1.ListOptions, fn func(page []v1.Pipeline) error) error {
+	found := false
+
+	for {
+		pipelines, err := c.Tekton.TektonV1().Pipelines(ns).List(ctx, opts)
+		if err != nil {
+			if interrupted := request.Interrupted(ctx, err, request.Listing("Pipelines", ns)); interrupted != nil {
+				return interrupted
+			}
+
+			return fmt.Errorf("failed to get Pipelines: %w", err)
+		}
+
+		if len(pipelines.Items) > 0 {
+			found = true
+
+			if err := fn(pipelines.Items); err != nil {
+				return err
+			}
+		}
+
+		if pipelines.Continue == "" {
+			break
+		}
+
+		opts.Continue = pipelines.Continue
+	}
+
+	if !found {
+		if ns == metav1.NamespaceAll {
+			return fmt.Errorf("no Pipelines found in any namespace")
+		}
+
+		return fmt.Errorf("no Pipelines found in namespace %s", ns)
 	}
 
-	return pipelines.Items, nil
+	return nil
 }
 
 // Get Pipeline by name
-func GetPipelineByName(c *cli.Clients, name string, ns string) (*v1.Pipeline, error) {
-	pipeline, err := c.Tekton.TektonV1().Pipelines(ns).Get(context.TODO(), name, metav1.GetOptions{})
+func GetPipelineByName(ctx context.Context, c *cli.Clients, name string, ns string) (*v1.Pipeline, error) {
+	pipeline, err := c.Tekton.TektonV1().Pipelines(ns).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
+		if interrupted := request.Interrupted(ctx, err, request.Getting("Pipeline", name, ns)); interrupted != nil {
+			return nil, interrupted
+		}
+
 		return nil, fmt.Errorf("failed to get Pipeline with name %s: %w", name, err)
 	}
 
@@ -2,12 +2,16 @@ package pipeline
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/tektoncd/cli/pkg/cli"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	fakeclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 const (
@@ -46,7 +50,7 @@ func TestGetAllPipelines(t *testing.T) {
 	}
 
 	// Get the pipeline runs
-	pipelines, err := GetAllPipelines(c, namespace)
+	pipelines, err := GetAllPipelines(context.Background(), c, namespace, metav1.ListOptions{})
 	if err != nil {
 		t.Fatalf("Error getting pipeline runs: %v", err)
 	}
@@ -71,7 +75,7 @@ func TestGetAllPipelineWithError(t *testing.T) {
 	}
 
 	// Get the pipeline runs
-	_, err := GetAllPipelines(c, namespace)
+	_, err := GetAllPipelines(context.Background(), c, namespace, metav1.ListOptions{})
 	if err == nil {
 		t.Fatal("GetAllPipelines did not return an error, expected an error")
 	}
@@ -103,7 +107,7 @@ func TestGetPipelineByName(t *testing.T) {
 	}
 
 	// Get the pipeline run
-	pipelineRun, err := GetPipelineByName(c, expectedPipeline.Name, namespace)
+	pipelineRun, err := GetPipelineByName(context.Background(), c, expectedPipeline.Name, namespace)
 	if err != nil {
 		t.Fatalf("Error getting pipeline run: %v", err)
 	}
@@ -122,7 +126,7 @@ func TestPipelineByNameWithError(t *testing.T) {
 	}
 
 	// Get the pipeline runs
-	_, err := GetPipelineByName(c, "fake-pipeline", namespace)
+	_, err := GetPipelineByName(context.Background(), c, "fake-pipeline", namespace)
 	if err == nil {
 		t.Fatal("GetPipelineByName did not return an error, expected an error")
 	}
@@ -131,3 +135,72 @@ func TestPipelineByNameWithError(t *testing.T) {
 		t.Fatalf("Expected error message to be 'failed to get Pipeline with name fake-pipeline: pipelines.tekton.dev \"fake-pipeline\" not found', got %s", err.Error())
 	}
 }
+
+func TestGetAllPipelinesWithSelectorInAllNamespaces(t *testing.T) {
+	fakeClient := fakeclient.NewSimpleClientset(
+		&v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "team-a", Labels: map[string]string{"app": "shop"}}},
+		&v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "team-b", Labels: map[string]string{"app": "shop"}}},
+		&v1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "team-b", Labels: map[string]string{"app": "blog"}}},
+	)
+
+	c := &cli.Clients{
+		Tekton: fakeClient,
+	}
+
+	pipelines, err := GetAllPipelines(context.Background(), c, metav1.NamespaceAll, metav1.ListOptions{LabelSelector: "app=shop"})
+	if err != nil {
+		t.Fatalf("Error getting pipelines: %v", err)
+	}
+
+	if len(pipelines) != 2 || pipelines[0].Namespace == pipelines[1].Namespace {
+		t.Fatalf("Expected the build pipelines of both namespaces, got %v", pipelines)
+	}
+
+	_, err = GetAllPipelines(context.Background(), c, metav1.NamespaceAll, metav1.ListOptions{LabelSelector: "app=unknown"})
+	if err == nil || err.Error() != "no Pipelines found in any namespace" {
+		t.Fatalf("Expected error message to be 'no Pipelines found in any namespace', got %v", err)
+	}
+}
+
+func TestGetPipelineByNameWithTimeout(t *testing.T) {
+	fakeClient := fakeclient.NewSimpleClientset()
+	fakeClient.PrependReactor("get", "pipelines", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("Get \"https://cluster/pipelines/build\": %w", context.DeadlineExceeded)
+	})
+
+	c := &cli.Clients{
+		Tekton: fakeClient,
+	}
+
+	_, err := GetPipelineByName(context.Background(), c, "build", namespace)
+	if err == nil {
+		t.Fatal("GetPipelineByName did not return an error, expected an error")
+	}
+
+	if err.Error() != "timed out getting Pipeline build in namespace my-namespace" {
+		t.Fatalf("Expected error message to be 'timed out getting Pipeline build in namespace my-namespace', got %s", err.Error())
+	}
+}
+
+func TestGetAllPipelinesWithCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fakeClient := fakeclient.NewSimpleClientset()
+	fakeClient.PrependReactor("list", "pipelines", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, context.Canceled
+	})
+
+	c := &cli.Clients{
+		Tekton: fakeClient,
+	}
+
+	_, err := GetAllPipelines(ctx, c, metav1.NamespaceAll, metav1.ListOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancellation error, got %v", err)
+	}
+
+	if err.Error() != "cancelled listing Pipelines in all namespaces" {
+		t.Fatalf("Expected error message to be 'cancelled listing Pipelines in all namespaces', got %s", err.Error())
+	}
+}
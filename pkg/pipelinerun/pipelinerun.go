@@ -4,30 +4,94 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/sergk/tkn-graph/pkg/request"
 	"github.com/tektoncd/cli/pkg/cli"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 )
 
-func GetAllPipelineRuns(c *cli.Clients, ns string) ([]v1.PipelineRun, error) {
-	pipelineruns, err := c.Tekton.TektonV1().PipelineRuns(ns).List(context.TODO(), metav1.ListOptions{})
+// GetAllPipelineRuns lists the PipelineRuns of the namespace, or of all namespaces if ns is empty, matching the selectors of opts
+func GetAllPipelineRuns(ctx context.Context, c *cli.Clients, ns string, opts metav1.ListOptions) ([]v1.PipelineRun, error) {
+	var pipelineruns []v1.PipelineRun
+
+	err := ListPipelineRuns(ctx, c, ns, opts, func(page []v1.PipelineRun) error {
+		pipelineruns = append(pipelineruns, page...)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get PipelineRuns: %w", err)
+		return nil, err
 	}
 
-	if len(pipelineruns.Items) == 0 {
-		return nil, fmt.Errorf("no PipelineRuns found in namespace %s", ns)
+	return pipelineruns, nil
+}
+
+// ListPipelineRuns lists the PipelineRuns like GetAllPipelineRuns, page by page. opts.Limit is the size of the pages,
+// all PipelineRuns are listed at once if it is 0. Listing stops at the first error returned by fn.
+func ListPipelineRuns(ctx context.Context, c *cli.Clients, ns string, opts metav1.ListOptions, fn func(page []v1.PipelineRun) error) error {
+	found := false
+
+	for {
+		pipelineruns, err := c.Tekton.TektonV1().PipelineRuns(ns).List(ctx, opts)
+		if err != nil {
+			if interrupted := request.Interrupted(ctx, err, request.Listing("PipelineRuns", ns)); interrupted != nil {
+				return interrupted
+			}
+
+			return fmt.Errorf("failed to get PipelineRuns: %w", err)
+		}
+
+		if len(pipelineruns.Items) > 0 {
+			found = true
+
+			if err := fn(pipelineruns.Items); err != nil {
+				return err
+			}
+		}
+
+		if pipelineruns.Continue == "" {
+			break
+		}
+
+		opts.Continue = pipelineruns.Continue
 	}
 
-	return pipelineruns.Items, nil
+	if !found {
+		if ns == metav1.NamespaceAll {
+			return fmt.Errorf("no PipelineRuns found in any namespace")
+		}
+
+		return fmt.Errorf("no PipelineRuns found in namespace %s", ns)
+	}
+
+	return nil
 }
 
 // Get PipelineRun by name
-func GetPipelineRunsByName(c *cli.Clients, name string, ns string) (*v1.PipelineRun, error) {
-	pipelinerun, err := c.Tekton.TektonV1().PipelineRuns(ns).Get(context.TODO(), name, metav1.GetOptions{})
+func GetPipelineRunsByName(ctx context.Context, c *cli.Clients, name string, ns string) (*v1.PipelineRun, error) {
+	pipelinerun, err := c.Tekton.TektonV1().PipelineRuns(ns).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
+		if interrupted := request.Interrupted(ctx, err, request.Getting("PipelineRun", name, ns)); interrupted != nil {
+			return nil, interrupted
+		}
+
 		return nil, fmt.Errorf("failed to get PipelineRun with name %s: %w", name, err)
 	}
 
 	return pipelinerun, nil
 }
+
+// WatchPipelineRuns watches the PipelineRuns of the namespace matching the selectors of opts.
+// Without opts.ResourceVersion the existing PipelineRuns are sent as added first.
+func WatchPipelineRuns(ctx context.Context, c *cli.Clients, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+	w, err := c.Tekton.TektonV1().PipelineRuns(ns).Watch(ctx, opts)
+	if err != nil {
+		if interrupted := request.Interrupted(ctx, err, request.Watching("PipelineRuns", ns)); interrupted != nil {
+			return nil, interrupted
+		}
+
+		return nil, fmt.Errorf("failed to watch PipelineRuns: %w", err)
+	}
+
+	return w, nil
+}
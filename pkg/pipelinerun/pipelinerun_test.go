@@ -2,12 +2,16 @@ package pipelinerun
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/tektoncd/cli/pkg/cli"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	fakeclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 const (
@@ -46,7 +50,7 @@ func TestGetAllPipelineRuns(t *testing.T) {
 	}
 
 	// Get the pipeline runs
-	pipelineRuns, err := GetAllPipelineRuns(c, namespace)
+	pipelineRuns, err := GetAllPipelineRuns(context.Background(), c, namespace, metav1.ListOptions{})
 	if err != nil {
 		t.Fatalf("Error getting pipeline runs: %v", err)
 	}
@@ -71,7 +75,7 @@ func TestGetAllPipelineRunsWithError(t *testing.T) {
 	}
 
 	// Get the pipeline runs
-	_, err := GetAllPipelineRuns(c, namespace)
+	_, err := GetAllPipelineRuns(context.Background(), c, namespace, metav1.ListOptions{})
 	if err == nil {
 		t.Fatal("GetAllPipelineRuns did not return an error, expected an error")
 	}
@@ -103,7 +107,7 @@ func TestGetPipelineRunsByName(t *testing.T) {
 	}
 
 	// Get the pipeline run
-	pipelineRun, err := GetPipelineRunsByName(c, expectedPipelineRun.Name, namespace)
+	pipelineRun, err := GetPipelineRunsByName(context.Background(), c, expectedPipelineRun.Name, namespace)
 	if err != nil {
 		t.Fatalf("Error getting pipeline run: %v", err)
 	}
@@ -122,7 +126,7 @@ func TestPipelineByNameWithError(t *testing.T) {
 	}
 
 	// Get the pipeline runs
-	_, err := GetPipelineRunsByName(c, "fake-pipeline", namespace)
+	_, err := GetPipelineRunsByName(context.Background(), c, "fake-pipeline", namespace)
 	if err == nil {
 		t.Fatal("GetPipelineRunsByName did not return an error, expected an error")
 	}
@@ -131,3 +135,65 @@ func TestPipelineByNameWithError(t *testing.T) {
 		t.Fatalf("Expected error message to be 'failed to get PipelineRun with name fake-pipeline: pipelineruns.tekton.dev \"fake-pipeline\" not found', got %s", err.Error())
 	}
 }
+
+func TestListPipelineRunsByPage(t *testing.T) {
+	fakeClient := fakeclient.NewSimpleClientset()
+
+	// The fake clientset neither paginates nor records Limit and Continue, the pages are served in order by the reactor
+	pages := []*v1.PipelineRunList{
+		{
+			ListMeta: metav1.ListMeta{Continue: "page-2"},
+			Items:    []v1.PipelineRun{{ObjectMeta: metav1.ObjectMeta{Name: "run-1"}}, {ObjectMeta: metav1.ObjectMeta{Name: "run-2"}}},
+		},
+		{
+			Items: []v1.PipelineRun{{ObjectMeta: metav1.ObjectMeta{Name: "run-3"}}},
+		},
+	}
+	requests := 0
+
+	fakeClient.PrependReactor("list", "pipelineruns", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		page := pages[requests%len(pages)]
+		requests++
+
+		return true, page, nil
+	})
+
+	c := &cli.Clients{
+		Tekton: fakeClient,
+	}
+
+	var names [][]string
+
+	err := ListPipelineRuns(context.Background(), c, namespace, metav1.ListOptions{Limit: 2}, func(page []v1.PipelineRun) error {
+		pageNames := []string{}
+		for _, pr := range page {
+			pageNames = append(pageNames, pr.Name)
+		}
+
+		names = append(names, pageNames)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error listing pipeline runs: %v", err)
+	}
+
+	if !reflect.DeepEqual(names, [][]string{{"run-1", "run-2"}, {"run-3"}}) {
+		t.Fatalf("Unexpected pages: %v", names)
+	}
+
+	// Listing stops at the first error of fn
+	requests = 0
+	stop := errors.New("stop")
+
+	err = ListPipelineRuns(context.Background(), c, namespace, metav1.ListOptions{Limit: 2}, func(page []v1.PipelineRun) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("Expected the error of fn, got %v", err)
+	}
+
+	if requests != 1 {
+		t.Fatalf("Expected a single request, got %d", requests)
+	}
+}
@@ -0,0 +1,68 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InterruptedError is returned when a request to the cluster is cut short by a timeout or a cancellation
+type InterruptedError struct {
+	Action string // Description of the request, e.g. "getting Pipeline build in namespace default"
+	Err    error  // context.DeadlineExceeded or context.Canceled
+}
+
+func (e *InterruptedError) Error() string {
+	if errors.Is(e.Err, context.Canceled) {
+		return "cancelled " + e.Action
+	}
+
+	return "timed out " + e.Action
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// Interrupted returns an *InterruptedError if the request failed because ctx is done or the API server timed out,
+// nil otherwise. The client rate limiter doesn't wrap the context errors, so ctx is checked as well.
+func Interrupted(ctx context.Context, err error, action string) error {
+	var interrupted *InterruptedError
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &interrupted):
+		return interrupted
+	case ctx.Err() != nil:
+		return &InterruptedError{Action: action, Err: ctx.Err()}
+	case errors.Is(err, context.DeadlineExceeded), apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return &InterruptedError{Action: action, Err: context.DeadlineExceeded}
+	case errors.Is(err, context.Canceled):
+		return &InterruptedError{Action: action, Err: context.Canceled}
+	default:
+		return nil
+	}
+}
+
+// Getting describes the request of a single resource
+func Getting(kind, name, namespace string) string {
+	return fmt.Sprintf("getting %s %s in namespace %s", kind, name, namespace)
+}
+
+// Listing describes the request of the resources of a namespace, or of all namespaces if it is empty
+func Listing(kind, namespace string) string {
+	if namespace == metav1.NamespaceAll {
+		return fmt.Sprintf("listing %s in all namespaces", kind)
+	}
+
+	return fmt.Sprintf("listing %s in namespace %s", kind, namespace)
+}
+
+// Watching describes the watch of the resources of a namespace
+func Watching(kind, namespace string) string {
+	return fmt.Sprintf("watching %s in namespace %s", kind, namespace)
+}
@@ -0,0 +1,71 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestInterrupted(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancelExpired := context.WithTimeout(context.Background(), 0)
+	defer cancelExpired()
+
+	action := Getting("Pipeline", "build", "default")
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		expected string
+	}{
+		{"no error", context.Background(), nil, ""},
+		{"other error", context.Background(), errors.New("forbidden"), ""},
+		{"deadline of the context", expired, errors.New("rate: Wait(n=1) would exceed context deadline"),
+			"timed out getting Pipeline build in namespace default"},
+		{"cancelled context", cancelled, fmt.Errorf("Get \"https://cluster\": %w", context.Canceled),
+			"cancelled getting Pipeline build in namespace default"},
+		{"wrapped deadline", context.Background(), fmt.Errorf("Get \"https://cluster\": %w", context.DeadlineExceeded),
+			"timed out getting Pipeline build in namespace default"},
+		{"server timeout", context.Background(), apierrors.NewTimeoutError("slow", 1),
+			"timed out getting Pipeline build in namespace default"},
+		{"server timeout status", context.Background(), apierrors.NewServerTimeout(schema.GroupResource{Resource: "pipelines"}, "get", 1),
+			"timed out getting Pipeline build in namespace default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Interrupted(tt.ctx, tt.err, action)
+
+			if tt.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestInterruptedKeepsTheFirstAction(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	inner := Interrupted(ctx, context.Canceled, Getting("TaskRun", "run-build", "default"))
+	err := Interrupted(ctx, fmt.Errorf("failed to get TaskRun: %w", inner), Listing("PipelineRuns", "default"))
+
+	assert.EqualError(t, err, "cancelled getting TaskRun run-build in namespace default")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestListing(t *testing.T) {
+	assert.Equal(t, "listing PipelineRuns in namespace default", Listing("PipelineRuns", "default"))
+	assert.Equal(t, "listing PipelineRuns in all namespaces", Listing("PipelineRuns", ""))
+}
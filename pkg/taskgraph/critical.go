@@ -0,0 +1,175 @@
+package taskgraph
+
+import (
+	"time"
+)
+
+// criticalColor is the color of the critical path in the svg, png and html graphs, the templates use the same color
+// unless the theme sets another one
+const criticalColor = "#d93025"
+
+// CriticalPath is the longest chain of dependent tasks, weighted by the duration of the tasks.
+// It is the chain of tasks which determines how long the PipelineRun takes.
+type CriticalPath struct {
+	Tasks    []*TaskNode              // Tasks of the path in execution order, empty if no task has a duration
+	Duration time.Duration            // Sum of the durations of the tasks of the path
+	Slack    map[string]time.Duration // How long each task could be delayed without delaying the run, keyed by name
+}
+
+// CriticalPath computes the critical path of the graph from the durations of the task statuses.
+// Tasks without status or which haven't completed have no duration. The finally tasks start when
+// all the other tasks are done.
+func (g *TaskGraph) CriticalPath() *CriticalPath {
+	var tasks, finally []*TaskNode
+
+	preds := map[*TaskNode][]*TaskNode{}
+	succs := map[*TaskNode][]*TaskNode{}
+
+	for _, name := range g.sortedNames() {
+		if node := g.Nodes[name]; node.IsFinally() {
+			finally = append(finally, node)
+		} else {
+			tasks = append(tasks, node)
+		}
+	}
+
+	for _, node := range tasks {
+		for _, dep := range node.Dependencies {
+			preds[dep] = append(preds[dep], node)
+			succs[node] = append(succs[node], dep)
+		}
+	}
+
+	for _, node := range finally {
+		preds[node] = tasks
+		for _, task := range tasks {
+			succs[task] = append(succs[task], node)
+		}
+	}
+
+	order := append(topologicalOrder(tasks, preds, succs), finally...)
+
+	// earliest finish, going forward
+	finish := make(map[*TaskNode]time.Duration, len(order))
+	path := &CriticalPath{Slack: make(map[string]time.Duration, len(order))}
+
+	for _, node := range order {
+		start := time.Duration(0)
+		for _, pred := range preds[node] {
+			start = max(start, finish[pred])
+		}
+
+		finish[node] = start + taskDuration(node)
+		path.Duration = max(path.Duration, finish[node])
+	}
+
+	// latest finish, going backward
+	latest := make(map[*TaskNode]time.Duration, len(order))
+
+	for i := len(order) - 1; i >= 0; i-- {
+		node := order[i]
+		latest[node] = path.Duration
+
+		for _, succ := range succs[node] {
+			if _, ok := latest[succ]; ok {
+				latest[node] = min(latest[node], latest[succ]-taskDuration(succ))
+			}
+		}
+
+		path.Slack[node.Name] = latest[node] - finish[node]
+	}
+
+	if path.Duration == 0 {
+		return path
+	}
+
+	// walk back from the task finishing last through the predecessors finishing last
+	var last *TaskNode
+
+	for _, node := range order {
+		if last == nil || finish[node] > finish[last] {
+			last = node
+		}
+	}
+
+	for node := last; node != nil; {
+		path.Tasks = append([]*TaskNode{node}, path.Tasks...)
+
+		var prev *TaskNode
+
+		for _, pred := range preds[node] {
+			if _, ok := finish[pred]; ok && (prev == nil || finish[pred] > finish[prev]) {
+				prev = pred
+			}
+		}
+
+		node = prev
+	}
+
+	return path
+}
+
+// HighlightCriticalPath computes the critical path and marks its tasks and edges, so they are highlighted
+// in the rendered graphs
+func (g *TaskGraph) HighlightCriticalPath() *CriticalPath {
+	path := g.CriticalPath()
+
+	for i, node := range path.Tasks {
+		node.Critical = true
+		if i+1 < len(path.Tasks) {
+			node.criticalNext = path.Tasks[i+1]
+		}
+	}
+
+	return path
+}
+
+// IsCriticalEdge returns true if the edge from the node to dep is part of the highlighted critical path
+func (n *TaskNode) IsCriticalEdge(dep *TaskNode) bool {
+	return dep != nil && n.criticalNext == dep
+}
+
+func taskDuration(node *TaskNode) time.Duration {
+	if node.Status == nil {
+		return 0
+	}
+
+	return node.Status.Duration()
+}
+
+// topologicalOrder sorts the tasks so every task comes after its predecessors, ties are kept in the given order.
+// Tasks which are part of a cycle are left out.
+func topologicalOrder(tasks []*TaskNode, preds, succs map[*TaskNode][]*TaskNode) []*TaskNode {
+	pending := make(map[*TaskNode]int, len(tasks))
+	for _, node := range tasks {
+		pending[node] = len(preds[node])
+	}
+
+	order := make([]*TaskNode, 0, len(tasks))
+
+	for len(order) < len(tasks) {
+		progress := false
+
+		for _, node := range tasks {
+			if pending[node] != 0 {
+				continue
+			}
+
+			pending[node] = -1
+			order = append(order, node)
+			progress = true
+
+			for _, succ := range succs[node] {
+				if _, ok := pending[succ]; ok {
+					pending[succ]--
+				}
+			}
+		}
+
+		if !progress {
+			break
+		}
+	}
+
+	return order
+}
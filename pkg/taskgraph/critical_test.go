@@ -0,0 +1,108 @@
+package taskgraph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func getTestCriticalGraph(t *testing.T) *TaskGraph {
+	t.Helper()
+
+	graph := mustBuildTaskGraph(t, getTestTasks(), v1pipeline.PipelineTask{
+		Name:    "notify",
+		TaskRef: &v1pipeline.TaskRef{Name: "taskRef5"},
+	})
+	graph.PipelineName = testPipelineName
+
+	status := func(start, duration time.Duration) *TaskStatus {
+		return &TaskStatus{
+			State:          TaskStateSucceeded,
+			StartTime:      testStart.Add(start),
+			CompletionTime: testStart.Add(start + duration),
+		}
+	}
+
+	graph.SetStatuses(map[string]*TaskStatus{
+		"task3":          status(0, time.Minute),
+		"task2":          status(time.Minute, 2*time.Minute),
+		"task1":          status(3*time.Minute, 30*time.Second),
+		"task-with-dash": status(0, time.Minute),
+		"notify":         status(3*time.Minute+30*time.Second, 10*time.Second),
+	})
+
+	return graph
+}
+
+func TestCriticalPath(t *testing.T) {
+	graph := getTestCriticalGraph(t)
+
+	path := graph.CriticalPath()
+
+	assert.Equal(t, []*TaskNode{
+		graph.Nodes["task3"], graph.Nodes["task2"], graph.Nodes["task1"], graph.Nodes["notify"],
+	}, path.Tasks)
+	assert.Equal(t, 3*time.Minute+40*time.Second, path.Duration)
+	assert.Equal(t, map[string]time.Duration{
+		"task3":          0,
+		"task2":          0,
+		"task1":          0,
+		"notify":         0,
+		"task-with-dash": 2*time.Minute + 30*time.Second,
+	}, path.Slack)
+
+	// computing the path doesn't highlight it
+	assert.False(t, graph.Nodes["task3"].Critical)
+}
+
+func TestCriticalPathWithoutDurations(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks())
+	graph.SetStatuses(map[string]*TaskStatus{
+		"task3": {State: TaskStateRunning, StartTime: testStart},
+	})
+
+	path := graph.CriticalPath()
+
+	assert.Empty(t, path.Tasks)
+	assert.Zero(t, path.Duration)
+	assert.Len(t, path.Slack, 4)
+}
+
+func TestHighlightCriticalPath(t *testing.T) {
+	graph := getTestCriticalGraph(t)
+
+	path := graph.HighlightCriticalPath()
+	require.Len(t, path.Tasks, 4)
+
+	assert.True(t, graph.Nodes["task2"].Critical)
+	assert.False(t, graph.Nodes["task-with-dash"].Critical)
+	assert.True(t, graph.Nodes["task3"].IsCriticalEdge(graph.Nodes["task2"]))
+	assert.False(t, graph.Nodes["task3"].IsCriticalEdge(graph.Nodes["task1"]))
+	assert.False(t, graph.Nodes["task1"].IsCriticalEdge(nil))
+
+	dot, err := graph.ToDOT(false)
+	assert.NoError(t, err)
+	assert.Contains(t, dot, "   \"task3\" -> \"task2\" [color=\"#d93025\" penwidth=2]\n")
+	assert.Contains(t, dot, "   \"task3\" -> \"task1\"\n")
+	assert.Contains(t, dot, "   \"task2\" [color=\"#d93025\" penwidth=2]\n")
+	assert.NotContains(t, dot, "   \"task-with-dash\" [color")
+
+	mermaid, err := graph.ToMermaid(false)
+	assert.NoError(t, err)
+	assert.Contains(t, mermaid, "   task3 ==> task2\n")
+	assert.Contains(t, mermaid, "   task3 --> task1\n")
+	assert.Contains(t, mermaid, "   style task1 stroke:#d93025,stroke-width:3px\n")
+
+	plantuml, err := graph.ToPlantUML(false)
+	assert.NoError(t, err)
+	assert.Contains(t, plantuml, "   task3 -down[#d93025,bold]-> task2\n")
+	assert.Contains(t, plantuml, "   task3 -down-> task1\n")
+	assert.Contains(t, plantuml, "   state task2 #b7e1cd;line:red;line.bold\n")
+
+	svg, err := graph.ToSVG(false)
+	assert.NoError(t, err)
+	assert.Contains(t, svg, "stroke=\"#d93025\" data-from=\"task3\" data-to=\"task2\" stroke-width=\"2\"")
+}
@@ -0,0 +1,373 @@
+package taskgraph
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Colors of the diff graphs, the node colors are the same as the Succeeded, Failed and Running states
+const (
+	diffAddedFill   = "#b7e1cd"
+	diffRemovedFill = "#f4c7c3"
+	diffChangedFill = "#fce8b2"
+	diffAddedEdge   = "#1e8e3e"
+	diffRemovedEdge = "#d93025"
+)
+
+// DiffState is the change of a task or a dependency between two graphs
+type DiffState string
+
+const (
+	DiffStateUnchanged DiffState = "unchanged"
+	DiffStateAdded     DiffState = "added"
+	DiffStateRemoved   DiffState = "removed"
+	DiffStateChanged   DiffState = "changed" // the task is renamed or its taskRef is changed
+)
+
+// DiffNode is a task of either of the compared graphs
+type DiffNode struct {
+	Name           string // Name in the new graph, or in the old graph if the task is removed
+	OldName        string // Name in the old graph if the task is renamed
+	TaskRefName    string
+	OldTaskRefName string // TaskRefName in the old graph if it is changed
+	Finally        bool
+	State          DiffState
+}
+
+// DiffEdge is a dependency of either of the compared graphs, the renamed tasks use the new name
+type DiffEdge struct {
+	From  string
+	To    string
+	State DiffState
+}
+
+// GraphDiff is the structural difference between two graphs. The nodes and the edges of both graphs are
+// merged, so the diff can be rendered as a single graph.
+type GraphDiff struct {
+	From  string // Name of the old graph
+	To    string // Name of the new graph
+	Nodes []DiffNode
+	Edges []DiffEdge
+}
+
+// Diff compares two graphs. A removed task and an added task are considered renamed when they are of the same
+// kind and reference the same task, inline tasks are never renamed.
+func Diff(from, to *TaskGraph) *GraphDiff {
+	d := &GraphDiff{From: from.PipelineName, To: to.PipelineName}
+	renames := matchRenames(from, to)
+
+	newName := func(name string) string {
+		if renamed, ok := renames[name]; ok {
+			return renamed
+		}
+
+		return name
+	}
+
+	oldNodes := make(map[string]*TaskNode, len(from.Nodes))
+	for name, node := range from.Nodes {
+		oldNodes[newName(name)] = node
+	}
+
+	for _, name := range to.sortedNames() {
+		node := to.Nodes[name]
+		dn := DiffNode{Name: name, TaskRefName: node.TaskRefName, Finally: node.IsFinally(), State: DiffStateUnchanged}
+
+		switch old, ok := oldNodes[name]; {
+		case !ok:
+			dn.State = DiffStateAdded
+		case old.Name != name:
+			dn.OldName = old.Name
+			dn.State = DiffStateChanged
+		case old.TaskRefName != node.TaskRefName:
+			dn.OldTaskRefName = old.TaskRefName
+			dn.State = DiffStateChanged
+		}
+
+		d.Nodes = append(d.Nodes, dn)
+	}
+
+	for _, name := range from.sortedNames() {
+		if _, renamed := renames[name]; renamed || to.Nodes[name] != nil {
+			continue
+		}
+
+		node := from.Nodes[name]
+		d.Nodes = append(d.Nodes, DiffNode{
+			Name: name, TaskRefName: node.TaskRefName, Finally: node.IsFinally(), State: DiffStateRemoved,
+		})
+	}
+
+	sort.SliceStable(d.Nodes, func(i, j int) bool { return d.Nodes[i].Name < d.Nodes[j].Name })
+
+	oldEdges := graphEdges(from, newName)
+	newEdges := graphEdges(to, func(name string) string { return name })
+
+	for edge := range newEdges {
+		state := DiffStateAdded
+		if oldEdges[edge] {
+			state = DiffStateUnchanged
+		}
+
+		d.Edges = append(d.Edges, DiffEdge{From: edge[0], To: edge[1], State: state})
+	}
+
+	for edge := range oldEdges {
+		if !newEdges[edge] {
+			d.Edges = append(d.Edges, DiffEdge{From: edge[0], To: edge[1], State: DiffStateRemoved})
+		}
+	}
+
+	sort.Slice(d.Edges, func(i, j int) bool {
+		if d.Edges[i].From == d.Edges[j].From {
+			return d.Edges[i].To < d.Edges[j].To
+		}
+
+		return d.Edges[i].From < d.Edges[j].From
+	})
+
+	return d
+}
+
+// matchRenames pairs the removed tasks with the added tasks referencing the same task, keyed by the old name.
+// The tasks must also have the same params or share a neighbouring task, otherwise they are reported as removed and
+// added. Tasks with the same params are preferred when several added tasks match.
+func matchRenames(from, to *TaskGraph) map[string]string {
+	renames := map[string]string{}
+	paired := map[string]bool{}
+	fromNeighbours := neighbours(from)
+	toNeighbours := neighbours(to)
+
+	for _, oldName := range from.sortedNames() {
+		old := from.Nodes[oldName]
+		if _, ok := to.Nodes[oldName]; ok || old.TaskRef.Inline || old.TaskRefName == "" {
+			continue
+		}
+
+		var match *TaskNode
+
+		for _, name := range to.sortedNames() {
+			node := to.Nodes[name]
+			if _, ok := from.Nodes[name]; ok || paired[name] || node.Kind != old.Kind || node.TaskRefName != old.TaskRefName {
+				continue
+			}
+
+			if !maps.Equal(node.Params, old.Params) && !overlaps(fromNeighbours[oldName], toNeighbours[name]) {
+				continue
+			}
+
+			if match == nil || (maps.Equal(node.Params, old.Params) && !maps.Equal(match.Params, old.Params)) {
+				match = node
+			}
+		}
+
+		if match != nil {
+			renames[oldName] = match.Name
+			paired[match.Name] = true
+		}
+	}
+
+	return renames
+}
+
+// neighbours returns the names of the tasks each task depends on or is depended on by, keyed by task name
+func neighbours(g *TaskGraph) map[string]map[string]bool {
+	names := map[string]map[string]bool{}
+
+	add := func(name, neighbour string) {
+		if names[name] == nil {
+			names[name] = map[string]bool{}
+		}
+
+		names[name][neighbour] = true
+	}
+
+	for _, node := range g.Nodes {
+		for _, dep := range node.Dependencies {
+			add(node.Name, dep.Name)
+			add(dep.Name, node.Name)
+		}
+	}
+
+	return names
+}
+
+// overlaps returns true if the sets have a name in common
+func overlaps(a, b map[string]bool) bool {
+	for name := range a {
+		if b[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// graphEdges returns the set of edges of the graph, the task names are mapped with the given function
+func graphEdges(g *TaskGraph, name func(string) string) map[[2]string]bool {
+	edges := map[[2]string]bool{}
+
+	for _, node := range g.Nodes {
+		for _, dep := range node.Dependencies {
+			edges[[2]string{name(node.Name), name(dep.Name)}] = true
+		}
+	}
+
+	return edges
+}
+
+// HasChanges returns true if any task or dependency differs between the graphs
+func (d *GraphDiff) HasChanges() bool {
+	for _, node := range d.Nodes {
+		if node.State != DiffStateUnchanged {
+			return true
+		}
+	}
+
+	for _, edge := range d.Edges {
+		if edge.State != DiffStateUnchanged {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String returns the summary of the changes: added (+), removed (-) and changed (~) tasks and dependencies
+func (d *GraphDiff) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", d.From, d.To)
+
+	if !d.HasChanges() {
+		b.WriteString("no differences\n")
+		return b.String()
+	}
+
+	var tasks, edges []string
+
+	for _, node := range d.Nodes {
+		switch {
+		case node.State == DiffStateAdded:
+			tasks = append(tasks, "+ "+node.Name)
+		case node.State == DiffStateRemoved:
+			tasks = append(tasks, "- "+node.Name)
+		case node.OldName != "":
+			tasks = append(tasks, fmt.Sprintf("~ %s: renamed from %s", node.Name, node.OldName))
+		case node.OldTaskRefName != "":
+			tasks = append(tasks, fmt.Sprintf("~ %s: taskRef changed from %s to %s", node.Name, node.OldTaskRefName, node.TaskRefName))
+		}
+	}
+
+	for _, edge := range d.Edges {
+		switch edge.State {
+		case DiffStateAdded:
+			edges = append(edges, fmt.Sprintf("+ %s -> %s", edge.From, edge.To))
+		case DiffStateRemoved:
+			edges = append(edges, fmt.Sprintf("- %s -> %s", edge.From, edge.To))
+		}
+	}
+
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{{"tasks", tasks}, {"dependencies", edges}} {
+		if len(section.lines) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s:\n", section.title)
+
+		for _, line := range section.lines {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+
+	return b.String()
+}
+
+// Label returns the label of the node in the diff graphs, with the old name or taskRef of changed tasks
+func (n DiffNode) Label() string {
+	return n.label(func(s string) string { return s })
+}
+
+// label returns the label with the names and taskRefs escaped for the format
+func (n DiffNode) label(escape func(string) string) string {
+	switch {
+	case n.OldName != "":
+		return fmt.Sprintf("%s\n(was %s)", escape(n.Name), escape(n.OldName))
+	case n.OldTaskRefName != "":
+		return fmt.Sprintf("%s\n(%s -> %s)", escape(n.Name), escape(n.OldTaskRefName), escape(n.TaskRefName))
+	default:
+		return escape(n.Name)
+	}
+}
+
+// Color returns the fill color of the node, empty for unchanged tasks
+func (n DiffNode) Color() string {
+	switch n.State {
+	case DiffStateAdded:
+		return diffAddedFill
+	case DiffStateRemoved:
+		return diffRemovedFill
+	case DiffStateChanged:
+		return diffChangedFill
+	default:
+		return ""
+	}
+}
+
+// Color returns the color of the edge, empty for unchanged dependencies
+func (e DiffEdge) Color() string {
+	switch e.State {
+	case DiffStateAdded:
+		return diffAddedEdge
+	case DiffStateRemoved:
+		return diffRemovedEdge
+	default:
+		return ""
+	}
+}
+
+// IsRemoved returns true if the edge only exists in the old graph
+func (e DiffEdge) IsRemoved() bool {
+	return e.State == DiffStateRemoved
+}
+
+// ToDOT renders the merged graphs, additions are green and removals are red
+func (d *GraphDiff) ToDOT() (string, error) {
+	return d.render("dot", "dot", diffDOTTemplate)
+}
+
+// ToMermaid renders the merged graphs, additions are green and removals are red
+func (d *GraphDiff) ToMermaid() (string, error) {
+	return d.render("mermaid", "mmd", diffMermaidTemplate)
+}
+
+func (d *GraphDiff) render(name, format, text string) (string, error) {
+	var builder strings.Builder
+
+	names := make([]string, 0, len(d.Nodes))
+	for _, node := range d.Nodes {
+		names = append(names, node.Name)
+	}
+
+	sort.Strings(names)
+
+	funcs := builtinFuncs(names, format)
+	funcs["nodeLabel"] = func(n DiffNode) string { return n.label(formatEscapers[format]) }
+
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s diff template: %w", name, err)
+	}
+
+	if err := tmpl.Execute(&builder, d); err != nil {
+		return "", fmt.Errorf("failed to execute %s diff template: %w", name, err)
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,165 @@
+package taskgraph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func getTestDiff(t *testing.T) *GraphDiff {
+	t.Helper()
+
+	from := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "clone", TaskRef: &v1pipeline.TaskRef{Name: "git-clone"}},
+		{Name: "build", TaskRef: &v1pipeline.TaskRef{Name: "golang"}, RunAfter: []string{"clone"}},
+		{Name: "lint", TaskRef: &v1pipeline.TaskRef{Name: "golangci"}, RunAfter: []string{"clone"}},
+		{Name: "test", TaskRef: &v1pipeline.TaskRef{Name: "golang"}, RunAfter: []string{"build", "lint"}},
+	})
+	from.PipelineName = "old"
+
+	to := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "fetch", TaskRef: &v1pipeline.TaskRef{Name: "git-clone"}},
+		{Name: "build", TaskRef: &v1pipeline.TaskRef{Name: "golang"}, RunAfter: []string{"fetch"}},
+		{Name: "test", TaskRef: &v1pipeline.TaskRef{Name: "go-test"}, RunAfter: []string{"build"}},
+		{Name: "deploy", TaskRef: &v1pipeline.TaskRef{Name: "kubectl"}, RunAfter: []string{"test"}},
+	})
+	to.PipelineName = "new"
+
+	return Diff(from, to)
+}
+
+func TestDiff(t *testing.T) {
+	diff := getTestDiff(t)
+
+	assert.Equal(t, []DiffNode{
+		{Name: "build", TaskRefName: "golang", State: DiffStateUnchanged},
+		{Name: "deploy", TaskRefName: "kubectl", State: DiffStateAdded},
+		{Name: "fetch", OldName: "clone", TaskRefName: "git-clone", State: DiffStateChanged},
+		{Name: "lint", TaskRefName: "golangci", State: DiffStateRemoved},
+		{Name: "test", TaskRefName: "go-test", OldTaskRefName: "golang", State: DiffStateChanged},
+	}, diff.Nodes)
+	assert.Equal(t, []DiffEdge{
+		{From: "build", To: "test", State: DiffStateUnchanged},
+		{From: "fetch", To: "build", State: DiffStateUnchanged},
+		{From: "fetch", To: "lint", State: DiffStateRemoved},
+		{From: "lint", To: "test", State: DiffStateRemoved},
+		{From: "test", To: "deploy", State: DiffStateAdded},
+	}, diff.Edges)
+	assert.True(t, diff.HasChanges())
+
+	assert.Equal(t, `--- old
++++ new
+tasks:
+  + deploy
+  ~ fetch: renamed from clone
+  - lint
+  ~ test: taskRef changed from golang to go-test
+dependencies:
+  - fetch -> lint
+  - lint -> test
+  + test -> deploy
+`, diff.String())
+}
+
+func TestDiffWithoutChanges(t *testing.T) {
+	from := mustBuildTaskGraph(t, getTestTasks())
+	from.PipelineName = "a"
+	to := mustBuildTaskGraph(t, getTestTasks())
+	to.PipelineName = "b"
+
+	diff := Diff(from, to)
+
+	assert.False(t, diff.HasChanges())
+	assert.Equal(t, "--- a\n+++ b\nno differences\n", diff.String())
+}
+
+func TestDiffRenamePrefersSameParams(t *testing.T) {
+	params := func(value string) v1pipeline.Params {
+		return v1pipeline.Params{{Name: "url", Value: *v1pipeline.NewStructuredValues(value)}}
+	}
+
+	from := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "clone", TaskRef: &v1pipeline.TaskRef{Name: "git-clone"}, Params: params("b")},
+		{Name: "inline", TaskSpec: &v1pipeline.EmbeddedTask{}},
+	})
+	to := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "clone-a", TaskRef: &v1pipeline.TaskRef{Name: "git-clone"}, Params: params("a")},
+		{Name: "clone-b", TaskRef: &v1pipeline.TaskRef{Name: "git-clone"}, Params: params("b")},
+		{Name: "embedded", TaskSpec: &v1pipeline.EmbeddedTask{}},
+	})
+
+	diff := Diff(from, to)
+
+	states := map[string]DiffState{}
+	for _, node := range diff.Nodes {
+		states[node.Name] = node.State
+	}
+
+	assert.Equal(t, map[string]DiffState{
+		"clone-a":  DiffStateAdded,
+		"clone-b":  DiffStateChanged,
+		"embedded": DiffStateAdded,
+		"inline":   DiffStateRemoved,
+	}, states)
+}
+
+func TestDiffRenameRequiresParamsOrNeighbours(t *testing.T) {
+	params := func(value string) v1pipeline.Params {
+		return v1pipeline.Params{{Name: "url", Value: *v1pipeline.NewStructuredValues(value)}}
+	}
+
+	from := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "clone", TaskRef: &v1pipeline.TaskRef{Name: "git-clone"}, Params: params("a")},
+		{Name: "build", TaskRef: &v1pipeline.TaskRef{Name: "golang"}, RunAfter: []string{"clone"}},
+		{Name: "notify", TaskRef: &v1pipeline.TaskRef{Name: "slack"}, Params: params("a")},
+	})
+	to := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "fetch", TaskRef: &v1pipeline.TaskRef{Name: "git-clone"}, Params: params("b")},
+		{Name: "build", TaskRef: &v1pipeline.TaskRef{Name: "golang"}, RunAfter: []string{"fetch"}},
+		{Name: "alert", TaskRef: &v1pipeline.TaskRef{Name: "slack"}, Params: params("b")},
+	})
+
+	diff := Diff(from, to)
+
+	states := map[string]DiffState{}
+	for _, node := range diff.Nodes {
+		states[node.Name] = node.State
+	}
+
+	// fetch still runs before build, alert has nothing in common with notify but the Task it references
+	assert.Equal(t, map[string]DiffState{
+		"alert":  DiffStateAdded,
+		"build":  DiffStateUnchanged,
+		"fetch":  DiffStateChanged,
+		"notify": DiffStateRemoved,
+	}, states)
+}
+
+func TestGraphDiffToDOT(t *testing.T) {
+	dot, err := getTestDiff(t).ToDOT()
+	require.NoError(t, err)
+
+	assert.Contains(t, dot, "   label=\"old -> new\"\n")
+	assert.Contains(t, dot, "   \"build\" [label=\"build\"]\n")
+	assert.Contains(t, dot, "   \"deploy\" [label=\"deploy\" style=\"filled\" fillcolor=\"#b7e1cd\"]\n")
+	assert.Contains(t, dot, "   \"lint\" [label=\"lint\" style=\"filled,dashed\" fillcolor=\"#f4c7c3\"]\n")
+	assert.Contains(t, dot, "   \"fetch\" [label=\"fetch\n(was clone)\" style=\"filled\" fillcolor=\"#fce8b2\"]\n")
+	assert.Contains(t, dot, "   \"build\" -> \"test\"\n")
+	assert.Contains(t, dot, "   \"lint\" -> \"test\" [color=\"#d93025\" penwidth=2 style=\"dashed\"]\n")
+	assert.Contains(t, dot, "   \"test\" -> \"deploy\" [color=\"#1e8e3e\" penwidth=2]\n")
+}
+
+func TestGraphDiffToMermaid(t *testing.T) {
+	mermaid, err := getTestDiff(t).ToMermaid()
+	require.NoError(t, err)
+
+	assert.Contains(t, mermaid, "title: old -> new\n")
+	assert.Contains(t, mermaid, "   test(\"test\n(golang -> go-test)\")\n   style test fill:#fce8b2\n")
+	assert.Contains(t, mermaid, "   lint -.-> test\n")
+	assert.Contains(t, mermaid, "   test --> deploy\n")
+	assert.Contains(t, mermaid, "   linkStyle 2 stroke:#d93025,stroke-width:2px\n")
+	assert.Contains(t, mermaid, "   linkStyle 4 stroke:#1e8e3e,stroke-width:2px\n")
+	assert.NotContains(t, mermaid, "linkStyle 0")
+}
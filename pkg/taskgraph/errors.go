@@ -0,0 +1,63 @@
+package taskgraph
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrUnknownDependency is returned when a task runs after or consumes results of a task which doesn't exist
+	ErrUnknownDependency = errors.New("unknown dependency")
+	// ErrSelfDependency is returned when a task runs after itself
+	ErrSelfDependency = errors.New("self dependency")
+	// ErrCycle is returned when the tasks depend on each other in a loop
+	ErrCycle = errors.New("dependency cycle")
+	// ErrDuplicateTask is returned when several tasks have the same name
+	ErrDuplicateTask = errors.New("duplicate task name")
+)
+
+// ValidationError is a single problem found while building a TaskGraph
+type ValidationError struct {
+	Err        error    // One of ErrUnknownDependency, ErrSelfDependency, ErrCycle or ErrDuplicateTask
+	Task       string   // Name of the task with the problem
+	Dependency string   // Name of the missing dependency, only for ErrUnknownDependency
+	Path       []string // Tasks forming the cycle, the first task is repeated at the end, only for ErrCycle
+}
+
+func (e *ValidationError) Error() string {
+	switch {
+	case errors.Is(e.Err, ErrUnknownDependency):
+		return fmt.Sprintf("%s: task %q depends on unknown task %q", e.Err, e.Task, e.Dependency)
+	case errors.Is(e.Err, ErrCycle):
+		return fmt.Sprintf("%s: %s", e.Err, strings.Join(e.Path, " -> "))
+	default:
+		return fmt.Sprintf("%s: %q", e.Err, e.Task)
+	}
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors holds all the problems found while building a TaskGraph
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap allows errors.Is and errors.As to inspect every problem
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
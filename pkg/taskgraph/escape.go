@@ -0,0 +1,251 @@
+package taskgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// formatEscapers escape a string for the quoted labels and strings of the formats
+var formatEscapers = map[string]func(string) string{
+	"dot":  escapeDOT,
+	"puml": escapePlantUML,
+	"mmd":  escapeMermaid,
+	"json": escapeJSON,
+	"yaml": escapeJSON, // the JSON escapes are valid in double-quoted YAML strings
+	"svg":  html.EscapeString,
+	"html": html.EscapeString,
+}
+
+// idScheme is how the task names are turned into the node identifiers of a format
+type idScheme struct {
+	// natural returns the identifier of a name, false if it isn't a valid identifier of the format
+	natural func(name string) (string, bool)
+	// keywords can't be used as identifiers, they are compared case-insensitively
+	keywords map[string]bool
+	// escape is applied to the identifiers when they are written, for the formats with quoted identifiers
+	escape func(string) string
+}
+
+var (
+	mermaidID  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(-[A-Za-z0-9_]+)*$`)
+	plantUMLID = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+var idSchemes = map[string]idScheme{
+	// the identifiers are quoted, any name can be used
+	"dot": {
+		natural: func(name string) (string, bool) { return name, true },
+		escape:  escapeDOT,
+	},
+	"mmd": {
+		natural: func(name string) (string, bool) { return name, mermaidID.MatchString(name) },
+		keywords: keywords("end", "graph", "flowchart", "flowchart-elk", "subgraph", "direction", "style",
+			"classDef", "class", "linkStyle", "click", "call", "href", "interpolate", "default"),
+	},
+	// the states are named after the tasks, with _ instead of -
+	"puml": {
+		natural: func(name string) (string, bool) {
+			id := strings.ReplaceAll(name, "-", "_")
+
+			return id, plantUMLID.MatchString(id)
+		},
+		keywords: keywords("state", "end", "as", "note", "title", "hide", "show", "skinparam", "scale", "legend",
+			"header", "footer", "caption", "left", "right", "up", "down", "top", "bottom", "of", "over", "fork",
+			"join", "choice", "history"),
+	},
+}
+
+func keywords(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[strings.ToLower(w)] = true
+	}
+
+	return m
+}
+
+// nodeIDs are the identifiers of the nodes of a graph in a format. The tasks keep the identifier derived from
+// their name when it is valid, the others get a synthetic one, task_ followed by the name with the invalid
+// characters replaced by _. The identifiers only depend on the task names, so they are stable between runs.
+type nodeIDs struct {
+	scheme idScheme
+	ids    map[string]string // keyed by the task name
+	extras map[string]string // identifiers of the nodes added by the templates, keyed by their preferred name
+	used   map[string]bool
+}
+
+// newNodeIDs assigns the identifiers of the tasks, names must be sorted so the conflicts are always resolved
+// the same way
+func newNodeIDs(names []string, format string) *nodeIDs {
+	n := &nodeIDs{
+		scheme: idSchemes[format],
+		ids:    make(map[string]string, len(names)),
+		extras: map[string]string{},
+		used:   map[string]bool{},
+	}
+
+	var synthetic []string
+
+	// the natural identifiers first, so a task doesn't lose its identifier to the synthetic one of another
+	for _, name := range names {
+		if id, ok := n.natural(name); ok && !n.used[id] {
+			n.ids[name] = id
+			n.used[id] = true
+		} else {
+			synthetic = append(synthetic, name)
+		}
+	}
+
+	for _, name := range synthetic {
+		n.ids[name] = n.unused(syntheticID(name))
+	}
+
+	return n
+}
+
+func (n *nodeIDs) natural(name string) (string, bool) {
+	if n.scheme.natural == nil {
+		return identifier(name), true
+	}
+
+	id, ok := n.scheme.natural(name)
+
+	return id, ok && !n.scheme.keywords[strings.ToLower(id)]
+}
+
+// unused returns id, or id followed by the lowest number which isn't used yet, and marks it as used
+func (n *nodeIDs) unused(id string) string {
+	candidate := id
+	for i := 2; n.used[candidate]; i++ {
+		candidate = id + "_" + strconv.Itoa(i)
+	}
+
+	n.used[candidate] = true
+
+	return candidate
+}
+
+// id returns the identifier of the task, escaped for the quoted identifiers
+func (n *nodeIDs) id(name string) string {
+	id, ok := n.ids[name]
+	if !ok {
+		// not a task of the graph, e.g. a dependency missing from a graph read from a file
+		id = n.unused(syntheticID(name))
+		n.ids[name] = id
+	}
+
+	if n.scheme.escape != nil {
+		return n.scheme.escape(id)
+	}
+
+	return id
+}
+
+// renamed returns true if the identifier of the task isn't the natural one, the templates must label the node
+// with the name of the task
+func (n *nodeIDs) renamed(name string) bool {
+	n.id(name)
+
+	natural, ok := n.natural(name)
+
+	return !ok || n.ids[name] != natural
+}
+
+// extra returns the identifier of a node added by the templates, e.g. start: the preferred name unless
+// a task already uses it
+func (n *nodeIDs) extra(name string) string {
+	id, ok := n.extras[name]
+	if !ok {
+		id = n.unused(name)
+		n.extras[name] = id
+	}
+
+	return id
+}
+
+// builtinFuncs are the functions of the built-in templates of the format:
+//
+//	id name      the identifier of the node of the task
+//	extraID s    the identifier of a node added by the template, e.g. start
+//	renamed name true if the identifier of the task isn't derived from its name, it must be labeled with it
+//	label s      escapes s for a quoted string of the format
+//	yamlScalar s s as a YAML scalar, quoted if needed, for the front matter of mermaid
+func builtinFuncs(names []string, format string) template.FuncMap {
+	ids := newNodeIDs(names, format)
+
+	label := formatEscapers[format]
+	if label == nil {
+		label = func(s string) string { return s }
+	}
+
+	return template.FuncMap{
+		"id":         ids.id,
+		"extraID":    ids.extra,
+		"renamed":    ids.renamed,
+		"label":      label,
+		"yamlScalar": yamlScalar,
+	}
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// syntheticID is the identifier of the tasks whose name can't be used, before the conflicts are resolved
+func syntheticID(name string) string {
+	return "task_" + nonIdentifierChars.ReplaceAllString(name, "_")
+}
+
+// identifier turns a name into an identifier accepted by the DOT, PlantUML and Mermaid formats
+func identifier(s string) string {
+	id := nonIdentifierChars.ReplaceAllString(s, "_")
+	if id == "" || (id[0] >= '0' && id[0] <= '9') {
+		id = "_" + id
+	}
+
+	return id
+}
+
+func escapeDOT(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func escapePlantUML(s string) string {
+	return strings.NewReplacer(`"`, `'`, "\n", `\n`).Replace(s)
+}
+
+func escapeMermaid(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "\n", "<br/>").Replace(s)
+}
+
+func escapeJSON(s string) string {
+	var b strings.Builder
+
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+
+	// without the quotes and the newline added by Encode
+	quoted := strings.TrimSuffix(b.String(), "\n")
+
+	return quoted[1 : len(quoted)-1]
+}
+
+var yamlPlain = regexp.MustCompile(`^[A-Za-z0-9_./()][A-Za-z0-9_./() <>-]*$`)
+
+// yamlScalar returns s unchanged if it is a plain YAML string, quoted otherwise
+func yamlScalar(s string) string {
+	if yamlPlain.MatchString(s) && !strings.HasSuffix(s, " ") {
+		if _, err := strconv.ParseFloat(s, 64); err != nil && !yamlKeywords[strings.ToLower(s)] {
+			return s
+		}
+	}
+
+	return fmt.Sprintf(`"%s"`, escapeJSON(s))
+}
+
+// yamlKeywords are the plain scalars YAML reads as booleans or null
+var yamlKeywords = keywords("true", "false", "yes", "no", "on", "off", "y", "n", "null", "~")
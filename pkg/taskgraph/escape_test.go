@@ -0,0 +1,572 @@
+package taskgraph
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"gonum.org/v1/gonum/graph/formats/dot"
+	"gonum.org/v1/gonum/graph/formats/dot/ast"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+func TestNodeIDs(t *testing.T) {
+	names := []string{"1-build", "a.b", "a_b", "build", "end", "graph", "start", "task-end", "task_end"}
+
+	testCases := []struct {
+		format   string
+		expected []string
+	}{
+		{
+			format:   "mmd",
+			expected: []string{"task_1_build", "task_a_b", "a_b", "build", "task_end_2", "task_graph", "start", "task-end", "task_end"},
+		},
+		{
+			format:   "puml",
+			expected: []string{"task_1_build", "task_a_b", "a_b", "build", "task_end_2", "graph", "start", "task_end", "task_task_end"},
+		},
+		{
+			format:   "dot",
+			expected: names,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			ids := newNodeIDs(names, tc.format)
+
+			for i, name := range names {
+				assert.Equal(t, tc.expected[i], ids.id(name), name)
+			}
+
+			// the nodes added by the templates don't take the identifier of a task
+			assert.Equal(t, "start_2", ids.extra("start"))
+			assert.Equal(t, "start_2", ids.extra("start"))
+			assert.Equal(t, "stop", ids.extra("stop"))
+		})
+	}
+}
+
+func TestBuiltinTemplatesReservedNames(t *testing.T) {
+	graph := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "start"},
+		{Name: "end", RunAfter: []string{"start"}},
+		{Name: "graph", RunAfter: []string{"end"}},
+	})
+	graph.PipelineName = `say "hi"`
+
+	mermaid, err := graph.ToMermaid(false)
+	require.NoError(t, err)
+	assert.Equal(t, `---
+title: "say \"hi\""
+---
+flowchart TD
+   task_end --> task_graph
+   task_graph --> stop([fa:fa-circle])
+   start_2([fa:fa-circle]) --> start
+   start --> task_end
+   task_end("end")
+   task_graph("graph")
+`, mermaid)
+
+	dotOutput, err := graph.ToDOT(false)
+	require.NoError(t, err)
+	assert.Contains(t, dotOutput, `label="say \"hi\""`)
+	assert.Contains(t, dotOutput, `end_2 [shape="point" width=0.2]`)
+	assert.Contains(t, dotOutput, `"start_2" -> "start"`)
+	assert.Contains(t, dotOutput, `"graph" -> "end_2"`)
+
+	puml, err := graph.ToPlantUML(false)
+	require.NoError(t, err)
+	assert.Contains(t, puml, "title say 'hi'\nstate \"end\" as task_end\n")
+	assert.Contains(t, puml, "   start -down-> task_end\n")
+}
+
+func TestYAMLScalar(t *testing.T) {
+	assert.Equal(t, "ns/release-1.2", yamlScalar("ns/release-1.2"))
+	assert.Equal(t, "old -> new", yamlScalar("old -> new"))
+	assert.Equal(t, `"a: b"`, yamlScalar("a: b"))
+	assert.Equal(t, `"true"`, yamlScalar("true"))
+	assert.Equal(t, `"1.5"`, yamlScalar("1.5"))
+	assert.Equal(t, `"-x"`, yamlScalar("-x"))
+	assert.Equal(t, `""`, yamlScalar(""))
+}
+
+// FuzzRenderedOutputs checks the built-in formats for any valid Tekton task and pipeline names. The DOT output is
+// parsed by gonum. The Mermaid and PlantUML outputs are read back by the test: every line must match one of the
+// statements the templates produce, and the dependencies read from the edges must be the ones of the graph, so every
+// identifier maps back to its task. Those regexes follow the templates, not the grammars of Mermaid and PlantUML, so
+// they don't prove the outputs are accepted by the tools.
+func FuzzRenderedOutputs(f *testing.F) {
+	f.Add("build end graph subgraph start stop finally-tasks style class", "release")
+	f.Add("a a-b a-b-c 1-build 2 x o end-1", "my.pipeline")
+	f.Add("state as note title end task-end task-end-2 hide", "ns-1.release")
+
+	f.Fuzz(func(t *testing.T, names, pipeline string) {
+		graph := fuzzGraph(t, strings.Fields(names))
+		if graph == nil {
+			return
+		}
+
+		graph.PipelineName = "pipeline"
+		if len(validation.IsDNS1123Subdomain(pipeline)) == 0 {
+			graph.PipelineName = pipeline
+		}
+
+		for _, withStatus := range []bool{false, true} {
+			if withStatus {
+				setFuzzStatuses(graph)
+			}
+
+			for _, withTaskRef := range []bool{false, true} {
+				checkDOT(t, graph, withTaskRef)
+				checkMermaid(t, graph, withTaskRef)
+				checkPlantUML(t, graph, withTaskRef)
+			}
+
+			checkDocuments(t, graph)
+			checkSVG(t, graph)
+		}
+	})
+}
+
+// fuzzGraph builds a tree of the valid names, the odd tasks consume a result of their parent and the last task
+// is a finally task if there are more than two
+func fuzzGraph(t *testing.T, names []string) *TaskGraph {
+	t.Helper()
+
+	seen := map[string]bool{}
+
+	var valid []string
+
+	for _, name := range names {
+		if len(validation.IsDNS1123Label(name)) == 0 && !seen[name] {
+			seen[name] = true
+			valid = append(valid, name)
+		}
+	}
+
+	if len(valid) == 0 {
+		return nil
+	}
+
+	var finally []v1pipeline.PipelineTask
+
+	if len(valid) > 2 {
+		finally = append(finally, v1pipeline.PipelineTask{Name: valid[len(valid)-1], TaskRef: &v1pipeline.TaskRef{Name: "notify"}})
+		valid = valid[:len(valid)-1]
+	}
+
+	tasks := make([]v1pipeline.PipelineTask, 0, len(valid))
+
+	for i, name := range valid {
+		task := v1pipeline.PipelineTask{Name: name, TaskRef: &v1pipeline.TaskRef{Name: name + "-ref"}}
+
+		switch {
+		case i == 0:
+		case i%2 == 1:
+			task.Params = v1pipeline.Params{{
+				Name:  "input",
+				Value: *v1pipeline.NewStructuredValues(fmt.Sprintf("$(tasks.%s.results.out)", valid[(i-1)/2])),
+			}}
+		default:
+			task.RunAfter = []string{valid[(i-1)/2]}
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	return mustBuildTaskGraph(t, tasks, finally...)
+}
+
+func setFuzzStatuses(graph *TaskGraph) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	statuses := map[string]*TaskStatus{}
+
+	for i, name := range graph.sortedNames() {
+		statuses[name] = &TaskStatus{
+			State:          TaskStateSucceeded,
+			StartTime:      start,
+			CompletionTime: start.Add(time.Duration(i+1) * time.Minute),
+		}
+	}
+
+	graph.SetStatuses(statuses)
+	graph.HighlightCriticalPath()
+}
+
+func checkDOT(t *testing.T, graph *TaskGraph, withTaskRef bool) {
+	t.Helper()
+
+	output, err := graph.ToDOT(withTaskRef)
+	require.NoError(t, err)
+
+	file, err := dot.ParseString(output)
+	require.NoError(t, err, output)
+	require.Len(t, file.Graphs, 1)
+
+	ids := map[string]bool{}
+	collectDOTNodes(file.Graphs[0].Stmts, ids)
+
+	// the tasks and the start and end points
+	assert.Len(t, ids, len(graph.Nodes)+2, output)
+
+	for name, node := range graph.Nodes {
+		id := name
+		if withTaskRef {
+			id = fmt.Sprintf("%s\n(%s)", name, node.TaskRefName)
+		}
+
+		assert.True(t, ids[id], "no node for %s in\n%s", name, output)
+	}
+
+	for _, attr := range file.Graphs[0].Stmts {
+		if a, ok := attr.(*ast.Attr); ok && a.Key == "label" {
+			assert.Equal(t, graph.Title(), unquoteDOT(a.Val))
+		}
+	}
+}
+
+func collectDOTNodes(stmts []ast.Stmt, ids map[string]bool) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.NodeStmt:
+			ids[unquoteDOT(s.Node.ID)] = true
+		case *ast.EdgeStmt:
+			collectDOTVertex(s.From, ids)
+
+			for edge := s.To; edge != nil; edge = edge.To {
+				collectDOTVertex(edge.Vertex, ids)
+			}
+		case *ast.Subgraph:
+			collectDOTNodes(s.Stmts, ids)
+		}
+	}
+}
+
+func collectDOTVertex(v ast.Vertex, ids map[string]bool) {
+	switch v := v.(type) {
+	case *ast.Node:
+		ids[unquoteDOT(v.ID)] = true
+	case *ast.Subgraph:
+		collectDOTNodes(v.Stmts, ids)
+	}
+}
+
+func unquoteDOT(id string) string {
+	if len(id) < 2 || id[0] != '"' {
+		return id
+	}
+
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(id[1 : len(id)-1])
+}
+
+var (
+	mermaidShape     = `(?:\("[^"]*"\)|\(\[fa:fa-circle\]\)|\["[^"]*"\])`
+	mermaidNode      = regexp.MustCompile(`^(\S+?)` + mermaidShape + `?$`)
+	mermaidNodeLabel = regexp.MustCompile(`^\S+?\("([^"]*)"\)$`)
+	mermaidEdge      = regexp.MustCompile(`^(\S+?` + mermaidShape + `?) (?:-->|-\.->|==>) (\S+?` + mermaidShape + `?)$`)
+	mermaidSubgraph  = regexp.MustCompile(`^subgraph (\S+) \[finally\]$`)
+	mermaidStyle     = regexp.MustCompile(`^style (\S+) [a-z-]+:#[0-9a-f]{6}(,[a-z-]+:[0-9a-z#]+)*$`)
+)
+
+// checkMermaid checks the statements of the flowchart, the labels spanning several lines are joined first
+func checkMermaid(t *testing.T, graph *TaskGraph, withTaskRef bool) {
+	t.Helper()
+
+	output, err := graph.ToMermaid(withTaskRef)
+	require.NoError(t, err)
+
+	parts := strings.SplitN(output, "---\n", 3)
+	require.Len(t, parts, 3, output)
+
+	var front map[string]any
+	require.NoError(t, yaml.Unmarshal([]byte(parts[1]), &front), output)
+	assert.Equal(t, graph.Title(), front["title"])
+
+	body, ok := strings.CutPrefix(parts[2], "flowchart TD\n")
+	require.True(t, ok, output)
+
+	ids := map[string]bool{}
+	labels := map[string]string{}
+	// the start and stop points and the finally subgraph aren't tasks
+	pseudo := map[string]bool{}
+
+	var edges [][2]string
+
+	for _, stmt := range joinQuotedLines(body) {
+		stmt = strings.TrimSpace(stmt)
+
+		switch {
+		case stmt == "end":
+		case mermaidSubgraph.MatchString(stmt):
+			id := mermaidSubgraph.FindStringSubmatch(stmt)[1]
+			assertMermaidID(t, id, output)
+			pseudo[id] = true
+		case mermaidStyle.MatchString(stmt):
+			ids[mermaidStyle.FindStringSubmatch(stmt)[1]] = true
+		case mermaidEdge.MatchString(stmt):
+			match := mermaidEdge.FindStringSubmatch(stmt)
+
+			var edge [2]string
+
+			for i, node := range match[1:] {
+				edge[i] = checkMermaidNode(t, node, ids, labels, output)
+				if strings.HasSuffix(node, "([fa:fa-circle])") {
+					pseudo[edge[i]] = true
+				}
+			}
+
+			edges = append(edges, edge)
+		default:
+			checkMermaidNode(t, stmt, ids, labels, output)
+		}
+	}
+
+	// the tasks, the start and stop points and the finally subgraph
+	expected := len(graph.Nodes) + 2
+	if len(graph.FinallyNodes()) > 0 {
+		expected++
+	}
+
+	assert.Len(t, ids, expected, output)
+
+	// every task is labeled with its name, unless its identifier is its name
+	for name := range graph.Nodes {
+		found := ids[name] && !strings.Contains(labels[name], "\n")
+		for _, label := range labels {
+			found = found || strings.HasPrefix(label, name+"\n") || label == name
+		}
+
+		assert.True(t, found, "no node for %s in\n%s", name, output)
+	}
+
+	// the labels start with the name of the task, the nodes without a label are identified by it
+	assertDependencies(t, graph, edges, pseudo, func(id string) string {
+		if label, ok := labels[id]; ok {
+			name, _, _ := strings.Cut(label, "\n")
+			return name
+		}
+
+		return id
+	}, output)
+}
+
+// checkMermaidNode checks the node of a statement and returns its identifier
+func checkMermaidNode(t *testing.T, node string, ids map[string]bool, labels map[string]string, output string) string {
+	t.Helper()
+
+	match := mermaidNode.FindStringSubmatch(node)
+	if !assert.NotNil(t, match, "invalid statement %q in\n%s", node, output) {
+		return ""
+	}
+
+	assertMermaidID(t, match[1], output)
+	ids[match[1]] = true
+
+	if label := mermaidNodeLabel.FindStringSubmatch(node); label != nil {
+		labels[match[1]] = strings.ReplaceAll(label[1], "\n   ", "\n")
+	}
+
+	return match[1]
+}
+
+// assertDependencies checks that the edges between the tasks read from the output are the dependencies of the graph.
+// The edges from or to the pseudo nodes are skipped, name returns the task of an identifier.
+func assertDependencies(
+	t *testing.T, graph *TaskGraph, edges [][2]string, pseudo map[string]bool, name func(id string) string, output string,
+) {
+	t.Helper()
+
+	expected := map[string]bool{}
+
+	for _, node := range graph.Nodes {
+		for _, dep := range node.Dependencies {
+			expected[node.Name+" -> "+dep.Name] = true
+		}
+	}
+
+	actual := map[string]bool{}
+
+	for _, edge := range edges {
+		if !pseudo[edge[0]] && !pseudo[edge[1]] {
+			actual[name(edge[0])+" -> "+name(edge[1])] = true
+		}
+	}
+
+	assert.Equal(t, slices.Sorted(maps.Keys(expected)), slices.Sorted(maps.Keys(actual)), output)
+}
+
+func assertMermaidID(t *testing.T, id, output string) {
+	t.Helper()
+
+	assert.Regexp(t, mermaidID, id, output)
+	assert.False(t, idSchemes["mmd"].keywords[strings.ToLower(id)], "keyword %s used as identifier in\n%s", id, output)
+}
+
+// joinQuotedLines splits the text in lines, the lines inside double quotes are joined with the next line
+func joinQuotedLines(text string) []string {
+	var stmts []string
+
+	var current strings.Builder
+
+	quoted := false
+
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '\n' && !quoted:
+			stmts = append(stmts, current.String())
+			current.Reset()
+
+			continue
+		}
+
+		current.WriteRune(r)
+	}
+
+	if current.Len() > 0 {
+		stmts = append(stmts, current.String())
+	}
+
+	return stmts
+}
+
+var (
+	plantUMLState     = regexp.MustCompile(`^state (\S+)( #[0-9a-f]{6}(;line:red;line\.bold)?| ##\[bold\]red)?$`)
+	plantUMLAlias     = regexp.MustCompile(`^state "([^"\n]*)" as (\S+)( \{)?$`)
+	plantUMLEdge      = regexp.MustCompile(`^(\S+) -(?:-|down(?:\[[a-z#0-9,]+\])?-)> (\S+)$`)
+	plantUMLDescribed = regexp.MustCompile(`^(\S+): [^\n]*$`)
+)
+
+func checkPlantUML(t *testing.T, graph *TaskGraph, withTaskRef bool) {
+	t.Helper()
+
+	output, err := graph.ToPlantUML(withTaskRef)
+	require.NoError(t, err)
+
+	ids := map[string]bool{}
+	aliases := map[string]string{}
+	// the initial and final pseudo states and the finally composite state aren't tasks
+	pseudo := map[string]bool{"[*]": true}
+
+	var edges [][2]string
+
+	state := func(id string) {
+		if id == "[*]" {
+			return
+		}
+
+		assert.Regexp(t, plantUMLID, id, output)
+		assert.False(t, idSchemes["puml"].keywords[strings.ToLower(id)], "keyword %s used as identifier in\n%s", id, output)
+		ids[id] = true
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	require.Equal(t, "@startuml", lines[0])
+	require.Equal(t, "@enduml", lines[len(lines)-1])
+
+	for _, line := range lines[1 : len(lines)-1] {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "", line == "}", line == "hide empty description":
+		case strings.HasPrefix(line, "title "):
+			assert.Equal(t, graph.Title(), strings.TrimPrefix(line, "title "))
+		case plantUMLAlias.MatchString(line):
+			match := plantUMLAlias.FindStringSubmatch(line)
+			state(match[2])
+
+			if match[3] != "" {
+				pseudo[match[2]] = true
+			} else {
+				aliases[match[1]] = match[2]
+			}
+		case plantUMLState.MatchString(line):
+			state(plantUMLState.FindStringSubmatch(line)[1])
+		case plantUMLEdge.MatchString(line):
+			match := plantUMLEdge.FindStringSubmatch(line)
+			state(match[1])
+			state(match[2])
+			edges = append(edges, [2]string{match[1], match[2]})
+		case plantUMLDescribed.MatchString(line):
+			state(plantUMLDescribed.FindStringSubmatch(line)[1])
+		default:
+			assert.Fail(t, "invalid statement", "%q in\n%s", line, output)
+		}
+	}
+
+	expected := len(graph.Nodes)
+	if len(graph.FinallyNodes()) > 0 {
+		expected++
+	}
+
+	assert.Len(t, ids, expected, output)
+
+	for name := range graph.Nodes {
+		id := strings.ReplaceAll(name, "-", "_")
+		if alias, ok := aliases[name]; ok {
+			id = alias
+		}
+
+		assert.True(t, ids[id], "no state for %s in\n%s", name, output)
+	}
+
+	// the states without an alias are identified by the name of the task, with underscores instead of dashes
+	names := map[string]string{}
+	for name, id := range aliases {
+		names[id] = name
+	}
+
+	assertDependencies(t, graph, edges, pseudo, func(id string) string {
+		if name, ok := names[id]; ok {
+			return name
+		}
+
+		return strings.ReplaceAll(id, "_", "-")
+	}, output)
+}
+
+func checkDocuments(t *testing.T, graph *TaskGraph) {
+	t.Helper()
+
+	for _, format := range []string{"json", "yaml"} {
+		output, err := renderString(graph, format, RenderOptions{})
+		require.NoError(t, err)
+
+		graphs, err := ReadGraphs(strings.NewReader(output))
+		require.NoError(t, err, output)
+		require.Len(t, graphs, 1)
+		assert.Equal(t, graph.Document(), graphs[0].Document())
+	}
+}
+
+func checkSVG(t *testing.T, graph *TaskGraph) {
+	t.Helper()
+
+	output, err := graph.ToSVG(true)
+	require.NoError(t, err)
+
+	decoder := xml.NewDecoder(strings.NewReader(output))
+
+	for {
+		_, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err, output)
+	}
+}
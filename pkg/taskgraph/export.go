@@ -0,0 +1,215 @@
+package taskgraph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigyaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// GraphAPIVersion is the version of the exported graph format, it is bumped on breaking changes
+	GraphAPIVersion = "tkn-graph/v1"
+	// GraphKind is the kind of the exported graph documents
+	GraphKind = "TaskGraph"
+)
+
+// GraphDocument is the stable, machine-readable representation of a TaskGraph used by the json and yaml formats.
+// Nodes are sorted by name and edges by their origin, so the output is reproducible.
+type GraphDocument struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Metadata   GraphMetadata  `json:"metadata"`
+	Nodes      []NodeDocument `json:"nodes"`
+	Edges      []EdgeDocument `json:"edges"`
+}
+
+// GraphMetadata describes the graph
+type GraphMetadata struct {
+	Name      string `json:"name"`                // Name of the Pipeline or PipelineRun
+	Namespace string `json:"namespace,omitempty"` // Only set when the graphs come from several namespaces
+}
+
+// NodeDocument is a task of the graph
+type NodeDocument struct {
+	Name    string            `json:"name"`
+	Kind    NodeKind          `json:"kind"`
+	TaskRef *TaskRef          `json:"taskRef,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+	Status  *TaskStatus       `json:"status,omitempty"` // Only set for graphs of PipelineRuns
+}
+
+// EdgeDocument is a dependency between two tasks, the From task runs before the To task
+type EdgeDocument struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+}
+
+// Document returns the exported representation of the graph
+func (g *TaskGraph) Document() *GraphDocument {
+	doc := &GraphDocument{
+		APIVersion: GraphAPIVersion,
+		Kind:       GraphKind,
+		Metadata:   GraphMetadata{Name: g.PipelineName, Namespace: g.Namespace},
+		Nodes:      make([]NodeDocument, 0, len(g.Nodes)),
+		Edges:      []EdgeDocument{},
+	}
+
+	for _, name := range g.sortedNames() {
+		node := g.Nodes[name]
+
+		nodeDoc := NodeDocument{
+			Name:   node.Name,
+			Kind:   node.Kind,
+			Params: node.Params,
+			Status: node.Status,
+		}
+		if nodeDoc.Kind == "" {
+			nodeDoc.Kind = NodeKindTask
+		}
+
+		// every reference has a kind, nodes without kind don't reference anything
+		if node.TaskRef.Kind != "" {
+			ref := node.TaskRef
+			nodeDoc.TaskRef = &ref
+		}
+
+		doc.Nodes = append(doc.Nodes, nodeDoc)
+
+		for _, dep := range node.Dependencies {
+			doc.Edges = append(doc.Edges, EdgeDocument{
+				From: node.Name,
+				To:   dep.Name,
+				Kind: node.EdgeKind(dep),
+			})
+		}
+	}
+
+	return doc
+}
+
+func (g *TaskGraph) ToJSON() (string, error) {
+	data, err := json.MarshalIndent(g.Document(), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal graph to json: %w", err)
+	}
+
+	return string(data) + "\n", nil
+}
+
+func (g *TaskGraph) ToYAML() (string, error) {
+	data, err := sigyaml.Marshal(g.Document())
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal graph to yaml: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// ReadGraphs decodes the graphs previously exported in the json or yaml format.
+// The input may contain several JSON objects or YAML documents, one per graph.
+func ReadGraphs(r io.Reader) ([]*TaskGraph, error) {
+	var graphs []*TaskGraph
+
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+
+	for {
+		doc := &GraphDocument{}
+		if err := decoder.Decode(doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("failed to decode graph: %w", err)
+		}
+
+		// empty YAML documents, e.g. a leading "---"
+		if doc.APIVersion == "" && doc.Kind == "" && len(doc.Nodes) == 0 {
+			continue
+		}
+
+		graph, err := doc.TaskGraph()
+		if err != nil {
+			return nil, err
+		}
+
+		graphs = append(graphs, graph)
+	}
+
+	return graphs, nil
+}
+
+// TaskGraph rebuilds the graph from the document. Unknown versions are rejected and the dependencies
+// are validated the same way as by BuildTaskGraph.
+func (d *GraphDocument) TaskGraph() (*TaskGraph, error) {
+	if d.APIVersion != GraphAPIVersion || d.Kind != GraphKind {
+		return nil, fmt.Errorf("unsupported graph %s %s, expected %s %s", d.APIVersion, d.Kind, GraphAPIVersion, GraphKind)
+	}
+
+	graph := &TaskGraph{
+		PipelineName: d.Metadata.Name,
+		Namespace:    d.Metadata.Namespace,
+		Nodes:        make(map[string]*TaskNode, len(d.Nodes)),
+	}
+
+	var errs ValidationErrors
+
+	for i := range d.Nodes {
+		nodeDoc := &d.Nodes[i]
+		if _, ok := graph.Nodes[nodeDoc.Name]; ok {
+			errs = append(errs, &ValidationError{Err: ErrDuplicateTask, Task: nodeDoc.Name})
+			continue
+		}
+
+		node := &TaskNode{
+			Name:   nodeDoc.Name,
+			Kind:   nodeDoc.Kind,
+			Params: nodeDoc.Params,
+			Status: nodeDoc.Status,
+			IsRoot: nodeDoc.Kind != NodeKindFinally,
+		}
+		if node.Kind == "" {
+			node.Kind = NodeKindTask
+		}
+
+		if nodeDoc.TaskRef != nil {
+			node.TaskRef = *nodeDoc.TaskRef
+			node.TaskRefName = node.TaskRef.String()
+		}
+
+		graph.Nodes[node.Name] = node
+	}
+
+	for _, edge := range d.Edges {
+		from, ok := graph.Nodes[edge.From]
+		if !ok {
+			errs = append(errs, &ValidationError{Err: ErrUnknownDependency, Task: edge.To, Dependency: edge.From})
+			continue
+		}
+
+		to, ok := graph.Nodes[edge.To]
+		if !ok {
+			errs = append(errs, &ValidationError{Err: ErrUnknownDependency, Task: edge.From, Dependency: edge.To})
+			continue
+		}
+
+		kind := edge.Kind
+		if kind == "" {
+			kind = EdgeKindRunAfter
+		}
+
+		from.addDependency(to, kind)
+	}
+
+	errs = append(errs, graph.findCycles()...)
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid graph %s: %w", d.Metadata.Name, errs)
+	}
+
+	return graph, nil
+}
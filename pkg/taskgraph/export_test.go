@@ -0,0 +1,212 @@
+package taskgraph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func getTestExportGraph(t *testing.T) *TaskGraph {
+	t.Helper()
+
+	graph := mustBuildTaskGraph(t, getTestResultRefTasks(), v1pipeline.PipelineTask{
+		Name:    "notify",
+		TaskRef: &v1pipeline.TaskRef{Name: "slack", Kind: v1pipeline.ClusterTaskRefKind},
+	})
+	graph.PipelineName = testPipelineName
+	graph.SetStatuses(map[string]*TaskStatus{
+		"build":  {State: TaskStateSucceeded, StartTime: testStart, CompletionTime: testEnd},
+		"notify": {State: TaskStateSkipped},
+	})
+
+	return graph
+}
+
+func TestTaskGraphDocument(t *testing.T) {
+	doc := getTestExportGraph(t).Document()
+
+	assert.Equal(t, GraphAPIVersion, doc.APIVersion)
+	assert.Equal(t, GraphKind, doc.Kind)
+	assert.Equal(t, testPipelineName, doc.Metadata.Name)
+	assert.Equal(t, []NodeDocument{
+		{
+			Name:    "build",
+			Kind:    NodeKindTask,
+			TaskRef: &TaskRef{Kind: "Task", Name: "buildah"},
+			Status:  &TaskStatus{State: TaskStateSucceeded, StartTime: testStart, CompletionTime: testEnd},
+		},
+		{
+			Name:    "deploy",
+			Kind:    NodeKindTask,
+			TaskRef: &TaskRef{Kind: "Task", Name: "helm"},
+			Params:  map[string]string{"image": "$(tasks.build.results.image)"},
+		},
+		{
+			Name:    "notify",
+			Kind:    NodeKindFinally,
+			TaskRef: &TaskRef{Kind: "ClusterTask", Name: "slack"},
+			Status:  &TaskStatus{State: TaskStateSkipped},
+		},
+		{
+			Name:    "scan",
+			Kind:    NodeKindTask,
+			TaskRef: &TaskRef{Kind: "Task", Name: "trivy"},
+			Params:  map[string]string{"image": "$(tasks.build.results.image)"},
+		},
+		{Name: "test", Kind: NodeKindTask, TaskRef: &TaskRef{Kind: "Task", Name: "tests"}},
+	}, doc.Nodes)
+	assert.Equal(t, []EdgeDocument{
+		{From: "build", To: "scan", Kind: EdgeKindResult},
+		{From: "build", To: "deploy", Kind: EdgeKindRunAfter},
+		{From: "deploy", To: "test", Kind: EdgeKindResult},
+		{From: "scan", To: "deploy", Kind: EdgeKindResult},
+	}, doc.Edges)
+}
+
+func TestTaskGraphToJSON(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks()[2:])
+	graph.PipelineName = testPipelineName
+	graph.SetStatuses(map[string]*TaskStatus{"task3": {State: TaskStateRunning, StartTime: testStart}})
+
+	output, err := graph.ToJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion": "tkn-graph/v1",
+  "kind": "TaskGraph",
+  "metadata": {
+    "name": "test-pipeline"
+  },
+  "nodes": [
+    {
+      "name": "task-with-dash",
+      "kind": "task",
+      "taskRef": {
+        "kind": "Task",
+        "name": "taskRef4"
+      }
+    },
+    {
+      "name": "task3",
+      "kind": "task",
+      "taskRef": {
+        "kind": "Task",
+        "name": "taskRef3"
+      },
+      "status": {
+        "state": "Running",
+        "startTime": "2024-01-02T10:04:05Z"
+      }
+    }
+  ],
+  "edges": []
+}
+`, output)
+}
+
+func TestTaskGraphToYAML(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks()[1:3])
+	graph.PipelineName = testPipelineName
+
+	output, err := graph.ToYAML()
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: tkn-graph/v1
+edges:
+- from: task3
+  kind: runAfter
+  to: task2
+kind: TaskGraph
+metadata:
+  name: test-pipeline
+nodes:
+- kind: task
+  name: task2
+  taskRef:
+    kind: Task
+    name: taskRef2
+- kind: task
+  name: task3
+  taskRef:
+    kind: Task
+    name: taskRef3
+`, output)
+}
+
+func TestReadGraphsRoundTrip(t *testing.T) {
+	graph := getTestExportGraph(t)
+
+	jsonOutput, err := graph.ToJSON()
+	require.NoError(t, err)
+	yamlOutput, err := graph.ToYAML()
+	require.NoError(t, err)
+
+	for name, input := range map[string]string{
+		"json stream":    jsonOutput + jsonOutput,
+		"yaml documents": "---\n" + yamlOutput + "---\n" + yamlOutput,
+	} {
+		t.Run(name, func(t *testing.T) {
+			graphs, err := ReadGraphs(strings.NewReader(input))
+			require.NoError(t, err)
+			require.Len(t, graphs, 2)
+
+			for _, g := range graphs {
+				assert.Equal(t, graph.Document(), g.Document())
+				assert.True(t, g.Nodes["build"].IsRoot)
+				assert.False(t, g.Nodes["scan"].IsRoot)
+				assert.False(t, g.Nodes["notify"].IsRoot)
+				assert.Equal(t, "ClusterTask/slack", g.Nodes["notify"].TaskRefName)
+
+				// the rendered graphs are the same as the ones of the original graph
+				for _, format := range []string{"dot", "puml", "mmd"} {
+					expected, err := renderString(graph, format, RenderOptions{WithTaskRef: true})
+					require.NoError(t, err)
+					actual, err := renderString(g, format, RenderOptions{WithTaskRef: true})
+					require.NoError(t, err)
+					assert.Equal(t, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestReadGraphsErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		expectedErr string
+	}{
+		{
+			name:        "unsupported version",
+			input:       `{"apiVersion": "tkn-graph/v2", "kind": "TaskGraph"}`,
+			expectedErr: "unsupported graph tkn-graph/v2 TaskGraph, expected tkn-graph/v1 TaskGraph",
+		},
+		{
+			name: "unknown node",
+			input: `{"apiVersion": "tkn-graph/v1", "kind": "TaskGraph", "metadata": {"name": "p"},
+				"nodes": [{"name": "a"}], "edges": [{"from": "a", "to": "b"}]}`,
+			expectedErr: `invalid graph p: unknown dependency: task "a" depends on unknown task "b"`,
+		},
+		{
+			name: "cycle",
+			input: `{"apiVersion": "tkn-graph/v1", "kind": "TaskGraph", "metadata": {"name": "p"},
+				"nodes": [{"name": "a"}, {"name": "b"}], "edges": [{"from": "a", "to": "b"}, {"from": "b", "to": "a"}]}`,
+			expectedErr: "invalid graph p: dependency cycle: a -> b -> a",
+		},
+		{
+			name:        "malformed",
+			input:       `{"apiVersion": `,
+			expectedErr: "failed to decode graph: unexpected EOF",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			graphs, err := ReadGraphs(strings.NewReader(tc.input))
+
+			assert.Nil(t, graphs)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
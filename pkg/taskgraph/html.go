@@ -0,0 +1,69 @@
+package taskgraph
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// htmlNode is the data of a task used by the scripts of the HTML page
+type htmlNode struct {
+	Name         string            `json:"name"`
+	Kind         NodeKind          `json:"kind"`
+	TaskRef      string            `json:"taskRef,omitempty"`
+	Params       map[string]string `json:"params,omitempty"`
+	Status       string            `json:"status,omitempty"`
+	Dependencies []string          `json:"dependencies"`
+}
+
+// ToHTML renders the graph as a single, self-contained HTML page with the svg graph, pan and zoom, search,
+// tooltips and highlighting of the upstream and downstream tasks. No external resources are loaded.
+func (g *TaskGraph) ToHTML(withTaskRef bool) (string, error) {
+	var svg strings.Builder
+	writeSVG(&svg, g.computeLayout(withTaskRef))
+
+	nodes := make([]htmlNode, 0, len(g.Nodes))
+
+	for _, name := range g.sortedNames() {
+		node := g.Nodes[name]
+
+		n := htmlNode{
+			Name:         node.Name,
+			Kind:         node.Kind,
+			TaskRef:      node.TaskRefName,
+			Params:       node.Params,
+			Dependencies: make([]string, 0, len(node.Dependencies)),
+		}
+
+		if node.Status != nil {
+			n.Status = node.Status.Summary()
+		}
+
+		for _, dep := range node.Dependencies {
+			n.Dependencies = append(n.Dependencies, dep.Name)
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	tmpl, err := template.New("html").Parse(htmlTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html template: %w", err)
+	}
+
+	var builder strings.Builder
+
+	if err := tmpl.Execute(&builder, struct {
+		Title string
+		SVG   template.HTML
+		Nodes []htmlNode
+	}{
+		Title: g.Title(),
+		SVG:   template.HTML(svg.String()), // the text of the svg is escaped by writeSVG
+		Nodes: nodes,
+	}); err != nil {
+		return "", fmt.Errorf("failed to execute html template: %w", err)
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,46 @@
+package taskgraph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskGraphToHTML(t *testing.T) {
+	graph := getTestExportGraph(t)
+	graph.PipelineName = "<test & pipeline>"
+
+	html, err := graph.ToHTML(true)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(html, "<!DOCTYPE html>\n"))
+	assert.Contains(t, html, "<title>&lt;test &amp; pipeline&gt;</title>")
+
+	// the svg graph is embedded as is
+	assert.Contains(t, html, "<svg xmlns=\"http://www.w3.org/2000/svg\"")
+	assert.Contains(t, html, "<g class=\"node\" data-task=\"build\">")
+	assert.Contains(t, html, "data-from=\"build\" data-to=\"scan\"")
+
+	// the data used by the tooltips and the highlighting
+	assert.Contains(t, html, `{"name":"build","kind":"task","taskRef":"buildah","status":"Succeeded 10:04:05 (1m30s)","dependencies":["scan","deploy"]}`)
+	assert.Contains(t, html, `{"name":"scan","kind":"task","taskRef":"trivy","params":{"image":"$(tasks.build.results.image)"},"dependencies":["deploy"]}`)
+	assert.Contains(t, html, `{"name":"notify","kind":"finally","taskRef":"ClusterTask/slack","status":"Skipped","dependencies":[]}`)
+
+	// the page works offline
+	assert.NotContains(t, html, "<script src")
+	assert.NotContains(t, html, "<link")
+	assert.NotContains(t, html, "https://")
+}
+
+func TestTaskGraphToHTMLEscapesData(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks()[2:3])
+	graph.Nodes["task3"].Params = map[string]string{"script": "</script><script>alert(1)</script>"}
+
+	html, err := graph.ToHTML(false)
+	require.NoError(t, err)
+
+	assert.NotContains(t, html, "<script>alert(1)")
+	assert.Equal(t, 1, strings.Count(html, "</script>"))
+}
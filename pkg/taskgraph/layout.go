@@ -0,0 +1,597 @@
+package taskgraph
+
+import (
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+// Sizes of the rendered graphs in pixels, the text is measured as a monospace font
+const (
+	layoutCharWidth   = 7
+	layoutLineHeight  = 16
+	layoutPaddingX    = 12
+	layoutPaddingY    = 8
+	layoutPointRadius = 7
+	layoutNodeSep     = 24
+	layoutRankSep     = 48
+	layoutMargin      = 20
+	layoutTitleHeight = 28
+	layoutClusterPad  = 12
+
+	// layoutSweeps is the number of passes of the crossing minimization and coordinate assignment
+	layoutSweeps = 8
+)
+
+// layoutMetrics are the sizes used to place the graph, in pixels for the images and in characters for the text
+type layoutMetrics struct {
+	charWidth, lineHeight float64
+	paddingX, paddingY    float64
+	pointRadius           float64
+	nodeSep, rankSep      float64
+	margin, titleHeight   float64
+	clusterPad            float64
+}
+
+// pixelMetrics places the svg, png and html graphs
+var pixelMetrics = layoutMetrics{
+	charWidth:   layoutCharWidth,
+	lineHeight:  layoutLineHeight,
+	paddingX:    layoutPaddingX,
+	paddingY:    layoutPaddingY,
+	pointRadius: layoutPointRadius,
+	nodeSep:     layoutNodeSep,
+	rankSep:     layoutRankSep,
+	margin:      layoutMargin,
+	titleHeight: layoutTitleHeight,
+	clusterPad:  layoutClusterPad,
+}
+
+type layoutNodeKind int
+
+const (
+	layoutTask layoutNodeKind = iota
+	layoutPoint
+	layoutVirtual
+	layoutCluster
+)
+
+// layoutNode is a box of the layered layout: a task, the start and end points, a bend of a long edge
+// or the cluster holding the finally tasks
+type layoutNode struct {
+	kind     layoutNodeKind
+	task     *TaskNode
+	label    []string
+	children []*layoutNode // tasks inside a cluster
+
+	rank          int
+	order         int
+	x, y          float64 // center
+	width, height float64
+
+	in, out []*layoutNode
+}
+
+func (n *layoutNode) top() float64    { return n.y - n.height/2 }
+func (n *layoutNode) bottom() float64 { return n.y + n.height/2 }
+func (n *layoutNode) left() float64   { return n.x - n.width/2 }
+func (n *layoutNode) right() float64  { return n.x + n.width/2 }
+
+type layoutPoint2D struct {
+	x, y float64
+}
+
+// layoutEdge is an edge routed as a polyline from the bottom of its source to the top of its target
+type layoutEdge struct {
+	kind     EdgeKind
+	from, to *TaskNode // nil for the start, the end and the finally cluster
+	points   []layoutPoint2D
+	gaps     []float64 // middle of the space above the rank of every point but the first, where the text bends
+}
+
+// graphLayout is the position of all the elements of a rendered graph
+type graphLayout struct {
+	title         string
+	width, height float64
+	nodes         []*layoutNode // tasks, points and clusters, virtual nodes are left out
+	edges         []*layoutEdge
+	rankTops      []float64
+}
+
+// layoutEdgeChain is an edge of the graph before it is split by virtual nodes
+type layoutEdgeChain struct {
+	kind  EdgeKind
+	nodes []*layoutNode
+}
+
+// computeLayout places the graph with the layered (Sugiyama) method: the tasks are assigned to ranks
+// by the longest path from the start, long edges are split with virtual nodes, the order in each rank
+// is chosen by the barycenter heuristic to reduce crossings and the edges are routed through the virtual nodes.
+// The finally tasks form a cluster in a rank of their own, as in the other formats.
+func (g *TaskGraph) computeLayout(withTaskRef bool) *graphLayout {
+	return g.computeLayoutWith(withTaskRef, pixelMetrics)
+}
+
+// computeLayoutWith places the graph like computeLayout with the given sizes
+func (g *TaskGraph) computeLayoutWith(withTaskRef bool, m layoutMetrics) *graphLayout {
+	l := &graphLayout{title: g.Title()}
+
+	start := &layoutNode{kind: layoutPoint, width: 2 * m.pointRadius, height: 2 * m.pointRadius}
+	end := &layoutNode{kind: layoutPoint, width: 2 * m.pointRadius, height: 2 * m.pointRadius}
+
+	// collect the tasks, dependencies which are not part of Nodes are included as well
+	nodes := map[*TaskNode]*layoutNode{}
+	var tasks []*TaskNode
+
+	var collect func(task *TaskNode)
+	collect = func(task *TaskNode) {
+		if _, ok := nodes[task]; ok {
+			return
+		}
+
+		nodes[task] = newLayoutTaskNode(task, withTaskRef, m)
+		tasks = append(tasks, task)
+
+		for _, dep := range task.Dependencies {
+			collect(dep)
+		}
+	}
+
+	for _, name := range g.sortedNames() {
+		collect(g.Nodes[name])
+	}
+
+	var (
+		chains  []*layoutEdgeChain
+		finally []*layoutNode
+		main    []*layoutNode
+	)
+
+	for _, task := range tasks {
+		if task.IsFinally() {
+			finally = append(finally, nodes[task])
+		} else {
+			main = append(main, nodes[task])
+		}
+	}
+
+	sort.SliceStable(finally, func(i, j int) bool { return finally[i].task.Name < finally[j].task.Name })
+
+	var cluster *layoutNode
+	if len(finally) > 0 {
+		cluster = newLayoutCluster(finally, m)
+	}
+
+	// the node the leaf tasks are connected to
+	sink := end
+	if cluster != nil {
+		sink = cluster
+	}
+
+	predecessors := map[*layoutNode]int{}
+
+	for _, node := range main {
+		for _, dep := range node.task.Dependencies {
+			target := nodes[dep]
+			if dep.IsFinally() {
+				target = cluster
+			}
+
+			chains = append(chains, &layoutEdgeChain{kind: node.task.EdgeKind(dep), nodes: []*layoutNode{node, target}})
+			predecessors[target]++
+		}
+	}
+
+	for _, node := range main {
+		if predecessors[node] == 0 {
+			chains = append(chains, &layoutEdgeChain{kind: EdgeKindRunAfter, nodes: []*layoutNode{start, node}})
+		}
+
+		if len(node.task.Dependencies) == 0 {
+			chains = append(chains, &layoutEdgeChain{kind: EdgeKindRunAfter, nodes: []*layoutNode{node, sink}})
+		}
+	}
+
+	if len(main) == 0 {
+		chains = append(chains, &layoutEdgeChain{kind: EdgeKindRunAfter, nodes: []*layoutNode{start, sink}})
+	}
+
+	if cluster != nil {
+		chains = append(chains, &layoutEdgeChain{kind: EdgeKindRunAfter, nodes: []*layoutNode{cluster, end}})
+	}
+
+	all := append([]*layoutNode{start}, main...)
+	if cluster != nil {
+		all = append(all, cluster)
+	}
+
+	all = append(all, end)
+
+	assignRanks(all, chains, start, cluster, end)
+	layers := splitLongEdges(all, chains)
+	orderLayers(layers)
+	assignCoordinates(l, layers, m)
+
+	for _, node := range all {
+		l.nodes = append(l.nodes, node)
+		l.nodes = append(l.nodes, node.children...)
+	}
+
+	for _, chain := range chains {
+		l.edges = append(l.edges, routeEdge(chain, l, m))
+	}
+
+	return l
+}
+
+func newLayoutTaskNode(task *TaskNode, withTaskRef bool, m layoutMetrics) *layoutNode {
+	label := []string{task.Name}
+	if withTaskRef {
+		label = append(label, "("+task.TaskRefName+")")
+	}
+
+	if task.Status != nil {
+		label = append(label, task.Status.Summary())
+	}
+
+	width := 0
+	for _, line := range label {
+		width = max(width, utf8.RuneCountInString(line))
+	}
+
+	return &layoutNode{
+		kind:   layoutTask,
+		task:   task,
+		label:  label,
+		width:  float64(width)*m.charWidth + 2*m.paddingX,
+		height: float64(len(label))*m.lineHeight + 2*m.paddingY,
+	}
+}
+
+// newLayoutCluster creates the cluster holding the finally tasks side by side, below its label
+func newLayoutCluster(children []*layoutNode, m layoutMetrics) *layoutNode {
+	cluster := &layoutNode{kind: layoutCluster, label: []string{"finally"}, children: children}
+
+	height := 0.0
+	for i, child := range children {
+		if i > 0 {
+			cluster.width += m.nodeSep
+		}
+
+		cluster.width += child.width
+		height = max(height, child.height)
+	}
+
+	cluster.width += 2 * m.clusterPad
+	cluster.height = height + 2*m.clusterPad + m.lineHeight
+
+	return cluster
+}
+
+// assignRanks places every node one rank below its lowest predecessor. The start is always
+// the first rank, the finally cluster and the end the last ones.
+func assignRanks(all []*layoutNode, chains []*layoutEdgeChain, start, cluster, end *layoutNode) {
+	preds := map[*layoutNode][]*layoutNode{}
+	for _, chain := range chains {
+		target := chain.nodes[len(chain.nodes)-1]
+		preds[target] = append(preds[target], chain.nodes[0])
+	}
+
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	state := map[*layoutNode]int{}
+
+	var rank func(node *layoutNode) int
+	rank = func(node *layoutNode) int {
+		if state[node] != unvisited {
+			// edges closing a cycle are ignored, BuildTaskGraph reports them
+			return node.rank
+		}
+
+		state[node] = inProgress
+		node.rank = 0
+
+		for _, pred := range preds[node] {
+			node.rank = max(node.rank, rank(pred)+1)
+		}
+
+		state[node] = done
+
+		return node.rank
+	}
+
+	last := 0
+
+	for _, node := range all {
+		if node != cluster && node != end {
+			last = max(last, rank(node))
+		}
+	}
+
+	start.rank = 0
+
+	if cluster != nil {
+		last++
+		cluster.rank = last
+	}
+
+	end.rank = last + 1
+}
+
+// splitLongEdges inserts a virtual node in every rank crossed by an edge, so all edges connect adjacent
+// ranks, and returns the nodes grouped by rank
+func splitLongEdges(all []*layoutNode, chains []*layoutEdgeChain) [][]*layoutNode {
+	last := 0
+	for _, node := range all {
+		last = max(last, node.rank)
+	}
+
+	layers := make([][]*layoutNode, last+1)
+	for _, node := range all {
+		layers[node.rank] = append(layers[node.rank], node)
+	}
+
+	for _, chain := range chains {
+		source, target := chain.nodes[0], chain.nodes[1]
+		path := []*layoutNode{source}
+
+		for rank := source.rank + 1; rank < target.rank; rank++ {
+			virtual := &layoutNode{kind: layoutVirtual, rank: rank}
+			layers[rank] = append(layers[rank], virtual)
+			path = append(path, virtual)
+		}
+
+		path = append(path, target)
+
+		for i := 1; i < len(path); i++ {
+			path[i-1].out = append(path[i-1].out, path[i])
+			path[i].in = append(path[i].in, path[i-1])
+		}
+
+		chain.nodes = path
+	}
+
+	for _, layer := range layers {
+		setOrder(layer)
+	}
+
+	return layers
+}
+
+func setOrder(layer []*layoutNode) {
+	for i, node := range layer {
+		node.order = i
+	}
+}
+
+// orderLayers reduces the edge crossings by sorting the ranks by the barycenter of the neighbours,
+// alternately downwards and upwards, and keeps the best order found
+func orderLayers(layers [][]*layoutNode) {
+	best := countCrossings(layers)
+	bestOrder := saveOrder(layers)
+
+	for sweep := 0; sweep < layoutSweeps && best > 0; sweep++ {
+		if sweep%2 == 0 {
+			for r := 1; r < len(layers); r++ {
+				sortByBarycenter(layers[r], func(n *layoutNode) []*layoutNode { return n.in })
+			}
+		} else {
+			for r := len(layers) - 2; r >= 0; r-- {
+				sortByBarycenter(layers[r], func(n *layoutNode) []*layoutNode { return n.out })
+			}
+		}
+
+		if crossings := countCrossings(layers); crossings < best {
+			best = crossings
+			bestOrder = saveOrder(layers)
+		}
+	}
+
+	for r, layer := range layers {
+		copy(layer, bestOrder[r])
+		setOrder(layer)
+	}
+}
+
+func saveOrder(layers [][]*layoutNode) [][]*layoutNode {
+	saved := make([][]*layoutNode, len(layers))
+	for r, layer := range layers {
+		saved[r] = append([]*layoutNode{}, layer...)
+	}
+
+	return saved
+}
+
+// sortByBarycenter sorts the rank by the average order of the neighbours, nodes without neighbours keep their place
+func sortByBarycenter(layer []*layoutNode, neighbours func(*layoutNode) []*layoutNode) {
+	barycenter := make(map[*layoutNode]float64, len(layer))
+
+	for _, node := range layer {
+		ns := neighbours(node)
+		if len(ns) == 0 {
+			barycenter[node] = float64(node.order)
+			continue
+		}
+
+		sum := 0.0
+		for _, n := range ns {
+			sum += float64(n.order)
+		}
+
+		barycenter[node] = sum / float64(len(ns))
+	}
+
+	sort.SliceStable(layer, func(i, j int) bool { return barycenter[layer[i]] < barycenter[layer[j]] })
+	setOrder(layer)
+}
+
+// countCrossings returns the number of crossing edges between all adjacent ranks
+func countCrossings(layers [][]*layoutNode) int {
+	crossings := 0
+
+	for r := 0; r < len(layers)-1; r++ {
+		var edges [][2]int
+
+		for _, node := range layers[r] {
+			for _, out := range node.out {
+				edges = append(edges, [2]int{node.order, out.order})
+			}
+		}
+
+		for i := range edges {
+			for j := i + 1; j < len(edges); j++ {
+				if (edges[i][0]-edges[j][0])*(edges[i][1]-edges[j][1]) < 0 {
+					crossings++
+				}
+			}
+		}
+	}
+
+	return crossings
+}
+
+// assignCoordinates places the ranks from top to bottom and moves every node towards its neighbours,
+// keeping the order of the rank and the minimal distance between the nodes
+func assignCoordinates(l *graphLayout, layers [][]*layoutNode, m layoutMetrics) {
+	for _, layer := range layers {
+		x := 0.0
+		for _, node := range layer {
+			node.x = x + node.width/2
+			x += node.width + m.nodeSep
+		}
+
+		// center the rank around 0
+		for _, node := range layer {
+			node.x -= (x - m.nodeSep) / 2
+		}
+	}
+
+	for sweep := 0; sweep < layoutSweeps; sweep++ {
+		if sweep%2 == 0 {
+			for r := 1; r < len(layers); r++ {
+				alignLayer(layers[r], m.nodeSep, func(n *layoutNode) []*layoutNode { return n.in })
+			}
+		} else {
+			for r := len(layers) - 2; r >= 0; r-- {
+				alignLayer(layers[r], m.nodeSep, func(n *layoutNode) []*layoutNode { return n.out })
+			}
+		}
+	}
+
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for _, layer := range layers {
+		for _, node := range layer {
+			minX = min(minX, node.left())
+			maxX = max(maxX, node.right())
+		}
+	}
+
+	y := m.margin + m.titleHeight
+
+	for _, layer := range layers {
+		l.rankTops = append(l.rankTops, y)
+
+		height := 0.0
+		for _, node := range layer {
+			height = max(height, node.height)
+		}
+
+		for _, node := range layer {
+			node.x += m.margin - minX
+			node.y = y + height/2
+		}
+
+		y += height + m.rankSep
+	}
+
+	l.width = maxX - minX + 2*m.margin
+	l.height = y - m.rankSep + m.margin
+
+	for _, layer := range layers {
+		for _, node := range layer {
+			placeChildren(node, m)
+		}
+	}
+}
+
+// alignLayer moves the nodes of the rank to the average position of their neighbours, then the overlaps are
+// removed from left to right and the rank is shifted back to stay as close as possible to the wanted positions
+func alignLayer(layer []*layoutNode, nodeSep float64, neighbours func(*layoutNode) []*layoutNode) {
+	if len(layer) == 0 {
+		return
+	}
+
+	wanted := make([]float64, len(layer))
+
+	for i, node := range layer {
+		wanted[i] = node.x
+
+		if ns := neighbours(node); len(ns) > 0 {
+			sum := 0.0
+			for _, n := range ns {
+				sum += n.x
+			}
+
+			wanted[i] = sum / float64(len(ns))
+		}
+	}
+
+	shift := 0.0
+
+	for i, node := range layer {
+		node.x = wanted[i]
+		if i > 0 {
+			node.x = max(node.x, layer[i-1].right()+nodeSep+node.width/2)
+		}
+
+		shift += node.x - wanted[i]
+	}
+
+	shift /= float64(len(layer))
+	for _, node := range layer {
+		node.x -= shift
+	}
+}
+
+// placeChildren places the tasks of a cluster side by side below the cluster label
+func placeChildren(cluster *layoutNode, m layoutMetrics) {
+	x := cluster.left() + m.clusterPad
+	y := cluster.top() + m.clusterPad + m.lineHeight
+
+	for _, child := range cluster.children {
+		child.x = x + child.width/2
+		child.y = y + child.height/2
+		x += child.width + m.nodeSep
+	}
+}
+
+// routeEdge creates the polyline of the edge through its virtual nodes
+func routeEdge(chain *layoutEdgeChain, l *graphLayout, m layoutMetrics) *layoutEdge {
+	source, target := chain.nodes[0], chain.nodes[len(chain.nodes)-1]
+
+	edge := &layoutEdge{kind: chain.kind, from: source.task, to: target.task}
+	edge.points = append(edge.points, layoutPoint2D{source.x, source.bottom()})
+
+	for _, virtual := range chain.nodes[1 : len(chain.nodes)-1] {
+		edge.points = append(edge.points, layoutPoint2D{virtual.x, virtual.y})
+	}
+
+	for _, node := range chain.nodes[1:] {
+		edge.gaps = append(edge.gaps, l.rankTops[node.rank]-m.rankSep/2)
+	}
+
+	// edges entering the cluster point to its border, straight below the previous point if possible
+	x := target.x
+	if target.kind == layoutCluster {
+		previous := edge.points[len(edge.points)-1]
+		x = min(max(previous.x, target.left()+m.clusterPad), target.right()-m.clusterPad)
+	}
+
+	edge.points = append(edge.points, layoutPoint2D{x, target.top()})
+
+	return edge
+}
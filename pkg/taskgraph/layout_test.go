@@ -0,0 +1,107 @@
+package taskgraph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func layoutTaskNodes(l *graphLayout) map[string]*layoutNode {
+	nodes := map[string]*layoutNode{}
+
+	for _, node := range l.nodes {
+		if node.kind == layoutTask {
+			nodes[node.task.Name] = node
+		}
+	}
+
+	return nodes
+}
+
+func TestComputeLayoutRanks(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks(), getTestFinallyTasks()...)
+	l := graph.computeLayout(false)
+	nodes := layoutTaskNodes(l)
+
+	// start, task3 and task-with-dash, task2, task1, finally, end
+	assert.Equal(t, 1, nodes["task3"].rank)
+	assert.Equal(t, 1, nodes["task-with-dash"].rank)
+	assert.Equal(t, 2, nodes["task2"].rank)
+	assert.Equal(t, 3, nodes["task1"].rank)
+
+	// the finally tasks are side by side in the cluster, below all the other tasks
+	assert.Equal(t, nodes["notify"].y, nodes["cleanup-ws"].y)
+	assert.Less(t, nodes["cleanup-ws"].right(), nodes["notify"].left())
+	assert.Greater(t, nodes["notify"].top(), nodes["task1"].bottom())
+
+	// the ranks go from top to bottom and the nodes of a rank don't overlap
+	assert.Less(t, nodes["task3"].bottom(), nodes["task2"].top())
+	assert.Equal(t, nodes["task3"].y, nodes["task-with-dash"].y)
+	assert.True(t, nodes["task3"].right() < nodes["task-with-dash"].left() ||
+		nodes["task-with-dash"].right() < nodes["task3"].left())
+
+	for _, node := range l.nodes {
+		assert.GreaterOrEqual(t, node.left(), float64(layoutMargin))
+		assert.LessOrEqual(t, node.right(), l.width-layoutMargin)
+		assert.LessOrEqual(t, node.bottom(), l.height-layoutMargin)
+	}
+}
+
+func TestComputeLayoutEdges(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestResultRefTasks())
+	l := graph.computeLayout(true)
+	nodes := layoutTaskNodes(l)
+
+	assert.Equal(t, []string{"build", "(buildah)"}, nodes["build"].label)
+
+	// 4 dependencies, the start edge of build and the end edge of test
+	require.Len(t, l.edges, 6)
+
+	kinds := map[EdgeKind]int{}
+	for _, edge := range l.edges {
+		kinds[edge.kind]++
+
+		// every edge goes downwards from the bottom of a node to the top of another one
+		for i := 1; i < len(edge.points); i++ {
+			assert.Greater(t, edge.points[i].y, edge.points[i-1].y)
+		}
+	}
+
+	assert.Equal(t, map[EdgeKind]int{EdgeKindRunAfter: 3, EdgeKindResult: 3}, kinds)
+
+	// build -> deploy spans two ranks and bends at a virtual node
+	for _, edge := range l.edges {
+		first, last := edge.points[0], edge.points[len(edge.points)-1]
+		if first == (layoutPoint2D{nodes["build"].x, nodes["build"].bottom()}) &&
+			last == (layoutPoint2D{nodes["deploy"].x, nodes["deploy"].top()}) {
+			assert.Len(t, edge.points, 3)
+			assert.Equal(t, EdgeKindRunAfter, edge.kind)
+		}
+	}
+}
+
+func TestOrderLayersRemovesCrossings(t *testing.T) {
+	// a and b are sorted by name, but their children are crossed
+	graph := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "a"},
+		{Name: "b"},
+		{Name: "c", RunAfter: []string{"b"}},
+		{Name: "d", RunAfter: []string{"a"}},
+	})
+	l := graph.computeLayout(false)
+	nodes := layoutTaskNodes(l)
+
+	assert.Less(t, nodes["a"].x, nodes["b"].x)
+	assert.Less(t, nodes["d"].x, nodes["c"].x)
+}
+
+func TestComputeLayoutEmptyGraph(t *testing.T) {
+	l := (&TaskGraph{PipelineName: "empty"}).computeLayout(false)
+
+	assert.Len(t, l.nodes, 2)
+	assert.Len(t, l.edges, 1)
+	assert.Positive(t, l.width)
+	assert.Positive(t, l.height)
+}
@@ -0,0 +1,191 @@
+package taskgraph
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	pngArrowLength = 9
+	pngArrowWidth  = 4
+	pngDashLength  = 5
+	pngGapLength   = 3
+)
+
+// ToPNG renders the graph as a PNG image with the same layout as ToSVG, no external tool is required.
+// The returned string holds the binary image.
+func (g *TaskGraph) ToPNG(withTaskRef bool) (string, error) {
+	l := g.computeLayout(withTaskRef)
+
+	c := &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, int(math.Ceil(l.width)), int(math.Ceil(l.height))))}
+	draw.Draw(c.img, c.img.Bounds(), image.NewUniform(hexColor(renderBackground)), image.Point{}, draw.Src)
+
+	stroke := hexColor(renderStroke)
+
+	c.text(l.width/2, float64(layoutMargin+layoutLineHeight), l.title, stroke)
+
+	for _, node := range l.nodes {
+		if node.kind == layoutCluster {
+			c.rect(node.left(), node.top(), node.right(), node.bottom(), hexColor(renderCluster), true)
+			c.text(node.x, node.top()+layoutLineHeight, node.label[0], stroke)
+		}
+	}
+
+	for _, edge := range l.edges {
+		col := stroke
+		if edge.from != nil && edge.from.IsCriticalEdge(edge.to) {
+			col = hexColor(criticalColor)
+		}
+
+		for i := 1; i < len(edge.points); i++ {
+			c.line(edge.points[i-1], edge.points[i], col, edge.kind.IsResult())
+		}
+
+		c.arrowHead(edge.points[len(edge.points)-2], edge.points[len(edge.points)-1], col)
+	}
+
+	for _, node := range l.nodes {
+		switch node.kind {
+		case layoutPoint:
+			c.circle(node.x, node.y, layoutPointRadius, stroke)
+		case layoutTask:
+			fill := renderNodeFill
+			if node.task.Status != nil {
+				fill = node.task.Status.Color()
+			}
+
+			c.fill(node.left(), node.top(), node.right(), node.bottom(), hexColor(fill))
+			c.rect(node.left(), node.top(), node.right(), node.bottom(), stroke, false)
+
+			if node.task.Critical {
+				c.rect(node.left()+1, node.top()+1, node.right()-1, node.bottom()-1, hexColor(criticalColor), false)
+				c.rect(node.left(), node.top(), node.right(), node.bottom(), hexColor(criticalColor), false)
+			}
+
+			for i, line := range node.label {
+				c.text(node.x, labelBaseline(node, i), line, stroke)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return "", fmt.Errorf("failed to encode png: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// pngCanvas draws the shapes of the graph on an image
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func (c *pngCanvas) fill(x0, y0, x1, y1 float64, col color.Color) {
+	draw.Draw(c.img, image.Rect(int(x0), int(y0), int(x1), int(y1)), image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+func (c *pngCanvas) rect(x0, y0, x1, y1 float64, col color.Color, dashed bool) {
+	c.line(layoutPoint2D{x0, y0}, layoutPoint2D{x1, y0}, col, dashed)
+	c.line(layoutPoint2D{x1, y0}, layoutPoint2D{x1, y1}, col, dashed)
+	c.line(layoutPoint2D{x1, y1}, layoutPoint2D{x0, y1}, col, dashed)
+	c.line(layoutPoint2D{x0, y1}, layoutPoint2D{x0, y0}, col, dashed)
+}
+
+// line draws a one pixel wide line, dashed lines alternate drawn and skipped pixels along the line
+func (c *pngCanvas) line(from, to layoutPoint2D, col color.Color, dashed bool) {
+	dx, dy := to.x-from.x, to.y-from.y
+	steps := int(math.Ceil(math.Max(math.Abs(dx), math.Abs(dy))))
+
+	for i := 0; i <= steps; i++ {
+		if dashed && i%(pngDashLength+pngGapLength) >= pngDashLength {
+			continue
+		}
+
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+
+		c.img.Set(int(math.Round(from.x+t*dx)), int(math.Round(from.y+t*dy)), col)
+	}
+}
+
+func (c *pngCanvas) circle(cx, cy, r float64, col color.Color) {
+	for y := int(cy - r); y <= int(cy+r); y++ {
+		for x := int(cx - r); x <= int(cx+r); x++ {
+			if math.Hypot(float64(x)-cx, float64(y)-cy) <= r {
+				c.img.Set(x, y, col)
+			}
+		}
+	}
+}
+
+// arrowHead draws a filled triangle pointing to the end of the segment
+func (c *pngCanvas) arrowHead(from, to layoutPoint2D, col color.Color) {
+	length := math.Hypot(to.x-from.x, to.y-from.y)
+	if length == 0 {
+		return
+	}
+
+	ux, uy := (to.x-from.x)/length, (to.y-from.y)/length
+	base := layoutPoint2D{to.x - ux*pngArrowLength, to.y - uy*pngArrowLength}
+	a := layoutPoint2D{base.x - uy*pngArrowWidth, base.y + ux*pngArrowWidth}
+	b := layoutPoint2D{base.x + uy*pngArrowWidth, base.y - ux*pngArrowWidth}
+
+	for y := int(math.Min(to.y, math.Min(a.y, b.y))); y <= int(math.Max(to.y, math.Max(a.y, b.y))); y++ {
+		for x := int(math.Min(to.x, math.Min(a.x, b.x))); x <= int(math.Max(to.x, math.Max(a.x, b.x))); x++ {
+			if inTriangle(layoutPoint2D{float64(x), float64(y)}, to, a, b) {
+				c.img.Set(x, y, col)
+			}
+		}
+	}
+}
+
+func inTriangle(p, a, b, c layoutPoint2D) bool {
+	side := func(p1, p2, p3 layoutPoint2D) float64 {
+		return (p1.x-p3.x)*(p2.y-p3.y) - (p2.x-p3.x)*(p1.y-p3.y)
+	}
+
+	d1, d2, d3 := side(p, a, b), side(p, b, c), side(p, c, a)
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+
+	return !(hasNeg && hasPos)
+}
+
+// text draws a line of text centered on x with the baseline at y, the fixed font matches layoutCharWidth
+func (c *pngCanvas) text(x, y float64, s string, col color.Color) {
+	d := &font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(col),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(int(x)-utf8.RuneCountInString(s)*layoutCharWidth/2, int(y)),
+	}
+	d.DrawString(s)
+}
+
+// hexColor parses a #rrggbb color, invalid colors are black
+func hexColor(s string) color.RGBA {
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{A: 0xff}
+	}
+
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{A: 0xff}
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
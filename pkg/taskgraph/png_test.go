@@ -0,0 +1,38 @@
+package taskgraph
+
+import (
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskGraphToPNG(t *testing.T) {
+	graph := getTestExportGraph(t)
+
+	output, err := graph.ToPNG(true)
+	require.NoError(t, err)
+
+	img, err := png.Decode(strings.NewReader(output))
+	require.NoError(t, err)
+
+	l := graph.computeLayout(true)
+	assert.Equal(t, int(math.Ceil(l.width)), img.Bounds().Dx())
+	assert.Equal(t, int(math.Ceil(l.height)), img.Bounds().Dy())
+
+	// the background is white and the nodes are filled with the color of their status
+	assert.Equal(t, color.RGBAModel.Convert(img.At(1, 1)), hexColor(renderBackground))
+
+	build := layoutTaskNodes(l)["build"]
+	assert.Equal(t, color.RGBAModel.Convert(img.At(int(build.left())+2, int(build.top())+2)), hexColor("#b7e1cd"))
+}
+
+func TestHexColor(t *testing.T) {
+	assert.Equal(t, color.RGBA{R: 0xb7, G: 0xe1, B: 0xcd, A: 0xff}, hexColor("#b7e1cd"))
+	assert.Equal(t, color.RGBA{A: 0xff}, hexColor("red"))
+	assert.Equal(t, color.RGBA{A: 0xff}, hexColor("#zzzzzz"))
+}
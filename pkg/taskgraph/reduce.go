@@ -0,0 +1,119 @@
+package taskgraph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RedundantEdge is a runAfter dependency of a task which is already implied by a longer chain of dependencies
+type RedundantEdge struct {
+	Task       string   // Task which declares the dependency
+	Dependency string   // Task the dependency points to, it runs before Task
+	Path       []string // Chain of tasks implying the dependency, from Dependency to Task
+}
+
+// String returns a human readable description of the redundant edge
+func (e RedundantEdge) String() string {
+	return fmt.Sprintf("%s: runAfter %s is implied by %s", e.Task, e.Dependency, strings.Join(e.Path, " -> "))
+}
+
+// RedundantEdges returns the runAfter dependencies which can be removed without changing the execution order
+// of the tasks, sorted by task and dependency name. The result references are never redundant, the task consumes
+// the result whatever the order, so they are neither reported nor removed.
+func (g *TaskGraph) RedundantEdges() []RedundantEdge {
+	var edges []RedundantEdge
+
+	for _, name := range g.sortedNames() {
+		from := g.Nodes[name]
+
+		for _, to := range from.Dependencies {
+			if from.EdgeKind(to).IsResult() {
+				continue
+			}
+
+			// the direct edge is a path of two tasks, any longer path implies it
+			path := longestPath(from, to)
+			if len(path) <= 2 {
+				continue
+			}
+
+			edges = append(edges, RedundantEdge{
+				Task:       to.Name,
+				Dependency: from.Name,
+				Path:       path,
+			})
+		}
+	}
+
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].Task == edges[j].Task {
+			return edges[i].Dependency < edges[j].Dependency
+		}
+
+		return edges[i].Task < edges[j].Task
+	})
+
+	return edges
+}
+
+// Reduce removes the redundant runAfter edges of the graph and returns them. The edges of the result references are
+// kept, so the graph is the transitive reduction of the graph only when it has no redundant result reference.
+func (g *TaskGraph) Reduce() []RedundantEdge {
+	edges := g.RedundantEdges()
+
+	for _, edge := range edges {
+		g.Nodes[edge.Dependency].removeDependency(edge.Task)
+	}
+
+	return edges
+}
+
+// removeDependency removes the edge from the node to the named task
+func (n *TaskNode) removeDependency(name string) {
+	for i, dep := range n.Dependencies {
+		if dep.Name == name {
+			n.Dependencies = append(n.Dependencies[:i:i], n.Dependencies[i+1:]...)
+			break
+		}
+	}
+
+	delete(n.DependencyKinds, name)
+}
+
+// longestPath returns the longest chain of tasks from one task to another, or nil if there is none.
+// The chain only uses edges which are kept by the transitive reduction, a shorter chain could use an edge
+// implied by a longer one.
+func longestPath(from, to *TaskNode) []string {
+	memo := map[*TaskNode][]string{}
+
+	var visit func(node *TaskNode) []string
+
+	visit = func(node *TaskNode) []string {
+		if node == to {
+			return []string{to.Name}
+		}
+
+		if path, ok := memo[node]; ok {
+			return path
+		}
+
+		var longest []string
+
+		for _, next := range node.Dependencies {
+			if path := visit(next); len(path) > len(longest) {
+				longest = path
+			}
+		}
+
+		if longest != nil {
+			longest = append([]string{node.Name}, longest...)
+		}
+
+		memo[node] = longest
+
+		return longest
+	}
+
+	return visit(from)
+}
@@ -0,0 +1,74 @@
+package taskgraph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func TestRedundantEdges(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks())
+
+	edges := graph.RedundantEdges()
+
+	assert.Equal(t, []RedundantEdge{
+		{Task: "task1", Dependency: "task3", Path: []string{"task3", "task2", "task1"}},
+	}, edges)
+	assert.Equal(t, "task1: runAfter task3 is implied by task3 -> task2 -> task1", edges[0].String())
+
+	// the graph is left untouched
+	assert.Len(t, graph.Nodes["task3"].Dependencies, 2)
+}
+
+func TestRedundantEdgesWithResults(t *testing.T) {
+	graph := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "clone"},
+		{Name: "build", RunAfter: []string{"clone"}},
+		{Name: "test", RunAfter: []string{"build"}},
+		{
+			Name:     "deploy",
+			RunAfter: []string{"test", "build"},
+			Params: v1pipeline.Params{
+				{Name: "commit", Value: *v1pipeline.NewStructuredValues("$(tasks.clone.results.commit)")},
+			},
+		},
+	})
+
+	// the result reference to clone is implied as well, but it is kept
+	assert.Equal(t, []RedundantEdge{
+		{Task: "deploy", Dependency: "build", Path: []string{"build", "test", "deploy"}},
+	}, graph.Reduce())
+	assert.Equal(t, EdgeKindResult, graph.Nodes["clone"].EdgeKind(graph.Nodes["deploy"]))
+	assert.Contains(t, graph.Nodes["clone"].Dependencies, graph.Nodes["deploy"])
+	assert.NotContains(t, graph.Nodes["build"].Dependencies, graph.Nodes["deploy"])
+}
+
+func TestReduce(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks())
+
+	edges := graph.Reduce()
+
+	assert.Len(t, edges, 1)
+	assert.Equal(t, []*TaskNode{graph.Nodes["task2"]}, graph.Nodes["task3"].Dependencies)
+	assert.NotContains(t, graph.Nodes["task3"].DependencyKinds, "task1")
+	assert.Empty(t, graph.RedundantEdges())
+
+	dot, err := graph.ToDOT(false)
+	assert.NoError(t, err)
+	assert.NotContains(t, dot, "\"task3\" -> \"task1\"")
+	assert.Contains(t, dot, "\"task3\" -> \"task2\"")
+	assert.Contains(t, dot, "\"task2\" -> \"task1\"")
+}
+
+func TestReduceWithoutRedundantEdges(t *testing.T) {
+	graph := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "a"},
+		{Name: "b", RunAfter: []string{"a"}},
+		{Name: "c", RunAfter: []string{"a"}},
+		{Name: "d", RunAfter: []string{"b", "c"}},
+	})
+
+	assert.Empty(t, graph.Reduce())
+	assert.Len(t, graph.Nodes["a"].Dependencies, 2)
+}
@@ -0,0 +1,218 @@
+package taskgraph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// RenderOptions are the options of the renderers, the renderers ignore the ones which don't apply to their format
+type RenderOptions struct {
+	WithTaskRef bool   // Include the taskRef of the tasks
+	Colour      bool   // The output is a terminal, the text formats are colored with escape codes
+	Theme       *Theme // Styling of the DOT, PlantUML and Mermaid graphs, their default styling if nil
+}
+
+// Renderer renders the graphs in an output format. The renderers are registered with RegisterRenderer and
+// selected by their name with --output-format.
+type Renderer interface {
+	// Name is the name of the format, lowercase, e.g. dot
+	Name() string
+	// Description is the short description of the format shown in the help of --output-format, e.g. DOT
+	Description() string
+	// Extension is the extension of the output files, without the dot
+	Extension() string
+	// Render writes the graph to w
+	Render(w io.Writer, graph *TaskGraph, opts RenderOptions) error
+}
+
+// BinaryRenderer is implemented by the renderers of binary formats, which can't be printed to the terminal
+// or produced by user templates
+type BinaryRenderer interface {
+	Renderer
+	Binary() bool
+}
+
+// MultiDocumentRenderer is implemented by the renderers of formats whose graphs are printed as separate documents
+// of a single stream, e.g. YAML
+type MultiDocumentRenderer interface {
+	Renderer
+	DocumentSeparator() string
+}
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]Renderer{}
+	// rendererNames are the names of the renderers in the order they were registered
+	rendererNames []string
+)
+
+func init() {
+	for _, r := range []Renderer{
+		&graphRenderer{name: "dot", description: "DOT", render: func(g *TaskGraph, opts RenderOptions) (string, error) {
+			return g.toDOT(opts.WithTaskRef, opts.Theme)
+		}},
+		&graphRenderer{name: "puml", description: "PlantUML", render: func(g *TaskGraph, opts RenderOptions) (string, error) {
+			return g.toPlantUML(opts.WithTaskRef, opts.Theme)
+		}},
+		&graphRenderer{name: "mmd", description: "Mermaid", render: func(g *TaskGraph, opts RenderOptions) (string, error) {
+			return g.toMermaid(opts.WithTaskRef, opts.Theme)
+		}},
+		&graphRenderer{name: "json", description: "graph structure", render: func(g *TaskGraph, _ RenderOptions) (string, error) {
+			return g.ToJSON()
+		}},
+		&graphRenderer{name: "yaml", description: "graph structure", separator: "---", render: func(g *TaskGraph, _ RenderOptions) (string, error) {
+			return g.ToYAML()
+		}},
+		&graphRenderer{name: "svg", description: "image", render: func(g *TaskGraph, opts RenderOptions) (string, error) {
+			return g.ToSVG(opts.WithTaskRef)
+		}},
+		&graphRenderer{name: "png", description: "image", binary: true, render: func(g *TaskGraph, opts RenderOptions) (string, error) {
+			return g.ToPNG(opts.WithTaskRef)
+		}},
+		&graphRenderer{name: "html", description: "interactive page", render: func(g *TaskGraph, opts RenderOptions) (string, error) {
+			return g.ToHTML(opts.WithTaskRef)
+		}},
+		newTextRenderer(TextASCII),
+		newTextRenderer(TextUnicode),
+	} {
+		MustRegisterRenderer(r)
+	}
+}
+
+// RegisterRenderer adds a renderer, its name must not be used by another renderer
+func RegisterRenderer(r Renderer) error {
+	name := r.Name()
+	if name == "" || name != strings.ToLower(name) {
+		return fmt.Errorf("invalid renderer name %q, it must be lowercase and not empty", name)
+	}
+
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+
+	if _, ok := renderers[name]; ok {
+		return fmt.Errorf("a renderer named %s is already registered", name)
+	}
+
+	renderers[name] = r
+	rendererNames = append(rendererNames, name)
+
+	return nil
+}
+
+// MustRegisterRenderer is like RegisterRenderer but panics if the renderer can't be registered
+func MustRegisterRenderer(r Renderer) {
+	if err := RegisterRenderer(r); err != nil {
+		panic(err)
+	}
+}
+
+// LookupRenderer returns the renderer of the format, the name isn't case sensitive
+func LookupRenderer(format string) (Renderer, bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	r, ok := renderers[strings.ToLower(format)]
+
+	return r, ok
+}
+
+// Renderers returns the registered renderers in the order they were registered
+func Renderers() []Renderer {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	all := make([]Renderer, 0, len(rendererNames))
+	for _, name := range rendererNames {
+		all = append(all, renderers[name])
+	}
+
+	return all
+}
+
+// RendererNames returns the names of the registered renderers in the order they were registered
+func RendererNames() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	return append([]string(nil), rendererNames...)
+}
+
+// IsBinary returns true if the renderer produces a binary format
+func IsBinary(r Renderer) bool {
+	b, ok := r.(BinaryRenderer)
+
+	return ok && b.Binary()
+}
+
+// DocumentSeparator returns the line printed between the graphs of the renderer, empty if the graphs are just
+// printed one after the other
+func DocumentSeparator(r Renderer) string {
+	if m, ok := r.(MultiDocumentRenderer); ok {
+		return m.DocumentSeparator()
+	}
+
+	return ""
+}
+
+// renderString renders the graph in the format to a string
+func renderString(graph *TaskGraph, format string, opts RenderOptions) (string, error) {
+	r, ok := LookupRenderer(format)
+	if !ok {
+		return "", fmt.Errorf("Invalid output format: %s", format)
+	}
+
+	var b strings.Builder
+	if err := r.Render(&b, graph, opts); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// graphRenderer is a built-in renderer producing the whole output at once
+type graphRenderer struct {
+	name, description, extension string
+	binary                       bool
+	separator                    string
+	render                       func(graph *TaskGraph, opts RenderOptions) (string, error)
+}
+
+func newTextRenderer(charset TextCharset) *graphRenderer {
+	return &graphRenderer{
+		name:        string(charset),
+		description: "text for the terminal",
+		extension:   "txt",
+		render: func(g *TaskGraph, opts RenderOptions) (string, error) {
+			return g.ToText(charset, opts.WithTaskRef, opts.Colour)
+		},
+	}
+}
+
+func (r *graphRenderer) Name() string { return r.name }
+
+func (r *graphRenderer) Description() string { return r.description }
+
+func (r *graphRenderer) Binary() bool { return r.binary }
+
+func (r *graphRenderer) DocumentSeparator() string { return r.separator }
+
+func (r *graphRenderer) Extension() string {
+	if r.extension == "" {
+		return r.name
+	}
+
+	return r.extension
+}
+
+func (r *graphRenderer) Render(w io.Writer, graph *TaskGraph, opts RenderOptions) error {
+	output, err := r.render(graph, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, output)
+
+	return err
+}
@@ -0,0 +1,97 @@
+package taskgraph
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countRenderer renders the number of tasks of the graph
+type countRenderer struct {
+	name string
+}
+
+func (r countRenderer) Name() string        { return r.name }
+func (r countRenderer) Description() string { return "number of tasks" }
+func (r countRenderer) Extension() string   { return "count" }
+
+func (r countRenderer) Render(w io.Writer, graph *TaskGraph, _ RenderOptions) error {
+	_, err := fmt.Fprintf(w, "%d tasks", len(graph.Nodes))
+
+	return err
+}
+
+func unregisterRenderer(t *testing.T, name string) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		renderersMu.Lock()
+		defer renderersMu.Unlock()
+
+		delete(renderers, name)
+
+		for i, n := range rendererNames {
+			if n == name {
+				rendererNames = append(rendererNames[:i], rendererNames[i+1:]...)
+
+				break
+			}
+		}
+	})
+}
+
+func TestBuiltinRenderers(t *testing.T) {
+	assert.Equal(t, []string{"dot", "puml", "mmd", "json", "yaml", "svg", "png", "html", "ascii", "unicode"}, RendererNames())
+
+	r, ok := LookupRenderer("PNG")
+	require.True(t, ok)
+	assert.Equal(t, "png", r.Extension())
+	assert.True(t, IsBinary(r))
+
+	r, ok = LookupRenderer("unicode")
+	require.True(t, ok)
+	assert.Equal(t, "txt", r.Extension())
+	assert.False(t, IsBinary(r))
+	assert.Empty(t, DocumentSeparator(r))
+
+	r, ok = LookupRenderer("YAML")
+	require.True(t, ok)
+	assert.Equal(t, "---", DocumentSeparator(r))
+
+	_, ok = LookupRenderer("gif")
+	assert.False(t, ok)
+}
+
+func TestRegisterRenderer(t *testing.T) {
+	unregisterRenderer(t, "count")
+	require.NoError(t, RegisterRenderer(countRenderer{name: "count"}))
+
+	assert.Contains(t, RendererNames(), "count")
+	assert.Equal(t, "count", RendererNames()[len(RendererNames())-1])
+
+	graph := mustBuildTaskGraph(t, getTestResultTasks())
+	graph.PipelineName = "release"
+
+	output, err := renderString(graph, "count", RenderOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "3 tasks", output)
+
+	dir := t.TempDir()
+	require.NoError(t, WriteAllGraphs([]*TaskGraph{graph}, "count", dir, RenderOptions{}, nil))
+
+	content, err := os.ReadFile(filepath.Join(dir, "release.count"))
+	require.NoError(t, err)
+	assert.Equal(t, "3 tasks", string(content))
+}
+
+func TestRegisterRendererErrors(t *testing.T) {
+	assert.EqualError(t, RegisterRenderer(countRenderer{name: "dot"}), "a renderer named dot is already registered")
+	assert.EqualError(t, RegisterRenderer(countRenderer{name: ""}), `invalid renderer name "", it must be lowercase and not empty`)
+	assert.EqualError(t, RegisterRenderer(countRenderer{name: "Count"}), `invalid renderer name "Count", it must be lowercase and not empty`)
+	assert.Panics(t, func() { MustRegisterRenderer(countRenderer{name: "dot"}) })
+}
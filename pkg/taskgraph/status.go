@@ -0,0 +1,137 @@
+package taskgraph
+
+import (
+	"time"
+
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/apis"
+)
+
+// TaskState is the outcome of a task in a PipelineRun
+type TaskState string
+
+const (
+	TaskStateSucceeded TaskState = "Succeeded"
+	TaskStateFailed    TaskState = "Failed"
+	TaskStateRunning   TaskState = "Running"
+	TaskStateCancelled TaskState = "Cancelled"
+	TaskStateSkipped   TaskState = "Skipped"
+	TaskStateTimedOut  TaskState = "TimedOut"
+)
+
+// statePriority is used to merge the states of several runs of the same task (matrix), the highest wins
+var statePriority = map[TaskState]int{
+	TaskStateSkipped:   0,
+	TaskStateSucceeded: 1,
+	TaskStateRunning:   2,
+	TaskStateCancelled: 3,
+	TaskStateTimedOut:  4,
+	TaskStateFailed:    5,
+}
+
+// stateColors are the fill colors of the nodes in the rendered graphs
+var stateColors = map[TaskState]string{
+	TaskStateSucceeded: "#b7e1cd",
+	TaskStateFailed:    "#f4c7c3",
+	TaskStateRunning:   "#fce8b2",
+	TaskStateCancelled: "#d9d9d9",
+	TaskStateSkipped:   "#f3f3f3",
+	TaskStateTimedOut:  "#f9cb9c",
+}
+
+// TaskStatus is the execution status of a task in a PipelineRun, collected from its TaskRuns or CustomRuns
+type TaskStatus struct {
+	State          TaskState `json:"state"`
+	StartTime      time.Time `json:"startTime,omitzero"`      // Zero if the task hasn't started
+	CompletionTime time.Time `json:"completionTime,omitzero"` // Zero if the task hasn't completed
+}
+
+// NewTaskStatus creates the TaskStatus from the Succeeded condition and the times of a TaskRun or CustomRun
+func NewTaskStatus(condition *apis.Condition, startTime, completionTime *time.Time) *TaskStatus {
+	status := &TaskStatus{State: TaskStateRunning}
+	if startTime != nil {
+		status.StartTime = *startTime
+	}
+
+	if completionTime != nil {
+		status.CompletionTime = *completionTime
+	}
+
+	if condition == nil {
+		return status
+	}
+
+	switch condition.Status {
+	case corev1.ConditionTrue:
+		status.State = TaskStateSucceeded
+	case corev1.ConditionFalse:
+		switch condition.Reason {
+		case string(v1pipeline.TaskRunReasonCancelled), string(v1beta1.CustomRunReasonCancelled):
+			status.State = TaskStateCancelled
+		case string(v1pipeline.TaskRunReasonTimedOut), string(v1beta1.CustomRunReasonTimedOut):
+			status.State = TaskStateTimedOut
+		default:
+			status.State = TaskStateFailed
+		}
+	case corev1.ConditionUnknown:
+		status.State = TaskStateRunning
+	}
+
+	return status
+}
+
+// Merge combines the status of another run of the same task, e.g. for matrix tasks.
+// The most severe state, the earliest start and the latest completion are kept.
+func (s *TaskStatus) Merge(other *TaskStatus) {
+	if statePriority[other.State] > statePriority[s.State] {
+		s.State = other.State
+	}
+
+	if !other.StartTime.IsZero() && (s.StartTime.IsZero() || other.StartTime.Before(s.StartTime)) {
+		s.StartTime = other.StartTime
+	}
+
+	if other.CompletionTime.After(s.CompletionTime) {
+		s.CompletionTime = other.CompletionTime
+	}
+}
+
+// Duration returns how long the task ran, zero if it hasn't completed
+func (s *TaskStatus) Duration() time.Duration {
+	if s.StartTime.IsZero() || s.CompletionTime.IsZero() {
+		return 0
+	}
+
+	return s.CompletionTime.Sub(s.StartTime)
+}
+
+// Color returns the fill color of the node
+func (s *TaskStatus) Color() string {
+	return stateColors[s.State]
+}
+
+// Summary returns the state, the start time (UTC) and the duration of the task, e.g. "Succeeded 10:04:05 (1m30s)"
+func (s *TaskStatus) Summary() string {
+	summary := string(s.State)
+
+	if !s.StartTime.IsZero() {
+		summary += " " + s.StartTime.UTC().Format(time.TimeOnly)
+	}
+
+	if d := s.Duration(); d > 0 {
+		summary += " (" + d.Round(time.Second).String() + ")"
+	}
+
+	return summary
+}
+
+// SetStatuses attaches the execution status to the nodes, keyed by the task name
+func (g *TaskGraph) SetStatuses(statuses map[string]*TaskStatus) {
+	for name, status := range statuses {
+		if node, ok := g.Nodes[name]; ok {
+			node.Status = status
+		}
+	}
+}
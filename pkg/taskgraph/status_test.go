@@ -0,0 +1,102 @@
+package taskgraph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/apis"
+)
+
+var (
+	testStart = time.Date(2024, time.January, 2, 10, 4, 5, 0, time.UTC)
+	testEnd   = testStart.Add(90 * time.Second)
+)
+
+func TestNewTaskStatus(t *testing.T) {
+	testCases := []struct {
+		name      string
+		condition *apis.Condition
+		expected  TaskState
+	}{
+		{"no condition", nil, TaskStateRunning},
+		{"succeeded", &apis.Condition{Status: corev1.ConditionTrue}, TaskStateSucceeded},
+		{"running", &apis.Condition{Status: corev1.ConditionUnknown, Reason: "Running"}, TaskStateRunning},
+		{"failed", &apis.Condition{Status: corev1.ConditionFalse, Reason: "Failed"}, TaskStateFailed},
+		{"cancelled", &apis.Condition{Status: corev1.ConditionFalse, Reason: "TaskRunCancelled"}, TaskStateCancelled},
+		{"custom run cancelled", &apis.Condition{Status: corev1.ConditionFalse, Reason: "CustomRunCancelled"}, TaskStateCancelled},
+		{"timed out", &apis.Condition{Status: corev1.ConditionFalse, Reason: "TaskRunTimeout"}, TaskStateTimedOut},
+		{"custom run timed out", &apis.Condition{Status: corev1.ConditionFalse, Reason: "CustomRunTimedOut"}, TaskStateTimedOut},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status := NewTaskStatus(tc.condition, &testStart, &testEnd)
+
+			assert.Equal(t, tc.expected, status.State)
+			assert.Equal(t, 90*time.Second, status.Duration())
+		})
+	}
+}
+
+func TestTaskStatusMerge(t *testing.T) {
+	status := &TaskStatus{State: TaskStateSucceeded, StartTime: testStart.Add(time.Second), CompletionTime: testEnd}
+	status.Merge(&TaskStatus{State: TaskStateFailed, StartTime: testStart, CompletionTime: testStart.Add(time.Minute)})
+	status.Merge(&TaskStatus{State: TaskStateRunning, StartTime: testStart.Add(time.Minute)})
+
+	assert.Equal(t, &TaskStatus{State: TaskStateFailed, StartTime: testStart, CompletionTime: testEnd}, status)
+}
+
+func TestTaskStatusSummary(t *testing.T) {
+	assert.Equal(t, "Succeeded 10:04:05 (1m30s)",
+		(&TaskStatus{State: TaskStateSucceeded, StartTime: testStart, CompletionTime: testEnd}).Summary())
+	assert.Equal(t, "Running 10:04:05", (&TaskStatus{State: TaskStateRunning, StartTime: testStart}).Summary())
+	assert.Equal(t, "Skipped", (&TaskStatus{State: TaskStateSkipped}).Summary())
+	assert.Equal(t, "#f4c7c3", (&TaskStatus{State: TaskStateFailed}).Color())
+}
+
+func TestTaskGraphWithStatuses(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks(), v1pipeline.PipelineTask{
+		Name:    "notify",
+		TaskRef: &v1pipeline.TaskRef{Name: "taskRef5"},
+	})
+	graph.PipelineName = testPipelineName
+	graph.SetStatuses(map[string]*TaskStatus{
+		"task3":   {State: TaskStateSucceeded, StartTime: testStart, CompletionTime: testEnd},
+		"task2":   {State: TaskStateFailed, StartTime: testStart, CompletionTime: testEnd},
+		"notify":  {State: TaskStateRunning, StartTime: testStart},
+		"unknown": {State: TaskStateSkipped},
+	})
+
+	assert.Nil(t, graph.Nodes["task1"].Status)
+	assert.Equal(t, TaskStateFailed, graph.Nodes["task2"].Status.State)
+
+	dot, err := graph.ToDOT(false)
+	assert.NoError(t, err)
+	assert.Contains(t, dot, "   \"task3\" [style=\"filled\" fillcolor=\"#b7e1cd\" label=\"task3\nSucceeded 10:04:05 (1m30s)\"]\n")
+	assert.Contains(t, dot, "   \"notify\" [style=\"filled\" fillcolor=\"#fce8b2\" label=\"notify\nRunning 10:04:05\"]\n")
+	assert.NotContains(t, dot, "\"task1\" [style")
+
+	dot, err = graph.ToDOT(true)
+	assert.NoError(t, err)
+	assert.Contains(t, dot, "   \"task2\n(taskRef2)\" [style=\"filled\" fillcolor=\"#f4c7c3\" label=\"task2\n(taskRef2)\nFailed 10:04:05 (1m30s)\"]\n")
+
+	plantuml, err := graph.ToPlantUML(false)
+	assert.NoError(t, err)
+	assert.Contains(t, plantuml, "   state task3 #b7e1cd\n   task3: Succeeded 10:04:05 (1m30s)\n")
+	assert.Contains(t, plantuml, "      state notify #fce8b2\n      notify: Running 10:04:05\n")
+
+	plantuml, err = graph.ToPlantUML(true)
+	assert.NoError(t, err)
+	assert.Contains(t, plantuml, "      state notify #fce8b2\n      notify: taskRef5\n      notify: Running 10:04:05\n")
+
+	mermaid, err := graph.ToMermaid(false)
+	assert.NoError(t, err)
+	assert.Contains(t, mermaid, "   task2(\"task2\n   Failed 10:04:05 (1m30s)\")\n   style task2 fill:#f4c7c3\n")
+
+	mermaid, err = graph.ToMermaid(true)
+	assert.NoError(t, err)
+	assert.Contains(t, mermaid, "   notify(\"notify\n   (taskRef5)\n   Running 10:04:05\")\n   style notify fill:#fce8b2\n")
+}
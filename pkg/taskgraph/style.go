@@ -0,0 +1,687 @@
+package taskgraph
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// legendStates is the order of the states in the legends
+var legendStates = []TaskState{
+	TaskStateSucceeded, TaskStateFailed, TaskStateRunning, TaskStateCancelled, TaskStateTimedOut, TaskStateSkipped,
+}
+
+// legendEntry is a line of the legend, it shows a node or an edge style with its meaning
+type legendEntry struct {
+	Label string
+	Node  *NodeStyle
+	Edge  *EdgeStyle
+}
+
+// legend returns the entries explaining the styles which encode something in the graph: the states of the
+// tasks, the finally tasks, the result references and the critical path. It is empty if the theme has no legend
+// or if the styling of the graph doesn't encode anything.
+func (g *TaskGraph) legend(theme *Theme) []legendEntry {
+	if theme == nil || !theme.Legend {
+		return nil
+	}
+
+	var (
+		finally, result, critical bool
+		states                    = map[TaskState]bool{}
+	)
+
+	for _, node := range g.Nodes {
+		finally = finally || node.IsFinally()
+		critical = critical || node.Critical
+
+		if node.Status != nil {
+			states[node.Status.State] = true
+		}
+
+		for _, dep := range node.Dependencies {
+			result = result || node.EdgeKind(dep).IsResult()
+		}
+	}
+
+	var entries []legendEntry
+
+	task := theme.nodeStyle(&TaskNode{Kind: NodeKindTask})
+
+	if finally {
+		finallyStyle := theme.nodeStyle(&TaskNode{Kind: NodeKindFinally})
+		entries = append(entries,
+			legendEntry{Label: "task", Node: &task},
+			legendEntry{Label: "finally", Node: &finallyStyle})
+	}
+
+	for _, state := range legendStates {
+		if states[state] {
+			style := task
+			style.Fill = theme.stateColor(&TaskStatus{State: state})
+			entries = append(entries, legendEntry{Label: string(state), Node: &style})
+		}
+	}
+
+	if result {
+		runAfter, resultStyle := theme.edgeStyle(EdgeKindRunAfter), theme.edgeStyle(EdgeKindResult)
+		entries = append(entries,
+			legendEntry{Label: "runAfter", Edge: &runAfter},
+			legendEntry{Label: "result", Edge: &resultStyle})
+	}
+
+	if critical {
+		entries = append(entries, legendEntry{Label: "critical path", Edge: &EdgeStyle{Color: theme.criticalColor(), Line: LineBold}})
+	}
+
+	return entries
+}
+
+// styleFuncs are the functions the built-in templates of the format use to apply the theme, they produce
+// the default styling of the format when theme is nil. funcs are the builtinFuncs of the template.
+func (g *TaskGraph) styleFuncs(theme *Theme, format string, funcs template.FuncMap) template.FuncMap {
+	s := &styler{
+		graph:   g,
+		theme:   theme,
+		id:      funcs["id"].(func(string) string),
+		extraID: funcs["extraID"].(func(string) string),
+	}
+
+	styleFuncs := template.FuncMap{
+		"themed":        func() bool { return theme != nil },
+		"stateColor":    theme.stateColor,
+		"criticalColor": theme.criticalColor,
+	}
+
+	switch format {
+	case "dot":
+		styleFuncs["dotGraphAttrs"] = s.dotGraphAttrs
+		styleFuncs["dotNodeAttrs"] = s.dotNodeAttrs
+		styleFuncs["dotPointAttrs"] = s.dotPointAttrs
+		styleFuncs["dotFillStyle"] = s.dotFillStyle
+		styleFuncs["dotEdgeAttrs"] = s.dotEdgeAttrs
+		styleFuncs["dotLegend"] = s.dotLegend
+	case "mmd":
+		styleFuncs["mermaidConfig"] = s.mermaidConfig
+		styleFuncs["direction"] = s.direction
+		styleFuncs["open"] = s.mermaidOpen
+		styleFuncs["close"] = s.mermaidClose
+		styleFuncs["arrow"] = s.mermaidArrow
+		styleFuncs["link"] = s.mermaidLink
+		styleFuncs["mermaidStyles"] = s.mermaidStyles
+	case "puml":
+		styleFuncs["pumlHeader"] = s.pumlHeader
+		styleFuncs["pumlArrow"] = s.pumlArrow
+		styleFuncs["pumlStereotype"] = s.pumlStereotype
+		styleFuncs["pumlCritical"] = s.pumlCritical
+		styleFuncs["pumlLegend"] = s.pumlLegend
+	}
+
+	for name, f := range styleFuncs {
+		funcs[name] = f
+	}
+
+	return funcs
+}
+
+// styler renders the styling of a graph with a theme, the nil theme is the default styling
+type styler struct {
+	graph   *TaskGraph
+	theme   *Theme
+	id      func(string) string
+	extraID func(string) string
+	// links are the colors of the mermaid links by index, empty for the default color
+	links []string
+}
+
+func (s *styler) direction() string {
+	if s.theme == nil {
+		return string(DirectionTopDown)
+	}
+
+	return string(s.theme.Direction)
+}
+
+// attrs joins the non-empty attributes
+func attrs(list ...string) string {
+	var kept []string
+
+	for _, a := range list {
+		if a != "" {
+			kept = append(kept, a)
+		}
+	}
+
+	return strings.Join(kept, " ")
+}
+
+// attr returns name="value", or nothing if value is empty
+func attr(name, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(`%s="%s"`, name, escapeDOT(value))
+}
+
+func dotShape(style NodeStyle) (shape, fill string) {
+	switch style.Shape {
+	case ShapeBox:
+		return "box", "filled"
+	case ShapeEllipse:
+		return "ellipse", "filled"
+	case ShapeHexagon:
+		return "hexagon", "filled"
+	default:
+		return "box", "rounded,filled"
+	}
+}
+
+func dotLine(line LineStyle) string {
+	if line == LineSolid {
+		return ""
+	}
+
+	return string(line)
+}
+
+func (s *styler) dotNodeStyle(style NodeStyle) string {
+	shape, fill := dotShape(style)
+
+	return attrs(attr("shape", shape), attr("style", fill), attr("fillcolor", style.Fill), attr("color", style.Stroke))
+}
+
+// dotGraphAttrs are the attributes of the graph and the default attributes of the nodes and the edges
+func (s *styler) dotGraphAttrs() string {
+	if s.theme == nil {
+		return ""
+	}
+
+	t := s.theme
+	font := attrs(attr("fontname", t.Font.Name), fontSize(t.Font.Size), attr("fontcolor", t.Font.Color))
+	edge := t.edgeStyle(EdgeKindRunAfter)
+
+	var b strings.Builder
+	if t.Direction == DirectionLeftRight {
+		b.WriteString("\n   rankdir=\"LR\"")
+	}
+
+	for _, graphAttr := range []string{attr("bgcolor", t.Background), font} {
+		if graphAttr != "" {
+			b.WriteString("\n   " + graphAttr)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n   node [%s]", attrs(s.dotNodeStyle(t.nodeStyle(&TaskNode{Kind: NodeKindTask})), font))
+	fmt.Fprintf(&b, "\n   edge [%s]", attrs(attr("color", edge.Color), attr("style", dotLine(edge.Line)), attr("fontcolor", t.Font.Color)))
+
+	return b.String()
+}
+
+func fontSize(size int) string {
+	if size == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("fontsize=%d", size)
+}
+
+// dotNodeAttrs are the attributes of the finally tasks, the other tasks use the default node attributes
+func (s *styler) dotNodeAttrs(node *TaskNode) string {
+	if s.theme == nil || !node.IsFinally() {
+		return ""
+	}
+
+	return " [" + s.dotNodeStyle(s.theme.nodeStyle(node)) + "]"
+}
+
+// dotPointAttrs are the attributes of the start and end points, they are filled with the color of the edges
+// rather than the default fill of the nodes
+func (s *styler) dotPointAttrs() string {
+	if s.theme == nil {
+		return ""
+	}
+
+	color := s.theme.edgeStyle(EdgeKindRunAfter).Color
+
+	return " " + attrs(`style="filled"`, attr("fillcolor", color), attr("color", color))
+}
+
+// dotFillStyle is the style of the nodes filled with the color of their state
+func (s *styler) dotFillStyle(node *TaskNode) string {
+	if s.theme == nil {
+		return "filled"
+	}
+
+	_, fill := dotShape(s.theme.nodeStyle(node))
+
+	return fill
+}
+
+// dotEdgeAttrs are the attributes of the edge from node to dep
+func (s *styler) dotEdgeAttrs(node, dep *TaskNode) string {
+	kind, critical := node.EdgeKind(dep), node.IsCriticalEdge(dep)
+
+	if s.theme == nil {
+		var b strings.Builder
+		if kind.IsResult() {
+			b.WriteString(` [style="dashed"]`)
+		}
+
+		if critical {
+			fmt.Fprintf(&b, ` [color="%s" penwidth=2]`, criticalColor)
+		}
+
+		return b.String()
+	}
+
+	style := s.theme.edgeStyle(kind)
+	if !kind.IsResult() && !critical {
+		// the default edge attributes
+		return ""
+	}
+
+	// the style is always set, the default edge style may not be solid
+	list := []string{attr("style", string(style.Line)), attr("color", style.Color)}
+	if critical {
+		list = []string{attr("style", string(style.Line)), attr("color", s.theme.criticalColor()), "penwidth=2"}
+	}
+
+	return " [" + attrs(list...) + "]"
+}
+
+// dotLegend is a cluster with a node or an edge of every legend entry, drawn with their actual style
+func (s *styler) dotLegend() string {
+	entries := s.graph.legend(s.theme)
+	if len(entries) == 0 {
+		return ""
+	}
+
+	legend := s.extraID("legend")
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\n   subgraph cluster_%s {\n      label=\"legend\"\n      style=\"dashed\"", legend)
+
+	for i, e := range entries {
+		id := s.extraID(fmt.Sprintf("%s_%d", legend, i))
+		if e.Node != nil {
+			fmt.Fprintf(&b, "\n      \"%s\" [%s]", id, attrs(attr("label", e.Label), s.dotNodeStyle(*e.Node)))
+
+			continue
+		}
+
+		// an edge between two invisible points, labeled with its meaning
+		from, to := s.extraID(id+"_from"), s.extraID(id+"_to")
+		fmt.Fprintf(&b, "\n      \"%s\" [shape=\"point\" style=\"invis\"]", from)
+		fmt.Fprintf(&b, "\n      \"%s\" [shape=\"point\" style=\"invis\"]", to)
+		fmt.Fprintf(&b, "\n      \"%s\" -> \"%s\" [%s]", from, to,
+			attrs(attr("label", e.Label), attr("style", string(e.Edge.Line)), attr("color", e.Edge.Color)))
+	}
+
+	b.WriteString("\n   }")
+
+	return b.String()
+}
+
+// legendArrow draws an arrow with the line style in text
+func legendArrow(line LineStyle, head string) string {
+	switch line {
+	case LineDashed:
+		return "- - -" + head
+	case LineDotted:
+		return "·····" + head
+	case LineBold:
+		return "━━━" + head
+	default:
+		return "───" + head
+	}
+}
+
+// mermaidConfig is the configuration of the mermaid theme, added to the front matter
+func (s *styler) mermaidConfig() string {
+	if s.theme == nil {
+		return ""
+	}
+
+	t := s.theme
+	task := t.nodeStyle(&TaskNode{Kind: NodeKindTask})
+	edge := t.edgeStyle(EdgeKindRunAfter)
+
+	variables := []struct{ name, value string }{
+		{"background", t.Background},
+		{"fontFamily", t.Font.Name},
+		{"primaryColor", task.Fill},
+		{"primaryBorderColor", task.Stroke},
+		{"primaryTextColor", t.Font.Color},
+		{"lineColor", edge.Color},
+	}
+
+	if t.Font.Size > 0 {
+		variables = append(variables, struct{ name, value string }{"fontSize", fmt.Sprintf("%dpx", t.Font.Size)})
+	}
+
+	var b strings.Builder
+
+	b.WriteString("\nconfig:")
+
+	if t.Mermaid != "" {
+		b.WriteString("\n  theme: " + yamlScalar(t.Mermaid))
+	}
+
+	b.WriteString("\n  themeVariables:")
+
+	for _, v := range variables {
+		if v.value != "" {
+			fmt.Fprintf(&b, "\n    %s: %s", v.name, yamlScalar(v.value))
+		}
+	}
+
+	return b.String()
+}
+
+// mermaidShapes are the opening and closing delimiters of the node shapes
+var mermaidShapes = map[NodeShape][2]string{
+	ShapeBox:     {"[", "]"},
+	ShapeRounded: {"(", ")"},
+	ShapeEllipse: {"([", "])"},
+	ShapeHexagon: {"{{", "}}"},
+}
+
+func (s *styler) mermaidShape(node *TaskNode) [2]string {
+	if s.theme == nil {
+		return mermaidShapes[ShapeRounded]
+	}
+
+	shape, ok := mermaidShapes[s.theme.nodeStyle(node).Shape]
+	if !ok {
+		return mermaidShapes[ShapeRounded]
+	}
+
+	return shape
+}
+
+func (s *styler) mermaidOpen(node *TaskNode) string { return s.mermaidShape(node)[0] }
+
+func (s *styler) mermaidClose(node *TaskNode) string { return s.mermaidShape(node)[1] }
+
+// mermaidArrow is the link from node to dep. The links are styled by their index, so every link of the
+// graph must be written with arrow or link.
+func (s *styler) mermaidArrow(node, dep *TaskNode) string {
+	kind, critical := node.EdgeKind(dep), node.IsCriticalEdge(dep)
+
+	if s.theme == nil {
+		switch {
+		case critical:
+			return "==>"
+		case kind.IsResult():
+			return "-.->"
+		default:
+			return "-->"
+		}
+	}
+
+	style := s.theme.edgeStyle(kind)
+
+	color := ""
+	if kind.IsResult() {
+		color = style.Color
+	}
+
+	if critical {
+		s.links = append(s.links, s.theme.criticalColor())
+
+		return "==>"
+	}
+
+	s.links = append(s.links, color)
+
+	return mermaidLine(style.Line)
+}
+
+func mermaidLine(line LineStyle) string {
+	switch line {
+	case LineDashed, LineDotted:
+		return "-.->"
+	case LineBold:
+		return "==>"
+	default:
+		return "-->"
+	}
+}
+
+// mermaidLink is a link with the default style, e.g. from the start node
+func (s *styler) mermaidLink() string {
+	s.links = append(s.links, "")
+
+	return "-->"
+}
+
+// mermaidStyles are the classes of the tasks, the colors of the links and the legend
+func (s *styler) mermaidStyles() string {
+	if s.theme == nil {
+		return ""
+	}
+
+	t := s.theme
+
+	var (
+		b              strings.Builder
+		tasks, finally []string
+	)
+
+	for _, name := range s.graph.sortedNames() {
+		if s.graph.Nodes[name].IsFinally() {
+			finally = append(finally, s.id(name))
+		} else {
+			tasks = append(tasks, s.id(name))
+		}
+	}
+
+	classes := []struct {
+		class string
+		ids   []string
+		style NodeStyle
+	}{
+		{s.extraID("task"), tasks, t.nodeStyle(&TaskNode{Kind: NodeKindTask})},
+		{s.extraID("finally"), finally, t.nodeStyle(&TaskNode{Kind: NodeKindFinally})},
+	}
+
+	for _, c := range classes {
+		if len(c.ids) > 0 {
+			fmt.Fprintf(&b, "\n   classDef %s %s", c.class, mermaidNodeStyle(c.style, t.Font.Color))
+			fmt.Fprintf(&b, "\n   class %s %s", strings.Join(c.ids, ","), c.class)
+		}
+	}
+
+	if entries := s.graph.legend(t); len(entries) > 0 {
+		legend := s.extraID("legend")
+		fmt.Fprintf(&b, "\n   subgraph %s [legend]", legend)
+
+		for i, e := range entries {
+			id := s.extraID(fmt.Sprintf("%s_%d", legend, i))
+			if e.Node != nil {
+				shape := mermaidShapes[e.Node.Shape]
+				fmt.Fprintf(&b, "\n      %s%s\"%s\"%s", id, shape[0], escapeMermaid(e.Label), shape[1])
+				fmt.Fprintf(&b, "\n      style %s %s", id, mermaidNodeStyle(*e.Node, t.Font.Color))
+
+				continue
+			}
+
+			// an edge between two invisible nodes, labeled with its meaning
+			from, to := s.extraID(id+"_from"), s.extraID(id+"_to")
+			fmt.Fprintf(&b, "\n      %s[\" \"] %s|\"%s\"| %s[\" \"]", from, mermaidLine(e.Edge.Line), escapeMermaid(e.Label), to)
+			fmt.Fprintf(&b, "\n      style %s fill:none,stroke:none", from)
+			fmt.Fprintf(&b, "\n      style %s fill:none,stroke:none", to)
+			s.links = append(s.links, e.Edge.Color)
+		}
+
+		b.WriteString("\n   end")
+	}
+
+	if edge := t.edgeStyle(EdgeKindRunAfter); edge.Color != "" {
+		fmt.Fprintf(&b, "\n   linkStyle default stroke:%s", edge.Color)
+	}
+
+	for i, color := range s.links {
+		if color != "" {
+			fmt.Fprintf(&b, "\n   linkStyle %d stroke:%s", i, color)
+		}
+	}
+
+	return b.String()
+}
+
+func mermaidNodeStyle(style NodeStyle, fontColor string) string {
+	var list []string
+
+	for _, p := range [][2]string{{"fill", style.Fill}, {"stroke", style.Stroke}, {"color", fontColor}} {
+		if p[1] != "" {
+			list = append(list, p[0]+":"+p[1])
+		}
+	}
+
+	return strings.Join(list, ",")
+}
+
+// pumlHeader are the direction and the skin parameters of the diagram
+func (s *styler) pumlHeader() string {
+	if s.theme == nil {
+		return ""
+	}
+
+	t := s.theme
+	task := t.nodeStyle(&TaskNode{Kind: NodeKindTask})
+	finally := t.nodeStyle(&TaskNode{Kind: NodeKindFinally})
+	edge := t.edgeStyle(EdgeKindRunAfter)
+
+	var b strings.Builder
+	if t.Direction == DirectionLeftRight {
+		b.WriteString("\nleft to right direction")
+	}
+
+	params := []struct{ name, value string }{
+		{"backgroundColor", t.Background},
+		{"defaultFontName", t.Font.Name},
+		{"defaultFontColor", t.Font.Color},
+	}
+
+	if t.Font.Size > 0 {
+		params = append(params, struct{ name, value string }{"defaultFontSize", fmt.Sprint(t.Font.Size)})
+	}
+
+	for _, p := range params {
+		if p.value != "" {
+			fmt.Fprintf(&b, "\nskinparam %s %s", p.name, p.value)
+		}
+	}
+
+	b.WriteString("\nskinparam state {")
+
+	for _, p := range []struct{ name, value string }{
+		{"BackgroundColor", task.Fill},
+		{"BorderColor", task.Stroke},
+		{"ArrowColor", edge.Color},
+		{"BackgroundColor<<finally>>", finally.Fill},
+		{"BorderColor<<finally>>", finally.Stroke},
+	} {
+		if p.value != "" {
+			fmt.Fprintf(&b, "\n   %s %s", p.name, p.value)
+		}
+	}
+
+	b.WriteString("\n}")
+
+	return b.String()
+}
+
+// pumlArrow is the transition from node to dep
+func (s *styler) pumlArrow(node, dep *TaskNode) string {
+	kind, critical := node.EdgeKind(dep), node.IsCriticalEdge(dep)
+
+	if s.theme == nil {
+		switch {
+		case critical && kind.IsResult():
+			return "-down[dashed," + criticalColor + ",bold]->"
+		case critical:
+			return "-down[" + criticalColor + ",bold]->"
+		case kind.IsResult():
+			return "-down[dashed]->"
+		default:
+			return "-down->"
+		}
+	}
+
+	direction := "-down"
+	if s.theme.Direction == DirectionLeftRight {
+		// the arrows follow the direction of the diagram
+		direction = "-"
+	}
+
+	style := s.theme.edgeStyle(kind)
+
+	var list []string
+	if style.Line != LineSolid && !(critical && style.Line == LineBold) {
+		list = append(list, string(style.Line))
+	}
+
+	switch {
+	case critical:
+		list = append(list, "#"+pumlColor(s.theme.criticalColor()), "bold")
+	case kind.IsResult() && style.Color != "":
+		list = append(list, "#"+pumlColor(style.Color))
+	}
+
+	if len(list) == 0 {
+		return direction + "->"
+	}
+
+	return direction + "[" + strings.Join(list, ",") + "]->"
+}
+
+// pumlColor returns the color without #, as PlantUML expects them after line: and ##
+func pumlColor(color string) string {
+	return strings.TrimPrefix(color, "#")
+}
+
+// pumlStereotype marks the finally tasks, so they are styled by the skin parameters of the finally stereotype
+func (s *styler) pumlStereotype(node *TaskNode) string {
+	if s.theme == nil || !node.IsFinally() {
+		return ""
+	}
+
+	return " <<finally>>"
+}
+
+// pumlCritical is the color of the border of the tasks of the critical path
+func (s *styler) pumlCritical() string {
+	if s.theme == nil {
+		return "red"
+	}
+
+	return pumlColor(s.theme.criticalColor())
+}
+
+// pumlLegend is the legend of the diagram, in creole
+func (s *styler) pumlLegend() string {
+	entries := s.graph.legend(s.theme)
+	if len(entries) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteString("\nlegend right")
+
+	for _, e := range entries {
+		if e.Node != nil {
+			fmt.Fprintf(&b, "\n   <back:%s>      </back> %s", e.Node.Fill, e.Label)
+		} else {
+			fmt.Fprintf(&b, "\n   <color:%s>%s</color> %s", e.Edge.Color, legendArrow(e.Edge.Line, ">"), e.Label)
+		}
+	}
+
+	b.WriteString("\nendlegend")
+
+	return b.String()
+}
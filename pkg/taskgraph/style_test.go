@@ -0,0 +1,201 @@
+package taskgraph
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gonum.org/v1/gonum/graph/formats/dot"
+	"sigs.k8s.io/yaml"
+)
+
+// getTestThemedGraph returns a graph using all the encodings of the legend: finally tasks, states,
+// result references and, if critical is true, the critical path
+func getTestThemedGraph(t *testing.T, critical bool) *TaskGraph {
+	t.Helper()
+
+	graph := mustBuildTaskGraph(t, getTestResultTasks(), getTestFinallyTasks()...)
+	graph.PipelineName = "release"
+	graph.SetStatuses(map[string]*TaskStatus{
+		"build": {State: TaskStateSucceeded},
+		"scan":  {State: TaskStateFailed},
+	})
+
+	if critical {
+		graph.Nodes["build"].Critical = true
+		graph.Nodes["build"].criticalNext = graph.Nodes["scan"]
+		graph.Nodes["scan"].Critical = true
+	}
+
+	return graph
+}
+
+func getTestTheme(t *testing.T, name string, direction Direction) *Theme {
+	t.Helper()
+
+	theme, ok := LookupTheme(name)
+	require.True(t, ok)
+
+	theme.Direction = direction
+
+	return theme
+}
+
+func TestLegend(t *testing.T) {
+	theme := getTestTheme(t, "light", DirectionTopDown)
+
+	var labels []string
+	for _, e := range getTestThemedGraph(t, true).legend(theme) {
+		labels = append(labels, e.Label)
+	}
+
+	assert.Equal(t, []string{"task", "finally", "Succeeded", "Failed", "runAfter", "result", "critical path"}, labels)
+
+	// nothing is encoded in a pipeline without finally tasks, results and status
+	assert.Empty(t, mustBuildTaskGraph(t, getTestTasks()).legend(theme))
+	assert.Empty(t, getTestThemedGraph(t, true).legend(nil))
+
+	theme.Legend = false
+	assert.Empty(t, getTestThemedGraph(t, true).legend(theme))
+}
+
+func TestThemedDOT(t *testing.T) {
+	for _, name := range ThemeNames() {
+		for _, withTaskRef := range []bool{false, true} {
+			output, err := getTestThemedGraph(t, true).toDOT(withTaskRef, getTestTheme(t, name, DirectionLeftRight))
+			require.NoError(t, err)
+
+			_, err = dot.ParseString(output)
+			require.NoError(t, err, output)
+		}
+	}
+
+	output, err := getTestThemedGraph(t, true).toDOT(false, getTestTheme(t, "dark", DirectionLeftRight))
+	require.NoError(t, err)
+
+	for _, expected := range []string{
+		`rankdir="LR"`,
+		`bgcolor="#202124"`,
+		`node [shape="box" style="rounded,filled" fillcolor="#303134" color="#9aa0a6" fontname="Helvetica" fontsize=14 fontcolor="#e8eaed"]`,
+		`"build" -> "scan" [style="dashed" color="#f28b82" penwidth=2]`,
+		`"cleanup-ws" [shape="box" style="filled" fillcolor="#3c4043" color="#9aa0a6"]`,
+		`"scan" [style="rounded,filled" fillcolor="#a50e0e" label="scan`,
+		`subgraph cluster_legend {`,
+		`"legend_2" [label="Succeeded" shape="box" style="rounded,filled" fillcolor="#0d652d" color="#9aa0a6"]`,
+		`"legend_5_from" -> "legend_5_to" [label="result" style="dashed" color="#8ab4f8"]`,
+	} {
+		assert.Contains(t, output, expected)
+	}
+
+	// without a legend and top down
+	theme := getTestTheme(t, "light", DirectionTopDown)
+	theme.Legend = false
+
+	output, err = getTestThemedGraph(t, true).toDOT(false, theme)
+	require.NoError(t, err)
+	assert.NotContains(t, output, "rankdir")
+	assert.NotContains(t, output, "legend")
+}
+
+func TestThemedMermaid(t *testing.T) {
+	output, err := getTestThemedGraph(t, true).toMermaid(false, getTestTheme(t, "print", DirectionLeftRight))
+	require.NoError(t, err)
+
+	// the front matter is valid YAML with the config of the theme
+	front := regexp.MustCompile(`(?s)^---\n(.*?)\n---\n`).FindStringSubmatch(output)
+	require.NotNil(t, front, output)
+
+	var config struct {
+		Title  string `json:"title"`
+		Config struct {
+			Theme          string            `json:"theme"`
+			ThemeVariables map[string]string `json:"themeVariables"`
+		} `json:"config"`
+	}
+
+	require.NoError(t, yaml.UnmarshalStrict([]byte(front[1]), &config))
+	assert.Equal(t, "release", config.Title)
+	assert.Equal(t, "neutral", config.Config.Theme)
+	assert.Equal(t, "Times", config.Config.ThemeVariables["fontFamily"])
+	assert.Equal(t, "12px", config.Config.ThemeVariables["fontSize"])
+
+	for _, expected := range []string{
+		"flowchart LR",
+		// the finally tasks are hexagons, the result references are dotted
+		"   cleanup-ws{{\"cleanup-ws\"}}\n",
+		"   build ==> scan\n",
+		"   build --> deploy\n",
+		"   scan --> deploy\n",
+		"   classDef task fill:#ffffff,stroke:#000000,color:#000000\n   class build,deploy,scan task\n",
+		"   class cleanup-ws,notify finally\n",
+		"   subgraph legend [legend]\n",
+		"   linkStyle default stroke:#000000\n",
+	} {
+		assert.Contains(t, output, expected)
+	}
+
+	// the links are styled by their index: start --> build, build ==> scan, ... and the links of the legend
+	links := regexp.MustCompile(`(?m) (-->|-\.->|==>)`).FindAllString(output, -1)
+	assert.Equal(t, " ==>", links[1])
+	assert.Contains(t, output, "   linkStyle 1 stroke:#000000\n")
+	assert.Regexp(t, regexp.MustCompile(`linkStyle \d+ stroke:#000000\n$`), output)
+	assert.Contains(t, output, "linkStyle "+strconv.Itoa(len(links)-1)+" stroke:")
+
+	// the result references are dotted and colored when they aren't on the critical path
+	graph := getTestThemedGraph(t, false)
+
+	output, err = graph.toMermaid(false, getTestTheme(t, "dark", DirectionTopDown))
+	require.NoError(t, err)
+	assert.Contains(t, output, "flowchart TD\n")
+	assert.Contains(t, output, "   build -.-> scan\n")
+	assert.Contains(t, output, "   linkStyle 1 stroke:#8ab4f8\n")
+}
+
+func TestThemedPlantUML(t *testing.T) {
+	output, err := getTestThemedGraph(t, true).toPlantUML(true, getTestTheme(t, "light", DirectionLeftRight))
+	require.NoError(t, err)
+
+	for _, expected := range []string{
+		"hide empty description\nleft to right direction\nskinparam backgroundColor #ffffff\n",
+		"   BackgroundColor<<finally>> #f1f3f4\n",
+		"   build -[dashed,#d93025,bold]-> scan\n",
+		"   scan --> deploy\n",
+		"   build --> deploy\n",
+		"      state cleanup_ws <<finally>>\n",
+		"   state build #b7e1cd;line:d93025;line.bold\n",
+		"legend right\n",
+		"   <color:#1a73e8>- - -></color> result\n",
+		"endlegend\n@enduml\n",
+	} {
+		assert.Contains(t, output, expected)
+	}
+
+	graph := getTestThemedGraph(t, false)
+
+	output, err = graph.toPlantUML(false, getTestTheme(t, "print", DirectionTopDown))
+	require.NoError(t, err)
+	assert.NotContains(t, output, "left to right direction")
+	assert.Contains(t, output, "   build -down-> deploy\n")
+	assert.Contains(t, output, "   build -down[dotted,#000000]-> scan\n")
+}
+
+// The default styling is used without a theme, the output of the renderers doesn't change
+func TestRenderWithoutTheme(t *testing.T) {
+	graph := getTestThemedGraph(t, true)
+
+	for _, format := range []string{"dot", "mmd", "puml"} {
+		expected, err := renderString(graph, format, RenderOptions{})
+		require.NoError(t, err)
+
+		themed, err := renderString(graph, format, RenderOptions{Theme: getTestTheme(t, "light", DirectionTopDown)})
+		require.NoError(t, err)
+		assert.NotEqual(t, expected, themed, format)
+	}
+
+	dotOutput, err := graph.ToDOT(false)
+	require.NoError(t, err)
+	assert.NotContains(t, dotOutput, "legend")
+	assert.Contains(t, dotOutput, `"build" -> "scan" [style="dashed"] [color="#d93025" penwidth=2]`)
+}
@@ -0,0 +1,120 @@
+package taskgraph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Colors of the rendered svg and png graphs, the nodes with a status use the color of the state
+const (
+	renderBackground = "#ffffff"
+	renderNodeFill   = "#ffffff"
+	renderStroke     = "#333333"
+	renderCluster    = "#666666"
+)
+
+// ToSVG renders the graph as a standalone SVG image, no external tool is required
+func (g *TaskGraph) ToSVG(withTaskRef bool) (string, error) {
+	var b strings.Builder
+
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	writeSVG(&b, g.computeLayout(withTaskRef))
+
+	return b.String(), nil
+}
+
+// writeSVG writes the svg element of the layout. The tasks and the edges between them carry the data-task,
+// data-from and data-to attributes, so they can be found by scripts.
+func writeSVG(b *strings.Builder, l *graphLayout) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]g" height="%[2]g" viewBox="0 0 %[1]g %[2]g" font-family="monospace" font-size="12">
+<defs>
+  <marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto">
+    <path d="M 0 0 L 10 5 L 0 10 z" fill="%[3]s"/>
+  </marker>
+</defs>
+<rect width="100%%" height="100%%" fill="%[4]s"/>
+`, l.width, l.height, renderStroke, renderBackground)
+
+	fmt.Fprintf(b, "<text x=\"%g\" y=\"%d\" text-anchor=\"middle\" font-size=\"14\" font-weight=\"bold\">%s</text>\n",
+		l.width/2, layoutMargin+layoutLineHeight, escapeXML(l.title))
+
+	for _, node := range l.nodes {
+		if node.kind != layoutCluster {
+			continue
+		}
+
+		fmt.Fprintf(b, "<g class=\"cluster\">\n  <rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" fill=\"none\" stroke=\"%s\" stroke-dasharray=\"5,3\"/>\n",
+			node.left(), node.top(), node.width, node.height, renderCluster)
+		fmt.Fprintf(b, "  <text x=\"%g\" y=\"%g\" text-anchor=\"middle\">%s</text>\n</g>\n",
+			node.x, node.top()+layoutLineHeight, escapeXML(node.label[0]))
+	}
+
+	for _, edge := range l.edges {
+		points := make([]string, 0, len(edge.points))
+		for _, p := range edge.points {
+			points = append(points, fmt.Sprintf("%g,%g", p.x, p.y))
+		}
+
+		attrs := ""
+		stroke := renderStroke
+
+		if edge.from != nil && edge.to != nil {
+			attrs = fmt.Sprintf(" data-from=\"%s\" data-to=\"%s\"", escapeXML(edge.from.Name), escapeXML(edge.to.Name))
+
+			if edge.from.IsCriticalEdge(edge.to) {
+				stroke = criticalColor
+				attrs += ` stroke-width="2"`
+			}
+		}
+
+		if edge.kind.IsResult() {
+			attrs += ` stroke-dasharray="5,3"`
+		}
+
+		fmt.Fprintf(b, "<polyline class=\"edge\" points=\"%s\" fill=\"none\" stroke=\"%s\"%s marker-end=\"url(#arrow)\"/>\n",
+			strings.Join(points, " "), stroke, attrs)
+	}
+
+	for _, node := range l.nodes {
+		switch node.kind {
+		case layoutPoint:
+			fmt.Fprintf(b, "<circle cx=\"%g\" cy=\"%g\" r=\"%d\" fill=\"%s\"/>\n", node.x, node.y, layoutPointRadius, renderStroke)
+		case layoutTask:
+			fill := renderNodeFill
+			if node.task.Status != nil {
+				fill = node.task.Status.Color()
+			}
+
+			fmt.Fprintf(b, "<g class=\"node\" data-task=\"%[1]s\">\n  <title>%[1]s</title>\n", escapeXML(node.task.Name))
+			stroke := fmt.Sprintf("stroke=\"%s\"", renderStroke)
+			if node.task.Critical {
+				stroke = fmt.Sprintf("stroke=\"%s\" stroke-width=\"3\"", criticalColor)
+			}
+
+			fmt.Fprintf(b, "  <rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" rx=\"4\" fill=\"%s\" %s/>\n",
+				node.left(), node.top(), node.width, node.height, fill, stroke)
+
+			for i, line := range node.label {
+				fmt.Fprintf(b, "  <text x=\"%g\" y=\"%g\" text-anchor=\"middle\">%s</text>\n",
+					node.x, labelBaseline(node, i), escapeXML(line))
+			}
+
+			b.WriteString("</g>\n")
+		}
+	}
+
+	b.WriteString("</svg>\n")
+}
+
+// labelBaseline returns the baseline of the i-th line of the node label
+func labelBaseline(node *layoutNode, i int) float64 {
+	return node.top() + layoutPaddingY + float64((i+1)*layoutLineHeight) - 4
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package taskgraph
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskGraphToSVG(t *testing.T) {
+	graph := getTestExportGraph(t)
+	graph.PipelineName = "<test & pipeline>"
+
+	svg, err := graph.ToSVG(true)
+	require.NoError(t, err)
+
+	// the output is well-formed XML
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := decoder.Token()
+		if err != nil {
+			assert.Equal(t, "EOF", err.Error())
+			break
+		}
+	}
+
+	assert.True(t, strings.HasPrefix(svg, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\""))
+	assert.Contains(t, svg, ">&lt;test &amp; pipeline&gt;</text>")
+	assert.Contains(t, svg, "<g class=\"node\" data-task=\"build\">\n  <title>build</title>")
+	assert.Contains(t, svg, "data-from=\"scan\" data-to=\"deploy\" stroke-dasharray=\"5,3\"")
+	assert.Contains(t, svg, ">(buildah)</text>")
+	assert.Contains(t, svg, ">Succeeded 10:04:05 (1m30s)</text>")
+	assert.Contains(t, svg, "fill=\"#b7e1cd\"")
+	assert.Contains(t, svg, "fill=\"#f3f3f3\"")
+	assert.Contains(t, svg, "<g class=\"cluster\">")
+	assert.Equal(t, 7, strings.Count(svg, "<polyline"))
+	assert.Equal(t, 3, strings.Count(svg, "stroke-dasharray=\"5,3\" marker-end"))
+	assert.Equal(t, 2, strings.Count(svg, "<circle"))
+	assert.True(t, strings.HasSuffix(svg, "</svg>\n"))
+}
+
+func TestTaskGraphToSVGWithoutTaskRef(t *testing.T) {
+	svg, err := mustBuildTaskGraph(t, getTestTasks()).ToSVG(false)
+	require.NoError(t, err)
+
+	assert.Contains(t, svg, ">task-with-dash</text>")
+	assert.NotContains(t, svg, "(taskRef4)")
+	assert.NotContains(t, svg, "class=\"cluster\"")
+}
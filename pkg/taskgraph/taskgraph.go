@@ -4,84 +4,319 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
+	"github.com/fatih/color"
+
 	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 )
 
 type TaskGraph struct {
 	PipelineName string
+	Namespace    string // Set when the graphs come from several namespaces, see Title
 	Nodes        map[string]*TaskNode
 }
 
 type TaskNode struct {
-	Name         string
-	TaskRefName  string // Name of the kind: Task referenced by this task in the pipeline
-	Dependencies []*TaskNode
-	IsRoot       bool // Flag to indicate the the node is the root of the graph
+	Name            string
+	TaskRefName     string  // Human readable reference of what the task executes, see TaskRef.String
+	TaskRef         TaskRef // Reference to the Task, custom task or inline spec executed by this task in the pipeline
+	Kind            NodeKind
+	Params          map[string]string // Params passed to the task, see paramValue for arrays and objects
+	Dependencies    []*TaskNode
+	DependencyKinds map[string]EdgeKind // Origin of the edge to each of the Dependencies, keyed by name
+	IsRoot          bool                // Flag to indicate the the node is the root of the graph
+	Status          *TaskStatus         // Execution status when the graph is built from a PipelineRun
+	Critical        bool                // The task is part of the highlighted critical path
+
+	criticalNext *TaskNode // next task of the highlighted critical path
 }
 
-// FormatFunc is a function that generates the output format string for a TaskGraph
-type formatFuncMap func(graph *TaskGraph, format string, withTaskRef bool) (string, error)
+// NodeKind is the section of the Pipeline the task is declared in
+type NodeKind string
+
+const (
+	// NodeKindTask is a task from spec.tasks
+	NodeKindTask NodeKind = "task"
+	// NodeKindFinally is a task from spec.finally, it runs after all the other tasks are done
+	NodeKindFinally NodeKind = "finally"
+)
+
+// EdgeKind is the origin of the dependency between two tasks
+type EdgeKind string
+
+const (
+	// EdgeKindRunAfter is an explicit dependency declared with runAfter
+	EdgeKindRunAfter EdgeKind = "runAfter"
+	// EdgeKindResult is an implicit dependency, the task consumes a result of another task
+	// in params, when expressions or matrix
+	EdgeKindResult EdgeKind = "result"
+)
+
+// IsResult returns true if the dependency comes from a result reference
+func (k EdgeKind) IsResult() bool {
+	return k == EdgeKindResult
+}
 
 func createTaskNode(task *v1pipeline.PipelineTask) *TaskNode {
-	return &TaskNode{
+	ref := newTaskRef(task)
+
+	node := &TaskNode{
 		Name:        task.Name,
-		TaskRefName: task.TaskRef.Name,
+		TaskRefName: ref.String(),
+		TaskRef:     ref,
+		Kind:        NodeKindTask,
 		IsRoot:      true, // we assume that the node is root until we find a parent
 	}
+
+	if len(task.Params) > 0 {
+		node.Params = make(map[string]string, len(task.Params))
+		for _, p := range task.Params {
+			node.Params[p.Name] = paramValue(p.Value)
+		}
+	}
+
+	return node
+}
+
+// IsFinally returns true if the node is a finally task
+func (n *TaskNode) IsFinally() bool {
+	return n.Kind == NodeKindFinally
+}
+
+// EdgeKind returns the origin of the edge from the node to dep, edges without a recorded kind are runAfter
+func (n *TaskNode) EdgeKind(dep *TaskNode) EdgeKind {
+	if kind, ok := n.DependencyKinds[dep.Name]; ok {
+		return kind
+	}
+
+	return EdgeKindRunAfter
+}
+
+// addDependency adds the edge from the node to dep, the first kind recorded for the edge wins
+func (n *TaskNode) addDependency(dep *TaskNode, kind EdgeKind) {
+	if _, ok := n.DependencyKinds[dep.Name]; ok {
+		return
+	}
+
+	if n.DependencyKinds == nil {
+		n.DependencyKinds = make(map[string]EdgeKind)
+	}
+
+	n.Dependencies = append(n.Dependencies, dep)
+	n.DependencyKinds[dep.Name] = kind
+	dep.IsRoot = false
 }
 
 // In the case where the order of tasks is arbitrary, it is necessary to create all the nodes first
 // and then add the dependencies in a separate loop (since dependencies doesn't exist in TaskRef).
-// BuildTaskGraph creates a TaskGraph from a list of PipelineTasks
-func BuildTaskGraph(tasks []v1pipeline.PipelineTask) *TaskGraph {
+// BuildTaskGraph creates a TaskGraph from a list of PipelineTasks and the optional finally tasks.
+// Finally tasks have no dependencies of their own, they are executed after all the other tasks.
+// All the problems of the Pipeline are returned as ValidationErrors, the graph is nil in this case.
+func BuildTaskGraph(tasks []v1pipeline.PipelineTask, finally ...v1pipeline.PipelineTask) (*TaskGraph, error) {
 	graph := &TaskGraph{
-		Nodes: make(map[string]*TaskNode, len(tasks)),
+		Nodes: make(map[string]*TaskNode, len(tasks)+len(finally)),
+	}
+
+	var errs ValidationErrors
+
+	for i := range finally {
+		node := createTaskNode(&finally[i])
+		node.Kind = NodeKindFinally
+		node.IsRoot = false
+
+		if _, ok := graph.Nodes[node.Name]; ok {
+			errs = append(errs, &ValidationError{Err: ErrDuplicateTask, Task: node.Name})
+			continue
+		}
+
+		graph.Nodes[node.Name] = node
 	}
 
-	// Create a node for each task and add it to the graph
+	// Create a node for each task and add it to the graph, duplicates are left out
+	nodes := make([]*TaskNode, len(tasks))
+
 	for i := range tasks {
 		task := &tasks[i]
-		node := createTaskNode(task)
-		graph.Nodes[task.Name] = node
+		if _, ok := graph.Nodes[task.Name]; ok {
+			errs = append(errs, &ValidationError{Err: ErrDuplicateTask, Task: task.Name})
+			continue
+		}
+
+		nodes[i] = createTaskNode(task)
+		graph.Nodes[task.Name] = nodes[i]
 	}
 
 	// Add dependencies to the nodes
 	for i := range tasks {
 		task := &tasks[i]
-		node := graph.Nodes[task.Name]
+		node := nodes[i]
+
+		if node == nil {
+			continue
+		}
 
-		// Add dependencies to the node
+		// Add dependencies to the node, explicit runAfter first so it takes precedence over result references
 		for _, depName := range task.RunAfter {
-			depNode := graph.Nodes[depName]
-			depNode.Dependencies = append(depNode.Dependencies, node)
-			node.IsRoot = false
+			depNode, err := graph.dependency(node, depName)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			depNode.addDependency(node, EdgeKindRunAfter)
+		}
+
+		// Tekton also orders tasks by the results they consume
+		for _, ref := range v1pipeline.PipelineTaskResultRefs(task) {
+			depNode, err := graph.dependency(node, ref.PipelineTask)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			depNode.addDependency(node, EdgeKindResult)
+		}
+	}
+
+	errs = append(errs, graph.findCycles()...)
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return graph, nil
+}
+
+// dependency returns the node the given node depends on
+func (g *TaskGraph) dependency(node *TaskNode, depName string) (*TaskNode, *ValidationError) {
+	if depName == node.Name {
+		return nil, &ValidationError{Err: ErrSelfDependency, Task: node.Name}
+	}
+
+	// finally tasks can't be used as a dependency, they are executed after all the tasks
+	depNode, ok := g.Nodes[depName]
+	if !ok || depNode.IsFinally() {
+		return nil, &ValidationError{Err: ErrUnknownDependency, Task: node.Name, Dependency: depName}
+	}
+
+	return depNode, nil
+}
+
+// findCycles walks the graph in depth and returns an error for every cycle found
+func (g *TaskGraph) findCycles() ValidationErrors {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	var (
+		errs  ValidationErrors
+		path  []string
+		visit func(node *TaskNode)
+	)
+
+	state := make(map[string]int, len(g.Nodes))
+
+	visit = func(node *TaskNode) {
+		state[node.Name] = inProgress
+		path = append(path, node.Name)
+
+		for _, dep := range node.Dependencies {
+			switch state[dep.Name] {
+			case unvisited:
+				visit(dep)
+			case inProgress:
+				// the cycle starts where dep was entered
+				start := len(path) - 1
+				for path[start] != dep.Name {
+					start--
+				}
+
+				cycle := append(append([]string{}, path[start:]...), dep.Name)
+				errs = append(errs, &ValidationError{Err: ErrCycle, Task: dep.Name, Path: cycle})
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[node.Name] = done
+	}
+
+	for _, name := range g.sortedNames() {
+		if state[name] == unvisited {
+			visit(g.Nodes[name])
+		}
+	}
+
+	return errs
+}
+
+// sortedNames returns the names of all nodes in alphabetical order
+func (g *TaskGraph) sortedNames() []string {
+	names := make([]string, 0, len(g.Nodes))
+	for name := range g.Nodes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Title returns the name of the graph, prefixed with the namespace if it is set
+func (g *TaskGraph) Title() string {
+	if g.Namespace == "" {
+		return g.PipelineName
+	}
+
+	return g.Namespace + "/" + g.PipelineName
+}
+
+// FinallyNodes returns the finally tasks of the graph sorted by name
+func (g *TaskGraph) FinallyNodes() []*TaskNode {
+	var nodes []*TaskNode
+
+	for _, node := range g.Nodes {
+		if node.IsFinally() {
+			nodes = append(nodes, node)
 		}
 	}
 
-	return graph
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	return nodes
 }
 
 func (g *TaskGraph) ToDOT(withTaskRef bool) (string, error) {
+	return g.toDOT(withTaskRef, nil)
+}
+
+// toDOT renders the DOT graph styled with the theme, the default styling if theme is nil
+func (g *TaskGraph) toDOT(withTaskRef bool, theme *Theme) (string, error) {
 	var builder strings.Builder
 
-	var tmpl *template.Template
+	text := dotTemplate
 	if withTaskRef {
-		tmpl = template.Must(template.New("dot").Parse(dotTemplateWithTaskRef))
-	} else {
-		tmpl = template.Must(template.New("dot").Parse(dotTemplate))
+		text = dotTemplateWithTaskRef
 	}
 
+	tmpl := template.Must(template.New("dot").Funcs(g.templateFuncs(theme, "dot")).Parse(text))
+
 	if err := tmpl.Execute(&builder, struct {
 		PipelineName string
 		Nodes        map[string]*TaskNode
 		Name         string
+		FinallyNodes []*TaskNode
 	}{
-		PipelineName: g.PipelineName,
+		PipelineName: g.Title(),
 		Nodes:        g.Nodes,
 		Name:         "G",
+		FinallyNodes: g.FinallyNodes(),
 	}); err != nil {
 		return "", fmt.Errorf("failed to execute dot template: %w", err)
 	}
@@ -90,11 +325,14 @@ func (g *TaskGraph) ToDOT(withTaskRef bool) (string, error) {
 }
 
 func (g *TaskGraph) ToPlantUML(withTaskRef bool) (string, error) {
+	return g.toPlantUML(withTaskRef, nil)
+}
+
+// toPlantUML renders the PlantUML state diagram styled with the theme, the default styling if theme is nil
+func (g *TaskGraph) toPlantUML(withTaskRef bool, theme *Theme) (string, error) {
 	var builder strings.Builder
 
-	funcMap := template.FuncMap{
-		"replace": strings.ReplaceAll,
-	}
+	funcMap := g.templateFuncs(theme, "puml")
 
 	var tmpl *template.Template
 
@@ -117,6 +355,11 @@ func (g *TaskGraph) ToPlantUML(withTaskRef bool) (string, error) {
 }
 
 func (g *TaskGraph) ToMermaid(withTaskRef bool) (string, error) {
+	return g.toMermaid(withTaskRef, nil)
+}
+
+// toMermaid renders the mermaid flowchart styled with the theme, the default styling if theme is nil
+func (g *TaskGraph) toMermaid(withTaskRef bool, theme *Theme) (string, error) {
 	var builder strings.Builder
 
 	tmpl := mermaidTemplate
@@ -124,7 +367,7 @@ func (g *TaskGraph) ToMermaid(withTaskRef bool) (string, error) {
 		tmpl = mermaidTemplateWithTaskRef
 	}
 
-	t, err := template.New("mermaid").Parse(tmpl)
+	t, err := template.New("mermaid").Funcs(g.templateFuncs(theme, "mmd")).Parse(tmpl)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse mermaid template: %w", err)
 	}
@@ -136,48 +379,66 @@ func (g *TaskGraph) ToMermaid(withTaskRef bool) (string, error) {
 	return builder.String(), nil
 }
 
-// formatFunc generates the output format string for a TaskGraph based on the specified format
-var formatFunc formatFuncMap = func(graph *TaskGraph, format string, withTaskRef bool) (string, error) {
-	switch strings.ToLower(format) {
-	case "dot":
-		return graph.ToDOT(withTaskRef)
-	case "puml":
-		return graph.ToPlantUML(withTaskRef)
-	case "mmd":
-		return graph.ToMermaid(withTaskRef)
-	default:
-		return "", fmt.Errorf("Invalid output format: %s", format)
-	}
+// templateFuncs are the functions of the built-in templates of the format, see builtinFuncs and styleFuncs
+func (g *TaskGraph) templateFuncs(theme *Theme, format string) template.FuncMap {
+	return g.styleFuncs(theme, format, builtinFuncs(g.sortedNames(), format))
 }
 
-// Function that prints graph to stdout
-func PrintAllGraphs(graphs []*TaskGraph, outputFormat string, withTaskRef bool) error {
-	for _, graph := range graphs {
-		output, err := formatFunc(graph, outputFormat, withTaskRef)
+// Function that prints graph to stdout, the formats with a user template in templates are rendered with it.
+// opts.Colour is ignored, the output is colored when the terminal supports it.
+func PrintAllGraphs(graphs []*TaskGraph, outputFormat string, opts RenderOptions, templates *Templates) error {
+	var separator string
+
+	if r, ok := LookupRenderer(outputFormat); ok {
+		if IsBinary(r) {
+			return fmt.Errorf("the %s output format is binary and can't be printed, use --output-dir", r.Name())
+		}
+
+		separator = DocumentSeparator(r)
+	}
+
+	// colored unless disabled with SetNoColour, --no-color or when the output isn't a terminal
+	opts.Colour = !color.NoColor
+
+	for i, graph := range graphs {
+		output, err := graphOutput(graph, outputFormat, opts, templates)
 		if err != nil {
 			return fmt.Errorf("Failed to generate output: %w", err)
 		}
 
+		// separate the documents, e.g. of YAML, so the output can be read back with ReadGraphs
+		if i > 0 && separator != "" {
+			fmt.Println(separator)
+		}
+
 		fmt.Println(output)
 	}
 
 	return nil
 }
 
-// Function that writes graph to file
-func WriteAllGraphs(graphs []*TaskGraph, outputFormat string, outputDir string, withTaskRef bool) error {
-	err := os.MkdirAll(outputDir, 0755)
-	if err != nil {
-		return fmt.Errorf("Failed to create directory %s: %w", outputDir, err)
+// Function that writes graph to file, the graphs with a namespace are written to a subdirectory named after it.
+// The formats with a user template in templates are rendered with it, the files are written without colors.
+func WriteAllGraphs(graphs []*TaskGraph, outputFormat string, outputDir string, opts RenderOptions, templates *Templates) error {
+	r, ok := LookupRenderer(outputFormat)
+	if !ok {
+		return fmt.Errorf("Failed to generate output: Invalid output format: %s", outputFormat)
 	}
 
+	opts.Colour = false
+
 	for _, graph := range graphs {
-		output, err := formatFunc(graph, outputFormat, withTaskRef)
+		output, err := graphOutput(graph, outputFormat, opts, templates)
 		if err != nil {
 			return fmt.Errorf("Failed to generate output: %w", err)
 		}
 
-		filename := filepath.Join(outputDir, fmt.Sprintf("%s.%s", graph.PipelineName, outputFormat))
+		dir := filepath.Join(outputDir, graph.Namespace)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Failed to create directory %s: %w", dir, err)
+		}
+
+		filename := filepath.Join(dir, fmt.Sprintf("%s.%s", graph.PipelineName, r.Extension()))
 		err = os.WriteFile(filename, []byte(output), 0600)
 
 		if err != nil {
@@ -187,3 +448,17 @@ func WriteAllGraphs(graphs []*TaskGraph, outputFormat string, outputDir string,
 
 	return nil
 }
+
+// graphOutput renders the graph with the user template of the format if there is one, with its renderer otherwise
+func graphOutput(graph *TaskGraph, format string, opts RenderOptions, templates *Templates) (string, error) {
+	tmpl := templates.For(format)
+	if tmpl == nil {
+		return renderString(graph, format, opts)
+	}
+
+	if r, ok := LookupRenderer(format); ok && IsBinary(r) {
+		return "", fmt.Errorf("the %s output format is binary and can't be rendered with a template", r.Name())
+	}
+
+	return graph.ExecuteTemplate(tmpl, format, opts.WithTaskRef)
+}
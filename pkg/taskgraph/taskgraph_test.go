@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 )
 
@@ -45,9 +46,18 @@ func getTestTasks() []v1pipeline.PipelineTask {
 	}
 }
 
+func mustBuildTaskGraph(t *testing.T, tasks []v1pipeline.PipelineTask, finally ...v1pipeline.PipelineTask) *TaskGraph {
+	t.Helper()
+
+	graph, err := BuildTaskGraph(tasks, finally...)
+	require.NoError(t, err)
+
+	return graph
+}
+
 func TestBuildTaskGraph(t *testing.T) {
 	// Build the task graph
-	graph := BuildTaskGraph(getTestTasks())
+	graph := mustBuildTaskGraph(t, getTestTasks())
 
 	// Assert that the graph has the correct number of nodes
 	assert.Equal(t, 4, len(graph.Nodes))
@@ -69,7 +79,7 @@ func TestBuildTaskGraph(t *testing.T) {
 
 func TestTaskGraphToDOT(t *testing.T) {
 	// Build the task graph
-	graph := BuildTaskGraph(getTestTasks())
+	graph := mustBuildTaskGraph(t, getTestTasks())
 	graph.PipelineName = testPipelineName
 
 	// Test the ToDOT method
@@ -87,7 +97,7 @@ func TestTaskGraphToDOT(t *testing.T) {
 
 func TestTaskGraphToDOTWithTaskRef(t *testing.T) {
 	// Build the task graph
-	graph := BuildTaskGraph(getTestTasks())
+	graph := mustBuildTaskGraph(t, getTestTasks())
 	graph.PipelineName = testPipelineName
 
 	// Test the ToDOTWithTaskRef method
@@ -105,7 +115,7 @@ func TestTaskGraphToDOTWithTaskRef(t *testing.T) {
 
 func TestTaskGraphToPlantUML(t *testing.T) {
 	// Build the task graph
-	graph := BuildTaskGraph(getTestTasks())
+	graph := mustBuildTaskGraph(t, getTestTasks())
 	graph.PipelineName = testPipelineName
 
 	// Test the ToPlantUML method
@@ -124,7 +134,7 @@ func TestTaskGraphToPlantUML(t *testing.T) {
 
 func TestTaskGraphToPlantUMLWithTaskRef(t *testing.T) {
 	// Build the task graph
-	graph := BuildTaskGraph(getTestTasks())
+	graph := mustBuildTaskGraph(t, getTestTasks())
 	graph.PipelineName = testPipelineName
 
 	// Test the ToPlantUMLWithTaskRef method
@@ -146,7 +156,7 @@ func TestTaskGraphToPlantUMLWithTaskRef(t *testing.T) {
 
 func TestTaskGraphToMermaid(t *testing.T) {
 	// Build the task graph
-	graph := BuildTaskGraph(getTestTasks())
+	graph := mustBuildTaskGraph(t, getTestTasks())
 	graph.PipelineName = testPipelineName
 
 	// Test the ToMermaid method
@@ -164,7 +174,7 @@ func TestTaskGraphToMermaid(t *testing.T) {
 
 func TestTaskGraphToMermaidWithTaskRef(t *testing.T) {
 	// Build the task graph
-	graph := BuildTaskGraph(getTestTasks())
+	graph := mustBuildTaskGraph(t, getTestTasks())
 	graph.PipelineName = testPipelineName
 
 	// Test the ToMermaidWithTaskRef method
@@ -180,25 +190,33 @@ func TestTaskGraphToMermaidWithTaskRef(t *testing.T) {
 	assert.Contains(t, mermaid, "   start([fa:fa-circle]) --> task-with-dash(\"task-with-dash\n   (taskRef4)\")\n")
 }
 
-func TestFormatFunc(t *testing.T) {
+func TestRenderString(t *testing.T) {
 	// Build the task graph
-	graph := BuildTaskGraph(getTestTasks())
+	graph := mustBuildTaskGraph(t, getTestTasks())
 	graph.PipelineName = testPipelineName
 
-	// Test the FormatFunc method
-	dot, err := formatFunc(graph, "dot", false)
+	// Test the renderString method
+	dot, err := renderString(graph, "dot", RenderOptions{})
 	assert.NoError(t, err)
 	assert.NotEmpty(t, dot)
 
-	puml, err := formatFunc(graph, "puml", false)
+	puml, err := renderString(graph, "puml", RenderOptions{})
 	assert.NoError(t, err)
 	assert.NotEmpty(t, puml)
 
-	mmd, err := formatFunc(graph, "mmd", false)
+	mmd, err := renderString(graph, "mmd", RenderOptions{})
 	assert.NoError(t, err)
 	assert.NotEmpty(t, mmd)
 
-	invalid, err := formatFunc(graph, "invalid", false)
+	json, err := renderString(graph, "json", RenderOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, json)
+
+	yaml, err := renderString(graph, "yaml", RenderOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, yaml)
+
+	invalid, err := renderString(graph, "invalid", RenderOptions{})
 	assert.Error(t, err)
 	assert.Empty(t, invalid)
 	assert.Equal(t, "Invalid output format: invalid", err.Error())
@@ -236,7 +254,7 @@ func TestPrintAllGraphs(t *testing.T) {
 	testWithTaskRef := true
 
 	// Test the PrintAllGraphs method
-	err := PrintAllGraphs([]*TaskGraph{testGraph}, testOutputFormat, testWithTaskRef)
+	err := PrintAllGraphs([]*TaskGraph{testGraph}, testOutputFormat, RenderOptions{WithTaskRef: testWithTaskRef}, nil)
 	assert.NoError(t, err)
 }
 
@@ -249,12 +267,17 @@ func TestPrintAllGraphsWithUnsupportedFormat(t *testing.T) {
 	testWithTaskRef := true
 
 	// Test the PrintAllGraphs method
-	err := PrintAllGraphs([]*TaskGraph{testGraph}, testOutputFormat, testWithTaskRef)
+	err := PrintAllGraphs([]*TaskGraph{testGraph}, testOutputFormat, RenderOptions{WithTaskRef: testWithTaskRef}, nil)
 	assert.Error(t, err)
 	// contains error message
 	assert.Contains(t, err.Error(), "Invalid output format: FAIL")
 }
 
+func TestPrintAllGraphsWithBinaryFormat(t *testing.T) {
+	err := PrintAllGraphs([]*TaskGraph{mustBuildTaskGraph(t, getTestTasks())}, "png", RenderOptions{}, nil)
+	assert.EqualError(t, err, "the png output format is binary and can't be printed, use --output-dir")
+}
+
 func TestWriteAllGraphs(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "test-output")
@@ -311,11 +334,17 @@ func TestWriteAllGraphs(t *testing.T) {
 	}
 
 	// Write the test graph to all supported formats
-	err = WriteAllGraphs([]*TaskGraph{testGraph}, "dot", tempDir, true)
+	err = WriteAllGraphs([]*TaskGraph{testGraph}, "dot", tempDir, RenderOptions{WithTaskRef: true}, nil)
+	assert.NoError(t, err)
+	err = WriteAllGraphs([]*TaskGraph{testGraph}, "puml", tempDir, RenderOptions{WithTaskRef: true}, nil)
+	assert.NoError(t, err)
+	err = WriteAllGraphs([]*TaskGraph{testGraph}, "mmd", tempDir, RenderOptions{WithTaskRef: true}, nil)
 	assert.NoError(t, err)
-	err = WriteAllGraphs([]*TaskGraph{testGraph}, "puml", tempDir, true)
+	err = WriteAllGraphs([]*TaskGraph{testGraph}, "svg", tempDir, RenderOptions{WithTaskRef: true}, nil)
 	assert.NoError(t, err)
-	err = WriteAllGraphs([]*TaskGraph{testGraph}, "mmd", tempDir, true)
+	err = WriteAllGraphs([]*TaskGraph{testGraph}, "png", tempDir, RenderOptions{WithTaskRef: true}, nil)
+	assert.NoError(t, err)
+	err = WriteAllGraphs([]*TaskGraph{testGraph}, "html", tempDir, RenderOptions{WithTaskRef: true}, nil)
 	assert.NoError(t, err)
 
 	// Check that the files were created
@@ -325,4 +354,276 @@ func TestWriteAllGraphs(t *testing.T) {
 	assert.NoError(t, err)
 	_, err = os.Stat(filepath.Join(tempDir, "test-pipeline.mmd"))
 	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(tempDir, "test-pipeline.svg"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(tempDir, "test-pipeline.png"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(tempDir, "test-pipeline.html"))
+	assert.NoError(t, err)
+}
+
+func getTestFinallyTasks() []v1pipeline.PipelineTask {
+	return []v1pipeline.PipelineTask{
+		{
+			Name: "notify",
+			TaskRef: &v1pipeline.TaskRef{
+				Name: "taskRef5",
+			},
+		},
+		{
+			Name: "cleanup-ws",
+			TaskRef: &v1pipeline.TaskRef{
+				Name: "taskRef6",
+			},
+		},
+	}
+}
+
+func TestBuildTaskGraphWithFinally(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks(), getTestFinallyTasks()...)
+
+	assert.Equal(t, 6, len(graph.Nodes))
+	assert.Equal(t, NodeKindTask, graph.Nodes["task1"].Kind)
+	assert.Equal(t, NodeKindFinally, graph.Nodes["notify"].Kind)
+	assert.False(t, graph.Nodes["notify"].IsRoot)
+	assert.Empty(t, graph.Nodes["notify"].Dependencies)
+	assert.Equal(t, []*TaskNode{graph.Nodes["cleanup-ws"], graph.Nodes["notify"]}, graph.FinallyNodes())
+}
+
+func TestTaskGraphWithFinally(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks(), getTestFinallyTasks()...)
+	graph.PipelineName = testPipelineName
+
+	dot, err := graph.ToDOT(false)
+	assert.NoError(t, err)
+	assert.Contains(t, dot, "   compound=true\n")
+	assert.Contains(t, dot, "  \"task1\" -> \"cleanup-ws\" [lhead=\"cluster_finally\"]")
+	assert.Contains(t, dot, "  \"task-with-dash\" -> \"cleanup-ws\" [lhead=\"cluster_finally\"]")
+	assert.Contains(t, dot, "   subgraph cluster_finally {\n      label=\"finally\"\n      style=\"dashed\"\n      \"cleanup-ws\"\n      \"notify\"\n   }")
+	assert.Contains(t, dot, "  \"cleanup-ws\" -> \"end\" [ltail=\"cluster_finally\"]")
+	assert.NotContains(t, dot, "\"task1\" -> \"end\"")
+	assert.NotContains(t, dot, "\"start\" -> \"notify\"")
+
+	dot, err = graph.ToDOT(true)
+	assert.NoError(t, err)
+	assert.Contains(t, dot, "  \"task1\n(taskRef1)\" -> \"cleanup-ws\n(taskRef6)\" [lhead=\"cluster_finally\"]")
+	assert.Contains(t, dot, "      \"notify\n(taskRef5)\"\n")
+
+	plantuml, err := graph.ToPlantUML(false)
+	assert.NoError(t, err)
+	assert.Contains(t, plantuml, "task1 --> finally_tasks\n")
+	assert.Contains(t, plantuml, "   state \"finally\" as finally_tasks {\n      state cleanup_ws\n      state notify\n   }\n")
+	assert.Contains(t, plantuml, "   finally_tasks --> [*]\n@enduml\n")
+	assert.NotContains(t, plantuml, "task1 --> [*]")
+
+	plantuml, err = graph.ToPlantUML(true)
+	assert.NoError(t, err)
+	assert.Contains(t, plantuml, "      cleanup_ws: taskRef6\n      notify: taskRef5\n")
+
+	mermaid, err := graph.ToMermaid(false)
+	assert.NoError(t, err)
+	assert.Contains(t, mermaid, "   task1 --> finally_tasks\n")
+	assert.Contains(t, mermaid, "   subgraph finally_tasks [finally]\n      cleanup-ws\n      notify\n   end\n")
+	assert.Contains(t, mermaid, "   finally_tasks --> stop([fa:fa-circle])\n")
+	assert.NotContains(t, mermaid, "task1 --> stop")
+
+	mermaid, err = graph.ToMermaid(true)
+	assert.NoError(t, err)
+	assert.Contains(t, mermaid, "   task1(\"task1\n   (taskRef1)\") --> finally_tasks\n")
+	assert.Contains(t, mermaid, "      notify(\"notify\n      (taskRef5)\")\n")
+}
+
+func getTestResultRefTasks() []v1pipeline.PipelineTask {
+	return []v1pipeline.PipelineTask{
+		{
+			Name:    "build",
+			TaskRef: &v1pipeline.TaskRef{Name: "buildah"},
+		},
+		{
+			Name:    "scan",
+			TaskRef: &v1pipeline.TaskRef{Name: "trivy"},
+			Params: v1pipeline.Params{
+				{Name: "image", Value: *v1pipeline.NewStructuredValues("$(tasks.build.results.image)")},
+			},
+		},
+		{
+			Name:     "deploy",
+			TaskRef:  &v1pipeline.TaskRef{Name: "helm"},
+			RunAfter: []string{"build"},
+			Params: v1pipeline.Params{
+				{Name: "image", Value: *v1pipeline.NewStructuredValues("$(tasks.build.results.image)")},
+			},
+			When: v1pipeline.WhenExpressions{
+				{Input: "$(tasks.scan.results.status)", Operator: "in", Values: []string{"ok"}},
+			},
+		},
+		{
+			Name:    "test",
+			TaskRef: &v1pipeline.TaskRef{Name: "tests"},
+			Matrix: &v1pipeline.Matrix{
+				Params: v1pipeline.Params{
+					{Name: "env", Value: *v1pipeline.NewStructuredValues("$(tasks.deploy.results.envs[*])")},
+				},
+			},
+		},
+	}
+}
+
+func TestBuildTaskGraphWithResultRefs(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestResultRefTasks())
+
+	assert.Equal(t, []*TaskNode{graph.Nodes["scan"], graph.Nodes["deploy"]}, graph.Nodes["build"].Dependencies)
+	assert.Equal(t, []*TaskNode{graph.Nodes["deploy"]}, graph.Nodes["scan"].Dependencies)
+	assert.Equal(t, []*TaskNode{graph.Nodes["test"]}, graph.Nodes["deploy"].Dependencies)
+	assert.True(t, graph.Nodes["build"].IsRoot)
+	assert.False(t, graph.Nodes["scan"].IsRoot)
+	assert.False(t, graph.Nodes["test"].IsRoot)
+
+	// runAfter takes precedence over the result reference to the same task
+	assert.Equal(t, EdgeKindRunAfter, graph.Nodes["build"].EdgeKind(graph.Nodes["deploy"]))
+	assert.Equal(t, EdgeKindResult, graph.Nodes["build"].EdgeKind(graph.Nodes["scan"]))
+	assert.Equal(t, EdgeKindResult, graph.Nodes["scan"].EdgeKind(graph.Nodes["deploy"]))
+	assert.Equal(t, EdgeKindResult, graph.Nodes["deploy"].EdgeKind(graph.Nodes["test"]))
+}
+
+func TestTaskGraphWithResultRefs(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestResultRefTasks())
+	graph.PipelineName = testPipelineName
+
+	dot, err := graph.ToDOT(false)
+	assert.NoError(t, err)
+	assert.Contains(t, dot, "  \"build\" -> \"scan\" [style=\"dashed\"]\n")
+	assert.Contains(t, dot, "  \"build\" -> \"deploy\"\n")
+
+	dot, err = graph.ToDOT(true)
+	assert.NoError(t, err)
+	assert.Contains(t, dot, "  \"build\n(buildah)\" -> \"scan\n(trivy)\" [style=\"dashed\"]\n")
+
+	plantuml, err := graph.ToPlantUML(false)
+	assert.NoError(t, err)
+	assert.Contains(t, plantuml, "build -down[dashed]-> scan\n")
+	assert.Contains(t, plantuml, "build -down-> deploy\n")
+
+	mermaid, err := graph.ToMermaid(false)
+	assert.NoError(t, err)
+	assert.Contains(t, mermaid, "   build -.-> scan\n")
+	assert.Contains(t, mermaid, "   build --> deploy\n")
+
+	mermaid, err = graph.ToMermaid(true)
+	assert.NoError(t, err)
+	assert.Contains(t, mermaid, "   scan(\"scan\n   (trivy)\") -.-> deploy(\"deploy\n   (helm)\")\n")
+}
+
+func TestBuildTaskGraphValidation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tasks    []v1pipeline.PipelineTask
+		finally  []v1pipeline.PipelineTask
+		expected ValidationErrors
+	}{
+		{
+			name: "unknown runAfter",
+			tasks: []v1pipeline.PipelineTask{
+				{Name: "task1", RunAfter: []string{"typo"}},
+			},
+			expected: ValidationErrors{
+				{Err: ErrUnknownDependency, Task: "task1", Dependency: "typo"},
+			},
+		},
+		{
+			name: "unknown result reference and finally dependency",
+			tasks: []v1pipeline.PipelineTask{
+				{Name: "task1", RunAfter: []string{"notify"}, Params: v1pipeline.Params{
+					{Name: "p", Value: *v1pipeline.NewStructuredValues("$(tasks.missing.results.r)")},
+				}},
+			},
+			finally: []v1pipeline.PipelineTask{{Name: "notify"}},
+			expected: ValidationErrors{
+				{Err: ErrUnknownDependency, Task: "task1", Dependency: "notify"},
+				{Err: ErrUnknownDependency, Task: "task1", Dependency: "missing"},
+			},
+		},
+		{
+			name: "self dependency",
+			tasks: []v1pipeline.PipelineTask{
+				{Name: "task1", RunAfter: []string{"task1"}},
+			},
+			expected: ValidationErrors{
+				{Err: ErrSelfDependency, Task: "task1"},
+			},
+		},
+		{
+			name: "duplicate names",
+			tasks: []v1pipeline.PipelineTask{
+				{Name: "task1"},
+				{Name: "task1", RunAfter: []string{"unknown"}},
+			},
+			finally: []v1pipeline.PipelineTask{{Name: "task1"}},
+			expected: ValidationErrors{
+				{Err: ErrDuplicateTask, Task: "task1"},
+				{Err: ErrDuplicateTask, Task: "task1"},
+			},
+		},
+		{
+			name: "cycle",
+			tasks: []v1pipeline.PipelineTask{
+				{Name: "a", RunAfter: []string{"c"}},
+				{Name: "b", RunAfter: []string{"a"}},
+				{Name: "c", RunAfter: []string{"b"}},
+				{Name: "d", RunAfter: []string{"c"}},
+			},
+			expected: ValidationErrors{
+				{Err: ErrCycle, Task: "a", Path: []string{"a", "b", "c", "a"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			graph, err := BuildTaskGraph(tc.tasks, tc.finally...)
+
+			assert.Nil(t, graph)
+			require.Error(t, err)
+
+			var errs ValidationErrors
+			require.ErrorAs(t, err, &errs)
+			assert.Equal(t, tc.expected, errs)
+		})
+	}
+}
+
+func TestValidationErrorMessages(t *testing.T) {
+	err := ValidationErrors{
+		{Err: ErrUnknownDependency, Task: "task1", Dependency: "typo"},
+		{Err: ErrSelfDependency, Task: "task2"},
+		{Err: ErrDuplicateTask, Task: "task3"},
+		{Err: ErrCycle, Task: "a", Path: []string{"a", "b", "a"}},
+	}
+
+	assert.Equal(t, `unknown dependency: task "task1" depends on unknown task "typo"; `+
+		`self dependency: "task2"; duplicate task name: "task3"; dependency cycle: a -> b -> a`, err.Error())
+	assert.ErrorIs(t, err, ErrCycle)
+	assert.ErrorIs(t, err, ErrUnknownDependency)
+	assert.NotErrorIs(t, err[1], ErrCycle)
+}
+
+func TestWriteAllGraphsWithNamespaces(t *testing.T) {
+	dir := t.TempDir()
+
+	graphs := []*TaskGraph{
+		{PipelineName: "build", Namespace: "team-a", Nodes: map[string]*TaskNode{}},
+		{PipelineName: "build", Namespace: "team-b", Nodes: map[string]*TaskNode{}},
+		{PipelineName: "build", Nodes: map[string]*TaskNode{}},
+	}
+
+	require.NoError(t, WriteAllGraphs(graphs, "mmd", dir, RenderOptions{}, nil))
+
+	for _, filename := range []string{"team-a/build.mmd", "team-b/build.mmd", "build.mmd"} {
+		assert.FileExists(t, filepath.Join(dir, filename))
+	}
+
+	mermaid, err := os.ReadFile(filepath.Join(dir, "team-b", "build.mmd"))
+	require.NoError(t, err)
+	assert.Contains(t, string(mermaid), "title: team-b/build\n")
+	assert.Equal(t, "build", graphs[2].Title())
 }
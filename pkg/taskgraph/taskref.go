@@ -0,0 +1,162 @@
+package taskgraph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+const (
+	kindTask        = "Task"
+	kindClusterTask = "ClusterTask"
+	kindPipeline    = "Pipeline"
+
+	inlineLabel = "inline"
+)
+
+// resolverNameParams are the resolver params which identify the resolved resource, in order of preference
+var resolverNameParams = []string{"name", "pathInRepo", "url", "bundle"}
+
+// TaskRef describes what is executed by a PipelineTask
+type TaskRef struct {
+	Kind           string            `json:"kind,omitempty"`           // Task, ClusterTask, Pipeline or the kind of a custom task
+	Name           string            `json:"name,omitempty"`           // Name of the referenced resource, empty for inline and resolver references
+	APIVersion     string            `json:"apiVersion,omitempty"`     // API version of a custom task
+	Resolver       string            `json:"resolver,omitempty"`       // Remote resolver, e.g. bundles, git, hub or cluster
+	ResolverParams map[string]string `json:"resolverParams,omitempty"` // Params passed to the resolver
+	Inline         bool              `json:"inline,omitempty"`         // The task is embedded in the Pipeline with taskSpec or pipelineSpec
+}
+
+// newTaskRef creates the TaskRef for the PipelineTask from its taskRef, taskSpec, pipelineRef or pipelineSpec
+func newTaskRef(task *v1pipeline.PipelineTask) TaskRef {
+	switch {
+	case task.TaskRef != nil:
+		ref := TaskRef{
+			Kind:       string(task.TaskRef.Kind),
+			Name:       task.TaskRef.Name,
+			APIVersion: task.TaskRef.APIVersion,
+		}
+		if ref.Kind == "" {
+			ref.Kind = kindTask
+		}
+
+		ref.setResolver(task.TaskRef.ResolverRef)
+
+		return ref
+	case task.TaskSpec != nil:
+		ref := TaskRef{
+			Kind:       task.TaskSpec.Kind,
+			APIVersion: task.TaskSpec.APIVersion,
+			Inline:     true,
+		}
+		if ref.Kind == "" {
+			ref.Kind = kindTask
+		}
+
+		return ref
+	case task.PipelineRef != nil:
+		ref := TaskRef{
+			Kind:       kindPipeline,
+			Name:       task.PipelineRef.Name,
+			APIVersion: task.PipelineRef.APIVersion,
+		}
+		ref.setResolver(task.PipelineRef.ResolverRef)
+
+		return ref
+	case task.PipelineSpec != nil:
+		return TaskRef{Kind: kindPipeline, Inline: true}
+	default:
+		return TaskRef{}
+	}
+}
+
+func (r *TaskRef) setResolver(resolver v1pipeline.ResolverRef) {
+	if resolver.Resolver == "" {
+		return
+	}
+
+	r.Resolver = string(resolver.Resolver)
+	r.ResolverParams = make(map[string]string, len(resolver.Params))
+
+	for _, p := range resolver.Params {
+		r.ResolverParams[p.Name] = paramValue(p.Value)
+	}
+}
+
+// paramValue returns the value of a param as a string, arrays are joined with "," and objects as key=value pairs
+func paramValue(value v1pipeline.ParamValue) string {
+	switch value.Type {
+	case v1pipeline.ParamTypeArray:
+		return strings.Join(value.ArrayVal, ",")
+	case v1pipeline.ParamTypeObject:
+		keys := make([]string, 0, len(value.ObjectVal))
+		for k := range value.ObjectVal {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, k+"="+value.ObjectVal[k])
+		}
+
+		return strings.Join(pairs, ",")
+	default:
+		return value.StringVal
+	}
+}
+
+// IsCustomTask returns true if the reference points to a custom task
+func (r TaskRef) IsCustomTask() bool {
+	return r.APIVersion != "" && r.Kind != kindTask && r.Kind != kindClusterTask && r.Kind != kindPipeline
+}
+
+// String returns the human readable reference used in the graphs, for example:
+//
+//	build-task                  Task
+//	ClusterTask/git-clone       ClusterTask
+//	Wait/wait (custom.dev/v1)   custom task
+//	git: task/build.yaml        resolver
+//	inline                      taskSpec
+func (r TaskRef) String() string {
+	var label string
+
+	switch {
+	case r.Inline:
+		label = inlineLabel
+		if r.Kind != kindTask {
+			label += " " + r.Kind
+		}
+	case r.Resolver != "":
+		label = r.Resolver
+		if name := r.resolvedName(); name != "" {
+			label += ": " + name
+		}
+	case r.Kind == kindTask:
+		label = r.Name
+	case r.Name == "":
+		label = r.Kind
+	default:
+		label = r.Kind + "/" + r.Name
+	}
+
+	if r.IsCustomTask() {
+		label = fmt.Sprintf("%s (%s)", label, r.APIVersion)
+	}
+
+	return label
+}
+
+// resolvedName returns the resolver param which identifies the resolved resource
+func (r TaskRef) resolvedName() string {
+	for _, name := range resolverNameParams {
+		if v := r.ResolverParams[name]; v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,145 @@
+package taskgraph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNewTaskRef(t *testing.T) {
+	testCases := []struct {
+		name     string
+		task     v1pipeline.PipelineTask
+		expected TaskRef
+		label    string
+	}{
+		{
+			name:     "task",
+			task:     v1pipeline.PipelineTask{TaskRef: &v1pipeline.TaskRef{Name: "build"}},
+			expected: TaskRef{Kind: "Task", Name: "build"},
+			label:    "build",
+		},
+		{
+			name:     "cluster task",
+			task:     v1pipeline.PipelineTask{TaskRef: &v1pipeline.TaskRef{Name: "git-clone", Kind: v1pipeline.ClusterTaskRefKind}},
+			expected: TaskRef{Kind: "ClusterTask", Name: "git-clone"},
+			label:    "ClusterTask/git-clone",
+		},
+		{
+			name: "custom task",
+			task: v1pipeline.PipelineTask{
+				TaskRef: &v1pipeline.TaskRef{Name: "wait", Kind: "Wait", APIVersion: "custom.dev/v1"},
+			},
+			expected: TaskRef{Kind: "Wait", Name: "wait", APIVersion: "custom.dev/v1"},
+			label:    "Wait/wait (custom.dev/v1)",
+		},
+		{
+			name: "git resolver",
+			task: v1pipeline.PipelineTask{
+				TaskRef: &v1pipeline.TaskRef{ResolverRef: v1pipeline.ResolverRef{
+					Resolver: "git",
+					Params: v1pipeline.Params{
+						{Name: "url", Value: *v1pipeline.NewStructuredValues("https://github.com/org/repo")},
+						{Name: "pathInRepo", Value: *v1pipeline.NewStructuredValues("task/build.yaml")},
+					},
+				}},
+			},
+			expected: TaskRef{
+				Kind:     "Task",
+				Resolver: "git",
+				ResolverParams: map[string]string{
+					"url":        "https://github.com/org/repo",
+					"pathInRepo": "task/build.yaml",
+				},
+			},
+			label: "git: task/build.yaml",
+		},
+		{
+			name: "bundles resolver",
+			task: v1pipeline.PipelineTask{
+				TaskRef: &v1pipeline.TaskRef{ResolverRef: v1pipeline.ResolverRef{
+					Resolver: "bundles",
+					Params: v1pipeline.Params{
+						{Name: "bundle", Value: *v1pipeline.NewStructuredValues("registry/bundle:1.0")},
+						{Name: "name", Value: *v1pipeline.NewStructuredValues("build")},
+						{Name: "tags", Value: *v1pipeline.NewStructuredValues("a", "b")},
+					},
+				}},
+			},
+			expected: TaskRef{
+				Kind:     "Task",
+				Resolver: "bundles",
+				ResolverParams: map[string]string{
+					"bundle": "registry/bundle:1.0",
+					"name":   "build",
+					"tags":   "a,b",
+				},
+			},
+			label: "bundles: build",
+		},
+		{
+			name:     "inline task",
+			task:     v1pipeline.PipelineTask{TaskSpec: &v1pipeline.EmbeddedTask{}},
+			expected: TaskRef{Kind: "Task", Inline: true},
+			label:    "inline",
+		},
+		{
+			name: "inline custom task",
+			task: v1pipeline.PipelineTask{TaskSpec: &v1pipeline.EmbeddedTask{
+				TypeMeta: runtime.TypeMeta{Kind: "Wait", APIVersion: "custom.dev/v1"},
+			}},
+			expected: TaskRef{Kind: "Wait", APIVersion: "custom.dev/v1", Inline: true},
+			label:    "inline Wait (custom.dev/v1)",
+		},
+		{
+			name:     "pipeline in pipeline",
+			task:     v1pipeline.PipelineTask{PipelineRef: &v1pipeline.PipelineRef{Name: "child"}},
+			expected: TaskRef{Kind: "Pipeline", Name: "child"},
+			label:    "Pipeline/child",
+		},
+		{
+			name:     "no reference",
+			task:     v1pipeline.PipelineTask{},
+			expected: TaskRef{},
+			label:    "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ref := newTaskRef(&tc.task)
+
+			assert.Equal(t, tc.expected, ref)
+			assert.Equal(t, tc.label, ref.String())
+		})
+	}
+}
+
+func TestBuildTaskGraphWithoutTaskRef(t *testing.T) {
+	graph := mustBuildTaskGraph(t, []v1pipeline.PipelineTask{
+		{Name: "inline", TaskSpec: &v1pipeline.EmbeddedTask{}},
+		{Name: "remote", RunAfter: []string{"inline"}, TaskRef: &v1pipeline.TaskRef{
+			ResolverRef: v1pipeline.ResolverRef{Resolver: "hub", Params: v1pipeline.Params{
+				{Name: "name", Value: *v1pipeline.NewStructuredValues("golang-build")},
+			}},
+		}},
+	})
+	graph.PipelineName = testPipelineName
+
+	assert.Equal(t, "inline", graph.Nodes["inline"].TaskRefName)
+	assert.Equal(t, "hub: golang-build", graph.Nodes["remote"].TaskRefName)
+
+	mermaid, err := graph.ToMermaid(true)
+	assert.NoError(t, err)
+	assert.Contains(t, mermaid, "   inline(\"inline\n   (inline)\") --> remote(\"remote\n   (hub: golang-build)\")\n")
+
+	plantuml, err := graph.ToPlantUML(true)
+	assert.NoError(t, err)
+	assert.Contains(t, plantuml, "   remote: hub: golang-build\n")
+
+	dot, err := graph.ToDOT(true)
+	assert.NoError(t, err)
+	assert.Contains(t, dot, "  \"inline\n(inline)\" -> \"remote\n(hub: golang-build)\"\n")
+}
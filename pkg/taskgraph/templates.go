@@ -3,23 +3,57 @@ package taskgraph
 // mermaidTemplate is the template used to generate the mermaid graph
 // The template is based on the mermaid flowchart syntax: https://mermaid-js.github.io/mermaid/#/flowchart
 // The template uses the following variables:
-//   - PipelineName: Name of the pipeline
+//   - Title: Name of the pipeline, prefixed with its namespace when graphing several namespaces
 //   - Nodes: Map of nodes in the graph
+//   - FinallyNodes: finally tasks, they are grouped in a subgraph which is connected to every leaf task
+//
+// Dependencies inferred from result references are drawn with dotted (-.->) edges.
+// When the graph is built from a PipelineRun, the nodes are colored by the TaskRun status
+// and annotated with the start time and duration. The highlighted critical path is drawn with thick (==>) edges.
+// The styling functions (open, close, arrow, link, ...) apply the theme, see styleFuncs.
 const mermaidTemplate = `---
-title: {{ .PipelineName }}
+title: {{ yamlScalar .Title }}{{ mermaidConfig }}
 ---
-flowchart TD
+flowchart {{ direction }}
 {{- range $name, $node := .Nodes }}
+{{- if not $node.IsFinally }}
 {{- if eq (len $node.Dependencies) 0 }}
-   {{ $name }} --> stop([fa:fa-circle])
+{{- if $.FinallyNodes }}
+   {{ id $name }} {{ link }} {{ extraID "finally_tasks" }}
+{{- else }}
+   {{ id $name }} {{ link }} {{ extraID "stop" }}([fa:fa-circle])
+{{- end }}
 {{- end }}
 {{- if $node.IsRoot }}
-   start([fa:fa-circle]) --> {{ $name }}
+   {{ extraID "start" }}([fa:fa-circle]) {{ link }} {{ id $name }}
 {{- end }}
 {{- range $dep := $node.Dependencies }}
-   {{ $name }} --> {{ $dep.Name }}
+   {{ id $name }} {{ arrow $node $dep }} {{ id $dep.Name }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- with .FinallyNodes }}
+   subgraph {{ extraID "finally_tasks" }} [finally]
+{{- range $node := . }}
+      {{ id $node.Name }}
+{{- end }}
+   end
+   {{ extraID "finally_tasks" }} {{ link }} {{ extraID "stop" }}([fa:fa-circle])
+{{- end }}
+{{- range $name, $node := .Nodes }}
+{{- with $node.Status }}
+   {{ id $name }}{{ open $node }}"{{ label $node.Name }}
+   {{ .Summary }}"{{ close $node }}
+   style {{ id $name }} fill:{{ stateColor . }}
+{{- else }}
+{{- if or (renamed $name) themed }}
+   {{ id $name }}{{ open $node }}"{{ label $node.Name }}"{{ close $node }}
 {{- end }}
 {{- end }}
+{{- if $node.Critical }}
+   style {{ id $name }} stroke:{{ criticalColor }},stroke-width:3px
+{{- end }}
+{{- end }}{{ mermaidStyles }}
 `
 
 // mermaidTemplateWithTaskRef is the template used to generate the mermaid graph with taskRefName
@@ -31,109 +65,578 @@ flowchart TD
 //	|(taskRefName) |
 //	---------------
 const mermaidTemplateWithTaskRef = `---
-title: {{ .PipelineName }}
+title: {{ yamlScalar .Title }}{{ mermaidConfig }}
 ---
-flowchart TD
+flowchart {{ direction }}
 {{- range $name, $node := .Nodes }}
+{{- if not $node.IsFinally }}
 {{- if eq (len $node.Dependencies) 0 }}
-   {{ $name }}("{{ $node.Name }}
-   ({{ $node.TaskRefName }})") --> stop([fa:fa-circle])
+{{- if $.FinallyNodes }}
+   {{ id $name }}{{ open $node }}"{{ label $node.Name }}
+   ({{ label $node.TaskRefName }})"{{ close $node }} {{ link }} {{ extraID "finally_tasks" }}
+{{- else }}
+   {{ id $name }}{{ open $node }}"{{ label $node.Name }}
+   ({{ label $node.TaskRefName }})"{{ close $node }} {{ link }} {{ extraID "stop" }}([fa:fa-circle])
+{{- end }}
 {{- end }}
 {{- if $node.IsRoot }}
-   start([fa:fa-circle]) --> {{ $name }}("{{ $node.Name }}
-   ({{ $node.TaskRefName }})")
+   {{ extraID "start" }}([fa:fa-circle]) {{ link }} {{ id $name }}{{ open $node }}"{{ label $node.Name }}
+   ({{ label $node.TaskRefName }})"{{ close $node }}
 {{- end }}
 {{- range $dep := $node.Dependencies }}
-   {{ $name }}("{{ $node.Name }}
-   ({{ $node.TaskRefName }})") --> {{ $dep.Name }}("{{ $dep.Name }}
-   ({{ $dep.TaskRefName }})")
+   {{ id $name }}{{ open $node }}"{{ label $node.Name }}
+   ({{ label $node.TaskRefName }})"{{ close $node }} {{ arrow $node $dep }} {{ id $dep.Name }}{{ open $dep }}"{{ label $dep.Name }}
+   ({{ label $dep.TaskRefName }})"{{ close $dep }}
+{{- end }}
+{{- end }}
 {{- end }}
+{{- with .FinallyNodes }}
+   subgraph {{ extraID "finally_tasks" }} [finally]
+{{- range $node := . }}
+      {{ id $node.Name }}{{ open $node }}"{{ label $node.Name }}
+      ({{ label $node.TaskRefName }})"{{ close $node }}
 {{- end }}
+   end
+   {{ extraID "finally_tasks" }} {{ link }} {{ extraID "stop" }}([fa:fa-circle])
+{{- end }}
+{{- range $name, $node := .Nodes }}
+{{- with $node.Status }}
+   {{ id $name }}{{ open $node }}"{{ label $node.Name }}
+   ({{ label $node.TaskRefName }})
+   {{ .Summary }}"{{ close $node }}
+   style {{ id $name }} fill:{{ stateColor . }}
+{{- end }}
+{{- if $node.Critical }}
+   style {{ id $name }} stroke:{{ criticalColor }},stroke-width:3px
+{{- end }}
+{{- end }}{{ mermaidStyles }}
 `
 
-// dotTemplate is the template used to generate the DOT graph
-// The template is based on the DOT language: https://graphviz.org/doc/info/lang.html
-// We replace "-" with "_" in the node names to avoid issues with the DOT language
+// plantumlTemplate is the template used to generate the PlantUML state diagram
+// The template is based on the PlantUML state diagram syntax: https://plantuml.com/state-diagram
+// The states are named after the tasks with "_" instead of "-", the tasks whose name isn't a valid
+// state name are declared with a synthetic name and labeled with their name.
 const plantumlTemplate = `@startuml
-hide empty description
-title {{ .PipelineName }}
+hide empty description{{ pumlHeader }}
+title {{ label .Title }}
+{{- range $name, $node := .Nodes }}
+{{- if renamed $name }}
+state "{{ label $name }}" as {{ id $name }}
+{{- end }}
+{{- end }}
 {{ range $name, $node := .Nodes }}
-{{- $trName := replace $name "-" "_" }}
+{{- if not $node.IsFinally }}
+{{- $trName := id $name }}
 {{- if eq (len $node.Dependencies) 0 }}
+{{- if $.FinallyNodes }}
+   {{ $trName }} --> {{ extraID "finally_tasks" }}
+{{- else }}
    {{ $trName }} --> [*]
 {{- end }}
+{{- end }}
 {{- if $node.IsRoot }}
    [*] --> {{ $trName }}
 {{- end }}
 {{- range $dep := $node.Dependencies }}
-   {{- $trDepName := replace $dep.Name "-" "_" }}
-   {{ $trName }} -down-> {{ $trDepName }}
+   {{- $trDepName := id $dep.Name }}
+   {{ $trName }} {{ pumlArrow $node $dep }} {{ $trDepName }}
+{{- end }}
 {{- end }}
 {{ end }}
+{{- with .FinallyNodes }}
+   state "finally" as {{ extraID "finally_tasks" }} {
+{{- range $node := . }}
+      state {{ id $node.Name }}{{ pumlStereotype $node }}{{ with $node.Status }} {{ stateColor . }}{{ if $node.Critical }};line:{{ pumlCritical }};line.bold{{ end }}{{ else }}{{ if $node.Critical }} ##[bold]{{ pumlCritical }}{{ end }}{{ end }}
+{{- with $node.Status }}
+      {{ id $node.Name }}: {{ .Summary }}
+{{- end }}
+{{- end }}
+   }
+   {{ extraID "finally_tasks" }} --> [*]
+{{- end }}
+{{- range $name, $node := .Nodes }}
+{{- if not $node.IsFinally }}
+{{- with $node.Status }}
+   state {{ id $name }} {{ stateColor . }}{{ if $node.Critical }};line:{{ pumlCritical }};line.bold{{ end }}
+   {{ id $name }}: {{ .Summary }}
+{{- else }}
+{{- if $node.Critical }}
+   state {{ id $name }} ##[bold]{{ pumlCritical }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}{{ pumlLegend }}
 @enduml
 `
 
-// dotTemplate is the template used to generate the DOT graph
-// The template is based on the DOT language: https://graphviz.org/doc/info/lang.html
-// We replace "-" with "_" in the node names to avoid issues with the DOT language
+// plantumlTemplateWithTaskRef is the template used to generate the PlantUML state diagram with taskRefName
+// The taskRefName is added to the description of the states.
 const plantumlTemplateWithTaskRef = `@startuml
-hide empty description
-title {{ .PipelineName }}
+hide empty description{{ pumlHeader }}
+title {{ label .Title }}
+{{- range $name, $node := .Nodes }}
+{{- if renamed $name }}
+state "{{ label $name }}" as {{ id $name }}
+{{- end }}
+{{- end }}
 {{ range $name, $node := .Nodes }}
-{{- $trName := replace $name "-" "_" }}
-   {{ $trName }}: {{ $node.TaskRefName }}
+{{- if not $node.IsFinally }}
+{{- $trName := id $name }}
+   {{ $trName }}: {{ label $node.TaskRefName }}
 {{- if eq (len $node.Dependencies) 0 }}
+{{- if $.FinallyNodes }}
+   {{ $trName }} --> {{ extraID "finally_tasks" }}
+{{- else }}
    {{ $trName }} --> [*]
 {{- end }}
+{{- end }}
 {{- if $node.IsRoot }}
    [*] --> {{ $trName }}
 {{- end }}
 {{- range $dep := $node.Dependencies }}
-   {{- $trDepName := replace $dep.Name "-" "_" }}
-   {{ $trName }} -down-> {{ $trDepName }}
+   {{- $trDepName := id $dep.Name }}
+   {{ $trName }} {{ pumlArrow $node $dep }} {{ $trDepName }}
+{{- end }}
 {{- end }}
 {{ end }}
+{{- with .FinallyNodes }}
+   state "finally" as {{ extraID "finally_tasks" }} {
+{{- range $node := . }}
+{{- with $node.Status }}
+      state {{ id $node.Name }}{{ pumlStereotype $node }} {{ stateColor . }}{{ if $node.Critical }};line:{{ pumlCritical }};line.bold{{ end }}
+{{- else }}
+{{- if or $node.Critical themed }}
+      state {{ id $node.Name }}{{ pumlStereotype $node }}{{ if $node.Critical }} ##[bold]{{ pumlCritical }}{{ end }}
+{{- end }}
+{{- end }}
+      {{ id $node.Name }}: {{ label $node.TaskRefName }}
+{{- with $node.Status }}
+      {{ id $node.Name }}: {{ .Summary }}
+{{- end }}
+{{- end }}
+   }
+   {{ extraID "finally_tasks" }} --> [*]
+{{- end }}
+{{- range $name, $node := .Nodes }}
+{{- if not $node.IsFinally }}
+{{- with $node.Status }}
+   state {{ id $name }} {{ stateColor . }}{{ if $node.Critical }};line:{{ pumlCritical }};line.bold{{ end }}
+   {{ id $name }}: {{ .Summary }}
+{{- else }}
+{{- if $node.Critical }}
+   state {{ id $name }} ##[bold]{{ pumlCritical }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}{{ pumlLegend }}
 @enduml
 `
 
+// dotTemplate is the template used to generate the DOT graph
+// The template is based on the DOT language: https://graphviz.org/doc/info/lang.html
+// The node identifiers are the quoted task names, the start and end points are renamed if a task uses their name.
 const dotTemplate = `digraph {{ .Name }} {
    labelloc="t"
-   label="{{ .PipelineName }}"
-   end [shape="point" width=0.2]
-   start [shape="point" width=0.2]
+   label="{{ label .PipelineName }}"{{ dotGraphAttrs }}
+ {{- if .FinallyNodes }}
+   compound=true
+ {{- end }}
+   {{ extraID "end" }} [shape="point" width=0.2{{ dotPointAttrs }}]
+   {{ extraID "start" }} [shape="point" width=0.2{{ dotPointAttrs }}]
  {{- range $node := .Nodes }}
+ {{- if not $node.IsFinally }}
  {{- if $node.IsRoot }}
-   "start" -> "{{ $node.Name }}"
+   "{{ extraID "start" }}" -> "{{ id $node.Name }}"
  {{- end }}
  {{- if eq (len $node.Dependencies) 0 }}
-   "{{ $node.Name }}" -> "end"
+ {{- if $.FinallyNodes }}
+   "{{ id $node.Name }}" -> "{{ id (index $.FinallyNodes 0).Name }}" [lhead="cluster_finally"]
+ {{- else }}
+   "{{ id $node.Name }}" -> "{{ extraID "end" }}"
+ {{- end }}
  {{- end }}
  {{- range $dep := $node.Dependencies }}
-   "{{ $node.Name }}" -> "{{ $dep.Name }}"
+   "{{ id $node.Name }}" -> "{{ id $dep.Name }}"{{ dotEdgeAttrs $node $dep }}
+ {{- end }}
  {{- end }}
  {{ end }}
+ {{- with .FinallyNodes }}
+   subgraph cluster_finally {
+      label="finally"
+      style="dashed"
+ {{- range $node := . }}
+      "{{ id $node.Name }}"{{ dotNodeAttrs $node }}
+ {{- end }}
+   }
+   "{{ id (index . 0).Name }}" -> "{{ extraID "end" }}" [ltail="cluster_finally"]
+ {{- end }}
+ {{- range $node := .Nodes }}
+ {{- with $node.Status }}
+   "{{ id $node.Name }}" [style="{{ dotFillStyle $node }}" fillcolor="{{ stateColor . }}" label="{{ label $node.Name }}
+{{ .Summary }}"]
+ {{- end }}
+ {{- if $node.Critical }}
+   "{{ id $node.Name }}" [color="{{ criticalColor }}" penwidth=2]
+ {{- end }}
+ {{- end }}{{ dotLegend }}
  }
  `
 
+// dotTemplateWithTaskRef is the template used to generate the DOT graph with taskRefName
+// The node identifiers are the task names followed by the taskRefName on a second line.
 const dotTemplateWithTaskRef = `digraph {{ .Name }} {
    labelloc="t"
-   label="{{ .PipelineName }}"
-   "end" [shape="point" width=0.2]
-   "start" [shape="point" width=0.2]
+   label="{{ label .PipelineName }}"{{ dotGraphAttrs }}
+ {{- if .FinallyNodes }}
+   compound=true
+ {{- end }}
+   "{{ extraID "end" }}" [shape="point" width=0.2{{ dotPointAttrs }}]
+   "{{ extraID "start" }}" [shape="point" width=0.2{{ dotPointAttrs }}]
  {{- range $node := .Nodes }}
+ {{- if not $node.IsFinally }}
  {{- if $node.IsRoot }}
-   "start" -> "{{ $node.Name }}
-({{ $node.TaskRefName }})"
+   "{{ extraID "start" }}" -> "{{ label $node.Name }}
+({{ label $node.TaskRefName }})"
  {{- end }}
  {{- if eq (len $node.Dependencies) 0 }}
-   "{{ $node.Name }}
-({{ $node.TaskRefName }})" -> "end"
+ {{- if $.FinallyNodes }}
+ {{- $first := index $.FinallyNodes 0 }}
+   "{{ label $node.Name }}
+({{ label $node.TaskRefName }})" -> "{{ label $first.Name }}
+({{ label $first.TaskRefName }})" [lhead="cluster_finally"]
+ {{- else }}
+   "{{ label $node.Name }}
+({{ label $node.TaskRefName }})" -> "{{ extraID "end" }}"
+ {{- end }}
  {{- end }}
  {{- range $dep := $node.Dependencies }}
-   "{{ $node.Name }}
-({{ $node.TaskRefName }})" -> "{{ $dep.Name }}
-({{ $dep.TaskRefName }})"
+   "{{ label $node.Name }}
+({{ label $node.TaskRefName }})" -> "{{ label $dep.Name }}
+({{ label $dep.TaskRefName }})"{{ dotEdgeAttrs $node $dep }}
+ {{- end }}
  {{- end }}
  {{ end }}
+ {{- with .FinallyNodes }}
+   subgraph cluster_finally {
+      label="finally"
+      style="dashed"
+ {{- range $node := . }}
+      "{{ label $node.Name }}
+({{ label $node.TaskRefName }})"{{ dotNodeAttrs $node }}
+ {{- end }}
+   }
+ {{- $first := index . 0 }}
+   "{{ label $first.Name }}
+({{ label $first.TaskRefName }})" -> "{{ extraID "end" }}" [ltail="cluster_finally"]
+ {{- end }}
+ {{- range $node := .Nodes }}
+ {{- with $node.Status }}
+   "{{ label $node.Name }}
+({{ label $node.TaskRefName }})" [style="{{ dotFillStyle $node }}" fillcolor="{{ stateColor . }}" label="{{ label $node.Name }}
+({{ label $node.TaskRefName }})
+{{ .Summary }}"]
+ {{- end }}
+ {{- if $node.Critical }}
+   "{{ label $node.Name }}
+({{ label $node.TaskRefName }})" [color="{{ criticalColor }}" penwidth=2]
+ {{- end }}
+ {{- end }}{{ dotLegend }}
  }
  `
+
+// htmlTemplate is the template of the interactive HTML page, it embeds the svg graph and all the scripts and styles,
+// so the page works offline. The template uses the following variables:
+//   - Title: Name of the pipeline
+//   - SVG: the graph rendered by writeSVG
+//   - Nodes: the tasks with their taskRef, params, status and dependencies, used by the scripts
+//
+// The page supports pan (drag) and zoom (wheel or buttons), searching the tasks by name or taskRef,
+// tooltips with the details of a task and highlighting all upstream and downstream tasks of the clicked task.
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+  html, body { margin: 0; height: 100%; font-family: sans-serif; }
+  body { display: flex; flex-direction: column; }
+  header { display: flex; gap: 8px; align-items: center; padding: 8px; border-bottom: 1px solid #ddd; }
+  header h1 { font-size: 16px; margin: 0 16px 0 0; }
+  #graph { flex: 1; overflow: hidden; cursor: grab; }
+  #graph.dragging { cursor: grabbing; }
+  #graph svg { display: block; }
+  .node { cursor: pointer; }
+  .dim { opacity: 0.2; }
+  .match rect, .selected rect { stroke: #d93025; stroke-width: 3; }
+  .edge.path { stroke: #d93025; stroke-width: 2; }
+  #tooltip { position: fixed; display: none; max-width: 480px; padding: 6px 8px; background: #fff;
+    border: 1px solid #999; box-shadow: 0 2px 6px rgba(0, 0, 0, 0.2); font: 12px monospace; pointer-events: none; }
+  #tooltip b { display: block; margin-bottom: 4px; }
+  #tooltip ul { margin: 4px 0 0; padding-left: 16px; }
+</style>
+</head>
+<body>
+<header>
+  <h1>{{ .Title }}</h1>
+  <input id="search" type="search" placeholder="Search tasks" aria-label="Search tasks">
+  <button id="zoom-in" title="Zoom in">+</button>
+  <button id="zoom-out" title="Zoom out">-</button>
+  <button id="reset" title="Reset the view and the selection">Reset</button>
+</header>
+<div id="graph">
+{{ .SVG }}
+</div>
+<div id="tooltip"></div>
+<script>
+(function () {
+  var nodes = {{ .Nodes }};
+  var byName = {};
+  var upstream = {};
+
+  nodes.forEach(function (n) { byName[n.name] = n; upstream[n.name] = []; });
+  nodes.forEach(function (n) {
+    n.dependencies.forEach(function (d) { if (upstream[d]) { upstream[d].push(n.name); } });
+  });
+
+  var container = document.getElementById('graph');
+  var svg = container.querySelector('svg');
+  var tooltip = document.getElementById('tooltip');
+  var search = document.getElementById('search');
+  var taskElements = svg.querySelectorAll('.node');
+  var edgeElements = svg.querySelectorAll('.edge');
+
+  var box = svg.viewBox.baseVal;
+  var initial = { x: box.x, y: box.y, w: box.width, h: box.height };
+  var view = Object.assign({}, initial);
+
+  svg.setAttribute('width', '100%');
+  svg.setAttribute('height', '100%');
+
+  function applyView() {
+    svg.setAttribute('viewBox', view.x + ' ' + view.y + ' ' + view.w + ' ' + view.h);
+  }
+
+  function toGraph(clientX, clientY) {
+    var p = svg.createSVGPoint();
+    p.x = clientX;
+    p.y = clientY;
+    return p.matrixTransform(svg.getScreenCTM().inverse());
+  }
+
+  function zoom(factor, clientX, clientY) {
+    var p = toGraph(clientX, clientY);
+    view.x = p.x - (p.x - view.x) * factor;
+    view.y = p.y - (p.y - view.y) * factor;
+    view.w *= factor;
+    view.h *= factor;
+    applyView();
+  }
+
+  function zoomCenter(factor) {
+    var r = svg.getBoundingClientRect();
+    zoom(factor, r.left + r.width / 2, r.top + r.height / 2);
+  }
+
+  container.addEventListener('wheel', function (e) {
+    e.preventDefault();
+    zoom(e.deltaY < 0 ? 0.9 : 1.1, e.clientX, e.clientY);
+  }, { passive: false });
+
+  var drag = null;
+  var moved = false;
+
+  container.addEventListener('pointerdown', function (e) {
+    drag = toGraph(e.clientX, e.clientY);
+    moved = false;
+    container.classList.add('dragging');
+  });
+
+  window.addEventListener('pointermove', function (e) {
+    if (!drag) {
+      return;
+    }
+    var p = toGraph(e.clientX, e.clientY);
+    if (Math.abs(p.x - drag.x) + Math.abs(p.y - drag.y) > 1) {
+      moved = true;
+    }
+    view.x -= p.x - drag.x;
+    view.y -= p.y - drag.y;
+    applyView();
+  });
+
+  window.addEventListener('pointerup', function () {
+    drag = null;
+    container.classList.remove('dragging');
+  });
+
+  function clearClasses() {
+    svg.querySelectorAll('.dim, .match, .selected, .path').forEach(function (el) {
+      el.classList.remove('dim', 'match', 'selected', 'path');
+    });
+  }
+
+  function reachable(start, next) {
+    var seen = {};
+    var queue = [start];
+    while (queue.length > 0) {
+      var name = queue.shift();
+      (next(name) || []).forEach(function (n) {
+        if (!seen[n]) {
+          seen[n] = true;
+          queue.push(n);
+        }
+      });
+    }
+    return seen;
+  }
+
+  function select(name) {
+    clearClasses();
+    search.value = '';
+
+    var before = reachable(name, function (n) { return upstream[n]; });
+    var after = reachable(name, function (n) { return byName[n] && byName[n].dependencies; });
+    before[name] = true;
+    after[name] = true;
+
+    taskElements.forEach(function (el) {
+      var task = el.getAttribute('data-task');
+      if (task === name) {
+        el.classList.add('selected');
+      } else if (!before[task] && !after[task]) {
+        el.classList.add('dim');
+      }
+    });
+
+    edgeElements.forEach(function (el) {
+      var from = el.getAttribute('data-from');
+      var to = el.getAttribute('data-to');
+      if ((before[from] && before[to]) || (after[from] && after[to])) {
+        el.classList.add('path');
+      } else {
+        el.classList.add('dim');
+      }
+    });
+  }
+
+  container.addEventListener('click', function (e) {
+    if (moved) {
+      return;
+    }
+    var el = e.target.closest('.node');
+    if (el) {
+      select(el.getAttribute('data-task'));
+    } else {
+      clearClasses();
+    }
+  });
+
+  search.addEventListener('input', function () {
+    clearClasses();
+    var q = search.value.trim().toLowerCase();
+    if (q === '') {
+      return;
+    }
+    taskElements.forEach(function (el) {
+      var n = byName[el.getAttribute('data-task')];
+      var text = (n.name + ' ' + (n.taskRef || '')).toLowerCase();
+      el.classList.add(text.indexOf(q) >= 0 ? 'match' : 'dim');
+    });
+    edgeElements.forEach(function (el) { el.classList.add('dim'); });
+  });
+
+  function line(parent, text) {
+    var el = document.createElement('div');
+    el.textContent = text;
+    parent.appendChild(el);
+  }
+
+  taskElements.forEach(function (el) {
+    var n = byName[el.getAttribute('data-task')];
+    el.addEventListener('mousemove', function (e) {
+      tooltip.textContent = '';
+      var title = document.createElement('b');
+      title.textContent = n.name + (n.kind === 'finally' ? ' (finally)' : '');
+      tooltip.appendChild(title);
+      if (n.taskRef) {
+        line(tooltip, 'taskRef: ' + n.taskRef);
+      }
+      if (n.status) {
+        line(tooltip, 'status: ' + n.status);
+      }
+      var params = Object.keys(n.params || {}).sort();
+      if (params.length > 0) {
+        line(tooltip, 'params:');
+        var list = document.createElement('ul');
+        params.forEach(function (p) {
+          var item = document.createElement('li');
+          item.textContent = p + ': ' + n.params[p];
+          list.appendChild(item);
+        });
+        tooltip.appendChild(list);
+      }
+      tooltip.style.left = (e.clientX + 12) + 'px';
+      tooltip.style.top = (e.clientY + 12) + 'px';
+      tooltip.style.display = 'block';
+    });
+    el.addEventListener('mouseleave', function () { tooltip.style.display = 'none'; });
+  });
+
+  document.getElementById('zoom-in').addEventListener('click', function () { zoomCenter(0.8); });
+  document.getElementById('zoom-out').addEventListener('click', function () { zoomCenter(1.25); });
+  document.getElementById('reset').addEventListener('click', function () {
+    view = Object.assign({}, initial);
+    applyView();
+    search.value = '';
+    clearClasses();
+  });
+  document.addEventListener('keydown', function (e) {
+    if (e.key === 'Escape') {
+      search.value = '';
+      clearClasses();
+    }
+  });
+})();
+</script>
+</body>
+</html>
+`
+
+// diffDOTTemplate is the template used to render a GraphDiff as a DOT graph
+// The template uses the following variables:
+//   - From, To: Names of the compared graphs
+//   - Nodes: Tasks of both graphs, the changed ones are filled with the color of the change, see DiffNode.Label
+//   - Edges: Dependencies of both graphs, the added and removed ones are colored, the removed ones are dashed
+const diffDOTTemplate = `digraph G {
+   labelloc="t"
+   label="{{ label .From }} -> {{ label .To }}"
+ {{- range $node := .Nodes }}
+   "{{ id $node.Name }}" [label="{{ nodeLabel $node }}"{{ with $node.Color }} style="filled{{ if eq $node.State "removed" }},dashed{{ end }}" fillcolor="{{ . }}"{{ end }}{{ if $node.Finally }} shape="box"{{ end }}]
+ {{- end }}
+ {{- range $edge := .Edges }}
+   "{{ id $edge.From }}" -> "{{ id $edge.To }}"{{ with $edge.Color }} [color="{{ . }}" penwidth=2{{ if $edge.IsRemoved }} style="dashed"{{ end }}]{{ end }}
+ {{- end }}
+ }
+ `
+
+// diffMermaidTemplate is the template used to render a GraphDiff as a mermaid flowchart
+// The template uses the same variables as diffDOTTemplate, the edges are styled by their index with linkStyle.
+const diffMermaidTemplate = `---
+title: {{ yamlScalar (print .From " -> " .To) }}
+---
+flowchart TD
+{{- range $node := .Nodes }}
+   {{ id $node.Name }}{{ if $node.Finally }}["{{ nodeLabel $node }}"]{{ else }}("{{ nodeLabel $node }}"){{ end }}
+{{- with $node.Color }}
+   style {{ id $node.Name }} fill:{{ . }}
+{{- end }}
+{{- end }}
+{{- range $edge := .Edges }}
+   {{ id $edge.From }} {{ if $edge.IsRemoved }}-.->{{ else }}-->{{ end }} {{ id $edge.To }}
+{{- end }}
+{{- range $i, $edge := .Edges }}
+{{- with $edge.Color }}
+   linkStyle {{ $i }} stroke:{{ . }},stroke-width:2px
+{{- end }}
+{{- end }}
+`
@@ -0,0 +1,326 @@
+package taskgraph
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// TextCharset is the set of characters the text graphs are drawn with
+type TextCharset string
+
+const (
+	TextASCII   TextCharset = "ascii"
+	TextUnicode TextCharset = "unicode"
+)
+
+// textMetrics places the text graphs, in characters: a box is a border and a space around the label
+// and the ranks are three lines apart, for the bends and the arrows of the edges
+var textMetrics = layoutMetrics{
+	charWidth:   1,
+	lineHeight:  1,
+	paddingX:    2,
+	paddingY:    1,
+	pointRadius: 0.5,
+	nodeSep:     2,
+	rankSep:     3,
+	margin:      0,
+	titleHeight: 2,
+	clusterPad:  2,
+}
+
+// stateTextColors are the colors of the boxes in the text graphs, the same hues as stateColors
+var stateTextColors = map[TaskState]color.Attribute{
+	TaskStateSucceeded: color.FgGreen,
+	TaskStateFailed:    color.FgRed,
+	TaskStateRunning:   color.FgYellow,
+	TaskStateCancelled: color.FgHiBlack,
+	TaskStateSkipped:   color.FgHiBlack,
+	TaskStateTimedOut:  color.FgMagenta,
+}
+
+// Directions of the lines going through a cell of the text graphs
+const (
+	lineUp = 1 << iota
+	lineDown
+	lineLeft
+	lineRight
+)
+
+// textGlyphs are the characters of a charset, the lines are looked up by their directions
+type textGlyphs struct {
+	lines                map[int]rune
+	junction             rune // any other combination of lines
+	dashedV, dashedH     rune
+	arrow, point         rune
+	vertical, horizontal rune
+}
+
+var charsetGlyphs = map[TextCharset]textGlyphs{
+	TextASCII: {
+		lines:    map[int]rune{},
+		junction: '+',
+		dashedV:  ':', dashedH: '-',
+		arrow: 'v', point: 'o',
+		vertical: '|', horizontal: '-',
+	},
+	TextUnicode: {
+		lines: map[int]rune{
+			lineDown | lineRight:            '┌',
+			lineDown | lineLeft:             '┐',
+			lineUp | lineRight:              '└',
+			lineUp | lineLeft:               '┘',
+			lineUp | lineDown | lineRight:   '├',
+			lineUp | lineDown | lineLeft:    '┤',
+			lineDown | lineLeft | lineRight: '┬',
+			lineUp | lineLeft | lineRight:   '┴',
+		},
+		junction: '┼',
+		dashedV:  '┆', dashedH: '┄',
+		arrow: '▼', point: '●',
+		vertical: '│', horizontal: '─',
+	},
+}
+
+// textCell is a character of a text graph, either a rune or the lines going through it
+type textCell struct {
+	r      rune
+	lines  int
+	solid  bool // at least one of the lines is solid, otherwise they are dashed
+	colour *color.Color
+}
+
+type textCanvas struct {
+	cells  [][]textCell
+	glyphs textGlyphs
+}
+
+// ToText renders the graph top to bottom with boxes and connectors, so it can be read in a terminal.
+// The task boxes are colored by the state of the task if colour is true, the other formats use the fill color.
+func (g *TaskGraph) ToText(charset TextCharset, withTaskRef, colour bool) (string, error) {
+	glyphs, ok := charsetGlyphs[charset]
+	if !ok {
+		glyphs = charsetGlyphs[TextASCII]
+	}
+
+	l := g.computeLayoutWith(withTaskRef, textMetrics)
+
+	width := max(int(math.Ceil(l.width))+1, utf8.RuneCountInString(l.title))
+	c := newTextCanvas(width, int(math.Ceil(l.height))+1, glyphs)
+
+	c.text(0, (width-utf8.RuneCountInString(l.title))/2, l.title, nil)
+
+	for _, node := range l.nodes {
+		if node.kind == layoutCluster {
+			top, left := textRound(node.top()), textRound(node.left())
+			c.box(top, left, int(node.height), int(node.width), false, nil)
+			c.text(top+1, left+(int(node.width)-utf8.RuneCountInString(node.label[0]))/2, node.label[0], nil)
+		}
+	}
+
+	for _, edge := range l.edges {
+		c.edge(edge)
+	}
+
+	for _, node := range l.nodes {
+		switch node.kind {
+		case layoutPoint:
+			c.set(textRound(node.top()), textCol(node.x), glyphs.point, nil)
+		case layoutTask:
+			var attr *color.Color
+			if colour && node.task.Status != nil {
+				attr = color.New(stateTextColors[node.task.Status.State])
+				attr.EnableColor()
+			}
+
+			top, left := textRound(node.top()), textRound(node.left())
+			c.box(top, left, int(node.height), int(node.width), true, attr)
+
+			for i, line := range node.label {
+				c.text(top+1+i, left+(int(node.width)-utf8.RuneCountInString(line))/2, line, attr)
+			}
+		}
+	}
+
+	return c.String(), nil
+}
+
+// textRound returns the line of a vertical position of the text layout, the boxes start on whole lines
+func textRound(y float64) int {
+	return int(math.Floor(y + 0.5))
+}
+
+// textCol returns the column of a horizontal position of the text layout
+func textCol(x float64) int {
+	return int(math.Floor(x))
+}
+
+func newTextCanvas(width, height int, glyphs textGlyphs) *textCanvas {
+	cells := make([][]textCell, height)
+	for i := range cells {
+		cells[i] = make([]textCell, width)
+	}
+
+	return &textCanvas{cells: cells, glyphs: glyphs}
+}
+
+func (c *textCanvas) cell(row, col int) *textCell {
+	if row < 0 || row >= len(c.cells) || col < 0 || col >= len(c.cells[row]) {
+		return nil
+	}
+
+	return &c.cells[row][col]
+}
+
+func (c *textCanvas) set(row, col int, r rune, colour *color.Color) {
+	if cell := c.cell(row, col); cell != nil {
+		cell.r = r
+		cell.colour = colour
+	}
+}
+
+func (c *textCanvas) text(row, col int, s string, colour *color.Color) {
+	for _, r := range s {
+		c.set(row, col, r, colour)
+		col++
+	}
+}
+
+// line adds the lines between two cells, they must be on the same row or column
+func (c *textCanvas) line(row1, col1, row2, col2 int, solid bool, colour *color.Color) {
+	if row1 > row2 || col1 > col2 {
+		row1, row2, col1, col2 = row2, row1, col2, col1
+	}
+
+	for row := row1; row <= row2; row++ {
+		for col := col1; col <= col2; col++ {
+			cell := c.cell(row, col)
+			if cell == nil {
+				continue
+			}
+
+			switch {
+			case row1 != row2 && row > row1:
+				cell.lines |= lineUp
+			case col1 != col2 && col > col1:
+				cell.lines |= lineLeft
+			}
+
+			switch {
+			case row1 != row2 && row < row2:
+				cell.lines |= lineDown
+			case col1 != col2 && col < col2:
+				cell.lines |= lineRight
+			}
+
+			cell.solid = cell.solid || solid
+			if colour != nil {
+				cell.colour = colour
+			}
+		}
+	}
+}
+
+func (c *textCanvas) box(top, left, height, width int, solid bool, colour *color.Color) {
+	bottom, right := top+height-1, left+width-1
+
+	c.line(top, left, top, right, solid, colour)
+	c.line(bottom, left, bottom, right, solid, colour)
+	c.line(top, left, bottom, left, solid, colour)
+	c.line(top, right, bottom, right, solid, colour)
+}
+
+// edge draws the edge down from the bottom border of its source, bending in the gaps between the ranks,
+// with an arrow above the top border of its target
+func (c *textCanvas) edge(edge *layoutEdge) {
+	solid := !edge.kind.IsResult()
+
+	row, col := textRound(edge.points[0].y)-1, textCol(edge.points[0].x)
+
+	for i, point := range edge.points[1:] {
+		bend := int(math.Floor(edge.gaps[i]))
+		nextRow, nextCol := textRound(point.y), textCol(point.x)
+
+		last := i == len(edge.points)-2
+		if last {
+			nextRow--
+		}
+
+		c.line(row, col, bend, col, solid, nil)
+		c.line(bend, col, bend, nextCol, solid, nil)
+		c.line(bend, nextCol, nextRow, nextCol, solid, nil)
+
+		if last {
+			c.set(nextRow, nextCol, c.glyphs.arrow, nil)
+		}
+
+		row, col = nextRow, nextCol
+	}
+}
+
+func (c *textCanvas) glyph(cell textCell) rune {
+	switch {
+	case cell.r != 0:
+		return cell.r
+	case cell.lines == 0:
+		return ' '
+	case cell.lines&(lineLeft|lineRight) == 0:
+		if !cell.solid {
+			return c.glyphs.dashedV
+		}
+
+		return c.glyphs.vertical
+	case cell.lines&(lineUp|lineDown) == 0:
+		if !cell.solid {
+			return c.glyphs.dashedH
+		}
+
+		return c.glyphs.horizontal
+	}
+
+	if r, ok := c.glyphs.lines[cell.lines]; ok {
+		return r
+	}
+
+	return c.glyphs.junction
+}
+
+// String returns the lines of the canvas without the trailing spaces and empty lines, the runs of colored
+// characters are wrapped in the escape codes of their color
+func (c *textCanvas) String() string {
+	lines := make([]string, 0, len(c.cells))
+
+	for _, row := range c.cells {
+		end := len(row)
+		for end > 0 && c.glyph(row[end-1]) == ' ' {
+			end--
+		}
+
+		var b strings.Builder
+
+		for start := 0; start < end; {
+			colour := row[start].colour
+
+			var run strings.Builder
+			for ; start < end && row[start].colour == colour; start++ {
+				run.WriteRune(c.glyph(row[start]))
+			}
+
+			if colour != nil {
+				b.WriteString(colour.Sprint(run.String()))
+			} else {
+				b.WriteString(run.String())
+			}
+		}
+
+		lines = append(lines, b.String())
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
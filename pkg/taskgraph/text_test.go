@@ -0,0 +1,124 @@
+package taskgraph
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func getTestResultTasks() []v1pipeline.PipelineTask {
+	return []v1pipeline.PipelineTask{
+		{Name: "build", TaskRef: &v1pipeline.TaskRef{Name: "buildah"}},
+		{
+			Name:    "scan",
+			TaskRef: &v1pipeline.TaskRef{Name: "trivy"},
+			Params: v1pipeline.Params{
+				{Name: "image", Value: *v1pipeline.NewStructuredValues("$(tasks.build.results.IMAGE_URL)")},
+			},
+		},
+		{Name: "deploy", TaskRef: &v1pipeline.TaskRef{Name: "helm"}, RunAfter: []string{"build", "scan"}},
+	}
+}
+
+func TestTaskGraphToTextUnicode(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestResultTasks())
+	graph.PipelineName = "release"
+
+	text, err := graph.ToText(TextUnicode, false, false)
+	require.NoError(t, err)
+
+	// the result reference from build to scan is dashed
+	expected := `   release
+
+       ●
+       │
+       │
+       ▼
+   ┌───────┐
+   │ build │
+   └───┬───┘
+       │
+    ┌┄┄┴──┐
+    ▼     │
+┌──────┐  │
+│ scan │  │
+└───┬──┘  │
+    │     │
+    └──┬──┘
+       ▼
+  ┌────────┐
+  │ deploy │
+  └────┬───┘
+       │
+       │
+       ▼
+       ●`
+	assert.Equal(t, expected, text)
+}
+
+func TestTaskGraphToTextASCII(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestTasks(), getTestFinallyTasks()...)
+
+	text, err := graph.ToText(TextASCII, true, false)
+	require.NoError(t, err)
+
+	for _, line := range strings.Split(text, "\n") {
+		for _, r := range line {
+			assert.Less(t, r, rune(128), "non ASCII character in %q", line)
+		}
+
+		assert.Equal(t, strings.TrimRight(line, " "), line)
+	}
+
+	assert.Contains(t, text, "| task-with-dash |")
+	assert.Contains(t, text, "|   (taskRef4)   |")
+	// the finally tasks are in a dashed cluster
+	assert.Contains(t, text, ":            finally             :")
+	assert.Contains(t, text, "| cleanup-ws |  |   notify   |")
+}
+
+func TestTaskGraphToTextWithStatuses(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestResultTasks())
+	graph.SetStatuses(map[string]*TaskStatus{
+		"build": {State: TaskStateSucceeded},
+		"scan":  {State: TaskStateFailed},
+	})
+
+	plain, err := graph.ToText(TextASCII, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, plain, "| Succeeded |")
+	assert.NotContains(t, plain, "\x1b[")
+
+	colored, err := graph.ToText(TextASCII, false, true)
+	require.NoError(t, err)
+	assert.Contains(t, colored, "\x1b[32mSucceeded\x1b[0m")
+	assert.Contains(t, colored, "\x1b[31m")
+	// the tasks without a status are not colored
+	assert.Contains(t, colored, "| deploy |")
+}
+
+func TestWriteAllGraphsText(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestResultTasks())
+	graph.PipelineName = "release"
+	graph.SetStatuses(map[string]*TaskStatus{"build": {State: TaskStateSucceeded}})
+
+	noColor := color.NoColor
+	color.NoColor = false
+
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	dir := t.TempDir()
+	require.NoError(t, WriteAllGraphs([]*TaskGraph{graph}, "unicode", dir, RenderOptions{}, nil))
+
+	// the files are written without the color escape codes
+	content, err := os.ReadFile(filepath.Join(dir, "release.txt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "│ Succeeded │")
+	assert.NotContains(t, string(content), "\x1b[")
+}
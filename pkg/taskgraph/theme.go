@@ -0,0 +1,370 @@
+package taskgraph
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Theme is the styling of the DOT, PlantUML and Mermaid graphs. The formats without the corresponding feature
+// ignore it, e.g. the states of PlantUML always have rounded corners.
+type Theme struct {
+	Name string `json:"name,omitempty"`
+	// Base is the built-in theme a theme file overrides, light if empty
+	Base       string    `json:"base,omitempty"`
+	Direction  Direction `json:"direction,omitempty"`
+	Background string    `json:"background,omitempty"`
+	Font       Font      `json:"font,omitempty"`
+	// Task is the style of the tasks of spec.tasks, Finally of the tasks of spec.finally
+	Task    NodeStyle `json:"task,omitempty"`
+	Finally NodeStyle `json:"finally,omitempty"`
+	// RunAfter is the style of the explicit dependencies, Result of the dependencies on results
+	RunAfter EdgeStyle `json:"runAfter,omitempty"`
+	Result   EdgeStyle `json:"result,omitempty"`
+	// Critical is the color of the highlighted critical path
+	Critical string `json:"critical,omitempty"`
+	// States are the fill colors of the tasks of PipelineRuns, by state
+	States map[TaskState]string `json:"states,omitempty"`
+	// Legend adds a legend explaining the colors, shapes and edge styles used by the graph
+	Legend bool `json:"legend,omitempty"`
+	// Mermaid is the built-in mermaid theme the theme variables are applied to, e.g. dark
+	Mermaid string `json:"mermaid,omitempty"`
+}
+
+// Direction is the direction of the layout, from the first tasks to the last ones
+type Direction string
+
+const (
+	DirectionTopDown   Direction = "TD"
+	DirectionLeftRight Direction = "LR"
+)
+
+// Font is the font of the labels
+type Font struct {
+	Name  string `json:"name,omitempty"`
+	Size  int    `json:"size,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
+// NodeStyle is the style of the task nodes
+type NodeStyle struct {
+	Shape  NodeShape `json:"shape,omitempty"`
+	Fill   string    `json:"fill,omitempty"`
+	Stroke string    `json:"stroke,omitempty"`
+}
+
+// NodeShape is the shape of the task nodes
+type NodeShape string
+
+const (
+	ShapeBox     NodeShape = "box"
+	ShapeRounded NodeShape = "rounded"
+	ShapeEllipse NodeShape = "ellipse"
+	ShapeHexagon NodeShape = "hexagon"
+)
+
+// EdgeStyle is the style of the dependencies
+type EdgeStyle struct {
+	Color string    `json:"color,omitempty"`
+	Line  LineStyle `json:"line,omitempty"`
+}
+
+// LineStyle is the style of the line of the dependencies
+type LineStyle string
+
+const (
+	LineSolid  LineStyle = "solid"
+	LineDashed LineStyle = "dashed"
+	LineDotted LineStyle = "dotted"
+	LineBold   LineStyle = "bold"
+)
+
+// themes are the built-in themes
+var themes = map[string]*Theme{
+	"light": {
+		Name:       "light",
+		Direction:  DirectionTopDown,
+		Background: "#ffffff",
+		Font:       Font{Name: "Helvetica", Size: 14, Color: "#202124"},
+		Task:       NodeStyle{Shape: ShapeRounded, Fill: "#ffffff", Stroke: "#5f6368"},
+		Finally:    NodeStyle{Shape: ShapeBox, Fill: "#f1f3f4", Stroke: "#5f6368"},
+		RunAfter:   EdgeStyle{Color: "#5f6368", Line: LineSolid},
+		Result:     EdgeStyle{Color: "#1a73e8", Line: LineDashed},
+		Critical:   criticalColor,
+		States:     stateColors,
+		Legend:     true,
+		Mermaid:    "base",
+	},
+	"dark": {
+		Name:       "dark",
+		Direction:  DirectionTopDown,
+		Background: "#202124",
+		Font:       Font{Name: "Helvetica", Size: 14, Color: "#e8eaed"},
+		Task:       NodeStyle{Shape: ShapeRounded, Fill: "#303134", Stroke: "#9aa0a6"},
+		Finally:    NodeStyle{Shape: ShapeBox, Fill: "#3c4043", Stroke: "#9aa0a6"},
+		RunAfter:   EdgeStyle{Color: "#9aa0a6", Line: LineSolid},
+		Result:     EdgeStyle{Color: "#8ab4f8", Line: LineDashed},
+		Critical:   "#f28b82",
+		States: map[TaskState]string{
+			TaskStateSucceeded: "#0d652d",
+			TaskStateFailed:    "#a50e0e",
+			TaskStateRunning:   "#8d6e00",
+			TaskStateCancelled: "#5f6368",
+			TaskStateSkipped:   "#3c4043",
+			TaskStateTimedOut:  "#b06000",
+		},
+		Legend:  true,
+		Mermaid: "dark",
+	},
+	// print is black and white, the states are shades of grey
+	"print": {
+		Name:       "print",
+		Direction:  DirectionTopDown,
+		Background: "#ffffff",
+		Font:       Font{Name: "Times", Size: 12, Color: "#000000"},
+		Task:       NodeStyle{Shape: ShapeBox, Fill: "#ffffff", Stroke: "#000000"},
+		Finally:    NodeStyle{Shape: ShapeHexagon, Fill: "#ffffff", Stroke: "#000000"},
+		RunAfter:   EdgeStyle{Color: "#000000", Line: LineSolid},
+		Result:     EdgeStyle{Color: "#000000", Line: LineDotted},
+		Critical:   "#000000",
+		States: map[TaskState]string{
+			TaskStateSucceeded: "#ffffff",
+			TaskStateFailed:    "#707070",
+			TaskStateRunning:   "#d0d0d0",
+			TaskStateCancelled: "#a0a0a0",
+			TaskStateSkipped:   "#f0f0f0",
+			TaskStateTimedOut:  "#909090",
+		},
+		Legend:  true,
+		Mermaid: "neutral",
+	},
+}
+
+// ThemeNames returns the names of the built-in themes
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LookupTheme returns a copy of the built-in theme
+func LookupTheme(name string) (*Theme, bool) {
+	theme, ok := themes[name]
+	if !ok {
+		return nil, false
+	}
+
+	return theme.clone(), true
+}
+
+// LoadThemeFile reads a YAML theme file, the fields it sets override the ones of its base theme
+func LoadThemeFile(path string) (*Theme, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme %s: %w", path, err)
+	}
+
+	return ParseTheme(path, content)
+}
+
+// ParseTheme reads a YAML or JSON theme named name, the fields it sets override the ones of its base theme
+func ParseTheme(name string, content []byte) (*Theme, error) {
+	var header struct {
+		Base string `json:"base"`
+	}
+
+	if err := yaml.Unmarshal(content, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse theme %s: %w", name, err)
+	}
+
+	base := header.Base
+	if base == "" {
+		base = "light"
+	}
+
+	theme, ok := LookupTheme(base)
+	if !ok {
+		return nil, fmt.Errorf("invalid theme %s: unknown base theme %s, the built-in themes are %v", name, base, ThemeNames())
+	}
+
+	theme.Name = name
+
+	// the fields which aren't set keep the value of the base theme
+	if err := yaml.UnmarshalStrict(content, theme); err != nil {
+		return nil, fmt.Errorf("failed to parse theme %s: %w", name, err)
+	}
+
+	if err := theme.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid theme %s: %w", name, err)
+	}
+
+	return theme, nil
+}
+
+func (t *Theme) clone() *Theme {
+	c := *t
+	c.States = maps.Clone(t.States)
+
+	return &c
+}
+
+// themeColor matches the colors accepted by all the formats, #rgb or #rrggbb, or a name such as red
+var themeColor = regexp.MustCompile(`^(#[0-9A-Fa-f]{3}|#[0-9A-Fa-f]{6}|[A-Za-z]+)$`)
+
+// themeFont matches the font names, which are written unquoted in some formats
+var themeFont = regexp.MustCompile(`^[A-Za-z0-9 _-]*$`)
+
+// Validate checks the values of the theme, so they can be written to the graphs without escaping
+func (t *Theme) Validate() error {
+	switch t.Direction {
+	case DirectionTopDown, DirectionLeftRight:
+	default:
+		return fmt.Errorf("invalid direction %q, it must be %s or %s", t.Direction, DirectionTopDown, DirectionLeftRight)
+	}
+
+	if !themeFont.MatchString(t.Font.Name) {
+		return fmt.Errorf("invalid font name %q", t.Font.Name)
+	}
+
+	if t.Font.Size < 0 {
+		return fmt.Errorf("invalid font size %d", t.Font.Size)
+	}
+
+	colors := map[string]string{
+		"background":     t.Background,
+		"font.color":     t.Font.Color,
+		"task.fill":      t.Task.Fill,
+		"task.stroke":    t.Task.Stroke,
+		"finally.fill":   t.Finally.Fill,
+		"finally.stroke": t.Finally.Stroke,
+		"runAfter.color": t.RunAfter.Color,
+		"result.color":   t.Result.Color,
+		"critical":       t.Critical,
+	}
+
+	for state, color := range t.States {
+		if _, ok := stateColors[state]; !ok {
+			return fmt.Errorf("invalid state %q", state)
+		}
+
+		colors["states."+string(state)] = color
+	}
+
+	for _, field := range sortedKeys(colors) {
+		if colors[field] != "" && !themeColor.MatchString(colors[field]) {
+			return fmt.Errorf("invalid color %q of %s, it must be #rgb, #rrggbb or a color name", colors[field], field)
+		}
+	}
+
+	for field, shape := range map[string]NodeShape{"task.shape": t.Task.Shape, "finally.shape": t.Finally.Shape} {
+		switch shape {
+		case "", ShapeBox, ShapeRounded, ShapeEllipse, ShapeHexagon:
+		default:
+			return fmt.Errorf("invalid %s %q, it must be one of %s, %s, %s or %s",
+				field, shape, ShapeBox, ShapeRounded, ShapeEllipse, ShapeHexagon)
+		}
+	}
+
+	for field, line := range map[string]LineStyle{"runAfter.line": t.RunAfter.Line, "result.line": t.Result.Line} {
+		switch line {
+		case "", LineSolid, LineDashed, LineDotted, LineBold:
+		default:
+			return fmt.Errorf("invalid %s %q, it must be one of %s, %s, %s or %s",
+				field, line, LineSolid, LineDashed, LineDotted, LineBold)
+		}
+	}
+
+	if !themeFont.MatchString(t.Mermaid) {
+		return fmt.Errorf("invalid mermaid theme %q", t.Mermaid)
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// stateColor returns the fill color of the state
+func (t *Theme) stateColor(status *TaskStatus) string {
+	if t != nil {
+		if color, ok := t.States[status.State]; ok {
+			return color
+		}
+	}
+
+	return status.Color()
+}
+
+// criticalColor returns the color of the critical path
+func (t *Theme) criticalColor() string {
+	if t == nil || t.Critical == "" {
+		return criticalColor
+	}
+
+	return t.Critical
+}
+
+// nodeStyle returns the style of the task, the finally tasks use the style of the other tasks for the fields
+// they don't set
+func (t *Theme) nodeStyle(node *TaskNode) NodeStyle {
+	style := t.Task
+	if !node.IsFinally() {
+		return style
+	}
+
+	if t.Finally.Shape != "" {
+		style.Shape = t.Finally.Shape
+	}
+
+	if t.Finally.Fill != "" {
+		style.Fill = t.Finally.Fill
+	}
+
+	if t.Finally.Stroke != "" {
+		style.Stroke = t.Finally.Stroke
+	}
+
+	return style
+}
+
+// edgeStyle returns the style of the dependency, the result references use the style of the explicit
+// dependencies for the fields they don't set
+func (t *Theme) edgeStyle(kind EdgeKind) EdgeStyle {
+	style := t.RunAfter
+	if style.Line == "" {
+		style.Line = LineSolid
+	}
+
+	if !kind.IsResult() {
+		return style
+	}
+
+	if t.Result.Color != "" {
+		style.Color = t.Result.Color
+	}
+
+	if t.Result.Line != "" {
+		style.Line = t.Result.Line
+	} else {
+		style.Line = LineDashed
+	}
+
+	return style
+}
@@ -0,0 +1,125 @@
+package taskgraph
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinThemes(t *testing.T) {
+	assert.Equal(t, []string{"dark", "light", "print"}, ThemeNames())
+
+	for _, name := range ThemeNames() {
+		theme, ok := LookupTheme(name)
+		require.True(t, ok, name)
+		assert.NoError(t, theme.Validate(), name)
+		assert.Len(t, theme.States, len(stateColors), name)
+	}
+
+	_, ok := LookupTheme("solarized")
+	assert.False(t, ok)
+
+	// the built-in themes can't be modified through the returned copy
+	theme, _ := LookupTheme("light")
+	theme.States[TaskStateFailed] = "red"
+	theme.Direction = DirectionLeftRight
+
+	light, _ := LookupTheme("light")
+	assert.Equal(t, "#f4c7c3", light.States[TaskStateFailed])
+	assert.Equal(t, DirectionTopDown, light.Direction)
+	assert.Equal(t, "#f4c7c3", stateColors[TaskStateFailed])
+}
+
+func TestLoadThemeFile(t *testing.T) {
+	path := writeTestTemplate(t, t.TempDir(), "theme.yaml", `base: dark
+direction: LR
+font:
+  name: Fira Sans
+finally:
+  shape: hexagon
+result:
+  line: dotted
+states:
+  Failed: red
+legend: false
+`)
+
+	theme, err := LoadThemeFile(path)
+	require.NoError(t, err)
+
+	dark, _ := LookupTheme("dark")
+	assert.Equal(t, path, theme.Name)
+	assert.Equal(t, DirectionLeftRight, theme.Direction)
+	assert.Equal(t, Font{Name: "Fira Sans", Size: 14, Color: "#e8eaed"}, theme.Font)
+	assert.Equal(t, NodeStyle{Shape: ShapeHexagon, Fill: dark.Finally.Fill, Stroke: dark.Finally.Stroke}, theme.Finally)
+	assert.Equal(t, EdgeStyle{Color: dark.Result.Color, Line: LineDotted}, theme.Result)
+	assert.Equal(t, "red", theme.States[TaskStateFailed])
+	assert.Equal(t, dark.States[TaskStateSucceeded], theme.States[TaskStateSucceeded])
+	assert.False(t, theme.Legend)
+	assert.Equal(t, "dark", theme.Mermaid)
+
+	// light is the default base
+	path = writeTestTemplate(t, t.TempDir(), "theme.yaml", `critical: "#ff0000"`)
+
+	theme, err = LoadThemeFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "#ff0000", theme.Critical)
+	assert.Equal(t, "#ffffff", theme.Background)
+	assert.True(t, theme.Legend)
+}
+
+func TestLoadThemeFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "base", content: "base: solarized", expected: "unknown base theme solarized, the built-in themes are [dark light print]"},
+		{name: "field", content: "colour: red", expected: `unknown field "colour"`},
+		{name: "direction", content: "direction: BT", expected: `invalid direction "BT", it must be TD or LR`},
+		{name: "shape", content: "task:\n  shape: star", expected: `invalid task.shape "star"`},
+		{name: "line", content: "result:\n  line: wavy", expected: `invalid result.line "wavy"`},
+		{name: "color", content: "background: 'blue;line:red'", expected: `invalid color "blue;line:red" of background`},
+		{name: "state", content: "states:\n  Pending: blue", expected: `invalid state "Pending"`},
+		{name: "font", content: "font:\n  name: 'Fira\"'", expected: `invalid font name "Fira\""`},
+		{name: "yaml", content: "direction: [", expected: "failed to parse theme"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeTestTemplate(t, dir, tc.name+".yaml", tc.content)
+
+			_, err := LoadThemeFile(path)
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+
+	_, err := LoadThemeFile(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read theme")
+}
+
+func TestThemeStyles(t *testing.T) {
+	theme := &Theme{
+		Task:     NodeStyle{Shape: ShapeEllipse, Fill: "white", Stroke: "black"},
+		Finally:  NodeStyle{Fill: "grey"},
+		RunAfter: EdgeStyle{Color: "black"},
+		Result:   EdgeStyle{Color: "blue"},
+	}
+
+	assert.Equal(t, NodeStyle{Shape: ShapeEllipse, Fill: "white", Stroke: "black"}, theme.nodeStyle(&TaskNode{}))
+	assert.Equal(t, NodeStyle{Shape: ShapeEllipse, Fill: "grey", Stroke: "black"}, theme.nodeStyle(&TaskNode{Kind: NodeKindFinally}))
+	assert.Equal(t, EdgeStyle{Color: "black", Line: LineSolid}, theme.edgeStyle(EdgeKindRunAfter))
+	assert.Equal(t, EdgeStyle{Color: "blue", Line: LineDashed}, theme.edgeStyle(EdgeKindResult))
+
+	// the default colors are used without a theme or when the theme doesn't set them
+	var none *Theme
+
+	assert.Equal(t, criticalColor, none.criticalColor())
+	assert.Equal(t, criticalColor, theme.criticalColor())
+	assert.Equal(t, "#b7e1cd", none.stateColor(&TaskStatus{State: TaskStateSucceeded}))
+	assert.Equal(t, "#b7e1cd", theme.stateColor(&TaskStatus{State: TaskStateSucceeded}))
+}
@@ -0,0 +1,268 @@
+package taskgraph
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateExtension is the extension of the per-format templates of a template directory, e.g. dot.tmpl
+const TemplateExtension = ".tmpl"
+
+// TemplateData is the data the user templates are executed with. It is part of the stable API: the graph document
+// of the json and yaml formats (apiVersion tkn-graph/v1) and the details of the requested output.
+type TemplateData struct {
+	*GraphDocument
+	Title         string   // Title of the built-in formats, the name prefixed with the namespace if any
+	Format        string   // Output format the template is executed for, selects the escaping of escape
+	WithTaskRef   bool     // --with-task-ref is set
+	CriticalTasks []string // Tasks of the highlighted critical path, in execution order
+}
+
+// Templates are the user templates replacing the built-in output of the formats, either one template for all
+// formats or one template per format named <format>.tmpl. The formats without a template use the built-in output.
+type Templates struct {
+	all      *template.Template
+	byFormat map[string]*template.Template
+}
+
+// LoadTemplates parses the template file, or all the <format>.tmpl files of the directory. Both can't be set,
+// nil is returned if none is.
+func LoadTemplates(file, dir string) (*Templates, error) {
+	switch {
+	case file != "" && dir != "":
+		return nil, errors.New("a template file and a template directory can't be combined")
+	case file != "":
+		tmpl, err := parseTemplate(file)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Templates{all: tmpl}, nil
+	case dir != "":
+		paths, err := filepath.Glob(filepath.Join(dir, "*"+TemplateExtension))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the templates of %s: %w", dir, err)
+		}
+
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no %s templates found in %s", TemplateExtension, dir)
+		}
+
+		templates := &Templates{byFormat: map[string]*template.Template{}}
+
+		for _, path := range paths {
+			tmpl, err := parseTemplate(path)
+			if err != nil {
+				return nil, err
+			}
+
+			templates.byFormat[strings.TrimSuffix(filepath.Base(path), TemplateExtension)] = tmpl
+		}
+
+		return templates, nil
+	default:
+		return nil, nil
+	}
+}
+
+func parseTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+
+	// the functions bound to the graph are replaced before every execution
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs(&TemplateData{GraphDocument: &GraphDocument{}})).
+		Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+
+	return tmpl, nil
+}
+
+// For returns the template of the format, nil if the built-in output is used
+func (t *Templates) For(format string) *template.Template {
+	if t == nil {
+		return nil
+	}
+
+	if t.all != nil {
+		return t.all
+	}
+
+	return t.byFormat[strings.ToLower(format)]
+}
+
+// ExecuteTemplate renders the graph with a user template, see TemplateData and templateFuncs
+func (g *TaskGraph) ExecuteTemplate(tmpl *template.Template, format string, withTaskRef bool) (string, error) {
+	data := g.TemplateData(format, withTaskRef)
+
+	t, err := tmpl.Clone()
+	if err != nil {
+		return "", fmt.Errorf("failed to clone template %s: %w", tmpl.Name(), err)
+	}
+
+	var b strings.Builder
+	if err := t.Funcs(templateFuncs(data)).Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %w", tmpl.Name(), err)
+	}
+
+	return b.String(), nil
+}
+
+// TemplateData returns the data the user templates are executed with
+func (g *TaskGraph) TemplateData(format string, withTaskRef bool) *TemplateData {
+	data := &TemplateData{
+		GraphDocument: g.Document(),
+		Title:         g.Title(),
+		Format:        strings.ToLower(format),
+		WithTaskRef:   withTaskRef,
+		CriticalTasks: []string{},
+	}
+
+	// the critical path starts with the critical task no other critical task leads to
+	next := map[string]string{}
+	led := map[string]bool{}
+
+	for _, node := range g.Nodes {
+		if node.criticalNext != nil {
+			next[node.Name] = node.criticalNext.Name
+			led[node.criticalNext.Name] = true
+		}
+	}
+
+	for _, name := range g.sortedNames() {
+		if g.Nodes[name].Critical && !led[name] {
+			for task := name; task != ""; task = next[task] {
+				data.CriticalTasks = append(data.CriticalTasks, task)
+			}
+
+			break
+		}
+	}
+
+	return data
+}
+
+// templateFuncs are the helper functions of the user templates:
+//
+//	escape s            escapes s for a quoted string of the output format
+//	id s                turns s into an identifier, the characters other than letters, digits and _ are replaced by _
+//	tasks, finally      the nodes of spec.tasks or spec.finally, sorted by name
+//	node name           the node with the name, nil if there is none
+//	edgesFrom name      the edges from the task, to the tasks running after it
+//	edgesTo name        the edges to the task, from the tasks running before it
+//	roots, leaves       the names of the tasks of spec.tasks without edges to them or from them
+//	taskRef node        the human readable taskRef of the node, empty if there is none
+//	critical name       true if the task is part of the highlighted critical path
+//	join, replace, lower, upper, trim, quote   the functions of the strings and strconv packages
+func templateFuncs(data *TemplateData) template.FuncMap {
+	nodesOfKind := func(kind NodeKind) []NodeDocument {
+		nodes := []NodeDocument{}
+
+		for _, node := range data.Nodes {
+			if node.Kind == kind {
+				nodes = append(nodes, node)
+			}
+		}
+
+		return nodes
+	}
+
+	edges := func(match func(EdgeDocument) bool) []EdgeDocument {
+		found := []EdgeDocument{}
+
+		for _, edge := range data.Edges {
+			if match(edge) {
+				found = append(found, edge)
+			}
+		}
+
+		sort.SliceStable(found, func(i, j int) bool {
+			return found[i].From+"\x00"+found[i].To < found[j].From+"\x00"+found[j].To
+		})
+
+		return found
+	}
+
+	hasEdge := func(name string, from bool) bool {
+		for _, edge := range data.Edges {
+			if (from && edge.From == name) || (!from && edge.To == name) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	tasksWithout := func(from bool) []string {
+		names := []string{}
+
+		for _, node := range nodesOfKind(NodeKindTask) {
+			if !hasEdge(node.Name, from) {
+				names = append(names, node.Name)
+			}
+		}
+
+		return names
+	}
+
+	return template.FuncMap{
+		"escape": func(s string) string {
+			if escaper, ok := formatEscapers[data.Format]; ok {
+				return escaper(s)
+			}
+
+			return s
+		},
+		"id":      identifier,
+		"tasks":   func() []NodeDocument { return nodesOfKind(NodeKindTask) },
+		"finally": func() []NodeDocument { return nodesOfKind(NodeKindFinally) },
+		"node": func(name string) *NodeDocument {
+			for i := range data.Nodes {
+				if data.Nodes[i].Name == name {
+					return &data.Nodes[i]
+				}
+			}
+
+			return nil
+		},
+		"edgesFrom": func(name string) []EdgeDocument {
+			return edges(func(e EdgeDocument) bool { return e.From == name })
+		},
+		"edgesTo": func(name string) []EdgeDocument {
+			return edges(func(e EdgeDocument) bool { return e.To == name })
+		},
+		"roots":  func() []string { return tasksWithout(false) },
+		"leaves": func() []string { return tasksWithout(true) },
+		"taskRef": func(node NodeDocument) string {
+			if node.TaskRef == nil {
+				return ""
+			}
+
+			return node.TaskRef.String()
+		},
+		"critical": func(name string) bool {
+			for _, task := range data.CriticalTasks {
+				if task == name {
+					return true
+				}
+			}
+
+			return false
+		},
+		"join":    strings.Join,
+		"replace": strings.ReplaceAll,
+		"lower":   strings.ToLower,
+		"upper":   strings.ToUpper,
+		"trim":    strings.TrimSpace,
+		"quote":   strconv.Quote,
+	}
+}
@@ -0,0 +1,167 @@
+package taskgraph
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1pipeline "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func writeTestTemplate(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	return path
+}
+
+func TestExecuteTemplate(t *testing.T) {
+	tasks := append(getTestResultTasks(), v1pipeline.PipelineTask{Name: `say "hi"`})
+	graph := mustBuildTaskGraph(t, tasks, getTestFinallyTasks()...)
+	graph.PipelineName = "release"
+	graph.SetStatuses(map[string]*TaskStatus{"build": {State: TaskStateSucceeded}})
+
+	path := writeTestTemplate(t, t.TempDir(), "house.tmpl", `digraph {{ id .Title }} {
+{{- range tasks }}
+  {{ id .Name }} [label="{{ escape .Name }}{{ if $.WithTaskRef }} ({{ taskRef . }}){{ end }}"{{ with .Status }} class="{{ lower (print .State) }}"{{ end }}]
+{{- end }}
+{{- range finally }}
+  {{ id .Name }} [shape=note]
+{{- end }}
+{{- range .Edges }}
+  {{ id .From }} -> {{ id .To }}{{ if eq .Kind "result" }} [style=dashed]{{ end }}
+{{- end }}
+  roots: {{ join roots "," }}
+  leaves: {{ join leaves "," }}
+  after build:{{ range edgesFrom "build" }} {{ .To }}{{ end }}
+  before deploy:{{ range edgesTo "deploy" }} {{ .From }}{{ end }}
+  {{ (node "deploy").Name }} {{ .Format }}
+}
+`)
+
+	templates, err := LoadTemplates(path, "")
+	require.NoError(t, err)
+
+	output, err := graph.ExecuteTemplate(templates.For("dot"), "dot", true)
+	require.NoError(t, err)
+
+	assert.Equal(t, `digraph release {
+  build [label="build (buildah)" class="succeeded"]
+  deploy [label="deploy (helm)"]
+  say__hi_ [label="say \"hi\" ()"]
+  scan [label="scan (trivy)"]
+  cleanup_ws [shape=note]
+  notify [shape=note]
+  build -> scan [style=dashed]
+  build -> deploy
+  scan -> deploy
+  roots: build,say "hi"
+  leaves: deploy,say "hi"
+  after build: deploy scan
+  before deploy: build scan
+  deploy dot
+}
+`, output)
+}
+
+func TestTemplateDataCriticalTasks(t *testing.T) {
+	graph := mustBuildTaskGraph(t, getTestResultTasks())
+	for _, node := range graph.Nodes {
+		node.Status = &TaskStatus{State: TaskStateSucceeded}
+	}
+
+	graph.Nodes["build"].Critical = true
+	graph.Nodes["build"].criticalNext = graph.Nodes["scan"]
+	graph.Nodes["scan"].Critical = true
+	graph.Nodes["scan"].criticalNext = graph.Nodes["deploy"]
+	graph.Nodes["deploy"].Critical = true
+
+	data := graph.TemplateData("MMD", false)
+
+	assert.Equal(t, []string{"build", "scan", "deploy"}, data.CriticalTasks)
+	assert.Equal(t, "mmd", data.Format)
+	assert.Equal(t, GraphAPIVersion, data.APIVersion)
+}
+
+func TestTemplateEscape(t *testing.T) {
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{format: "dot", expected: `a \"b\" <c>\\d`},
+		{format: "mmd", expected: `a #quot;b#quot; #lt;c#gt;\d`},
+		{format: "puml", expected: `a 'b' <c>\d`},
+		{format: "json", expected: `a \"b\" <c>\\d`},
+		{format: "html", expected: `a &#34;b&#34; &lt;c&gt;\d`},
+		{format: "ascii", expected: `a "b" <c>\d`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			escape := templateFuncs(&TemplateData{GraphDocument: &GraphDocument{}, Format: tc.format})["escape"].(func(string) string)
+			assert.Equal(t, tc.expected, escape(`a "b" <c>\d`))
+		})
+	}
+}
+
+func TestLoadTemplatesFromDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTemplate(t, dir, "mmd.tmpl", `flowchart TD{{ range .Nodes }} {{ id .Name }}{{ end }}`)
+	writeTestTemplate(t, dir, "README.md", `not a template {{`)
+
+	templates, err := LoadTemplates("", dir)
+	require.NoError(t, err)
+	assert.NotNil(t, templates.For("mmd"))
+	// the other formats use the built-in output
+	assert.Nil(t, templates.For("dot"))
+
+	graph := mustBuildTaskGraph(t, getTestResultTasks())
+	graph.PipelineName = "release"
+
+	out := t.TempDir()
+	require.NoError(t, WriteAllGraphs([]*TaskGraph{graph}, "mmd", out, RenderOptions{}, templates))
+	require.NoError(t, WriteAllGraphs([]*TaskGraph{graph}, "dot", out, RenderOptions{}, templates))
+
+	mmd, err := os.ReadFile(filepath.Join(out, "release.mmd"))
+	require.NoError(t, err)
+	assert.Equal(t, "flowchart TD build deploy scan", string(mmd))
+
+	dot, err := os.ReadFile(filepath.Join(out, "release.dot"))
+	require.NoError(t, err)
+	assert.Contains(t, string(dot), "digraph G {")
+}
+
+func TestLoadTemplatesErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := writeTestTemplate(t, dir, "invalid.tmpl", `{{ range .Nodes }}`)
+
+	_, err := LoadTemplates(invalid, "")
+	assert.ErrorContains(t, err, "failed to parse template "+invalid)
+
+	_, err = LoadTemplates(filepath.Join(dir, "missing.tmpl"), "")
+	assert.ErrorContains(t, err, "failed to read template")
+
+	_, err = LoadTemplates(invalid, dir)
+	assert.EqualError(t, err, "a template file and a template directory can't be combined")
+
+	_, err = LoadTemplates("", t.TempDir())
+	assert.ErrorContains(t, err, "no .tmpl templates found in")
+
+	templates, err := LoadTemplates("", "")
+	assert.NoError(t, err)
+	assert.Nil(t, templates.For("dot"))
+
+	unknown := writeTestTemplate(t, dir, "unknown.txt", `{{ .Missing }}`)
+	templates, err = LoadTemplates(unknown, "")
+	require.NoError(t, err)
+
+	_, err = mustBuildTaskGraph(t, getTestResultTasks()).ExecuteTemplate(templates.For("dot"), "dot", false)
+	assert.ErrorContains(t, err, "failed to execute template unknown.txt")
+
+	err = WriteAllGraphs([]*TaskGraph{mustBuildTaskGraph(t, getTestResultTasks())}, "png", t.TempDir(), RenderOptions{}, templates)
+	assert.ErrorContains(t, err, "the png output format is binary and can't be rendered with a template")
+}
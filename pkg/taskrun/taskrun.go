@@ -0,0 +1,106 @@
+package taskrun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sergk/tkn-graph/pkg/request"
+	"github.com/tektoncd/cli/pkg/cli"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// GetAllTaskRuns lists the TaskRuns of the namespace, or of all namespaces if ns is empty, matching the selectors of opts.
+// opts.Limit is the size of the pages requested from the cluster, all TaskRuns are requested at once if it is 0.
+func GetAllTaskRuns(ctx context.Context, c *cli.Clients, ns string, opts metav1.ListOptions) ([]v1.TaskRun, error) {
+	var taskruns []v1.TaskRun
+
+	for {
+		page, err := c.Tekton.TektonV1().TaskRuns(ns).List(ctx, opts)
+		if err != nil {
+			if interrupted := request.Interrupted(ctx, err, request.Listing("TaskRuns", ns)); interrupted != nil {
+				return nil, interrupted
+			}
+
+			return nil, fmt.Errorf("failed to get TaskRuns: %w", err)
+		}
+
+		taskruns = append(taskruns, page.Items...)
+
+		if page.Continue == "" {
+			return taskruns, nil
+		}
+
+		opts.Continue = page.Continue
+	}
+}
+
+// Get TaskRun by name
+func GetTaskRunByName(ctx context.Context, c *cli.Clients, name string, ns string) (*v1.TaskRun, error) {
+	taskrun, err := c.Tekton.TektonV1().TaskRuns(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if interrupted := request.Interrupted(ctx, err, request.Getting("TaskRun", name, ns)); interrupted != nil {
+			return nil, interrupted
+		}
+
+		return nil, fmt.Errorf("failed to get TaskRun with name %s: %w", name, err)
+	}
+
+	return taskrun, nil
+}
+
+// GetAllCustomRuns lists the CustomRuns of the namespace, or of all namespaces if ns is empty, matching the selectors of opts.
+// opts.Limit is the size of the pages requested from the cluster, all CustomRuns are requested at once if it is 0.
+func GetAllCustomRuns(ctx context.Context, c *cli.Clients, ns string, opts metav1.ListOptions) ([]v1beta1.CustomRun, error) {
+	var customruns []v1beta1.CustomRun
+
+	for {
+		page, err := c.Tekton.TektonV1beta1().CustomRuns(ns).List(ctx, opts)
+		if err != nil {
+			if interrupted := request.Interrupted(ctx, err, request.Listing("CustomRuns", ns)); interrupted != nil {
+				return nil, interrupted
+			}
+
+			return nil, fmt.Errorf("failed to get CustomRuns: %w", err)
+		}
+
+		customruns = append(customruns, page.Items...)
+
+		if page.Continue == "" {
+			return customruns, nil
+		}
+
+		opts.Continue = page.Continue
+	}
+}
+
+// Get CustomRun by name
+func GetCustomRunByName(ctx context.Context, c *cli.Clients, name string, ns string) (*v1beta1.CustomRun, error) {
+	customrun, err := c.Tekton.TektonV1beta1().CustomRuns(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if interrupted := request.Interrupted(ctx, err, request.Getting("CustomRun", name, ns)); interrupted != nil {
+			return nil, interrupted
+		}
+
+		return nil, fmt.Errorf("failed to get CustomRun with name %s: %w", name, err)
+	}
+
+	return customrun, nil
+}
+
+// WatchTaskRuns watches the TaskRuns of the namespace matching the selectors of opts.
+// Without opts.ResourceVersion the existing TaskRuns are sent as added first.
+func WatchTaskRuns(ctx context.Context, c *cli.Clients, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+	w, err := c.Tekton.TektonV1().TaskRuns(ns).Watch(ctx, opts)
+	if err != nil {
+		if interrupted := request.Interrupted(ctx, err, request.Watching("TaskRuns", ns)); interrupted != nil {
+			return nil, interrupted
+		}
+
+		return nil, fmt.Errorf("failed to watch TaskRuns: %w", err)
+	}
+
+	return w, nil
+}
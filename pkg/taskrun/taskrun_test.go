@@ -0,0 +1,83 @@
+package taskrun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tektoncd/cli/pkg/cli"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	fakeclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	namespace = "my-namespace"
+)
+
+func TestGetTaskRuns(t *testing.T) {
+	fakeClient := fakeclient.NewSimpleClientset(
+		&v1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "taskrun-1", Namespace: namespace}},
+		&v1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "taskrun-2", Namespace: namespace}},
+	)
+
+	c := &cli.Clients{
+		Tekton: fakeClient,
+	}
+
+	taskRuns, err := GetAllTaskRuns(context.Background(), c, namespace, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error getting task runs: %v", err)
+	}
+
+	if len(taskRuns) != 2 {
+		t.Fatalf("Expected 2 task runs, got %d", len(taskRuns))
+	}
+
+	taskRun, err := GetTaskRunByName(context.Background(), c, "taskrun-2", namespace)
+	if err != nil {
+		t.Fatalf("Error getting task run: %v", err)
+	}
+
+	if taskRun.Name != "taskrun-2" {
+		t.Fatalf("Expected task run to have name taskrun-2, got %s", taskRun.Name)
+	}
+
+	_, err = GetTaskRunByName(context.Background(), c, "unknown", namespace)
+	if err == nil {
+		t.Fatal("GetTaskRunByName did not return an error, expected an error")
+	}
+}
+
+func TestGetCustomRuns(t *testing.T) {
+	fakeClient := fakeclient.NewSimpleClientset(
+		&v1beta1.CustomRun{ObjectMeta: metav1.ObjectMeta{Name: "customrun-1", Namespace: namespace}},
+	)
+
+	c := &cli.Clients{
+		Tekton: fakeClient,
+	}
+
+	customRuns, err := GetAllCustomRuns(context.Background(), c, namespace, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error getting custom runs: %v", err)
+	}
+
+	if len(customRuns) != 1 {
+		t.Fatalf("Expected 1 custom run, got %d", len(customRuns))
+	}
+
+	customRun, err := GetCustomRunByName(context.Background(), c, "customrun-1", namespace)
+	if err != nil {
+		t.Fatalf("Error getting custom run: %v", err)
+	}
+
+	if customRun.Name != "customrun-1" {
+		t.Fatalf("Expected custom run to have name customrun-1, got %s", customRun.Name)
+	}
+
+	_, err = GetCustomRunByName(context.Background(), c, "unknown", namespace)
+	if err == nil {
+		t.Fatal("GetCustomRunByName did not return an error, expected an error")
+	}
+}